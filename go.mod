@@ -4,14 +4,15 @@ go 1.25.1
 
 require (
 	gioui.org v0.9.0
+	github.com/andybalholm/brotli v1.2.0
 	github.com/mat/besticon v3.12.0+incompatible
 	golang.org/x/exp/shiny v0.0.0-20250408133849-7e4ce0ab07d0
+	golang.org/x/image v0.26.0
+	golang.org/x/text v0.24.0
 )
 
 require (
 	gioui.org/shader v1.0.8 // indirect
 	github.com/go-text/typesetting v0.3.0 // indirect
-	golang.org/x/image v0.26.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
-	golang.org/x/text v0.24.0 // indirect
 )
@@ -0,0 +1,305 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	urlPkg "net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// built-in page at about:<name>, body is already html
+const aboutPageTemplate = `<html>
+<head><title>%s</title></head>
+<body>
+<h1>%s</h1>
+%s
+</body>
+</html>`
+
+// names of the documents kind shown in about:cache
+var documentKindNames = map[DocumentKind]string{
+	HtmlDocument:       "html",
+	TextDocument:       "text",
+	SourceDocument:     "source",
+	ImageDocument:      "image",
+	JsonDocument:       "json",
+	ViewSourceDocument: "view-source",
+	MarkdownDocument:   "markdown",
+	GeminiDocument:     "gemini",
+	GopherMenuDocument: "gopher menu",
+}
+
+// aboutPage builds the internal page at about:<name>?<query> from what the tab knows,
+// false if there is no such page
+func aboutPage(name string, query urlPkg.Values, history *navHistory, store *historyStore, bookmarks *bookmarkStore,
+	cache map[string]Dom) (Dom, bool) {
+	var title, body string
+	switch strings.ToLower(name) {
+	case "blank":
+		return Dom{Root: documentRoot("about:blank")}, true
+	case "history":
+		title, body = "History", aboutHistory(history, store, query)
+	case "bookmarks":
+		title, body = "Bookmarks", aboutBookmarks(bookmarks, query)
+	case "cache":
+		title, body = "Cache", aboutCache(cache)
+	case "settings":
+		title, body = "Settings", aboutSettings()
+	case "keys":
+		title, body = "Keyboard shortcuts", aboutKeys()
+	default:
+		return Dom{}, false
+	}
+
+	html := fmt.Sprintf(aboutPageTemplate, title, title, body)
+	root, err := parser.Parse(html)
+	if err != nil {
+		log.Println("aboutPage: parser.Parse:", err)
+		return Dom{}, false
+	}
+	return Dom{Root: root}, true
+}
+
+// about: pages with actions, their links and forms go as PageAction instead of Search.
+// The params are what the page shows e.g. a search, it's shown again the same way after an action.
+var actionPages = map[string][]string{"history": {"q", "from", "to"}, "bookmarks": {"q", "folder"}}
+
+// actionPageView is the url of the action page at pageUrl with only what it shows, without the action
+func actionPageView(pageUrl *urlPkg.URL) *urlPkg.URL {
+	query, view := pageUrl.Query(), urlPkg.Values{}
+	for _, param := range actionPages[pageUrl.Opaque] {
+		if value := query.Get(param); value != "" {
+			view.Set(param, value)
+		}
+	}
+	return &urlPkg.URL{Scheme: "about", Opaque: pageUrl.Opaque, RawQuery: view.Encode()}
+}
+
+// IsPageAction tells if following href from the page at pageUrl is for the page itself e.g. a Delete link of about:history.
+// Only those run the page's actions, the same link from anywhere else just shows the page.
+func IsPageAction(pageUrl, href string) bool {
+	page, err := prepareUrl(pageUrl)
+	if err != nil {
+		return false
+	}
+	target, err := prepareUrl(href)
+	if err != nil {
+		return false
+	}
+	_, hasActions := actionPages[page.Opaque]
+	return page.Scheme == "about" && target.Scheme == "about" && page.Opaque == target.Opaque && hasActions
+}
+
+// search form of about:history, dates are like 2024-01-31
+const historySearchForm = `<form action="about:history">
+<input name="q" value="%s" placeholder="Search history">
+<input name="from" value="%s" placeholder="From (YYYY-MM-DD)">
+<input name="to" value="%s" placeholder="To (YYYY-MM-DD)">
+<input type="submit" value="Search">
+</form>
+<p><a href="about:history?clear=all">Clear history</a></p>
+`
+
+// aboutHistory lists the pages visited in this tab (the current one is bold),
+// then the whole browsing history matching the query, grouped by day
+func aboutHistory(history *navHistory, store *historyStore, query urlPkg.Values) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, historySearchForm,
+		escapeText(query.Get("q")), escapeText(query.Get("from")), escapeText(query.Get("to")))
+
+	builder.WriteString("<h2>This tab</h2>\n")
+	urls, curIdx := history.entries()
+	if len(urls) == 0 {
+		builder.WriteString("<p>Nothing here yet.</p>\n")
+	}
+	// newest first
+	for i := len(urls) - 1; i >= 0; i-- {
+		link := historyLink(store, urls[i])
+		if i == curIdx {
+			link = "<b>" + link + "</b>"
+		}
+		fmt.Fprintf(&builder, "<p>%d. %s</p>\n", i+1, link)
+	}
+
+	filter := HistoryQuery{Text: query.Get("q")}
+	if from, err := parseHistoryDate(query.Get("from")); err == nil {
+		filter.From = from
+	}
+	if to, err := parseHistoryDate(query.Get("to")); err == nil {
+		filter.To = to.AddDate(0, 0, 1) // the whole day
+	}
+	entries := store.query(filter)
+	if len(entries) == 0 {
+		builder.WriteString("<h2>All tabs</h2>\n<p>Nothing found.</p>\n")
+		return builder.String()
+	}
+
+	var day string
+	for _, entry := range entries {
+		lastVisit := entry.LastVisit().Local()
+		if entryDay := lastVisit.Format("Monday, 2 January 2006"); entryDay != day {
+			day = entryDay
+			fmt.Fprintf(&builder, "<h2>%s</h2>\n", day)
+		}
+		visits := "1 visit"
+		if entry.VisitCount > 1 {
+			visits = fmt.Sprintf("%d visits", entry.VisitCount)
+		}
+		deleteUrl := "about:history?delete=" + urlPkg.QueryEscape(entry.Url)
+		fmt.Fprintf(&builder, `<p>%s %s <i>%s</i> <a href="%s">Delete</a></p>`+"\n",
+			lastVisit.Format(time.Kitchen), historyLink(store, entry.Url), visits, escapeText(deleteUrl))
+	}
+	return builder.String()
+}
+
+// historyLink links to the url with its title from the history, or the url if there is no title
+func historyLink(store *historyStore, url string) string {
+	text := url
+	if entry, ok := store.get(url); ok && entry.Title != "" {
+		text = entry.Title
+	}
+	return fmt.Sprintf(`<a href="%s">%s</a>`, escapeText(url), escapeText(text))
+}
+
+// forms of about:bookmarks under the bookmarks, with the files import reads and export writes
+const bookmarksOrganizeForms = `<h2>Organize</h2>
+<form action="about:bookmarks">
+<input name="new_folder" placeholder="New folder e.g. Bookmarks bar/Go">
+<input type="submit" value="Create folder">
+</form>
+<p>Import reads the bookmark file %s, export writes %s.</p>
+<form action="about:bookmarks">
+<input type="hidden" name="import" value="1">
+<input type="submit" value="Import">
+</form>
+<form action="about:bookmarks">
+<input type="hidden" name="export" value="1">
+<input type="submit" value="Export">
+</form>
+`
+
+// edit form of a bookmark in about:bookmarks, the id goes along hidden
+const bookmarkEditForm = `<h2>Edit %s</h2>
+<form action="about:bookmarks">
+<input type="hidden" name="edit" value="%d">
+<input name="title" value="%s" placeholder="Title">
+<input name="folder" value="%s" placeholder="Folder e.g. Bookmarks bar/Go">
+<input name="tags" value="%s" placeholder="Tags e.g. go, docs">
+<input type="submit" value="Save">
+</form>
+`
+
+// aboutBookmarks lists the bookmarks by folder with links to edit and delete them.
+// ?q= shows only the ones with it in the title, url or tags, ?folder= only that folder,
+// ?id= shows the edit form of the bookmark and ?notice= is what the latest action did.
+func aboutBookmarks(store *bookmarkStore, query urlPkg.Values) string {
+	bookmarks, folders := store.all()
+	var builder strings.Builder
+	if notice := query.Get("notice"); notice != "" {
+		fmt.Fprintf(&builder, "<p><b>%s</b></p>\n", escapeText(notice))
+	}
+	fmt.Fprintf(&builder, `<form action="about:bookmarks">
+<input name="q" value="%s" placeholder="Search bookmarks">
+<input type="submit" value="Search">
+</form>
+`, escapeText(query.Get("q")))
+
+	if id, err := strconv.ParseUint(query.Get("id"), 10, 64); err == nil {
+		for _, bookmark := range bookmarks {
+			if bookmark.ID == BookmarkID(id) {
+				fmt.Fprintf(&builder, bookmarkEditForm, escapeText(bookmark.Url), bookmark.ID, escapeText(bookmark.Title),
+					escapeText(bookmark.Folder), escapeText(strings.Join(bookmark.Tags, ", ")))
+			}
+		}
+	}
+
+	text, only := query.Get("q"), query.Get("folder")
+	for _, folder := range folders {
+		if only != "" && !inFolder(folder, cleanFolder(only)) {
+			continue
+		}
+		var rows []string
+		for _, bookmark := range bookmarks {
+			if bookmark.Folder != folder || (text != "" && !bookmarkMatches(bookmark, text)) {
+				continue
+			}
+			tags := ""
+			for _, tag := range bookmark.Tags {
+				tags += " #" + tag
+			}
+			title := bookmark.Title
+			if title == "" {
+				title = bookmark.Url
+			}
+			rows = append(rows, fmt.Sprintf(`<p><a href="%s">%s</a> <i>%s</i> <a href="about:bookmarks?id=%d">Edit</a> `+
+				`<a href="about:bookmarks?delete=%d">Delete</a></p>`,
+				escapeText(bookmark.Url), escapeText(title), escapeText(tags), bookmark.ID, bookmark.ID))
+		}
+		if text != "" && len(rows) == 0 {
+			continue // searching, empty folder is noise
+		}
+		deleteUrl := "about:bookmarks?delete_folder=" + urlPkg.QueryEscape(folder)
+		fmt.Fprintf(&builder, "<h2>%s</h2>\n<p><a href=\"%s\">Delete folder</a></p>\n", escapeText(folder), escapeText(deleteUrl))
+		if len(rows) == 0 {
+			builder.WriteString("<p>Nothing here yet.</p>\n")
+		}
+		for _, row := range rows {
+			builder.WriteString(row + "\n")
+		}
+	}
+
+	fmt.Fprintf(&builder, bookmarksOrganizeForms, escapeText(filepath.Join(settings.DownloadDir, bookmarkImportFile)),
+		escapeText(filepath.Join(settings.DownloadDir, bookmarkExportFile)))
+	return builder.String()
+}
+
+// bookmarkMatches tells if the title, url or a tag of the bookmark contains the text (case insensitive)
+func bookmarkMatches(bookmark Bookmark, text string) bool {
+	return containsFold(bookmark.Url, text) || containsFold(bookmark.Title, text) ||
+		slices.ContainsFunc(bookmark.Tags, func(tag string) bool { return containsFold(tag, text) })
+}
+
+// aboutCache lists the pages cached in this tab with their kind
+func aboutCache(cache map[string]Dom) string {
+	if len(cache) == 0 {
+		return "<p>Nothing is cached.</p>"
+	}
+
+	urls := make([]string, 0, len(cache))
+	for url := range cache {
+		urls = append(urls, url)
+	}
+	slices.Sort(urls)
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "<p>%d pages are cached in this tab.</p>\n", len(urls))
+	for _, url := range urls {
+		fmt.Fprintf(&builder, `<p><a href="%s">%s</a> <i>%s</i></p>`+"\n",
+			escapeText(url), escapeText(url), documentKindNames[cache[url].Kind])
+	}
+	return builder.String()
+}
+
+// aboutSettings shows the current engine settings
+func aboutSettings() string {
+	rows := [][2]string{
+		{"User agent", settings.UserAgent},
+		{"Request timeout", settings.RequestTimeout.String()},
+		{"Max redirects", fmt.Sprint(settings.MaxRedirects)},
+		{"Max concurrent image fetches", fmt.Sprint(settings.MaxConcurrentImageFetch)},
+		{"Data directory", settings.DataDir},
+		{"Download directory", settings.DownloadDir},
+		{"Search engine", settings.SearchEngine},
+	}
+	var builder strings.Builder
+	for _, row := range rows {
+		fmt.Fprintf(&builder, "<p><b>%s:</b> %s</p>\n", row[0], escapeText(row[1]))
+	}
+	return builder.String()
+}
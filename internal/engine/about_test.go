@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	urlPkg "net/url"
+	"strings"
+	"testing"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// pageText collects all text in the DOM tree
+func pageText(node *parser.Node) string {
+	if node == nil {
+		return ""
+	}
+	var builder strings.Builder
+	if node.Tag == parser.Text {
+		builder.WriteString(node.Inner)
+	}
+	for _, child := range node.Children {
+		builder.WriteString(pageText(child))
+	}
+	return builder.String()
+}
+
+func TestAboutPage(t *testing.T) {
+	history := newNavHistory()
+	history.nav("https://a.com/")
+	history.nav("https://b.com/")
+	history.back()
+	cache := map[string]Dom{"https://a.com/": {Kind: HtmlDocument}}
+
+	tests := []struct {
+		name     string
+		page     string
+		contains []string
+		ok       bool
+	}{
+		{"blank", "blank", nil, true},
+		{"history", "history", []string{"https://a.com/", "https://b.com/"}, true},
+		{"cache", "cache", []string{"https://a.com/", "html"}, true},
+		{"settings", "settings", []string{settings.UserAgent, settings.RequestTimeout.String()}, true},
+		{"unknown", "nope", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dom, ok := aboutPage(test.page, nil, history, newHistoryStore(""), newBookmarkStore(""), cache)
+			if ok != test.ok {
+				t.Fatalf("Expected: %v | Got: %v", test.ok, ok)
+			}
+			text := pageText(dom.Root)
+			for _, part := range test.contains {
+				if !strings.Contains(text, part) {
+					t.Errorf("Expected %q in the page | Got: %v", part, text)
+				}
+			}
+		})
+	}
+}
+
+func TestIsPageAction(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     string
+		href     string
+		expected bool
+	}{
+		{"delete on about:history", "about:history", "about:history?delete=https%3A%2F%2Fa.com%2F", true},
+		{"from a search of it", "about:history?q=a", "about:history?clear=all", true},
+		{"page name case", "about:history", "About:History?clear=all", true},
+		{"from another about page", "about:blank", "about:history?clear=all", false},
+		{"from a web page", "https://evil.com/", "about:history?clear=all", false},
+		{"page without actions", "about:cache", "about:cache?clear=all", false},
+		{"leaving the page", "about:history", "https://a.com/", false},
+		{"no page", "", "about:history?clear=all", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsPageAction(test.page, test.href); got != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestNavHistoryEntries(t *testing.T) {
+	history := newNavHistory()
+	if urls, cur := history.entries(); len(urls) != 0 || cur != -1 {
+		t.Errorf("Expected: [] -1 | Got: %v %v", urls, cur)
+	}
+
+	history.nav("a")
+	history.nav("b")
+	history.nav("c")
+	history.back()
+	urls, cur := history.entries()
+	if strings.Join(urls, ",") != "a,b,c" || cur != 1 {
+		t.Errorf("Expected: [a b c] 1 | Got: %v %v", urls, cur)
+	}
+}
+
+func TestViewSource(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<a href="/next">next</a>`)
+	})
+	mux.Handle("/old", http.RedirectHandler("/page", http.StatusFound))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url, _ := urlPkg.Parse(server.URL + "/old")
+	reporter := newProgressReporter(context.Background(), newTab(1), new(fakeWindow), url.Host)
+	dom, finalUrl, err := viewSource(context.Background(), url.String(), reporter)
+	if err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	if finalUrl != "view-source:"+server.URL+"/page" {
+		t.Errorf("Expected: %v | Got: %v", "view-source:"+server.URL+"/page", finalUrl)
+	}
+	if dom.Kind != ViewSourceDocument || dom.Source != `<a href="/next">next</a>` {
+		t.Errorf("Expected: view-source document with the source | Got: %v %v", dom.Kind, dom.Source)
+	}
+
+	_, finalUrl, err = viewSource(context.Background(), server.URL+"/missing", reporter)
+	if err == nil || finalUrl != "view-source:"+server.URL+"/missing" {
+		t.Errorf("Expected error at %v | Got: %v %v", "view-source:"+server.URL+"/missing", finalUrl, err)
+	}
+}
@@ -0,0 +1,478 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	urlPkg "net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BookmarkID is a stable identity of a bookmark, it never gets reused
+type BookmarkID uint64
+
+// bookmarks live in the data directory
+const bookmarksFile = "bookmarks.json"
+
+const (
+	BarFolder   = "Bookmarks bar" // shown under the top bar
+	OtherFolder = "Other bookmarks"
+)
+
+// Bookmark is a saved page. Folder is a path of folder names separated by "/" e.g. "Bookmarks bar/Go".
+type Bookmark struct {
+	ID      BookmarkID `json:"id"`
+	Url     string     `json:"url"`
+	Title   string     `json:"title"`
+	Folder  string     `json:"folder"`
+	Tags    []string   `json:"tags,omitempty"`
+	AddedAt time.Time  `json:"added_at"`
+}
+
+// bookmarkStore is the bookmarks of all tabs, saved to path on every change.
+// Folders are kept on their own too, so an empty folder stays.
+type bookmarkStore struct {
+	mu        sync.Mutex
+	path      string // empty means keep nothing on disk
+	nextId    BookmarkID
+	bookmarks []*Bookmark // in the order they were added
+	folders   []string    // every folder, sorted
+}
+
+// content of bookmarks.json
+type bookmarksContent struct {
+	Folders   []string    `json:"folders"`
+	Bookmarks []*Bookmark `json:"bookmarks"`
+}
+
+func newBookmarkStore(path string) *bookmarkStore {
+	store := &bookmarkStore{path: path, folders: []string{BarFolder, OtherFolder}}
+	if path == "" {
+		return store
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Println("newBookmarkStore: os.ReadFile:", err)
+		}
+		return store
+	}
+	var content bookmarksContent
+	if err := json.Unmarshal(raw, &content); err != nil {
+		log.Println("newBookmarkStore: json.Unmarshal:", err)
+		return store
+	}
+	for _, folder := range content.Folders {
+		store.addFolderLocked(folder)
+	}
+	for _, bookmark := range content.Bookmarks {
+		store.addFolderLocked(bookmark.Folder)
+		store.nextId = max(store.nextId, bookmark.ID)
+	}
+	store.bookmarks = content.Bookmarks
+	return store
+}
+
+// add bookmarks the url in the folder (and creates the folder if needed)
+func (b *bookmarkStore) add(url, title, folder string, tags []string) BookmarkID {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.addLocked(&Bookmark{Url: url, Title: title, Folder: folder, Tags: tags, AddedAt: time.Now()})
+	b.save()
+	return id
+}
+
+// requires: b.mu is locked
+func (b *bookmarkStore) addLocked(bookmark *Bookmark) BookmarkID {
+	b.nextId++
+	bookmark.ID = b.nextId
+	bookmark.Folder = b.addFolderLocked(bookmark.Folder)
+	bookmark.Tags = cleanTags(bookmark.Tags)
+	b.bookmarks = append(b.bookmarks, bookmark)
+	return bookmark.ID
+}
+
+// remove deletes the bookmark, nothing happens if there isn't one
+func (b *bookmarkStore) remove(id BookmarkID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bookmarks = slices.DeleteFunc(b.bookmarks, func(bookmark *Bookmark) bool { return bookmark.ID == id })
+	b.save()
+}
+
+// update moves the bookmark to the folder and replaces its title and tags, false if there is no such bookmark
+func (b *bookmarkStore) update(id BookmarkID, title, folder string, tags []string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, bookmark := range b.bookmarks {
+		if bookmark.ID != id {
+			continue
+		}
+		if title != "" {
+			bookmark.Title = title
+		}
+		bookmark.Folder = b.addFolderLocked(folder)
+		bookmark.Tags = cleanTags(tags)
+		b.save()
+		return true
+	}
+	return false
+}
+
+// addFolder creates the folder and its parents, it returns the cleaned folder path
+func (b *bookmarkStore) addFolder(folder string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	folder = b.addFolderLocked(folder)
+	b.save()
+	return folder
+}
+
+// requires: b.mu is locked
+func (b *bookmarkStore) addFolderLocked(folder string) string {
+	folder = cleanFolder(folder)
+	names := strings.Split(folder, "/")
+	for i := range names {
+		parent := strings.Join(names[:i+1], "/")
+		if idx, found := slices.BinarySearch(b.folders, parent); !found {
+			b.folders = slices.Insert(b.folders, idx, parent)
+		}
+	}
+	return folder
+}
+
+// removeFolder deletes the folder with everything in it. The bar and the default folder stay, only emptied.
+func (b *bookmarkStore) removeFolder(folder string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	folder = cleanFolder(folder)
+	b.bookmarks = slices.DeleteFunc(b.bookmarks, func(bookmark *Bookmark) bool {
+		return inFolder(bookmark.Folder, folder)
+	})
+	b.folders = slices.DeleteFunc(b.folders, func(f string) bool {
+		return inFolder(f, folder) && f != BarFolder && f != OtherFolder
+	})
+	b.save()
+}
+
+// all returns copies of all the bookmarks and all the folders
+func (b *bookmarkStore) all() ([]Bookmark, []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bookmarks := make([]Bookmark, len(b.bookmarks))
+	for i, bookmark := range b.bookmarks {
+		bookmarks[i] = *bookmark
+		bookmarks[i].Tags = slices.Clone(bookmark.Tags)
+	}
+	return bookmarks, slices.Clone(b.folders)
+}
+
+// save writes the bookmarks to the file, all or nothing
+// requires: b.mu is locked
+func (b *bookmarkStore) save() {
+	if b.path == "" {
+		return
+	}
+	content, err := json.MarshalIndent(bookmarksContent{Folders: b.folders, Bookmarks: b.bookmarks}, "", "  ")
+	if err != nil {
+		log.Println("bookmarkStore.save: json.MarshalIndent:", err)
+		return
+	}
+	if err := writeFileAtomic(b.path, content); err != nil {
+		log.Println("bookmarkStore.save:", err)
+	}
+}
+
+// cleanFolder trims the names in the folder path and drops the empty ones, no folder is OtherFolder
+func cleanFolder(folder string) string {
+	var names []string
+	for _, name := range strings.Split(folder, "/") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return OtherFolder
+	}
+	return strings.Join(names, "/")
+}
+
+// cleanTags trims the tags, drops the empty and repeated ones
+func cleanTags(tags []string) []string {
+	var res []string
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(res, tag) {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
+
+// splitTags splits the comma separated tags e.g. "go, docs"
+func splitTags(tags string) []string {
+	return cleanTags(strings.Split(tags, ","))
+}
+
+// inFolder tells if folder is parent or the same as the other one
+func inFolder(folder, parent string) bool {
+	return folder == parent || strings.HasPrefix(folder, parent+"/")
+}
+
+// Bookmark returns the first bookmark of the url, false if it's not bookmarked
+func (s Snapshot) Bookmark(url string) (Bookmark, bool) {
+	for _, bookmark := range s.Bookmarks {
+		if bookmark.Url == url {
+			return bookmark, true
+		}
+	}
+	return Bookmark{}, false
+}
+
+// bookmarkTab bookmarks the page of the tab in the bookmarks bar
+func (s *State) bookmarkTab(id TabID) {
+	tab := s.tab(id)
+	if tab == nil {
+		return // already closed
+	}
+	s.mu.RLock()
+	url, title := tab.url, PageTitle(tab.dom.Root)
+	s.mu.RUnlock()
+	if url == "" {
+		return // blank page
+	}
+	s.bookmarks.add(url, title, BarFolder, nil)
+}
+
+// header of the Netscape bookmark file, it's what every browser writes and reads
+const netscapeHeader = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+`
+
+// exportHTML writes all the bookmarks in the Netscape bookmark format, folders nested like ours.
+// The bookmarks bar is marked as the toolbar folder so other browsers put it in their bar.
+func (b *bookmarkStore) exportHTML(w io.Writer) error {
+	bookmarks, folders := b.all()
+	var builder strings.Builder
+	builder.WriteString(netscapeHeader)
+
+	var writeFolder func(folder string, depth int)
+	writeFolder = func(folder string, depth int) {
+		indent := strings.Repeat("    ", depth)
+		builder.WriteString(indent + "<DL><p>\n")
+		for _, f := range folders {
+			if parent, name := splitFolder(f); parent == folder {
+				toolbar := ""
+				if f == BarFolder {
+					toolbar = ` PERSONAL_TOOLBAR_FOLDER="true"`
+				}
+				fmt.Fprintf(&builder, "%s    <DT><H3%s>%s</H3>\n", indent, toolbar, html.EscapeString(name))
+				writeFolder(f, depth+1)
+			}
+		}
+		for _, bookmark := range bookmarks {
+			if bookmark.Folder != folder {
+				continue
+			}
+			tags := ""
+			if len(bookmark.Tags) > 0 {
+				tags = fmt.Sprintf(` TAGS="%s"`, html.EscapeString(strings.Join(bookmark.Tags, ",")))
+			}
+			fmt.Fprintf(&builder, "%s    <DT><A HREF=\"%s\" ADD_DATE=\"%d\"%s>%s</A>\n", indent,
+				html.EscapeString(bookmark.Url), bookmark.AddedAt.Unix(), tags, html.EscapeString(bookmark.Title))
+		}
+		builder.WriteString(indent + "</DL><p>\n")
+	}
+	writeFolder("", 0)
+
+	if _, err := io.WriteString(w, builder.String()); err != nil {
+		return fmt.Errorf("io.WriteString: %v", err)
+	}
+	return nil
+}
+
+// splitFolder splits the folder path into its parent path and its own name, parent of a top folder is ""
+func splitFolder(folder string) (string, string) {
+	idx := strings.LastIndex(folder, "/")
+	if idx == -1 {
+		return "", folder
+	}
+	return folder[:idx], folder[idx+1:]
+}
+
+// tags of the Netscape bookmark file we care about, the file is not quite html (<DT> and <p> never close)
+// so it's scanned tag by tag instead of parsed
+var (
+	netscapeTagRe  = regexp.MustCompile(`(?is)<(/?)(dl|h3|a)\b([^>]*)>`)
+	netscapeAttrRe = regexp.MustCompile(`(?is)([a-z_]+)\s*=\s*"([^"]*)"`)
+)
+
+// importHTML adds the bookmarks of the Netscape bookmark file, it returns how many were added.
+// The toolbar folder goes to our bookmarks bar whatever its name is, bookmarks outside any folder go to OtherFolder.
+// A bookmark already in the same folder isn't added again.
+func (b *bookmarkStore) importHTML(r io.Reader) (int, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return 0, fmt.Errorf("io.ReadAll: %v", err)
+	}
+	content := string(raw)
+	if !strings.Contains(strings.ToUpper(content), "NETSCAPE-BOOKMARK-FILE") {
+		return 0, fmt.Errorf("Not a bookmark file")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var stack []string // the folder we are in, "" is the top
+	var pending string // folder of the latest <H3>, the next <DL> goes into it
+	count := 0
+	for _, match := range netscapeTagRe.FindAllStringSubmatchIndex(content, -1) {
+		closing := match[3] > match[2]
+		tag := strings.ToLower(content[match[4]:match[5]])
+		attrs := netscapeAttrs(content[match[6]:match[7]])
+		// text up to the closing tag
+		text := content[match[1]:]
+		if end := strings.Index(strings.ToLower(text), "</"+tag); end != -1 {
+			text = text[:end]
+		}
+		text = strings.TrimSpace(html.UnescapeString(text))
+		current := ""
+		if len(stack) > 0 {
+			current = stack[len(stack)-1]
+		}
+
+		switch {
+		case tag == "dl" && closing:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case tag == "dl":
+			stack = append(stack, pending)
+		case tag == "h3" && !closing:
+			name := strings.ReplaceAll(text, "/", "-")
+			switch {
+			case strings.EqualFold(attrs["personal_toolbar_folder"], "true"):
+				pending = BarFolder
+			case current == "":
+				pending = cleanFolder(name)
+			default:
+				pending = cleanFolder(current + "/" + name)
+			}
+			b.addFolderLocked(pending)
+		case tag == "a" && !closing:
+			url := attrs["href"]
+			if parsed, err := urlPkg.Parse(url); err != nil || parsed.Scheme == "" || parsed.Scheme == "javascript" || parsed.Scheme == "place" {
+				continue // bookmarklet, smart folder or garbage
+			}
+			folder := cleanFolder(current)
+			if slices.ContainsFunc(b.bookmarks, func(bookmark *Bookmark) bool {
+				return bookmark.Url == url && bookmark.Folder == folder
+			}) {
+				continue
+			}
+			addedAt := time.Now()
+			if seconds, err := strconv.ParseInt(attrs["add_date"], 10, 64); err == nil {
+				addedAt = time.Unix(seconds, 0)
+			}
+			b.addLocked(&Bookmark{Url: url, Title: text, Folder: folder, Tags: splitTags(attrs["tags"]), AddedAt: addedAt})
+			count++
+		}
+	}
+	b.save()
+	return count, nil
+}
+
+// netscapeAttrs is the attributes of the tag with lowercase names and unescaped values
+func netscapeAttrs(raw string) map[string]string {
+	attrs := make(map[string]string)
+	for _, match := range netscapeAttrRe.FindAllStringSubmatch(raw, -1) {
+		attrs[strings.ToLower(match[1])] = html.UnescapeString(match[2])
+	}
+	return attrs
+}
+
+// importBookmarks imports the Netscape bookmark file at path
+func importBookmarks(store *bookmarkStore, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("os.Open: %v", err)
+	}
+	defer file.Close()
+	return store.importHTML(file)
+}
+
+// exportBookmarks writes the Netscape bookmark file to path
+func exportBookmarks(store *bookmarkStore, path string) error {
+	var builder strings.Builder
+	if err := store.exportHTML(&builder); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, []byte(builder.String())); err != nil {
+		return err
+	}
+	return nil
+}
+
+// bookmark files of import and export, they're always in the download directory so a url never picks a file
+const (
+	bookmarkImportFile = "bookmarks.html" // what Firefox exports
+	bookmarkExportFile = "gazer-bookmarks.html"
+)
+
+// bookmarkAction does what the about:bookmarks link or form asks, it returns what to tell the user
+// and false if it asks nothing. Actions:
+//   - ?delete=<id>
+//   - ?edit=<id>&title=..&folder=..&tags=a,b
+//   - ?new_folder=<path>
+//   - ?delete_folder=<path>
+//   - ?import (bookmarkImportFile)
+//   - ?export (bookmarkExportFile)
+func bookmarkAction(store *bookmarkStore, query urlPkg.Values) (string, bool) {
+	switch {
+	case query.Has("delete"):
+		id, err := strconv.ParseUint(query.Get("delete"), 10, 64)
+		if err != nil {
+			return "No such bookmark", true
+		}
+		store.remove(BookmarkID(id))
+		return "Bookmark deleted", true
+	case query.Has("edit"):
+		id, err := strconv.ParseUint(query.Get("edit"), 10, 64)
+		if err != nil || !store.update(BookmarkID(id), query.Get("title"), query.Get("folder"), splitTags(query.Get("tags"))) {
+			return "No such bookmark", true
+		}
+		return "Bookmark saved", true
+	case query.Has("new_folder"):
+		return "Folder " + store.addFolder(query.Get("new_folder")) + " created", true
+	case query.Has("delete_folder"):
+		store.removeFolder(query.Get("delete_folder"))
+		return "Folder deleted", true
+	case query.Has("import"):
+		count, err := importBookmarks(store, filepath.Join(settings.DownloadDir, bookmarkImportFile))
+		if err != nil {
+			return fmt.Sprintf("Couldn't import: %v", err), true
+		}
+		return fmt.Sprintf("Imported %d bookmarks", count), true
+	case query.Has("export"):
+		path := filepath.Join(settings.DownloadDir, bookmarkExportFile)
+		if err := exportBookmarks(store, path); err != nil {
+			return fmt.Sprintf("Couldn't export: %v", err), true
+		}
+		return "Exported to " + path, true
+	}
+	return "", false
+}
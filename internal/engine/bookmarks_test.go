@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	urlPkg "net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bookmarkList is "folder:url[tags]" of the bookmarks in order
+func bookmarkList(bookmarks []Bookmark) string {
+	list := make([]string, len(bookmarks))
+	for i, bookmark := range bookmarks {
+		list[i] = fmt.Sprintf("%s:%s%v", bookmark.Folder, bookmark.Url, bookmark.Tags)
+	}
+	return strings.Join(list, ",")
+}
+
+func TestBookmarkStore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, bookmarksFile)
+	store := newBookmarkStore(path)
+	store.add("https://go.dev/", "Go", BarFolder, []string{"go", " lang ", "go"})
+	docs := store.add("https://pkg.go.dev/", "Docs", " Bookmarks bar / Go /", nil)
+	store.add("https://example.com/", "Example", "", nil)
+	store.addFolder("Reading/Later")
+
+	bookmarks, folders := store.all()
+	if got := bookmarkList(bookmarks); got != "Bookmarks bar:https://go.dev/[go lang],"+
+		"Bookmarks bar/Go:https://pkg.go.dev/[],Other bookmarks:https://example.com/[]" {
+		t.Errorf("Expected: cleaned folders and tags | Got: %v", got)
+	}
+	if got := strings.Join(folders, ","); got != "Bookmarks bar,Bookmarks bar/Go,Other bookmarks,Reading,Reading/Later" {
+		t.Errorf("Expected: sorted folders with the parents | Got: %v", got)
+	}
+
+	if !store.update(docs, "", "Reading", []string{"docs"}) {
+		t.Fatalf("Expected: bookmark %d is updated", docs)
+	}
+	store.removeFolder(BarFolder) // bar stays, only emptied
+	store.removeFolder("Reading/Later")
+
+	// it's all on disk for the next start, and nothing is left half written
+	loaded := newBookmarkStore(path)
+	bookmarks, folders = loaded.all()
+	if got := bookmarkList(bookmarks); got != "Reading:https://pkg.go.dev/[docs],Other bookmarks:https://example.com/[]" {
+		t.Errorf("Expected: docs in Reading and example | Got: %v", got)
+	}
+	if bookmarks[0].Title != "Docs" {
+		t.Errorf("Expected: Docs | Got: %v", bookmarks[0].Title)
+	}
+	if got := strings.Join(folders, ","); got != "Bookmarks bar,Other bookmarks,Reading" {
+		t.Errorf("Expected: Bookmarks bar,Other bookmarks,Reading | Got: %v", got)
+	}
+	if id := loaded.add("https://gazer.dev/", "", "", nil); id <= docs {
+		t.Errorf("Expected: new id after %d | Got: %d", docs, id)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected: only %v in the directory | Got: %v", bookmarksFile, entries)
+	}
+}
+
+// exported by Firefox, folders nested and a bookmarklet
+const firefoxBookmarks = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks Menu</H1>
+<DL><p>
+    <DT><A HREF="https://example.com/" ADD_DATE="1700000000">Example &amp; co</A>
+    <DT><H3 ADD_DATE="1700000000" PERSONAL_TOOLBAR_FOLDER="true">Bookmarks Toolbar</H3>
+    <DL><p>
+        <DT><A HREF="https://go.dev/" ADD_DATE="1700000001" TAGS="go,lang">Go</A>
+        <DT><H3>Docs/Refs</H3>
+        <DL><p>
+            <DT><A HREF="https://pkg.go.dev/">Packages</A>
+        </DL><p>
+        <DT><A HREF="javascript:alert(1)">Bookmarklet</A>
+    </DL><p>
+    <DT><H3>Reading</H3>
+    <DL><p>
+        <DT><A HREF="https://gazer.dev/blog">Blog</A>
+    </DL><p>
+</DL>
+`
+
+func TestBookmarksHTML(t *testing.T) {
+	store := newBookmarkStore("")
+	store.add("https://go.dev/", "Go", BarFolder, nil) // already there, not added again
+	count, err := store.importHTML(strings.NewReader(firefoxBookmarks))
+	if err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected: 3 bookmarks imported | Got: %d", count)
+	}
+	bookmarks, _ := store.all()
+	expected := "Bookmarks bar:https://go.dev/[],Other bookmarks:https://example.com/[]," +
+		"Bookmarks bar/Docs-Refs:https://pkg.go.dev/[],Reading:https://gazer.dev/blog[]"
+	if got := bookmarkList(bookmarks); got != expected {
+		t.Errorf("Expected: %v | Got: %v", expected, got)
+	}
+	if bookmarks[1].Title != "Example & co" || !bookmarks[1].AddedAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Expected: Example & co added at 1700000000 | Got: %+v", bookmarks[1])
+	}
+
+	// what we export, we (and everyone else) can import as it was
+	store.update(bookmarks[0].ID, "", BarFolder, []string{"go", "lang"})
+	var exported strings.Builder
+	if err := store.exportHTML(&exported); err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	if !strings.Contains(exported.String(), `<H3 PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>`) {
+		t.Errorf("Expected: the bar is the toolbar folder | Got: %v", exported.String())
+	}
+	reimported := newBookmarkStore("")
+	if _, err := reimported.importHTML(strings.NewReader(exported.String())); err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	bookmarks, _ = store.all()
+	again, folders := reimported.all()
+	if got, want := sortedBookmarkList(again), sortedBookmarkList(bookmarks); got != want {
+		t.Errorf("Expected: %v | Got: %v", want, got)
+	}
+	if got := strings.Join(folders, ","); got != "Bookmarks bar,Bookmarks bar/Docs-Refs,Other bookmarks,Reading" {
+		t.Errorf("Expected: same folders | Got: %v", got)
+	}
+
+	if _, err := store.importHTML(strings.NewReader("<p>not bookmarks</p>")); err == nil {
+		t.Errorf("Expected error for a page that isn't a bookmark file")
+	}
+}
+
+// sortedBookmarkList is bookmarkList ignoring the order, export groups the bookmarks by folder
+func sortedBookmarkList(bookmarks []Bookmark) string {
+	list := strings.Split(bookmarkList(bookmarks), ",")
+	slices.Sort(list)
+	return strings.Join(list, ",")
+}
+
+func TestBookmarkNotifications(t *testing.T) {
+	downloadDir := useTempDataDirs(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><title>Gazer</title></head><body></body></html>")
+	}))
+	defer server.Close()
+
+	state := NewState()
+	startEngine(t, state)
+	id := state.Snapshot().Selected
+
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: server.URL + "/"}
+	waitTabUrl(t, state, server.URL+"/")
+	state.Notifier <- Notification{Type: AddBookmark, TabID: id}
+	var bookmark Bookmark
+	deadline := time.Now().Add(3 * time.Second)
+	for ok := false; !ok; bookmark, ok = state.Snapshot().Bookmark(server.URL + "/") {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected: %v is bookmarked", server.URL)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if bookmark.Title != "Gazer" || bookmark.Folder != BarFolder {
+		t.Errorf("Expected: Gazer in the bar | Got: %+v", bookmark)
+	}
+
+	// a link to the action from anywhere else only shows the manager, even with a notice
+	edit := fmt.Sprintf("about:bookmarks?edit=%d&title=&folder=Reading&tags=go&notice=Saved", bookmark.ID)
+	state.Notifier <- Notification{Type: PageAction, TabID: id, Url: edit}
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: edit, Transition: LinkTransition}
+	tab := waitTabUrl(t, state, edit)
+	if text := pageText(tab.Dom.Root); strings.Contains(text, "Saved") || strings.Contains(text, "#go") {
+		t.Errorf("Expected: nothing edited | Got: %v", text)
+	}
+
+	// the manager does it with its own forms and shows what it did
+	act := func(action string) string {
+		state.Notifier <- Notification{Type: Search, TabID: id, Url: "about:bookmarks?q="}
+		waitTabUrl(t, state, "about:bookmarks?q=")
+		state.Notifier <- Notification{Type: PageAction, TabID: id, Url: action}
+		return pageText(waitTabUrl(t, state, "about:bookmarks").Dom.Root)
+	}
+	if text := act(edit); !strings.Contains(text, "Bookmark saved") || !strings.Contains(text, "#go") {
+		t.Errorf("Expected: Bookmark saved and the tag | Got: %v", text)
+	}
+
+	// export and import only use their files in the download directory, the value is ignored
+	exportFile := filepath.Join(downloadDir, bookmarkExportFile)
+	elsewhere := urlPkg.QueryEscape(filepath.Join(t.TempDir(), "x.html"))
+	if text := act("about:bookmarks?export=" + elsewhere); !strings.Contains(text, "Exported to "+exportFile) {
+		t.Errorf("Expected: Exported to %v | Got: %v", exportFile, text)
+	}
+	if exported, err := os.ReadFile(exportFile); err != nil || !strings.Contains(string(exported), server.URL) {
+		t.Errorf("Expected: the bookmark exported | Got: %v %s", err, exported)
+	}
+	if err := os.WriteFile(filepath.Join(downloadDir, bookmarkImportFile), []byte(firefoxBookmarks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if text := act("about:bookmarks?import=/etc/passwd"); !strings.Contains(text, "Imported 4 bookmarks") {
+		t.Errorf("Expected: Imported 4 bookmarks | Got: %v", text)
+	}
+
+	state.Notifier <- Notification{Type: RemoveBookmark, BookmarkID: bookmark.ID}
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: "about:blank"}
+	waitTabUrl(t, state, "about:blank")
+	if got := state.Snapshot().Bookmarks; len(got) != 4 {
+		t.Errorf("Expected: only the imported bookmarks | Got: %v", bookmarkList(got))
+	}
+}
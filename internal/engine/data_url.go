@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	urlPkg "net/url"
+	"strings"
+)
+
+// dataUrl is a parsed data: url, data:[<mediatype>][;base64],<data>
+type dataUrl struct {
+	mediaType string // default is text/plain
+	charset   string // default is US-ASCII
+	data      []byte
+}
+
+// parseDataUrl parses the data: url, the fragment is not a part of the data
+func parseDataUrl(url urlPkg.URL) (*dataUrl, error) {
+	url.Fragment = ""
+	body, ok := strings.CutPrefix(url.String(), "data:")
+	if !ok {
+		return nil, fmt.Errorf("Not a data url: %v", url.String())
+	}
+
+	header, data, ok := strings.Cut(body, ",")
+	if !ok {
+		return nil, fmt.Errorf("Missing comma in data url")
+	}
+
+	header = strings.TrimSpace(header)
+	header, isBase64 := cutBase64Suffix(header)
+
+	res := &dataUrl{mediaType: "text/plain", charset: "US-ASCII"}
+	if header != "" {
+		// header is percent-encoded too e.g. text/html;charset=utf%2D8
+		header = percentDecode(header)
+		if strings.HasPrefix(header, ";") {
+			header = "text/plain" + header
+		}
+		mediaType, params, err := mime.ParseMediaType(header)
+		if err == nil {
+			res.mediaType = mediaType
+			res.charset = params["charset"]
+		}
+	}
+
+	decoded := percentDecode(data)
+	if isBase64 {
+		content, err := decodeBase64(decoded)
+		if err != nil {
+			return nil, fmt.Errorf("decodeBase64: %v", err)
+		}
+		res.data = content
+	} else {
+		res.data = []byte(decoded)
+	}
+	return res, nil
+}
+
+// cutBase64Suffix removes ";base64" at the end of the header and tells if it's there
+func cutBase64Suffix(header string) (string, bool) {
+	idx := strings.LastIndex(header, ";")
+	if idx == -1 || !strings.EqualFold(strings.TrimSpace(header[idx+1:]), "base64") {
+		return header, false
+	}
+	return header[:idx], true
+}
+
+// percentDecode decodes %XX, broken escape is left as it is (unlike url.PathUnescape)
+func percentDecode(s string) string {
+	var builder strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			builder.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+			continue
+		}
+		builder.WriteByte(s[i])
+	}
+	return builder.String()
+}
+
+// decodeBase64 decodes base64 forgivingly: whitespace and missing padding are fine
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(s, "=")
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"context"
+	"io"
+	urlPkg "net/url"
+	"testing"
+)
+
+func TestParseDataUrl(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		mediaType string
+		charset   string
+		data      string
+	}{
+		{"plain", "data:,hello", "text/plain", "US-ASCII", "hello"},
+		{"html", "data:text/html,<h1>Hi</h1>", "text/html", "", "<h1>Hi</h1>"},
+		{"percent-encoded", "data:text/plain;charset=utf-8,caf%C3%A9%20au%20lait", "text/plain", "utf-8", "café au lait"},
+		{"base64", "data:text/plain;base64,aGVsbG8gd29ybGQ=", "text/plain", "", "hello world"},
+		{"base64 without padding", "data:;base64,aGk", "text/plain", "US-ASCII", "hi"},
+		{"base64 with spaces", "data:image/png;base64,aGVs%20bG8=", "image/png", "", "hello"},
+		{"broken escape", "data:,100%", "text/plain", "US-ASCII", "100%"},
+		{"fragment is not data", "data:,a?b#c", "text/plain", "US-ASCII", "a?b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url, err := urlPkg.Parse(test.url)
+			if err != nil {
+				t.Fatalf("url.Parse: %v", err)
+			}
+			got, err := parseDataUrl(*url)
+			if err != nil {
+				t.Fatalf("Expected no error | Got: %v", err)
+			}
+			if got.mediaType != test.mediaType {
+				t.Errorf("Expected: %v | Got: %v", test.mediaType, got.mediaType)
+			}
+			if got.charset != test.charset {
+				t.Errorf("Expected: %v | Got: %v", test.charset, got.charset)
+			}
+			if string(got.data) != test.data {
+				t.Errorf("Expected: %v | Got: %v", test.data, string(got.data))
+			}
+		})
+	}
+
+	for _, invalid := range []string{"data:text/plain", "data:;base64,!!!"} {
+		url, _ := urlPkg.Parse(invalid)
+		if _, err := parseDataUrl(*url); err == nil {
+			t.Errorf("Expected error for %q | Got: nil", invalid)
+		}
+	}
+}
+
+func TestFetchDataUrl(t *testing.T) {
+	url, _ := urlPkg.Parse("data:text/css,p%20%7B%20color%3A%20red%20%7D")
+	resource, err := Fetch(context.Background(), *url)
+	if err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	defer resource.Close()
+
+	content, _ := io.ReadAll(resource)
+	if resource.ContentType != "text/css" || string(content) != "p { color: red }" {
+		t.Errorf("Expected: text/css p { color: red } | Got: %v %v", resource.ContentType, string(content))
+	}
+}
+
+func TestPrepareUrl(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+		ok       bool
+	}{
+		{"no scheme", "example.com", "https://example.com", true},
+		{"localhost with port", "localhost:8080/a", "https://localhost:8080/a", true},
+		{"words", "golang generics", "https://html.duckduckgo.com/html/?q=golang+generics", true},
+		{"one word", "gazer", "https://html.duckduckgo.com/html/?q=gazer", true},
+		{"http", "http://example.com/a?b=c", "http://example.com/a?b=c", true},
+		{"fragment", "example.com/a?b=c#d%20e", "https://example.com/a?b=c#d%20e", true},
+		{"file", "file:///tmp/a.html", "file:///tmp/a.html", true},
+		{"data", "data:text/html,<b>hi</b>", "data:text/html,<b>hi</b>", true},
+		{"gemini", "GEMINI://example.com/a b", "gemini://example.com/a%20b", true},
+		{"gopher", "gopher://example.com/7/search%09gazer", "gopher://example.com/7/search%09gazer", true},
+		{"about", "About:Blank", "about:blank", true},
+		{"about with query", "About:History?q=Gazer", "about:history?q=Gazer", true},
+		{"view source", "view-source:example.com/?q=1", "view-source:https://example.com/?q=1", true},
+		{"view source of data", "view-source:data:text/html,<p>", "view-source:data:text/html,<p>", true},
+		{"view source of view source", "view-source:view-source:example.com", "", false},
+		{"view source of about", "view-source:about:blank", "", false},
+		{"empty", "", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url, err := prepareUrl(test.url)
+			if !test.ok {
+				if err == nil {
+					t.Errorf("Expected error | Got: %v", url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error | Got: %v", err)
+			}
+			if url.String() != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, url.String())
+			}
+		})
+	}
+}
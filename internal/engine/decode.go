@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// content encodings we can decode, sent in Accept-Encoding header
+const acceptEncoding = "gzip, deflate, br"
+
+// how far we look for <meta charset> (same as the HTML spec)
+const prescanLength = 1024
+
+var (
+	metaTagRegex   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrRegex      = regexp.MustCompile(`(?is)([a-z-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	charsetRegex   = regexp.MustCompile(`(?i)charset\s*=\s*["']?([^\s"';]+)`)
+	byteOrderMarks = []struct {
+		bom     []byte
+		charset string
+	}{
+		{[]byte{0xEF, 0xBB, 0xBF}, "utf-8"},
+		{[]byte{0xFE, 0xFF}, "utf-16be"},
+		{[]byte{0xFF, 0xFE}, "utf-16le"},
+	}
+)
+
+// readContent reads everything from r and undo the Content-Encoding (e.g. "gzip")
+func readContent(r io.Reader, contentEncoding string) ([]byte, error) {
+	r, err := decodingReader(r, contentEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+	return content, nil
+}
+
+// decodingReader reads r with the Content-Encoding undone
+func decodingReader(r io.Reader, contentEncoding string) (io.Reader, error) {
+	// encodings are listed in the order they were applied, so undo them backward
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+		switch encoding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			gr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, fmt.Errorf("gzip.NewReader: %v", err)
+			}
+			r = gr
+		case "deflate":
+			r = newDeflateReader(r)
+		case "br":
+			r = brotli.NewReader(r)
+		default:
+			return nil, fmt.Errorf("Unsupported content encoding: %v", encoding)
+		}
+	}
+	return r, nil
+}
+
+// newDeflateReader reads "deflate" content which should be zlib wrapped,
+// but some servers send raw deflate stream, so we fall back to that.
+func newDeflateReader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(2)
+	// zlib header: CM = 8 and the header is multiple of 31
+	if len(header) == 2 && header[0]&0x0F == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		zr, err := zlib.NewReader(br)
+		if err == nil {
+			return zr
+		}
+	}
+	return flate.NewReader(br)
+}
+
+// decodeHtml converts the html content to UTF-8 string.
+// The charset is decided by (in order): BOM, charset from Content-Type header, <meta> prescan.
+// If there is none, it's UTF-8 if the content is valid UTF-8, otherwise Windows-1252.
+func decodeHtml(content []byte, headerCharset string) string {
+	if charset, rest, ok := sniffBom(content); ok {
+		return decodeCharset(rest, charset)
+	}
+	if isKnownCharset(headerCharset) {
+		return decodeCharset(content, headerCharset)
+	}
+	if charset := prescanCharset(content); charset != "" {
+		return decodeCharset(content, charset)
+	}
+	return decodeCharset(content, guessCharset(content))
+}
+
+// decodeText is decodeHtml for other text e.g. stylesheet, there is no <meta> to look at.
+func decodeText(content []byte, headerCharset string) string {
+	if charset, rest, ok := sniffBom(content); ok {
+		return decodeCharset(rest, charset)
+	}
+	if isKnownCharset(headerCharset) {
+		return decodeCharset(content, headerCharset)
+	}
+	return decodeCharset(content, guessCharset(content))
+}
+
+// sniffBom returns the charset the byte order mark says and the content without it
+func sniffBom(content []byte) (string, []byte, bool) {
+	for _, mark := range byteOrderMarks {
+		if bytes.HasPrefix(content, mark.bom) {
+			return mark.charset, content[len(mark.bom):], true
+		}
+	}
+	return "", content, false
+}
+
+// prescanCharset looks for <meta charset="..."> or
+// <meta http-equiv="content-type" content="...; charset=..."> at the start of the content.
+// It returns empty string if not found.
+func prescanCharset(content []byte) string {
+	head := content[:min(len(content), prescanLength)]
+	for _, tag := range metaTagRegex.FindAll(head, -1) {
+		attrs := make(map[string]string)
+		for _, match := range attrRegex.FindAllSubmatch(tag, -1) {
+			key := strings.ToLower(string(match[1]))
+			attrs[key] = strings.Trim(string(match[2]), `"'`)
+		}
+
+		charset := attrs["charset"]
+		if charset == "" && strings.EqualFold(attrs["http-equiv"], "content-type") {
+			if match := charsetRegex.FindStringSubmatch(attrs["content"]); match != nil {
+				charset = match[1]
+			}
+		}
+		if !isKnownCharset(charset) {
+			continue
+		}
+		// the page can't be UTF-16 if we can read its <meta> as ASCII
+		if strings.HasPrefix(strings.ToLower(charset), "utf-16") {
+			return "utf-8"
+		}
+		return charset
+	}
+	return ""
+}
+
+// guessCharset is for content that doesn't say its charset
+func guessCharset(content []byte) string {
+	if utf8.Valid(content) {
+		return "utf-8"
+	}
+	return "windows-1252"
+}
+
+func isKnownCharset(charset string) bool {
+	_, err := htmlindex.Get(charset)
+	return charset != "" && err == nil
+}
+
+// decodeCharset converts the content in the charset to UTF-8 string.
+// Unknown charset is treated as UTF-8.
+func decodeCharset(content []byte, charset string) string {
+	encoding, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(content)
+	}
+	if name, _ := htmlindex.Name(encoding); name == "utf-8" {
+		return string(content)
+	}
+	decoded, err := encoding.NewDecoder().Bytes(content)
+	if err != nil {
+		return string(content)
+	}
+	return string(decoded)
+}
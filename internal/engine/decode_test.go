@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	urlPkg "net/url"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const sample = "<p>Hello, Gazer!</p>"
+
+func compress(t *testing.T, encoding string, content []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			t.Fatalf("flate.NewWriter: %v", err)
+		}
+		w = fw
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		return content
+	}
+	w.Write(content)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestReadContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string // Content-Encoding header
+		content  []byte
+	}{
+		{"identity", "", []byte(sample)},
+		{"gzip", "gzip", compress(t, "gzip", []byte(sample))},
+		{"deflate", "deflate", compress(t, "deflate", []byte(sample))},
+		{"raw deflate", "deflate", compress(t, "raw-deflate", []byte(sample))},
+		{"brotli", "br", compress(t, "br", []byte(sample))},
+		{"gzip then brotli", "gzip, br", compress(t, "br", compress(t, "gzip", []byte(sample)))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readContent(bytes.NewReader(test.content), test.encoding)
+			if err != nil {
+				t.Fatalf("Expected no error | Got: %v", err)
+			}
+			if string(got) != sample {
+				t.Errorf("Expected: %v | Got: %v", sample, string(got))
+			}
+		})
+	}
+
+	if _, err := readContent(bytes.NewReader([]byte(sample)), "zstd"); err == nil {
+		t.Errorf("Expected error for unsupported encoding | Got: nil")
+	}
+}
+
+func encode(t *testing.T, text string, encoder interface{ Bytes([]byte) ([]byte, error) }) []byte {
+	res, err := encoder.Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return res
+}
+
+func TestDecodeHtml(t *testing.T) {
+	latin1Page := `<html><head><meta charset="iso-8859-1"></head><body><p>Café crème</p></body></html>`
+	httpEquivPage := `<html><head><meta http-equiv="Content-Type" content="text/html; charset=windows-1252"></head><body><p>“Quoted” – naïve</p></body></html>`
+	sjisPage := `<html><head><meta charset=shift_jis></head><body><p>こんにちは世界</p></body></html>`
+	noCharsetPage := `<p>Résumé</p>`
+	utf16Page := `<p>Grüße</p>`
+
+	utf16le := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()
+	utf16be := unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder()
+
+	tests := []struct {
+		name          string
+		content       []byte
+		headerCharset string
+		expected      string
+	}{
+		{"utf-8", []byte(sample), "", sample},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, sample...), "", sample},
+		{"header charset", encode(t, "<p>Ça va</p>", charmap.ISO8859_1.NewEncoder()), "ISO-8859-1", "<p>Ça va</p>"},
+		{"header over meta", encode(t, latin1Page, charmap.Windows1252.NewEncoder()), "windows-1252", latin1Page},
+		{"meta charset", encode(t, latin1Page, charmap.ISO8859_1.NewEncoder()), "", latin1Page},
+		{"meta http-equiv", encode(t, httpEquivPage, charmap.Windows1252.NewEncoder()), "", httpEquivPage},
+		{"shift_jis", encode(t, sjisPage, japanese.ShiftJIS.NewEncoder()), "", sjisPage},
+		{"iso-8859-2", encode(t, "<p>Łódź</p>", charmap.ISO8859_2.NewEncoder()), "iso-8859-2", "<p>Łódź</p>"},
+		{"utf-16le bom", encode(t, utf16Page, utf16le), "", utf16Page},
+		{"utf-16be bom", encode(t, utf16Page, utf16be), "", utf16Page},
+		{"bom over header", encode(t, utf16Page, utf16le), "iso-8859-1", utf16Page},
+		{"unknown header charset", []byte(sample), "no-such-charset", sample},
+		{"no charset latin-1", encode(t, noCharsetPage, charmap.Windows1252.NewEncoder()), "", noCharsetPage},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := decodeHtml(test.content, test.headerCharset)
+			if got != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestFetchDecoding(t *testing.T) {
+	page := `<p>Crème brûlée</p>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != acceptEncoding {
+			t.Errorf("Expected Accept-Encoding: %v | Got: %v", acceptEncoding, r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=ISO-8859-1")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compress(t, "gzip", encode(t, page, charmap.ISO8859_1.NewEncoder())))
+	}))
+	defer server.Close()
+
+	url, _ := urlPkg.Parse(server.URL)
+	resource, err := Fetch(context.Background(), *url)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	defer resource.Close()
+	if resource.Charset != "ISO-8859-1" || resource.Encoding != "gzip" {
+		t.Errorf("Expected: ISO-8859-1, gzip | Got: %v, %v", resource.Charset, resource.Encoding)
+	}
+
+	content, err := readContent(resource, resource.Encoding)
+	if err != nil {
+		t.Fatalf("readContent: %v", err)
+	}
+	if got := decodeHtml(content, resource.Charset); got != page {
+		t.Errorf("Expected: %v | Got: %v", page, got)
+	}
+}
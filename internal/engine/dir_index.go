@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"cmp"
+	"fmt"
+	urlPkg "net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// built-in page listing a local directory
+const dirIndexTemplate = `<html>
+<head><title>Index of %s</title></head>
+<body>
+<h1>Index of %s</h1>
+<table>
+<tr><th>%s</th><th>%s</th><th>%s</th></tr>
+%s</table>
+</body>
+</html>`
+
+const dirIndexRowTemplate = `<tr><td><a href="%s">%s</a></td><td>%s</td><td>%s</td></tr>` + "\n"
+
+// columns the directory index can be sorted by, it's the sort query of the url
+const (
+	sortByName     = "name"
+	sortBySize     = "size"
+	sortByModified = "modified"
+)
+
+// dirEntry is one row in the directory index
+type dirEntry struct {
+	name    string
+	isDir   bool
+	size    int64
+	modTime time.Time
+}
+
+// dirIndex builds the index page of the directory at url.
+// The url query decides the order: ?sort=name|size|modified&order=asc|desc
+func dirIndex(url *urlPkg.URL) (string, error) {
+	files, err := os.ReadDir(url.Path)
+	if err != nil {
+		return "", fmt.Errorf("os.ReadDir: %w", err)
+	}
+
+	entries := make([]dirEntry, 0, len(files))
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil {
+			continue // removed while we're reading
+		}
+		entries = append(entries, dirEntry{name: file.Name(), isDir: file.IsDir(), size: info.Size(), modTime: info.ModTime()})
+	}
+
+	query := url.Query()
+	sortBy, desc := query.Get("sort"), query.Get("order") == "desc"
+	if sortBy != sortBySize && sortBy != sortByModified {
+		sortBy = sortByName
+	}
+	sortDirEntries(entries, sortBy, desc)
+
+	var rows strings.Builder
+	if url.Path != "/" {
+		fmt.Fprintf(&rows, dirIndexRowTemplate, "../", "../", "", "")
+	}
+	for _, entry := range entries {
+		name, size := entry.name, formatSize(entry.size)
+		if entry.isDir {
+			name, size = name+"/", "-"
+		}
+		fmt.Fprintf(&rows, dirIndexRowTemplate, escapeText(entryHref(name)), escapeText(name), size,
+			entry.modTime.Format("2006-01-02 15:04"))
+	}
+
+	title := escapeText(url.Path)
+	return fmt.Sprintf(dirIndexTemplate, title, title,
+		sortHeader("Name", sortByName, sortBy, desc),
+		sortHeader("Size", sortBySize, sortBy, desc),
+		sortHeader("Modified", sortByModified, sortBy, desc),
+		rows.String()), nil
+}
+
+// sortDirEntries sorts the entries by the column, directories always come first
+func sortDirEntries(entries []dirEntry, sortBy string, desc bool) {
+	slices.SortStableFunc(entries, func(a, b dirEntry) int {
+		if a.isDir != b.isDir {
+			if a.isDir {
+				return -1
+			}
+			return 1
+		}
+
+		var res int
+		switch sortBy {
+		case sortBySize:
+			res = cmp.Compare(a.size, b.size)
+		case sortByModified:
+			res = a.modTime.Compare(b.modTime)
+		}
+		if res == 0 {
+			res = cmp.Compare(strings.ToLower(a.name), strings.ToLower(b.name))
+		}
+		if desc {
+			return -res
+		}
+		return res
+	})
+}
+
+// sortHeader is the header of a column, clicking it sorts by the column
+// or flips the order if it's already sorted by it
+func sortHeader(text, column, sortBy string, desc bool) string {
+	order := "asc"
+	if column == sortBy {
+		if desc {
+			text += " ▼"
+		} else {
+			text += " ▲"
+			order = "desc"
+		}
+	}
+	return fmt.Sprintf(`<a href="?sort=%s&order=%s">%s</a>`, column, order, text)
+}
+
+// entryHref is the relative link to the entry, "./" stops a name like "a:b" being taken as a scheme
+func entryHref(name string) string {
+	return "./" + (&urlPkg.URL{Path: name}).EscapedPath()
+}
+
+// formatSize shows the size in bytes like "12 B", "3.4 KB", "1.0 MB"
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exp := float64(size)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[exp])
+}
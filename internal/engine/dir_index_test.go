@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"context"
+	urlPkg "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// linkTexts collects the text of all <a> in the DOM tree in order
+func linkTexts(node *parser.Node) []string {
+	var res []string
+	if node.Tag == parser.A {
+		return []string{pageText(node)}
+	}
+	for _, child := range node.Children {
+		res = append(res, linkTexts(child)...)
+	}
+	return res
+}
+
+func TestDirIndex(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	files := []struct {
+		name    string
+		content string
+		age     time.Duration
+	}{
+		{"b.txt", "tiny", time.Hour},
+		{"A.md", "a bit longer", 2 * time.Hour},
+		{"c.html", "the longest of them all", 0},
+	}
+	for _, file := range files {
+		filePath := filepath.Join(dir, file.name)
+		if err := os.WriteFile(filePath, []byte(file.content), 0o644); err != nil {
+			t.Fatalf("os.WriteFile: %v", err)
+		}
+		os.Chtimes(filePath, now.Add(-file.age), now.Add(-file.age))
+	}
+	if err := os.Mkdir(filepath.Join(dir, "z docs"), 0o755); err != nil {
+		t.Fatalf("os.Mkdir: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string // names in order, directories come first
+	}{
+		{"default", "", []string{"z docs/", "A.md", "b.txt", "c.html"}},
+		{"name desc", "?sort=name&order=desc", []string{"z docs/", "c.html", "b.txt", "A.md"}},
+		{"size", "?sort=size", []string{"z docs/", "b.txt", "A.md", "c.html"}},
+		{"modified desc", "?sort=modified&order=desc", []string{"z docs/", "c.html", "b.txt", "A.md"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// without the trailing slash, it should get one
+			url, _ := urlPkg.Parse("file://" + filepath.ToSlash(dir) + test.query)
+			reporter := newProgressReporter(context.Background(), newTab(1), new(fakeWindow), "")
+			dom, finalUrl, err := getDom(context.Background(), *url, reporter)
+			if err != nil {
+				t.Fatalf("Expected no error | Got: %v", err)
+			}
+			if !strings.HasSuffix(finalUrl.Path, "/") {
+				t.Errorf("Expected trailing slash | Got: %v", finalUrl)
+			}
+			if dom.Kind != HtmlDocument {
+				t.Errorf("Expected: %v | Got: %v", HtmlDocument, dom.Kind)
+			}
+
+			// first 3 links are the headers, then the parent directory
+			links := linkTexts(dom.Root)
+			if len(links) < 4 || links[3] != "../" {
+				t.Fatalf("Expected headers and ../ | Got: %v", links)
+			}
+			got := strings.Join(links[4:], ",")
+			if got != strings.Join(test.expected, ",") {
+				t.Errorf("Expected: %v | Got: %v", strings.Join(test.expected, ","), got)
+			}
+		})
+	}
+}
+
+func TestDirIndexLinks(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a:b #1.txt"), nil, 0o644)
+
+	url, _ := urlPkg.Parse("file://" + filepath.ToSlash(dir) + "/")
+	index, err := dirIndex(url)
+	if err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	// the link resolves back to the file, not a scheme or a fragment
+	expected := url.String() + "a:b%20%231.txt"
+	target, err := ResolveJumpTarget("./a:b%20%231.txt", url.String())
+	if err != nil || target != expected || !strings.Contains(index, `href="./a:b%20%231.txt"`) {
+		t.Errorf("Expected: %v | Got: %v %v", expected, target, err)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:                 "0 B",
+		1023:              "1023 B",
+		1024:              "1.0 KB",
+		1536:              "1.5 KB",
+		5 * 1024 * 1024:   "5.0 MB",
+		3 << 30:           "3.0 GB",
+		2 << 40:           "2.0 TB",
+		(2 << 40) * 10000: "20000.0 TB",
+	}
+	for size, expected := range tests {
+		if got := formatSize(size); got != expected {
+			t.Errorf("Expected: %v | Got: %v", expected, got)
+		}
+	}
+}
+
+func TestTypeByExtension(t *testing.T) {
+	tests := map[string]string{
+		"/docs/README.md":   "text/markdown",
+		"/docs/INDEX.HTML":  "text/html",
+		"/docs/app.js":      "text/javascript",
+		"/docs/logo.png":    "image/png",
+		"/docs/Makefile":    "",
+		"/docs/archive.tgz": typeByExtension("/x.tgz"), // whatever the system says
+	}
+	for filePath, expected := range tests {
+		if got := typeByExtension(filePath); got != expected {
+			t.Errorf("Expected: %v | Got: %v", expected, got)
+		}
+	}
+
+	// .md is converted from markdown, not parsed as html
+	dir := t.TempDir()
+	mdPath := filepath.Join(dir, "notes.md")
+	os.WriteFile(mdPath, []byte("# Notes\n\n- *one*"), 0o644)
+	url, _ := urlPkg.Parse("file://" + filepath.ToSlash(mdPath))
+	reporter := newProgressReporter(context.Background(), newTab(1), new(fakeWindow), "")
+	dom, _, err := getDom(context.Background(), *url, reporter)
+	if err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	if dom.Kind != MarkdownDocument {
+		t.Errorf("Expected: %v | Got: %v", MarkdownDocument, dom.Kind)
+	}
+	if got := pageText(dom.Root); got != "notes.mdNotesone" {
+		t.Errorf("Expected: notes.mdNotesone | Got: %v", got)
+	}
+}
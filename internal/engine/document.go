@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	urlPkg "net/url"
+	"path"
+	"strings"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// DocumentKind tells which viewer should show the document
+type DocumentKind uint8
+
+const (
+	HtmlDocument       DocumentKind = iota
+	TextDocument                    // plain text, shown as it is in monospace
+	SourceDocument                  // CSS, JavaScript, shown as source code with line numbers
+	ImageDocument                   // standalone image, shown centered with zoom
+	JsonDocument                    // pretty-printed with collapsible nodes
+	ViewSourceDocument              // source of html at view-source:<url>, highlighted with clickable links
+	MarkdownDocument                // converted to DOM, then shown like html
+	GeminiDocument                  // text/gemini, converted to DOM like markdown
+	GopherMenuDocument              // converted to DOM, a line of links per item
+)
+
+// documentKind maps the media type to the document kind, false if we can't show it
+func documentKind(mediaType string) (DocumentKind, bool) {
+	switch mediaType {
+	case "text/html", "application/xhtml+xml":
+		return HtmlDocument, true
+	case "text/markdown", "text/x-markdown":
+		return MarkdownDocument, true
+	case "text/gemini":
+		return GeminiDocument, true
+	case gopherMenuType:
+		return GopherMenuDocument, true
+	case "application/json", "text/json":
+		return JsonDocument, true
+	case "text/css", "text/javascript", "application/javascript",
+		"application/x-javascript", "application/ecmascript", "text/ecmascript":
+		return SourceDocument, true
+	case "image/png", "image/jpeg", "image/jpg", "image/gif":
+		return ImageDocument, true
+	case "application/xml":
+		return TextDocument, true
+	}
+
+	if strings.HasSuffix(mediaType, "+json") {
+		return JsonDocument, true
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+xml") {
+		return TextDocument, true
+	}
+	return 0, false
+}
+
+// documentRoot builds the DOM of a non-html document, it's just for the tab title
+func documentRoot(title string) *parser.Node {
+	html := fmt.Sprintf("<html><head><title>%s</title></head><body></body></html>", escapeText(title))
+	root, err := parser.Parse(html)
+	if err != nil {
+		log.Println("documentRoot: parser.Parse:", err)
+		return nil
+	}
+	return root
+}
+
+// documentTitle names a non-html document by its file name like other browsers do
+func documentTitle(url *urlPkg.URL) string {
+	// gopher search words come after a tab, they aren't part of the name
+	filePath, _, _ := strings.Cut(url.Path, "\t")
+	name := path.Base(filePath)
+	if name == "/" || name == "." {
+		return url.Host
+	}
+	return strings.TrimSpace(name)
+}
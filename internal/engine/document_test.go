@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	urlPkg "net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSniffContentType(t *testing.T) {
+	var pngContent bytes.Buffer
+	png.Encode(&pngContent, image.NewGray(image.Rect(0, 0, 1, 1)))
+
+	tests := []struct {
+		name     string
+		declared string
+		content  []byte
+		noSniff  bool
+		expected string
+	}{
+		{"declared html", "text/html", []byte("just text"), false, "text/html"},
+		{"missing html", "", []byte("<!DOCTYPE html><p>hi</p>"), false, "text/html"},
+		{"missing text", "", []byte("2025-01-01 INFO started\n"), false, "text/plain"},
+		{"missing json", "", []byte(`  {"ok": true}`), false, "application/json"},
+		{"missing broken json", "", []byte(`{"ok": tru`), false, "text/plain"},
+		{"unknown png", "application/unknown", pngContent.Bytes(), false, "image/png"},
+		{"text plain binary", "text/plain", []byte{0, 1, 2, 3}, false, "application/octet-stream"},
+		{"text plain json", "text/plain", []byte(`{"a": 1}`), false, "text/plain"},
+		{"wrong image type", "image/jpeg", pngContent.Bytes(), false, "image/png"},
+		{"nosniff", "text/plain", []byte{0, 1, 2, 3}, true, "text/plain"},
+		{"declared json", "application/json", []byte(`[1, 2]`), false, "application/json"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := sniffContentType(test.declared, test.content, test.noSniff)
+			if got != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestDocumentKind(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		expected  DocumentKind
+		ok        bool
+	}{
+		{"text/html", HtmlDocument, true},
+		{"text/plain", TextDocument, true},
+		{"text/csv", TextDocument, true},
+		{"image/svg+xml", TextDocument, true},
+		{"text/markdown", MarkdownDocument, true},
+		{"text/x-markdown", MarkdownDocument, true},
+		{"application/gopher-menu", GopherMenuDocument, true},
+		{"text/css", SourceDocument, true},
+		{"application/javascript", SourceDocument, true},
+		{"application/json", JsonDocument, true},
+		{"application/problem+json", JsonDocument, true},
+		{"image/gif", ImageDocument, true},
+		{"application/pdf", 0, false},
+		{"application/octet-stream", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mediaType, func(t *testing.T) {
+			got, ok := documentKind(test.mediaType)
+			if got != test.expected || ok != test.ok {
+				t.Errorf("Expected: %v, %v | Got: %v, %v", test.expected, test.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestParseJson(t *testing.T) {
+	root, err := parseJson(`{"name": "Gazer", "tags": ["go", "gio"], "stars": 3.5, "fork": null, "ok": true}`)
+	if err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	if root.Kind != JsonObject || len(root.Children) != 5 {
+		t.Fatalf("Expected object with 5 children | Got: %v with %d", root.Kind, len(root.Children))
+	}
+
+	// keys keep the document order
+	expected := []struct {
+		key   string
+		kind  JsonKind
+		value string
+	}{
+		{"name", JsonValue, `"Gazer"`},
+		{"tags", JsonArray, ""},
+		{"stars", JsonValue, "3.5"},
+		{"fork", JsonValue, "null"},
+		{"ok", JsonValue, "true"},
+	}
+	for i, child := range root.Children {
+		if child.Key != expected[i].key || child.Kind != expected[i].kind || child.Value != expected[i].value {
+			t.Errorf("Expected: %v | Got: %v %v %v", expected[i], child.Key, child.Kind, child.Value)
+		}
+	}
+	if tags := root.Children[1]; len(tags.Children) != 2 || tags.Children[1].Value != `"gio"` {
+		t.Errorf("Expected tags [go gio] | Got: %v", tags.Children)
+	}
+
+	for _, invalid := range []string{`{"a": }`, `[1, 2`, `{} {}`} {
+		if _, err := parseJson(invalid); err == nil {
+			t.Errorf("Expected error for %q | Got: nil", invalid)
+		}
+	}
+}
+
+func TestGetDom(t *testing.T) {
+	var pngContent bytes.Buffer
+	png.Encode(&pngContent, image.NewGray(image.Rect(0, 0, 1, 1)))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id": 1}`)
+	})
+	mux.HandleFunc("/style.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		io.WriteString(w, "p { color: red; }")
+	})
+	mux.HandleFunc("/logo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pngContent.Bytes()) // no content type
+	})
+	mux.HandleFunc("/data.bin", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte{0, 1, 2})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "server.log")
+	if err := os.WriteFile(logPath, []byte("line 1\nline 2\n"), 0o644); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		url   string
+		kind  DocumentKind
+		title string
+	}{
+		{"json api", server.URL + "/api/user", JsonDocument, "user"},
+		{"stylesheet", server.URL + "/style.css", SourceDocument, "style.css"},
+		{"image", server.URL + "/logo", ImageDocument, "logo"},
+		{"log file", "file://" + logPath, TextDocument, "server.log"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url, _ := urlPkg.Parse(test.url)
+			reporter := newProgressReporter(context.Background(), newTab(1), new(fakeWindow), url.Host)
+			dom, _, err := getDom(context.Background(), *url, reporter)
+			if err != nil {
+				t.Fatalf("Expected no error | Got: %v", err)
+			}
+			if dom.Kind != test.kind {
+				t.Errorf("Expected kind: %v | Got: %v", test.kind, dom.Kind)
+			}
+			head := findHead(dom.Root)
+			if head == nil || head.Children[0].Children[0].Inner != test.title {
+				t.Errorf("Expected title: %v", test.title)
+			}
+		})
+	}
+
+	url, _ := urlPkg.Parse(server.URL + "/data.bin")
+	reporter := newProgressReporter(context.Background(), newTab(1), new(fakeWindow), url.Host)
+	_, _, err := getDom(context.Background(), *url, reporter)
+	// not something to show, it's a file to download
+	var download *downloadResponse
+	if !errors.As(err, &download) {
+		t.Fatalf("Expected: downloadResponse | Got: %v", err)
+	}
+	if download.name != "data.bin" {
+		t.Errorf("Expected: data.bin | Got: %v", download.name)
+	}
+}
@@ -0,0 +1,632 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	urlPkg "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DownloadID is a stable identity of a download, like TabID
+type DownloadID uint64
+
+type DownloadStatus uint8
+
+const (
+	Downloading DownloadStatus = iota
+	Paused
+	Completed
+	Failed // can be resumed, it continues from what we have
+	Cancelled
+)
+
+// download history lives in the data directory
+const downloadsFile = "downloads.json"
+
+// Download is what the client sees of a download
+type Download struct {
+	ID        DownloadID     `json:"id"`
+	Url       string         `json:"url"`
+	FilePath  string         `json:"file_path"` // where the file is when completed
+	Received  int64          `json:"received"`
+	Total     int64          `json:"total"` // -1 if unknown
+	Status    DownloadStatus `json:"status"`
+	Err       string         `json:"error,omitempty"` // why it failed
+	StartedAt time.Time      `json:"started_at"`
+}
+
+// Name is the file name of the download
+func (d Download) Name() string {
+	return filepath.Base(d.FilePath)
+}
+
+// Fraction returns how much is downloaded between 0 and 1, 0 if the size is unknown
+func (d Download) Fraction() float32 {
+	if d.Status == Completed {
+		return 1
+	}
+	if d.Total <= 0 {
+		return 0
+	}
+	return min(float32(d.Received)/float32(d.Total), 1)
+}
+
+// StatusText tells how the download is going e.g. "Paused, 1.2 MB of 3.4 MB"
+func (d Download) StatusText() string {
+	size := formatBytes(d.Received)
+	if d.Total > 0 {
+		size = fmt.Sprintf("%s of %s", size, formatBytes(d.Total))
+	}
+	switch d.Status {
+	case Downloading:
+		return size
+	case Paused:
+		return "Paused, " + size
+	case Completed:
+		return "Done, " + formatBytes(d.Received)
+	case Failed:
+		return "Failed: " + d.Err
+	default:
+		return "Cancelled"
+	}
+}
+
+// download is the engine-side state of a download, its fields are guarded by State.mu
+type download struct {
+	Download
+	Validator string `json:"validator,omitempty"` // ETag or Last-Modified of the content we have
+
+	cancel         context.CancelFunc // stops the running worker, nil if there is none
+	done           chan struct{}      // closed when the latest worker is done with the file
+	lastInvalidate time.Time
+}
+
+// downloadResponse is returned by getDom (as the error) when the response is a file to save
+// instead of a page to show
+type downloadResponse struct {
+	url       string
+	name      string // file name to save as
+	total     int64  // -1 if unknown
+	validator string
+	// content with the Content-Encoding undone, if getDom has read it already.
+	// Otherwise the download fetches it again, on its own context and without the page timeout.
+	content []byte
+}
+
+func (d *downloadResponse) Error() string {
+	return fmt.Sprintf("%v is a file to download", d.url)
+}
+
+// readCloser reads from Reader but closes Closer e.g. decoded body of a resource
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// newDownloadResponse describes the download of resource, content is what getDom has read
+// from it, nil if nothing
+func newDownloadResponse(resource *Resource, content []byte) *downloadResponse {
+	res := &downloadResponse{
+		url:       resource.Url.String(),
+		name:      downloadName(resource),
+		total:     resource.Length,
+		validator: resource.Validator,
+		content:   content,
+	}
+	if content != nil {
+		res.total = int64(len(content))
+	} else if !isIdentity(resource.Encoding) {
+		res.total = -1 // Length is the encoded size, we count the decoded bytes
+	}
+	return res
+}
+
+// downloads can take long, so only waiting for the server to answer is timed out
+var downloadClient = newDownloadClient()
+
+func newDownloadClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = settings.RequestTimeout
+	return &http.Client{Transport: transport, CheckRedirect: checkRedirect}
+}
+
+func isIdentity(contentEncoding string) bool {
+	encoding := strings.ToLower(strings.TrimSpace(contentEncoding))
+	return encoding == "" || encoding == "identity"
+}
+
+// downloadName is the file name the server suggests, or the last part of the url
+func downloadName(resource *Resource) string {
+	name := sanitizeFilename(resource.Filename)
+	if name == "" {
+		name = sanitizeFilename(path.Base(resource.Url.Path))
+	}
+	if name == "" {
+		name = "download"
+	}
+	if filepath.Ext(name) == "" {
+		if exts, _ := mime.ExtensionsByType(resource.ContentType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return name
+}
+
+// sanitizeFilename makes the name safe to be a file name in the download directory,
+// empty if nothing is left
+func sanitizeFilename(name string) string {
+	// never let the server pick the directory
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	return strings.Trim(name, " .")
+}
+
+// partPath is where the file is while downloading
+func partPath(filePath string) string {
+	return filePath + ".part"
+}
+
+// responseValidator is what identifies the content for If-Range, empty if nothing does
+func responseValidator(header http.Header) string {
+	// weak ETag can't be used for ranges
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+// startDownload saves the response into the download directory in the background
+func (s *State) startDownload(response *downloadResponse) {
+	if err := os.MkdirAll(settings.DownloadDir, 0o755); err != nil {
+		log.Println("startDownload: os.MkdirAll:", err)
+	}
+
+	s.mu.Lock()
+	s.nextDownloadId++
+	d := &download{
+		Download: Download{
+			ID:        s.nextDownloadId,
+			Url:       response.url,
+			FilePath:  s.uniqueDownloadPath(response.name),
+			Total:     response.total,
+			Status:    Downloading,
+			StartedAt: time.Now(),
+		},
+		Validator: response.validator,
+	}
+	ctx, done := s.newDownloadWorker(d)
+	s.downloads = append(s.downloads, d)
+	s.events = append(s.events, Event{Type: DownloadStarted, Url: d.Url})
+	s.mu.Unlock()
+
+	s.saveDownloads()
+	s.invalidate()
+	go s.runDownload(ctx, d, nil, done, response.content)
+}
+
+// saveUrl downloads what's at the url, even a page the tab would show.
+// The download fetches it again, this only asks for its name and size.
+func (s *State) saveUrl(rawUrl string) {
+	url, err := prepareUrl(rawUrl)
+	if err != nil {
+		log.Println("saveUrl: prepareUrl:", err)
+		return
+	}
+	resource, err := Fetch(context.Background(), *url)
+	if err != nil {
+		log.Println("saveUrl: Fetch:", err)
+		return
+	}
+	resource.Close()
+	s.startDownload(newDownloadResponse(resource, nil))
+}
+
+// pauseDownload stops the download, keeping what we have for resuming
+func (s *State) pauseDownload(id DownloadID) {
+	s.mu.Lock()
+	d := s.download(id)
+	if d == nil || d.Status != Downloading {
+		s.mu.Unlock()
+		return
+	}
+	d.Status = Paused
+	d.cancel()
+	s.mu.Unlock()
+
+	s.saveDownloads()
+	s.invalidate()
+}
+
+// resumeDownload continues paused or failed download from where it stopped
+func (s *State) resumeDownload(id DownloadID) {
+	s.mu.Lock()
+	d := s.download(id)
+	if d == nil || (d.Status != Paused && d.Status != Failed) {
+		s.mu.Unlock()
+		return
+	}
+	d.Status = Downloading
+	d.Err = ""
+	prev := d.done
+	ctx, done := s.newDownloadWorker(d)
+	s.mu.Unlock()
+
+	s.saveDownloads()
+	s.invalidate()
+	go s.runDownload(ctx, d, prev, done, nil)
+}
+
+// cancelDownload stops the download and throws away what we have
+func (s *State) cancelDownload(id DownloadID) {
+	s.mu.Lock()
+	d := s.download(id)
+	if d == nil || d.Status == Completed || d.Status == Cancelled {
+		s.mu.Unlock()
+		return
+	}
+	d.Status = Cancelled
+	if d.cancel != nil {
+		d.cancel()
+	}
+	done := d.done
+	s.mu.Unlock()
+
+	// the worker might be writing the last bytes
+	if done != nil {
+		<-done
+	}
+	if err := os.Remove(partPath(d.FilePath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Println("cancelDownload: os.Remove:", err)
+	}
+	s.saveDownloads()
+	s.invalidate()
+}
+
+// clearDownloads forgets the downloads that are over (completed, failed and cancelled)
+func (s *State) clearDownloads() {
+	s.mu.Lock()
+	var kept []*download
+	var failed []string // their part files are never going to be used
+	for _, d := range s.downloads {
+		switch d.Status {
+		case Downloading, Paused:
+			kept = append(kept, d)
+		case Failed:
+			failed = append(failed, partPath(d.FilePath))
+		}
+	}
+	s.downloads = kept
+	s.mu.Unlock()
+
+	for _, part := range failed {
+		os.Remove(part)
+	}
+	s.saveDownloads()
+	s.invalidate()
+}
+
+// newDownloadWorker sets up the context and done channel of a new worker of d
+// requires: s.mu is locked
+func (s *State) newDownloadWorker(d *download) (context.Context, chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.done = make(chan struct{})
+	return ctx, d.done
+}
+
+// runDownload writes content (or the rest of the file fetched from where d stopped, if content is nil)
+// to the part file of d then moves it to the final path.
+// The worker waits for the previous one (prev, if any) to be done with the file first.
+func (s *State) runDownload(ctx context.Context, d *download, prev, done chan struct{}, content []byte) {
+	defer close(done)
+	if prev != nil {
+		<-prev
+	}
+	if content != nil {
+		s.finishDownload(ctx, d, s.writeDownload(ctx, d, bytes.NewReader(content), 0))
+		return
+	}
+
+	s.mu.RLock()
+	url, offset, validator := d.Url, d.Received, d.Validator
+	s.mu.RUnlock()
+
+	body, offset, total, validator, err := fetchRest(ctx, url, offset, validator)
+	if err != nil {
+		s.finishDownload(ctx, d, err)
+		return
+	}
+	defer body.Close()
+	s.mu.Lock()
+	d.Received, d.Total, d.Validator = offset, total, validator
+	s.mu.Unlock()
+
+	s.finishDownload(ctx, d, s.writeDownload(ctx, d, body, offset))
+}
+
+// writeDownload writes body to the part file of d starting at offset
+func (s *State) writeDownload(ctx context.Context, d *download, body io.Reader, offset int64) error {
+	file, err := os.OpenFile(partPath(d.FilePath), os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %v", err)
+	}
+	defer file.Close()
+	if err := file.Truncate(offset); err != nil {
+		return fmt.Errorf("file.Truncate: %v", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("file.Seek: %v", err)
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, err := file.Write(buf[:n]); err != nil {
+				return fmt.Errorf("file.Write: %v", err)
+			}
+			s.downloadReceived(d, int64(n))
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// downloadReceived counts the bytes and let the window redraw (not too often)
+func (s *State) downloadReceived(d *download, n int64) {
+	s.mu.Lock()
+	d.Received += n
+	now := time.Now()
+	shouldInvalidate := now.Sub(d.lastInvalidate) >= progressInvalidateInterval
+	if shouldInvalidate {
+		d.lastInvalidate = now
+	}
+	s.mu.Unlock()
+
+	if shouldInvalidate {
+		s.invalidate()
+	}
+}
+
+// finishDownload marks d as completed (moving the part file to its place) or failed by err.
+// Worker whose context is cancelled has been paused or cancelled, that's already taken care of.
+func (s *State) finishDownload(ctx context.Context, d *download, err error) {
+	s.mu.Lock()
+	if ctx.Err() != nil {
+		s.mu.Unlock()
+		return
+	}
+	if err == nil {
+		err = os.Rename(partPath(d.FilePath), d.FilePath)
+	}
+	if err != nil {
+		log.Println("download:", err)
+		d.Status = Failed
+		d.Err = err.Error()
+	} else {
+		d.Status = Completed
+		d.Total = d.Received
+	}
+	d.cancel()
+	d.cancel = nil
+	s.mu.Unlock()
+
+	s.saveDownloads()
+	s.invalidate()
+}
+
+// fetchRest fetches the content of rawUrl from offset on, with If-Range so we don't mix up
+// 2 versions of the file. It returns the body, where the body starts (0 if the server
+// sends everything), the total size and the validator of what it sends.
+func fetchRest(ctx context.Context, rawUrl string, offset int64, validator string) (io.ReadCloser, int64, int64, string, error) {
+	url, err := urlPkg.Parse(rawUrl)
+	if err != nil {
+		return nil, 0, 0, "", fmt.Errorf("url.Parse: %v", err)
+	}
+	if url.Scheme != "http" && url.Scheme != "https" {
+		// no ranges, everything again
+		resource, err := Fetch(ctx, *url)
+		if err != nil {
+			return nil, 0, 0, "", fmt.Errorf("Fetch: %w", err)
+		}
+		body, err := decodingReader(resource, resource.Encoding)
+		if err != nil {
+			resource.Close()
+			return nil, 0, 0, "", fmt.Errorf("decodingReader: %w", err)
+		}
+		response := newDownloadResponse(resource, nil)
+		return readCloser{body, resource}, 0, response.total, response.validator, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
+	if err != nil {
+		return nil, 0, 0, "", fmt.Errorf("http.NewRequestWithContext: %v", err)
+	}
+	req.Header.Set("User-Agent", settings.UserAgent)
+	// ranges count the bytes of the file, so it must come unencoded
+	req.Header.Set("Accept-Encoding", "identity")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+	}
+
+	res, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, 0, 0, "", newFetchError(rawUrl, fmt.Errorf("client.Do: %w", err))
+	}
+	switch {
+	case res.StatusCode == http.StatusPartialContent && offset > 0:
+		start, total, ok := parseContentRange(res.Header.Get("Content-Range"))
+		if ok && start == offset {
+			return res.Body, offset, total, validator, nil
+		}
+		res.Body.Close()
+		return fetchRest(ctx, rawUrl, 0, "")
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// what we have doesn't fit the file anymore, start over
+		res.Body.Close()
+		return fetchRest(ctx, rawUrl, 0, "")
+	case res.StatusCode >= 400:
+		res.Body.Close()
+		return nil, 0, 0, "", &FetchError{Kind: HttpError, Url: rawUrl, StatusCode: res.StatusCode}
+	}
+
+	// the whole file, from the start (server ignored the range or the file has changed)
+	encoding := res.Header.Get("Content-Encoding")
+	body, err := decodingReader(res.Body, encoding)
+	if err != nil {
+		res.Body.Close()
+		return nil, 0, 0, "", fmt.Errorf("decodingReader: %w", err)
+	}
+	total := res.ContentLength
+	if !isIdentity(encoding) {
+		total = -1
+	}
+	return readCloser{body, res.Body}, 0, total, responseValidator(res.Header), nil
+}
+
+// parseContentRange parses "bytes <start>-<end>/<total>", total is -1 if it's "*"
+func parseContentRange(value string) (int64, int64, bool) {
+	value, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+	byteRange, rawTotal, ok := strings.Cut(value, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	rawStart, _, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(rawStart), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	total := int64(-1)
+	if rawTotal != "*" {
+		if total, err = strconv.ParseInt(strings.TrimSpace(rawTotal), 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, total, true
+}
+
+// uniqueDownloadPath is the path of name in the download directory that no file
+// or other download has, e.g. "report (1).pdf" if "report.pdf" is taken
+// requires: s.mu is locked
+func (s *State) uniqueDownloadPath(name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 0; ; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+		}
+		filePath := filepath.Join(settings.DownloadDir, candidate)
+		if !s.downloadPathTaken(filePath) && !fileExists(filePath) && !fileExists(partPath(filePath)) {
+			return filePath
+		}
+	}
+}
+
+// downloadPathTaken tells if an unfinished download is going to be at filePath
+// requires: s.mu is locked
+func (s *State) downloadPathTaken(filePath string) bool {
+	for _, d := range s.downloads {
+		if d.FilePath == filePath && d.Status != Completed && d.Status != Cancelled {
+			return true
+		}
+	}
+	return false
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// download returns the download with the id, nil if not found
+// requires: s.mu is locked
+func (s *State) download(id DownloadID) *download {
+	for _, d := range s.downloads {
+		if d.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+// saveDownloads writes the download history to the data directory
+func (s *State) saveDownloads() {
+	path := dataFile(downloadsFile)
+	if path == "" {
+		return
+	}
+	// one writer at a time, so the file ends up with the latest history
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.RLock()
+	content, err := json.MarshalIndent(s.downloads, "", "  ")
+	s.mu.RUnlock()
+	if err != nil {
+		log.Println("saveDownloads: json.MarshalIndent:", err)
+		return
+	}
+	if err := writeFileAtomic(path, content); err != nil {
+		log.Println("saveDownloads:", err)
+	}
+}
+
+// loadDownloads reads the download history from the data directory.
+// Downloads that were running when Gazer quit are paused.
+func loadDownloads() []*download {
+	path := dataFile(downloadsFile)
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Println("loadDownloads: os.ReadFile:", err)
+		}
+		return nil
+	}
+	var downloads []*download
+	if err := json.Unmarshal(content, &downloads); err != nil {
+		log.Println("loadDownloads: json.Unmarshal:", err)
+		return nil
+	}
+	for _, d := range downloads {
+		if d.Status == Downloading {
+			d.Status = Paused
+		}
+	}
+	return downloads
+}
@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	urlPkg "net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startDownloadOf visits the url like a tab does, expecting it to be a download
+func startDownloadOf(t *testing.T, state *State, rawUrl string) DownloadID {
+	url, _ := urlPkg.Parse(rawUrl)
+	reporter := newProgressReporter(context.Background(), newTab(1), new(fakeWindow), url.Host)
+	_, _, err := getDom(context.Background(), *url, reporter)
+	var download *downloadResponse
+	if !errors.As(err, &download) {
+		t.Fatalf("Expected: downloadResponse | Got: %v", err)
+	}
+	state.startDownload(download)
+	downloads := state.Snapshot().Downloads
+	return downloads[len(downloads)-1].ID
+}
+
+// waitDownload waits until the download satisfies ok and returns it
+func waitDownload(t *testing.T, state *State, id DownloadID, ok func(d Download) bool) Download {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		for _, d := range state.Snapshot().Downloads {
+			if d.ID == id && ok(d) {
+				return d
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected: download %v to get there | Got: %+v", id, state.Snapshot().Downloads)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func hasStatus(status DownloadStatus) func(d Download) bool {
+	return func(d Download) bool { return d.Status == status }
+}
+
+func TestDownload(t *testing.T) {
+	dir := useTempDataDirs(t)
+	content := bytes.Repeat([]byte("gazer"), 1000)
+	half := len(content) / 2
+	var mu sync.Mutex
+	var ranges []string // Range of the requests to /big.zip
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `attachment; filename="../report.pdf"`)
+		w.Write(content)
+	})
+	mux.HandleFunc("/big.zip", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("Range") != "" {
+			http.ServeContent(w, r, "big.zip", time.Time{}, bytes.NewReader(content))
+			return
+		}
+		// send half of it then hang until the client gives up
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write(content[:half])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	state := NewState()
+
+	// server picks the name, without the directory part
+	id := startDownloadOf(t, state, server.URL+"/report")
+	d := waitDownload(t, state, id, hasStatus(Completed))
+	if d.FilePath != filepath.Join(dir, "report.pdf") || d.Received != int64(len(content)) {
+		t.Errorf("Expected: %v %v | Got: %v %v", filepath.Join(dir, "report.pdf"), len(content), d.FilePath, d.Received)
+	}
+	if saved, _ := os.ReadFile(d.FilePath); !bytes.Equal(saved, content) {
+		t.Errorf("Expected: %v bytes | Got: %v bytes", len(content), len(saved))
+	}
+
+	// same name again doesn't overwrite
+	id = startDownloadOf(t, state, server.URL+"/report")
+	if d := waitDownload(t, state, id, hasStatus(Completed)); d.FilePath != filepath.Join(dir, "report (1).pdf") {
+		t.Errorf("Expected: %v | Got: %v", filepath.Join(dir, "report (1).pdf"), d.FilePath)
+	}
+
+	// pause keeps what we have, resume asks for the rest
+	id = startDownloadOf(t, state, server.URL+"/big.zip")
+	waitDownload(t, state, id, func(d Download) bool { return d.Received == int64(half) })
+	state.pauseDownload(id)
+	d = waitDownload(t, state, id, hasStatus(Paused))
+	if part, _ := os.ReadFile(partPath(d.FilePath)); !bytes.Equal(part, content[:half]) {
+		t.Errorf("Expected: %v bytes in the part file | Got: %v bytes", half, len(part))
+	}
+	state.resumeDownload(id)
+	d = waitDownload(t, state, id, hasStatus(Completed))
+	if saved, _ := os.ReadFile(d.FilePath); !bytes.Equal(saved, content) {
+		t.Errorf("Expected: %v bytes | Got: %v bytes", len(content), len(saved))
+	}
+	// the visit, the download and the resume
+	mu.Lock()
+	if expected := "bytes=" + strconv.Itoa(half) + "-"; len(ranges) != 3 || ranges[2] != expected {
+		t.Errorf("Expected: %v | Got: %v", expected, ranges)
+	}
+	mu.Unlock()
+	if fileExists(partPath(d.FilePath)) {
+		t.Errorf("Expected: no part file after completed")
+	}
+
+	// cancel throws the part file away
+	id = startDownloadOf(t, state, server.URL+"/big.zip")
+	d = waitDownload(t, state, id, func(d Download) bool { return d.Received == int64(half) })
+	state.cancelDownload(id)
+	d = waitDownload(t, state, id, hasStatus(Cancelled))
+	if fileExists(partPath(d.FilePath)) || fileExists(d.FilePath) {
+		t.Errorf("Expected: no file after cancelled")
+	}
+
+	// history is kept for the next start, unfinished one is paused
+	paused := startDownloadOf(t, state, server.URL+"/big.zip")
+	waitDownload(t, state, paused, func(d Download) bool { return d.Received == int64(half) })
+	loaded := NewState().Snapshot().Downloads
+	if len(loaded) != 5 || loaded[4].Status != Paused || loaded[0].Status != Completed {
+		t.Fatalf("Expected: 5 downloads, last one paused | Got: %+v", loaded)
+	}
+	state.cancelDownload(paused)
+
+	// clear forgets the finished ones
+	state.clearDownloads()
+	if downloads := state.Snapshot().Downloads; len(downloads) != 0 {
+		t.Errorf("Expected: no downloads | Got: %+v", downloads)
+	}
+}
+
+func TestSaveUrl(t *testing.T) {
+	dir := useTempDataDirs(t)
+	content := "<html><head><title>Page</title></head><body>Save me</body></html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	state := NewState()
+	startEngine(t, state)
+	// a page the tab would show is saved too
+	state.Notifier <- Notification{Type: SaveUrl, Url: server.URL + "/page.html"}
+	deadline := time.Now().Add(3 * time.Second)
+	for len(state.Snapshot().Downloads) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected: a download | Got: none")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	d := waitDownload(t, state, state.Snapshot().Downloads[0].ID, hasStatus(Completed))
+	if d.FilePath != filepath.Join(dir, "page.html") {
+		t.Errorf("Expected: %v | Got: %v", filepath.Join(dir, "page.html"), d.FilePath)
+	}
+	if saved, _ := os.ReadFile(d.FilePath); string(saved) != content {
+		t.Errorf("Expected: %v | Got: %v", content, string(saved))
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Windows\evil.exe`, "evil.exe"},
+		{"what?.zip", "what_.zip"},
+		{" .hidden ", "hidden"},
+		{"..", ""},
+		{"/", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sanitizeFilename(test.name); got != test.expected {
+				t.Errorf("Expected: %q | Got: %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value string
+		start int64
+		total int64
+		ok    bool
+	}{
+		{"bytes 100-199/200", 100, 200, true},
+		{"bytes 0-99/*", 0, -1, true},
+		{"bytes */200", 0, 0, false},
+		{"items 0-1/2", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			start, total, ok := parseContentRange(test.value)
+			if start != test.start || total != test.total || ok != test.ok {
+				t.Errorf("Expected: %v %v %v | Got: %v %v %v", test.start, test.total, test.ok, start, total, ok)
+			}
+		})
+	}
+}
+
+func TestDownloadStatus(t *testing.T) {
+	tests := []struct {
+		download Download
+		status   string
+		fraction float32
+	}{
+		{Download{Status: Downloading, Received: 512, Total: 2048}, "512 B of 2.0 KB", 0.25},
+		{Download{Status: Downloading, Received: 512, Total: -1}, "512 B", 0},
+		{Download{Status: Paused, Received: 1024, Total: 2048}, "Paused, 1.0 KB of 2.0 KB", 0.5},
+		{Download{Status: Completed, Received: 2048, Total: 2048}, "Done, 2.0 KB", 1},
+		{Download{Status: Failed, Err: "connection reset"}, "Failed: connection reset", 0},
+		{Download{Status: Cancelled, Received: 10, Total: 20}, "Cancelled", 0.5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.status, func(t *testing.T) {
+			if got := test.download.StatusText(); got != test.status {
+				t.Errorf("Expected: %v | Got: %v", test.status, got)
+			}
+			if got := test.download.Fraction(); got != test.fraction {
+				t.Errorf("Expected: %v | Got: %v", test.fraction, got)
+			}
+		})
+	}
+}
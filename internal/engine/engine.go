@@ -1,17 +1,23 @@
 package engine
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	urlPkg "net/url"
 	"os"
 	"strings"
-	"time"
+	"sync"
 
-	"gioui.org/app"
 	"github.com/WaronLimsakul/Gazer/internal/css"
+	"github.com/WaronLimsakul/Gazer/internal/gemini"
+	"github.com/WaronLimsakul/Gazer/internal/gopher"
+	"github.com/WaronLimsakul/Gazer/internal/markdown"
 	"github.com/WaronLimsakul/Gazer/internal/parser"
 )
 
@@ -24,163 +30,545 @@ const (
 	CloseTab
 	NavBack  // click go back in history
 	NavForth // click go forth in history
-	AcknowledgeUrlChanged
+	Stop     // stop the in-flight navigation
+	PauseDownload
+	ResumeDownload
+	CancelDownload
+	ClearDownloads // forget the finished downloads
+	AddBookmark    // bookmark the page of the tab
+	RemoveBookmark
+	ReopenTab  // open the latest closed tab again
+	Scroll     // user scrolled the page at Url in the tab
+	Reload     // load the page of the tab again, the cached one is kept if the server says it didn't change
+	HardReload // load the page of the tab and everything on it from the server again
+	SaveUrl    // download Url e.g. "save as" of the page or an image on it
+	MoveTab    // drag the tab to another place in the tab bar
+	PinTab
+	UnpinTab
+	DuplicateTab // open a copy of the tab with its back/forward list
+	PageAction   // a link or form of the about: page in the tab does something e.g. deletes a history entry
 )
 
 type Notification struct {
-	Type   NotificationType
-	TabIdx int
-	Url    string
+	Type       NotificationType
+	TabID      TabID          // ignored by ReopenTab, RemoveBookmark, SaveUrl and the download notifications, AddTab's opener
+	Url        string         // AddTab with it opens a link in the new tab
+	Background bool           // only for AddTab with Url: the new tab isn't selected
+	Transition Transition     // how the user got to Url, only for Search
+	DownloadID DownloadID     // only for the download notifications
+	BookmarkID BookmarkID     // only for RemoveBookmark
+	Scroll     ScrollPosition // only for Scroll
+	Index      int            // only for MoveTab: display index the tab goes to
 }
 
-// represent the program logic state
-// only engine modules can change this
-type State struct {
-	Tabs []*Tab
-	// a channel for client to notify the engine with the event
-	Notifier chan Notification
-}
-
-type Tab struct {
-	Url       string // processed URL
-	Dom       Dom
-	IsLoading bool
-	history   *navHistory
-	// a flag to let the UI knows that engine change the Url
-	UrlChanged   bool
-	LoadProgress chan float32 // a channel reporting the loading progress
+// Resource is a fetched content with some information about it
+type Resource struct {
+	io.ReadCloser
+	Url         *urlPkg.URL // final url after redirects
+	ContentType string      // media type without parameters e.g. "text/html", empty if unknown
+	Charset     string      // charset parameter of the Content-Type, empty if not given
+	Encoding    string      // Content-Encoding e.g. "gzip", read the content with readContent
+	Length      int64       // content length (before decoding), -1 if unknown
+	NoSniff     bool        // server says don't guess the content type (X-Content-Type-Options: nosniff)
+	Attachment  bool        // server says save it instead of showing it (Content-Disposition: attachment)
+	Filename    string      // suggested file name from Content-Disposition, empty if not given
+	Validator   string      // ETag (or Last-Modified), to resume downloading the same content
 }
 
 // represent logic Dom information
 type Dom struct {
+	Kind DocumentKind
+	// DOM tree of html document, other documents only have <head> with <title>
 	Root   *parser.Node
 	Styles *css.StyleSet
+	// raw content of <img> in the DOM, map resolved src url -> content.
+	// Image document has its content here too, with its url as the key.
+	Images map[string][]byte
+	// decoded content of the document, empty for image document
+	Source string
+	// parsed json document, nil if it's not a json document
+	Json *JsonNode
+	// ETag or Last-Modified of the document, reload asks the server if it's still this
+	Validator string
 }
 
-var client = &http.Client{Timeout: 3 * time.Second}
-
-// checked content type in HTTPS header
-var supportedContentType = map[string]bool{
-	"text/html":  true,
-	"text/css":   true,
-	"image/png":  true,
-	"image/jpeg": true,
-	"image/jpg":  true,
-	"image/gif":  true,
+// result of one navigation (fetching + parsing) ran by navigate
+type navResult struct {
+	id        int    // navigation id, used for discarding stale result
+	requested string // url we were asked to go
+	url       string // final url after redirects, same as requested if failed
+	dom       Dom
+	err       error
+	download  *downloadResponse // not a page but a file to save, nil if it's a page
+	// reload found the page didn't change, show the cached one
+	notModified bool
 }
 
-// Start starts the engine to watch for notification and serve the request>
-func Start(state *State, window *app.Window) {
-	serverNotifiers := make(map[*Tab]chan Notification) // map notification to channel to server
+var client = &http.Client{Timeout: settings.RequestTimeout, CheckRedirect: checkRedirect}
+
+// Start starts the engine to watch for notification and serve the request.
+// It returns when state.Notifier is closed.
+func Start(state *State, window Invalidator) {
+	state.mu.Lock()
+	state.window = window
+	state.mu.Unlock()
+
+	serverNotifiers := make(map[TabID]chan Notification) // map tab to channel to its server
+	done := make(chan struct{})
+	defer func() {
+		for _, serverNotifier := range serverNotifiers {
+			close(serverNotifier)
+		}
+		close(done)
+		state.SaveSession()
+		state.history.flush()
+	}()
+	go state.autosaveSession(done)
+
+	// serverOf returns the channel to the tab's server, start the server if it's not running yet
+	serverOf := func(tab *Tab) chan Notification {
+		serverNotifier, ok := serverNotifiers[tab.id]
+		if !ok {
+			serverNotifier = make(chan Notification)
+			serverNotifiers[tab.id] = serverNotifier
+			go serveTab(state, tab, serverNotifier)
+		}
+		return serverNotifier
+	}
+	// restored tab loads its page when its server starts, the selected one is shown first
+	if tab := state.tab(state.Snapshot().Selected); tab != nil {
+		serverOf(tab)
+	}
 
 	for noti := range state.Notifier {
-		// 2 operations that manager has to deal: open and close tab
+		// operations that manager has to deal: open, select and close tab
 		switch noti.Type {
 		case AddTab:
-			state.Tabs = append(state.Tabs, newTab())
+			if noti.Url == "" {
+				state.addTab()
+			} else {
+				// link opened in a new tab, it goes next to the tab it's from
+				tab := state.openTab(noti.TabID, noti.Background)
+				state.emit(Event{Type: UrlChanged, TabID: tab.id, Url: noti.Url})
+				serverOf(tab) <- Notification{Type: Search, TabID: tab.id, Url: noti.Url, Transition: LinkTransition}
+			}
+			window.Invalidate()
+		case ChangeTab:
+			state.selectTab(noti.TabID)
+			if tab := state.tab(noti.TabID); tab != nil {
+				serverOf(tab) // restored tab loads when it's first selected
+			}
 			window.Invalidate()
+		case ReopenTab:
+			if tab := state.reopenTab(); tab != nil {
+				serverOf(tab)
+			}
+			window.Invalidate()
+		case DuplicateTab:
+			if tab := state.duplicateTab(noti.TabID); tab != nil {
+				serverOf(tab)
+			}
+			window.Invalidate()
+		case MoveTab:
+			state.moveTab(noti.TabID, noti.Index)
+			window.Invalidate()
+		case PinTab, UnpinTab:
+			state.pinTab(noti.TabID, noti.Type == PinTab)
+			window.Invalidate()
+		case Scroll:
+			state.setScroll(noti.TabID, noti.Url, noti.Scroll)
 		case CloseTab:
-			delete(serverNotifiers, state.Tabs[noti.TabIdx]) // delete the serverNotifier
-			state.Tabs = append(state.Tabs[:noti.TabIdx], state.Tabs[noti.TabIdx+1:]...)
+			// closing the notifier stops the tab server and cancels its navigation
+			if serverNotifier, ok := serverNotifiers[noti.TabID]; ok {
+				close(serverNotifier)
+				delete(serverNotifiers, noti.TabID)
+			}
+			state.closeTab(noti.TabID)
+			window.Invalidate()
+		case PauseDownload:
+			state.pauseDownload(noti.DownloadID)
+		case ResumeDownload:
+			state.resumeDownload(noti.DownloadID)
+		case CancelDownload:
+			state.cancelDownload(noti.DownloadID)
+		case ClearDownloads:
+			state.clearDownloads()
+		case SaveUrl:
+			go state.saveUrl(noti.Url)
+		case AddBookmark:
+			state.bookmarkTab(noti.TabID)
+			window.Invalidate()
+		case RemoveBookmark:
+			state.bookmarks.remove(noti.BookmarkID)
 			window.Invalidate()
 		default:
-			tab := state.Tabs[noti.TabIdx]
-			serverNotifier, ok := serverNotifiers[tab]
-			if !ok {
-				serverNotifier = make(chan Notification)
-				serverNotifiers[tab] = serverNotifier
-				go serveTab(tab, serverNotifier, window)
+			tab := state.tab(noti.TabID)
+			if tab == nil {
+				continue // tab is already closed
 			}
-			serverNotifier <- noti
+			serverOf(tab) <- noti
 		}
 	}
 }
 
-func serveTab(tab *Tab, notifier chan Notification, window *app.Window) {
+// serveTab serves the notification of one tab, it's the only one who writes the tab's fields.
+func serveTab(state *State, tab *Tab, notifier chan Notification) {
 	// cache for node parsing: 1 url = 1 root node
 	cache := make(map[string]Dom)
-	for noti := range notifier {
-		switch noti.Type {
-		case Search:
-			preparedUrl, err := prepareUrl(noti.Url)
-			if err != nil {
-				fmt.Println("prepareUrl: ", err)
-				continue
+	results := make(chan navResult)
+	// every navigation runs under its own context, cancel the in-flight one
+	// when user navigates elsewhere, stop or close the tab
+	var navId int
+	// whether the in-flight navigation is a visit to record in the history, and how the user got there
+	visiting, transition := false, TypedTransition
+	cancelNav := context.CancelFunc(func() {})
+	defer func() { cancelNav() }()
+
+	// stopNav cancels the in-flight navigation (if any) and leaves the tab at the previous page
+	stopNav := func() {
+		cancelNav()
+		navId++ // any result from now on is stale
+		if tab.isLoading {
+			state.updateTab(tab, func(t *Tab) { t.isLoading = false })
+			// tell ui to bring the previous url back
+			state.emit(Event{Type: UrlChanged, TabID: tab.id, Url: tab.url})
+		}
+	}
+
+	// commit shows the page (or error page) as the result of navigating to requested.
+	// Going to the current url again (e.g. retry) replaces the history entry instead of adding one.
+	commit := func(requested, url string, dom Dom) {
+		state.updateTab(tab, func(t *Tab) {
+			if t.history.getUrl() == requested {
+				t.history.replace(url)
+			} else {
+				t.history.nav(url)
+			}
+			if visiting && transition == SensitiveTransition {
+				t.history.cur.sensitive = true // the session doesn't save it
 			}
-			tab.Url = preparedUrl.String()
-			tab.history.nav(tab.Url)
+			t.isLoading = false
+			t.url = url
+			t.dom = dom
+		})
+		if url != requested {
+			// redirected, tell ui the real url
+			state.emit(Event{Type: UrlChanged, TabID: tab.id, Url: url})
+		}
+	}
 
-			cachedDom, ok := cache[tab.Url]
-			if ok {
-				tab.Dom = cachedDom
-				window.Invalidate()
-				continue
+	// showAbout builds the internal about: page and shows it as the result of navigating to requested.
+	// It shows the latest information, so it's never cached.
+	showAbout := func(requested string, url *urlPkg.URL, query urlPkg.Values) {
+		dom, ok := aboutPage(url.Opaque, query, tab.history, state.history, state.bookmarks, cache)
+		if !ok {
+			err := &FetchError{Kind: InvalidUrlError, Url: url.String(),
+				Err: fmt.Errorf("No such page: %v", url.String())}
+			dom = errorPage(url.String(), err)
+		}
+		commit(requested, url.String(), dom)
+	}
+
+	// startNav starts loading url in the background, the result comes back at results.
+	// Internal about: page is built right away instead, its actions only run with PageAction.
+	startNav := func(url *urlPkg.URL, options fetchOptions) {
+		if url.Scheme == "about" {
+			query := url.Query()
+			query.Del("notice") // only an action tells what it did
+			showAbout(url.String(), url, query)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelNav = cancel
+		state.updateTab(tab, func(t *Tab) { t.isLoading = true })
+		reporter := newProgressReporter(ctx, tab, state.window, url.Host)
+		go navigate(ctx, navId, url, options, reporter, results)
+	}
+
+	// showHistory shows the current page in history, it's not a new visit
+	showHistory := func() {
+		visiting = false
+		curUrl := tab.history.getUrl()
+		state.emit(Event{Type: UrlChanged, TabID: tab.id, Url: curUrl})
+		// If we already visit this url, it should be cached
+		cachedDom, ok := cache[withoutFragment(curUrl)]
+		if !ok && curUrl != "" {
+			// not cached e.g. it was an error page, load it again
+			url, err := prepareUrl(curUrl)
+			if err == nil {
+				startNav(url, fetchOptions{})
+				return
 			}
+		}
+		state.updateTab(tab, func(t *Tab) {
+			t.url = curUrl
+			t.dom = cachedDom // empty dom in case we're back at the blank page
+		})
+	}
 
-			tab.IsLoading = true
-			go reportProgress(tab, window, tab.LoadProgress)
+	// reload loads the current page in history again, it's not a new visit.
+	// Reload lets the server tell us to keep the cached page, hard one gets everything again.
+	reload := func(hard bool) {
+		visiting = false
+		curUrl := tab.history.getUrl()
+		url, err := prepareUrl(curUrl)
+		if err != nil {
+			showHistory() // the blank page, or nothing we can load
+			return
+		}
+		options := fetchOptions{mode: revalidate}
+		if hard {
+			options.mode = bypassCache
+		} else if cachedDom, ok := cache[withoutFragment(curUrl)]; ok {
+			options.validator = cachedDom.Validator
+		}
+		startNav(url, options)
+	}
 
-			root, err := getDom(*preparedUrl)
-			styles := getStyles(root, preparedUrl)
-			tab.IsLoading = false
+	// restored tab (from the session or reopened) has a page in history but nothing shown yet
+	if tab.url != "" && tab.dom.Root == nil {
+		showHistory()
+	}
 
-			if err != nil {
-				fmt.Println("search:", err)
+	for {
+		select {
+		case noti, ok := <-notifier:
+			if !ok {
+				return // tab closed
+			}
+			switch noti.Type {
+			case Search:
+				stopNav()
+				preparedUrl, err := prepareUrl(noti.Url)
+				if err != nil {
+					log.Println("prepareUrl:", err)
+					err = &FetchError{Kind: InvalidUrlError, Url: noti.Url, Err: err}
+					commit(noti.Url, noti.Url, errorPage(noti.Url, err))
+					continue
+				}
+				visiting, transition = true, noti.Transition
+				if noti.Transition == FormTransition {
+					// the answer of a gemini or gopher input page
+					var sensitive bool
+					if preparedUrl, sensitive, _ = inputAnswer(preparedUrl); sensitive {
+						transition = SensitiveTransition
+					}
+				}
+				url := preparedUrl.String()
+
+				// also a #fragment of the page we're on, it's the same document
+				cachedDom, ok := cache[withoutFragment(url)]
+				if ok {
+					state.history.visit(url, PageTitle(cachedDom.Root), transition)
+					state.updateTab(tab, func(t *Tab) {
+						if t.history.getUrl() != url {
+							t.history.nav(url)
+						}
+						t.history.cur.sensitive = t.history.cur.sensitive || transition == SensitiveTransition
+						t.url = url
+						t.dom = cachedDom
+					})
+					continue
+				}
+
+				startNav(preparedUrl, fetchOptions{})
+			case PageAction:
+				// only the page the tab is on does its actions, any other page could link to them
+				if !IsPageAction(tab.url, noti.Url) {
+					log.Println("pageAction: not from the page:", noti.Url)
+					continue
+				}
+				stopNav()
+				visiting = false
+				url, _ := prepareUrl(noti.Url)
+				query := url.Query()
+				acted, notice := false, ""
+				switch url.Opaque {
+				case "history":
+					acted = historyAction(state.history, query)
+				case "bookmarks":
+					notice, acted = bookmarkAction(state.bookmarks, query)
+				}
+				if !acted {
+					// nothing to do e.g. the search form of the page, it's a page like any other
+					showAbout(noti.Url, url, query)
+				} else {
+					// done, the page shows itself again (e.g. still searching) in place of the current entry
+					curUrl, _ := prepareUrl(tab.url)
+					page := actionPageView(curUrl)
+					view := page.Query()
+					if notice != "" {
+						view.Set("notice", notice)
+					}
+					showAbout(tab.url, page, view)
+				}
+				// the ui doesn't know where the page ended up e.g. its search form
+				state.emit(Event{Type: UrlChanged, TabID: tab.id, Url: tab.url})
+			case Stop:
+				stopNav()
+			case Reload:
+				stopNav()
+				reload(false)
+			case HardReload:
+				stopNav()
+				reload(true)
+			case NavBack:
+				stopNav()
+				state.updateTab(tab, func(t *Tab) { t.history.back() })
+				showHistory()
+			case NavForth:
+				stopNav()
+				state.updateTab(tab, func(t *Tab) { t.history.forth() })
+				showHistory()
+			default:
 				continue
 			}
+		case res := <-results:
+			if res.id != navId {
+				continue // result of cancelled navigation
+			}
+			cancelNav() // done, release the context
 
-			tab.Dom = Dom{Root: root, Styles: styles}
-			cache[tab.Url] = tab.Dom
-			window.Invalidate()
-		case NavBack:
-			tab.history.back()
-			curUrl := tab.history.getUrl()
-			tab.Url = curUrl
-			tab.UrlChanged = true
-			// If we already visit this url, it should be cached
-			cachedDom, ok := cache[curUrl]
-			if !ok {
-				log.Println("NavBack: couldn't find cached dom data")
-				tab.Dom = Dom{nil, nil} // in case we're back at invalid url
+			if res.download != nil {
+				// nothing to show, the tab stays at the current page
+				state.startDownload(res.download)
+				state.updateTab(tab, func(t *Tab) { t.isLoading = false })
+				state.emit(Event{Type: UrlChanged, TabID: tab.id, Url: tab.url})
+				continue
 			}
-			tab.Dom = cachedDom
-			window.Invalidate()
-		case NavForth:
-			tab.history.forth()
-			curUrl := tab.history.getUrl()
-			tab.Url = curUrl
-			tab.UrlChanged = true
-			// If we already visit this url, it should be cached
-			cachedDom, ok := cache[curUrl]
-			if !ok {
-				log.Println("NavForth: couldn't find cached dom data")
-				tab.Dom = Dom{nil, nil} // in case we're back at invalid url
+
+			if res.notModified {
+				commit(res.requested, res.url, cache[withoutFragment(res.url)])
+				continue
+			}
+
+			if res.err != nil {
+				log.Println("search:", res.err)
+				// error page is not cached, so going back to it tries again
+				commit(res.requested, res.url, errorPage(res.url, res.err))
+				continue
+			}
+
+			// only commit the page when everything is loaded
+			cache[withoutFragment(res.url)] = res.dom
+			commit(res.requested, res.url, res.dom)
+			if visiting {
+				state.history.visit(res.url, PageTitle(res.dom.Root), transition)
 			}
-			tab.Dom = cachedDom
-			window.Invalidate()
-		case AcknowledgeUrlChanged:
-			tab.UrlChanged = false // switch flag back after ui acknowledge our change
-		default:
-			continue
 		}
 	}
 }
 
-func NewState() *State {
-	s := State{}
-	s.Notifier = make(chan Notification)
-	s.Tabs = []*Tab{newTab()}
-	return &s
+// navigate fetches and parses the page at url with all of its subresources then
+// send the result to results. It gives up when ctx is cancelled.
+// The options' validator is only for the page, not the subresources.
+func navigate(ctx context.Context, id int, url *urlPkg.URL, options fetchOptions,
+	reporter *progressReporter, results chan<- navResult) {
+	res := navResult{id: id, requested: url.String(), url: url.String()}
+	pageCtx := withFetchOptions(ctx, options)
+	ctx = withFetchOptions(ctx, fetchOptions{mode: options.mode})
+	if url.Scheme == "view-source" {
+		res.dom, res.url, res.err = viewSource(pageCtx, url.Opaque, reporter)
+		if errors.As(res.err, &res.download) {
+			res.err = nil // no source to show, it's downloaded like a normal visit
+		}
+		reporter.update(func(p *Progress) { p.Phase = Loaded })
+		select {
+		case results <- res:
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	dom, finalUrl, err := getDom(pageCtx, *url, reporter)
+	switch {
+	case errors.Is(err, errNotModified):
+		res.notModified = true
+		reporter.update(func(p *Progress) { p.Phase = Loaded })
+	case errors.As(err, &res.download):
+		// not a page, the tab server hands it over to the downloads
+	case err != nil:
+		res.err = err
+		// error can happen after redirects e.g. redirected to 404
+		var fetchErr *FetchError
+		if errors.As(err, &fetchErr) && fetchErr.Url != "" {
+			res.url = fetchErr.Url
+		}
+	default:
+		// redirect without a #fragment keeps ours, like other browsers
+		if finalUrl.Fragment == "" && url.Fragment != "" {
+			redirected := *finalUrl
+			redirected.Fragment, redirected.RawFragment = url.Fragment, url.RawFragment
+			finalUrl = &redirected
+		}
+		// subresources are relative to where we end up
+		url = finalUrl
+		res.url = finalUrl.String()
+		if dom.Kind == HtmlDocument || dom.Kind == MarkdownDocument {
+			root := dom.Root
+			reporter.update(func(p *Progress) {
+				p.Phase = Subresources
+				p.StylesTotal = len(findStylesheetLinks(root))
+				p.ImagesTotal = len(findImageSrcs(root, url))
+			})
+			dom.Styles = getStyles(ctx, root, url, reporter)
+			dom.Images = getImages(ctx, root, url, reporter)
+		}
+		res.dom = dom
+		reporter.update(func(p *Progress) { p.Phase = Loaded })
+		// subresources fail silently, but cancellation means the whole navigation fails
+		res.err = ctx.Err()
+	}
+
+	select {
+	case results <- res:
+	case <-ctx.Done():
+	}
 }
 
-func newTab() *Tab {
-	return &Tab{LoadProgress: make(chan float32), history: newNavHistory()}
+// viewSource fetches the document at rawUrl and returns its source as a view-source document
+// with the final view-source url. Document without source (image) is shown as it is.
+func viewSource(ctx context.Context, rawUrl string, reporter *progressReporter) (Dom, string, error) {
+	url := "view-source:" + rawUrl
+	inner, err := urlPkg.Parse(rawUrl)
+	if err != nil {
+		return Dom{}, url, &FetchError{Kind: InvalidUrlError, Url: url, Err: fmt.Errorf("url.Parse: %w", err)}
+	}
+
+	dom, finalUrl, err := getDom(ctx, *inner, reporter)
+	if err != nil {
+		var fetchErr *FetchError
+		if errors.As(err, &fetchErr) && fetchErr.Url != "" {
+			url = "view-source:" + fetchErr.Url
+		}
+		return Dom{}, url, err
+	}
+
+	url = "view-source:" + finalUrl.String()
+	if dom.Kind == ImageDocument {
+		// image viewer looks the image up by the url of the tab
+		dom.Images = map[string][]byte{url: dom.Images[finalUrl.String()]}
+		return dom, url, ctx.Err()
+	}
+	return Dom{Kind: ViewSourceDocument, Root: documentRoot(url), Source: dom.Source}, url, ctx.Err()
+}
+
+// withoutFragment is the url without its #fragment, pages are cached by it
+// because a fragment is a place in the same document
+func withoutFragment(raw string) string {
+	url, err := urlPkg.Parse(raw)
+	if err != nil || (url.Fragment == "" && url.RawFragment == "") {
+		return raw
+	}
+	url.Fragment, url.RawFragment = "", ""
+	return url.String()
 }
 
 // ResolveJumpTarget takes href string and the base url of the site
 // to determine the jump target address
 func ResolveJumpTarget(href, base string) (string, error) {
-	url, err := urlPkg.ParseRequestURI(base)
+	url, err := urlPkg.ParseRequestURI(withoutFragment(base)) // it'd be a part of the path
 	if err != nil {
 		return "", err
 	}
@@ -191,33 +579,84 @@ func ResolveJumpTarget(href, base string) (string, error) {
 	return target.String(), nil
 }
 
-// getDom fetches the url and parse the DOM tree
-// then return the root of DOM tree and error if exists
-func getDom(url urlPkg.URL) (*parser.Node, error) {
-	contentReader, err := Fetch(url)
+// getDom fetches the url, decides what kind of document it is and parse it
+// then return the Dom (without subresources), the final url after redirects and error if exists
+// reporter is informed about the bytes received.
+func getDom(ctx context.Context, url urlPkg.URL, reporter *progressReporter) (Dom, *urlPkg.URL, error) {
+	resource, err := Fetch(ctx, url)
 	if err != nil {
-		return nil, fmt.Errorf("Fetch: %v", err)
+		return Dom{}, nil, fmt.Errorf("Fetch: %w", err)
 	}
-	defer contentReader.Close()
-
-	resBody, err := io.ReadAll(contentReader)
+	// decide by the headers if we can, so the file doesn't have to fit in memory
+	if _, ok := documentKind(sniffContentType(resource.ContentType, nil, resource.NoSniff)); !ok || resource.Attachment {
+		resource.Close()
+		return Dom{}, nil, newDownloadResponse(resource, nil)
+	}
+	defer resource.Close()
+
+	reporter.update(func(p *Progress) {
+		p.Phase = Receiving
+		p.BytesTotal = resource.Length
+	})
+	// count the bytes before decoding, that's what Length is about
+	content, err := readContent(reporter.wrap(resource), resource.Encoding)
 	if err != nil {
-		return nil, fmt.Errorf("io.ReadAll: %v", err)
+		return Dom{}, nil, fmt.Errorf("readContent: %w", err)
 	}
 
-	log.Println("fetch:\n", string(resBody))
+	finalUrl := resource.Url
+	contentType := sniffContentType(resource.ContentType, content, resource.NoSniff)
+	kind, ok := documentKind(contentType)
+	if !ok {
+		// it looks like a binary after all
+		return Dom{}, nil, newDownloadResponse(resource, content)
+	}
 
-	root, err := parser.Parse(string(resBody))
-	if err != nil {
-		return nil, fmt.Errorf("parse: %v", err)
+	dom := Dom{Kind: kind, Validator: resource.Validator}
+	switch kind {
+	case HtmlDocument:
+		resBody := decodeHtml(content, resource.Charset)
+		log.Println("fetch:\n", resBody)
+
+		root, err := parser.Parse(resBody)
+		if err != nil {
+			return Dom{}, nil, fmt.Errorf("parse: %v", err)
+		}
+		log.Println("parse:\n", *root)
+		dom.Root, dom.Source = root, resBody
+		return dom, finalUrl, nil
+	case MarkdownDocument:
+		dom.Source = decodeText(content, resource.Charset)
+		dom.Root = markdown.Parse(dom.Source, documentTitle(finalUrl))
+		return dom, finalUrl, nil
+	case GeminiDocument:
+		dom.Source = decodeText(content, resource.Charset)
+		dom.Root = gemini.Parse(dom.Source, documentTitle(finalUrl))
+		return dom, finalUrl, nil
+	case GopherMenuDocument:
+		dom.Source = decodeText(content, resource.Charset)
+		dom.Root = gopher.ParseMenu(dom.Source, documentTitle(finalUrl))
+		return dom, finalUrl, nil
+	case ImageDocument:
+		dom.Images = map[string][]byte{finalUrl.String(): content}
+	case JsonDocument:
+		dom.Source = decodeText(content, resource.Charset)
+		dom.Json, err = parseJson(dom.Source)
+		if err != nil {
+			// broken json is still worth reading
+			log.Println("parseJson:", err)
+			dom.Kind = TextDocument
+		}
+	default:
+		dom.Source = decodeText(content, resource.Charset)
 	}
-	log.Println("parse:\n", *root)
-	return root, nil
+	dom.Root = documentRoot(documentTitle(finalUrl))
+	return dom, finalUrl, nil
 }
 
 // getStyles get the CSS StyleSet from the DOM root (and might need the base url of the root).
 // it returns nil if not found.
-func getStyles(root *parser.Node, baseUrl *urlPkg.URL) *css.StyleSet {
+func getStyles(ctx context.Context, root *parser.Node, baseUrl *urlPkg.URL, reporter *progressReporter) *css.StyleSet {
 	head := findHead(root)
 	if head == nil {
 		return nil
@@ -241,39 +680,16 @@ func getStyles(root *parser.Node, baseUrl *urlPkg.URL) *css.StyleSet {
 			}
 			internal = styles
 		case parser.Link:
-			if rel, ok := node.Attrs["rel"]; ok && rel == "stylesheet" {
-				href, ok := node.Attrs["href"]
-				if !ok {
-					continue
-				}
-				hrefUrl, err := baseUrl.Parse(href) // OP function
-				if err != nil {
-					log.Println("baseUrl.Parse: ", err)
-					continue
-				}
-				contentReader, err := Fetch(*hrefUrl)
-				if err != nil {
-					log.Println("Fetch:", err)
-					continue
-				}
-
-				defer contentReader.Close()
-				content, err := io.ReadAll(contentReader)
-				if err != nil {
-					log.Println("io.ReadAll: ", err)
-					continue
-				}
-				log.Printf("fetch CSS [%s]: %s", href, content)
-
-				styles, err := css.Parse(string(content))
-				if err != nil {
-					fmt.Println("css.Parse", err)
-					continue
-				}
-				log.Println("parse CSS: ", *styles)
-
-				external = styles
+			if !isStylesheetLink(node) {
+				continue
 			}
+			styles, err := fetchStylesheet(ctx, node.Attrs["href"], baseUrl)
+			reporter.update(func(p *Progress) { p.StylesDone++ })
+			if err != nil {
+				log.Println("fetchStylesheet:", err)
+				continue
+			}
+			external = styles
 		}
 	}
 
@@ -286,70 +702,222 @@ func getStyles(root *parser.Node, baseUrl *urlPkg.URL) *css.StyleSet {
 	}
 }
 
+// fetchStylesheet fetches the stylesheet at href (relative to baseUrl) and parse it
+func fetchStylesheet(ctx context.Context, href string, baseUrl *urlPkg.URL) (*css.StyleSet, error) {
+	hrefUrl, err := baseUrl.Parse(href) // OP function
+	if err != nil {
+		return nil, fmt.Errorf("baseUrl.Parse: %v", err)
+	}
+	resource, err := Fetch(ctx, *hrefUrl)
+	if err != nil {
+		return nil, fmt.Errorf("Fetch: %v", err)
+	}
+	defer resource.Close()
+
+	content, err := readContent(resource, resource.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("readContent: %v", err)
+	}
+	text := decodeText(content, resource.Charset)
+	log.Printf("fetch CSS [%s]: %s", href, text)
+
+	styles, err := css.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("css.Parse: %v", err)
+	}
+	log.Println("parse CSS: ", *styles)
+	return styles, nil
+}
+
+// getImages fetches content of all <img> in the DOM tree concurrently,
+// and returns map of resolved src url -> content. Failed image is just left out.
+func getImages(ctx context.Context, root *parser.Node, baseUrl *urlPkg.URL, reporter *progressReporter) map[string][]byte {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, settings.MaxConcurrentImageFetch)
+	res := make(map[string][]byte)
+	for _, src := range findImageSrcs(root, baseUrl) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			defer reporter.update(func(p *Progress) { p.ImagesDone++ })
+
+			srcUrl, _ := urlPkg.Parse(src) // already parsed once
+			resource, err := Fetch(ctx, *srcUrl)
+			if err != nil {
+				log.Println("Fetch:", err)
+				return
+			}
+			defer resource.Close()
+			content, err := readContent(resource, resource.Encoding)
+			if err != nil {
+				log.Println("readContent: ", err)
+				return
+			}
+
+			mu.Lock()
+			res[src] = content
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return res
+}
+
+// findStylesheetLinks returns all <link rel="stylesheet" href=".."> nodes in the <head>
+func findStylesheetLinks(root *parser.Node) []*parser.Node {
+	head := findHead(root)
+	if head == nil {
+		return nil
+	}
+	var res []*parser.Node
+	for _, node := range head.Children {
+		if node.Tag == parser.Link && isStylesheetLink(node) {
+			res = append(res, node)
+		}
+	}
+	return res
+}
+
+func isStylesheetLink(node *parser.Node) bool {
+	_, hasHref := node.Attrs["href"]
+	return node.Attrs["rel"] == "stylesheet" && hasHref
+}
+
+// findImageSrcs returns resolved src urls of all <img> in the DOM tree without duplicate
+func findImageSrcs(root *parser.Node, baseUrl *urlPkg.URL) []string {
+	var res []string
+	seen := make(map[string]bool)
+	var collect func(node *parser.Node)
+	collect = func(node *parser.Node) {
+		if node.Tag == parser.Img {
+			if src, ok := node.Attrs["src"]; ok {
+				srcUrl, err := baseUrl.Parse(src)
+				if err == nil && !seen[srcUrl.String()] {
+					seen[srcUrl.String()] = true
+					res = append(res, srcUrl.String())
+				}
+			}
+		}
+		for _, child := range node.Children {
+			collect(child)
+		}
+	}
+	if root != nil {
+		collect(root)
+	}
+	return res
+}
+
 // Fetch uses the url to fetch the content and return
-// io.ReadCloser representing a content reader
-func Fetch(url urlPkg.URL) (io.ReadCloser, error) {
+// a Resource representing a content reader and its information.
+// The fetching is aborted when ctx is cancelled.
+// Failure is returned as *FetchError, including the 4xx and 5xx status codes.
+// A reload sets its cache headers through the context, a 304 answer to it is errNotModified.
+func Fetch(ctx context.Context, url urlPkg.URL) (*Resource, error) {
 	switch url.Scheme {
 	case "file":
-		file, err := os.Open(url.Path)
+		info, err := os.Stat(url.Path)
 		if err != nil {
-			return nil, fmt.Errorf("os.Open: %v", err)
+			return nil, newFetchError(url.String(), fmt.Errorf("os.Stat: %w", err))
+		}
+		if info.IsDir() {
+			// links in the index are relative to the directory, so it has to end with /
+			if !strings.HasSuffix(url.Path, "/") {
+				url.Path += "/"
+				url.RawPath = ""
+			}
+			index, err := dirIndex(&url)
+			if err != nil {
+				return nil, newFetchError(url.String(), fmt.Errorf("dirIndex: %w", err))
+			}
+			return &Resource{ReadCloser: io.NopCloser(strings.NewReader(index)), Url: &url,
+				ContentType: "text/html", Charset: "utf-8", Length: int64(len(index))}, nil
 		}
-		return file, nil
-	case "http":
-		res, err := http.Get(url.String())
+
+		file, err := os.Open(url.Path)
 		if err != nil {
-			return nil, fmt.Errorf("http.Get: %v", err)
+			return nil, newFetchError(url.String(), fmt.Errorf("os.Open: %w", err))
 		}
-		return res.Body, nil
-	case "https":
-		// use serious client for https
-		req, err := http.NewRequest(http.MethodGet, url.String(), nil)
+		// unknown extension is sniffed after reading the content
+		contentType := typeByExtension(url.Path)
+		return &Resource{ReadCloser: file, Url: &url, ContentType: contentType, Length: info.Size()}, nil
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
 		if err != nil {
-			return nil, fmt.Errorf("http.NewRequest: %v", err)
+			return nil, newFetchError(url.String(), fmt.Errorf("http.NewRequestWithContext: %w", err))
 		}
-		req.Header.Set("User-Agent", "Gazer")
+		req.Header.Set("User-Agent", settings.UserAgent)
+		// setting it ourselves means the transport won't decompress for us
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		setCacheHeaders(ctx, req.Header)
 
 		res, err := client.Do(req)
 		if err != nil {
-			return nil, fmt.Errorf("client.Do: %v", err)
+			return nil, newFetchError(url.String(), fmt.Errorf("client.Do: %w", err))
 		}
-
-		contentTypes, ok := res.Header["Content-Type"]
-		if !ok {
-			return nil, fmt.Errorf("No content type provided")
+		// the client follows redirects, the request of the response has the final url
+		finalUrl := res.Request.URL
+		if res.StatusCode == http.StatusNotModified {
+			res.Body.Close()
+			return nil, errNotModified
 		}
 
-		contentType := contentTypes[0]
-		contentType = strings.Split(contentType, ";")[0]
-		if _, ok := supportedContentType[contentType]; !ok {
-			return nil, fmt.Errorf("Unsupported content type: %v", contentType)
+		if res.StatusCode >= 400 {
+			res.Body.Close()
+			return nil, &FetchError{Kind: HttpError, Url: finalUrl.String(), StatusCode: res.StatusCode}
+		}
 
+		// missing or unknown content type is sniffed after reading the content
+		contentType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+		if err != nil {
+			contentType = mediaType(res.Header.Get("Content-Type"))
 		}
 
-		return res.Body, nil
+		resource := &Resource{
+			ReadCloser:  res.Body,
+			Url:         finalUrl,
+			ContentType: contentType,
+			Charset:     params["charset"],
+			Encoding:    res.Header.Get("Content-Encoding"),
+			Length:      res.ContentLength,
+			NoSniff:     strings.EqualFold(res.Header.Get("X-Content-Type-Options"), "nosniff"),
+			Validator:   responseValidator(res.Header),
+		}
+		// ParseMediaType also decodes filename*=UTF-8''...
+		if disposition, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition")); err == nil {
+			resource.Attachment = disposition == "attachment"
+			resource.Filename = params["filename"]
+		}
+		return resource, nil
+	case "gemini":
+		return fetchGemini(ctx, url)
+	case "gopher":
+		return fetchGopher(ctx, url)
+	case "data":
+		data, err := parseDataUrl(url)
+		if err != nil {
+			return nil, &FetchError{Kind: InvalidUrlError, Url: url.String(), Err: fmt.Errorf("parseDataUrl: %w", err)}
+		}
+		return &Resource{
+			ReadCloser:  io.NopCloser(bytes.NewReader(data.data)),
+			Url:         &url,
+			ContentType: data.mediaType,
+			Charset:     data.charset,
+			Length:      int64(len(data.data)),
+		}, nil
 	default:
-		return nil, fmt.Errorf("Unsupported scheme: %v", url.Scheme)
+		return nil, &FetchError{Kind: UnsupportedSchemeError, Url: url.String(),
+			Err: fmt.Errorf("Unsupported scheme: %v", url.Scheme)}
 	}
 }
 
-// reportProgress keep reporting synthetic progress to the channel in the state
-// and also keep invalidating the window intending to rerender the progress ui.
-func reportProgress(t *Tab, w *app.Window, progressChan chan float32) {
-	var progress float32 = 0.0
-	const rate = 0.1
-
-	for t.IsLoading {
-		time.Sleep(25 * time.Millisecond)
-		progress += (1 - progress) * rate
-		progressChan <- progress
-		w.Invalidate()
-	}
-
-	progressChan <- 1.0
-	w.Invalidate()
-}
-
 func findHead(root *parser.Node) *parser.Node {
 	if root == nil {
 		return nil
@@ -371,16 +939,48 @@ func findHead(root *parser.Node) *parser.Node {
 }
 
 // prepareUrl takes a url string and return a new url.URL we can Fetch from
-// supported scheme: HTTP, HTTPS, file system
+// supported scheme: HTTP, HTTPS, file system, data, gemini, gopher.
+// It also accepts about:<page> and view-source:<url> which the tab server handles itself.
 func prepareUrl(rawUrl string) (*urlPkg.URL, error) {
 	if len(rawUrl) == 0 {
 		return nil, fmt.Errorf("Empty URL")
 	}
 
+	lowerUrl := strings.ToLower(rawUrl)
+	switch {
+	case strings.HasPrefix(lowerUrl, "view-source:"):
+		// url.Parse would split the query of the inner url away, so cut it ourselves
+		inner, err := prepareUrl(strings.TrimSpace(rawUrl[len("view-source:"):]))
+		if err != nil {
+			return nil, err
+		}
+		if inner.Scheme == "view-source" || inner.Scheme == "about" {
+			return nil, fmt.Errorf("Can't view source of %v", inner.String())
+		}
+		return &urlPkg.URL{Scheme: "view-source", Opaque: inner.String()}, nil
+	case strings.HasPrefix(lowerUrl, "about:"):
+		// page name is case insensitive, but the query is what it is e.g. about:history?q=Gazer
+		name, query, _ := strings.Cut(rawUrl[len("about:"):], "?")
+		return &urlPkg.URL{Scheme: "about", Opaque: strings.ToLower(name), RawQuery: query}, nil
+	case strings.HasPrefix(lowerUrl, "data:"):
+		url, err := urlPkg.Parse(rawUrl)
+		if err != nil {
+			return nil, fmt.Errorf("url.Parse: %v", err)
+		}
+		return url, nil
+	}
+
+	// words, not an address: search them
+	if !looksLikeUrl(rawUrl) && settings.SearchEngine != "" {
+		rawUrl = SearchUrl(rawUrl)
+	}
+
 	// handle prefix: we want https:// or http://
 	if !strings.HasPrefix(rawUrl, "file://") &&
 		!strings.HasPrefix(rawUrl, "https://") &&
-		!strings.HasPrefix(rawUrl, "http://") {
+		!strings.HasPrefix(rawUrl, "http://") &&
+		!strings.HasPrefix(lowerUrl, "gemini://") &&
+		!strings.HasPrefix(lowerUrl, "gopher://") {
 		rawUrl = "https://" + rawUrl
 	}
 
@@ -390,16 +990,18 @@ func prepareUrl(rawUrl string) (*urlPkg.URL, error) {
 		return nil, fmt.Errorf("url.Parse: %v", err)
 	}
 
-	// support local file
-	if url.Scheme == "file" {
+	// support local file, gemini and gopher, it's not a HTTP request uri
+	if url.Scheme == "file" || url.Scheme == "gemini" || url.Scheme == "gopher" {
 		return url, nil
 	}
 
-	// check valid HTTP request url
-	url, err = urlPkg.ParseRequestURI(rawUrl)
+	// check valid HTTP request url, the #fragment isn't a part of the request
+	request, _, _ := strings.Cut(rawUrl, "#")
+	requestUrl, err := urlPkg.ParseRequestURI(request)
 	if err != nil {
 		return nil, fmt.Errorf("url.ParseRequestURI: %v", err)
 	}
+	requestUrl.Fragment, requestUrl.RawFragment = url.Fragment, url.RawFragment
 
-	return url, nil
+	return requestUrl, nil
 }
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// built-in page shown in the tab when the navigation fails
+const errorPageTemplate = `<html>
+<head><title>%s</title></head>
+<body>
+<h1>%s</h1>
+<p>%s</p>
+<p><i>%s</i></p>
+%s
+</body>
+</html>`
+
+const retryTemplate = `<p><a href="%s">Try again</a></p>`
+
+// errorPage builds the page telling the user why loading url failed
+func errorPage(url string, err error) Dom {
+	fetchErr := newFetchError(url, err)
+	title, description := describeError(fetchErr)
+
+	retry := ""
+	if fetchErr.Retryable() {
+		retry = fmt.Sprintf(retryTemplate, escapeText(url))
+	}
+	html := fmt.Sprintf(errorPageTemplate,
+		escapeText(title), escapeText(title), escapeText(description), escapeText(err.Error()), retry)
+
+	root, parseErr := parser.Parse(html)
+	if parseErr != nil {
+		log.Println("errorPage: parser.Parse:", parseErr)
+		return Dom{}
+	}
+	return Dom{Root: root}
+}
+
+// describeError returns the title and a human-readable description of the error
+func describeError(err *FetchError) (string, string) {
+	switch err.Kind {
+	case InvalidUrlError:
+		return "Invalid address", "This doesn't look like an address Gazer can go to."
+	case UnsupportedSchemeError:
+		return "Unsupported address", "Gazer doesn't know how to open this kind of address."
+	case DnsError:
+		return "Server not found", "Gazer can't find the server. Check the address for typos or check your connection."
+	case ConnectionError:
+		return "Unable to connect", "The server refused or dropped the connection. It might be down, try again later."
+	case TimeoutError:
+		return "The connection timed out", "The server is taking too long to respond."
+	case TlsError:
+		return "Secure connection failed", "Gazer can't verify the server's identity, its certificate might be invalid or expired."
+	case TooManyRedirectsError:
+		return "Too many redirects", "The page keeps redirecting and never gets anywhere."
+	case HttpError:
+		title := fmt.Sprintf("%d %s", err.StatusCode, http.StatusText(err.StatusCode))
+		if err.StatusCode >= 500 {
+			return title, "The server failed to handle the request."
+		}
+		return title, "The server couldn't give the page."
+	case UnsupportedContentError:
+		return "Unsupported content", "Gazer can't show this kind of content."
+	case GeminiError:
+		title := fmt.Sprintf("%d %s", err.StatusCode, geminiStatus(err.StatusCode))
+		switch err.StatusCode / 10 {
+		case 4:
+			return title, "The server can't give the page right now, try again later."
+		case 6:
+			return title, "The page wants a client certificate, Gazer doesn't have one."
+		}
+		return title, "The server couldn't give the page."
+	case FileNotFoundError:
+		return "File not found", "The file doesn't exist, it might have been moved or deleted."
+	default:
+		return "Something went wrong", "Gazer can't load the page."
+	}
+}
+
+// escapeText removes characters our parser would take as markup.
+// The parser doesn't decode entities, so "&lt;" would show as it is.
+func escapeText(text string) string {
+	return strings.NewReplacer("<", "‹", ">", "›", `"`, "'").Replace(text)
+}
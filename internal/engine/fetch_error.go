@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+)
+
+type ErrorKind uint8
+
+const (
+	UnknownError ErrorKind = iota
+	InvalidUrlError
+	UnsupportedSchemeError
+	DnsError        // host name can't be resolved
+	ConnectionError // refused, reset, unreachable
+	TimeoutError
+	TlsError // bad certificate, handshake failure
+	TooManyRedirectsError
+	HttpError // 4xx or 5xx status code
+	UnsupportedContentError
+	FileNotFoundError
+	GeminiError // gemini failure status code (4x, 5x, 6x)
+)
+
+// FetchError is an error of a failed fetch, classified by what went wrong
+// so we can tell the user something better than a Go error string
+type FetchError struct {
+	Kind       ErrorKind
+	Url        string
+	StatusCode int // only for HttpError and GeminiError
+	Err        error
+}
+
+var errTooManyRedirects = errors.New("stopped after too many redirects")
+
+func (e *FetchError) Error() string {
+	if e.Kind == HttpError {
+		return fmt.Sprintf("%s: %d %s", e.Url, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	if e.Kind == GeminiError {
+		return fmt.Sprintf("%s: %d %v", e.Url, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Url, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable tells if trying again might give a different result
+func (e *FetchError) Retryable() bool {
+	switch e.Kind {
+	case InvalidUrlError, UnsupportedSchemeError:
+		return false
+	case GeminiError:
+		// only 4x is temporary
+		return e.StatusCode < 50
+	default:
+		return true
+	}
+}
+
+// newFetchError classifies err that happens while fetching url
+func newFetchError(url string, err error) *FetchError {
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) {
+		return fetchErr
+	}
+
+	return &FetchError{Kind: classifyError(err), Url: url, Err: err}
+}
+
+// classifyError tells what kind of failure err is, the order matters
+// e.g. DNS error can also be a timeout but it's more useful to say it's DNS.
+func classifyError(err error) ErrorKind {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCertErr x509.CertificateInvalidError
+	var opErr *net.OpError
+
+	switch {
+	case errors.Is(err, errTooManyRedirects):
+		return TooManyRedirectsError
+	case errors.As(err, &dnsErr):
+		return DnsError
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &alertErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidCertErr):
+		return TlsError
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return TimeoutError
+	case errors.As(err, &opErr):
+		return ConnectionError
+	case errors.Is(err, os.ErrNotExist):
+		return FileNotFoundError
+	default:
+		return UnknownError
+	}
+}
+
+// checkRedirect is http.Client.CheckRedirect with our own error to classify
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= settings.MaxRedirects {
+		return errTooManyRedirects
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	urlPkg "net/url"
+	"testing"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, "<p>hello</p>")
+	})
+	mux.Handle("/old", http.RedirectHandler("/page", http.StatusMovedPermanently))
+	mux.Handle("/gone", http.RedirectHandler("/missing", http.StatusFound))
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "oops", http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/data", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte{0, 1, 2})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// server that is not listening anymore
+	closed := httptest.NewServer(mux)
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		finalUrl   string    // checked if fetch succeeds
+		kind       ErrorKind // checked if fetch fails
+		statusCode int
+		ok         bool
+	}{
+		{"ok", server.URL + "/page", server.URL + "/page", 0, 0, true},
+		{"redirect", server.URL + "/old", server.URL + "/page", 0, 0, true},
+		{"not found", server.URL + "/missing", "", HttpError, 404, false},
+		{"redirect to not found", server.URL + "/gone", "", HttpError, 404, false},
+		{"server error", server.URL + "/broken", "", HttpError, 500, false},
+		{"redirect loop", server.URL + "/loop", "", TooManyRedirectsError, 0, false},
+		{"binary content", server.URL + "/data", server.URL + "/data", 0, 0, true},
+		{"connection refused", closed.URL + "/page", "", ConnectionError, 0, false},
+		{"no file", "file:///no/such/file.html", "", FileNotFoundError, 0, false},
+		{"unsupported scheme", "ftp://example.com/", "", UnsupportedSchemeError, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url, err := urlPkg.Parse(test.url)
+			if err != nil {
+				t.Fatalf("url.Parse: %v", err)
+			}
+			resource, err := Fetch(context.Background(), *url)
+			if test.ok {
+				if err != nil {
+					t.Fatalf("Expected no error | Got: %v", err)
+				}
+				defer resource.Close()
+				if resource.Url.String() != test.finalUrl {
+					t.Errorf("Expected: %v | Got: %v", test.finalUrl, resource.Url)
+				}
+				return
+			}
+
+			var fetchErr *FetchError
+			if !errors.As(err, &fetchErr) {
+				t.Fatalf("Expected *FetchError | Got: %v", err)
+			}
+			if fetchErr.Kind != test.kind {
+				t.Errorf("Expected kind: %v | Got: %v (%v)", test.kind, fetchErr.Kind, err)
+			}
+			if fetchErr.StatusCode != test.statusCode {
+				t.Errorf("Expected status: %v | Got: %v", test.statusCode, fetchErr.StatusCode)
+			}
+		})
+	}
+}
+
+func TestErrorPage(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		err       error
+		title     string
+		wantRetry bool
+	}{
+		{
+			"http error",
+			"https://example.com/missing",
+			&FetchError{Kind: HttpError, Url: "https://example.com/missing", StatusCode: 404},
+			"404 Not Found",
+			true,
+		},
+		{
+			"invalid url",
+			"https://<bad>",
+			&FetchError{Kind: InvalidUrlError, Url: "https://<bad>", Err: errors.New("invalid")},
+			"Invalid address",
+			false,
+		},
+		{
+			"unclassified error",
+			"https://example.com/",
+			errors.New("something"),
+			"Something went wrong",
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dom := errorPage(test.url, test.err)
+			if dom.Root == nil {
+				t.Fatalf("Expected error page | Got: nil root")
+			}
+
+			var title, retryHref string
+			var find func(node *parser.Node)
+			find = func(node *parser.Node) {
+				if node.Tag == parser.Title && len(node.Children) > 0 {
+					title = node.Children[0].Inner
+				}
+				if node.Tag == parser.A {
+					retryHref = node.Attrs["href"]
+				}
+				for _, child := range node.Children {
+					find(child)
+				}
+			}
+			find(dom.Root)
+
+			if title != test.title {
+				t.Errorf("Expected title: %v | Got: %v", test.title, title)
+			}
+			if test.wantRetry && retryHref != test.url {
+				t.Errorf("Expected retry link: %v | Got: %v", test.url, retryHref)
+			}
+			if !test.wantRetry && retryHref != "" {
+				t.Errorf("Expected no retry link | Got: %v", retryHref)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"unicode"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// FindOptions are the find bar's toggles
+type FindOptions struct {
+	CaseSensitive bool
+	WholeWord     bool // the match isn't a part of a longer word
+}
+
+// FindMatch is a match of find in page in a text node.
+// Start and End count the runes of the node's Inner, like the ui's text does.
+type FindMatch struct {
+	Node       *parser.Node
+	Start, End int
+}
+
+// FindInPage finds the query in the text the page shows, in document order.
+// A match doesn't go across text nodes e.g. half in a link.
+func FindInPage(root *parser.Node, query string, options FindOptions) []FindMatch {
+	pattern := []rune(query)
+	if root == nil || len(pattern) == 0 {
+		return nil
+	}
+
+	var matches []FindMatch
+	var walk func(node *parser.Node)
+	walk = func(node *parser.Node) {
+		if node.Tag == parser.Head {
+			return // nothing in <head> is on the page
+		}
+		if node.Tag == parser.Text {
+			text := []rune(node.Inner)
+			for i := 0; i+len(pattern) <= len(text); i++ {
+				if matchAt(text, i, pattern, options) {
+					matches = append(matches, FindMatch{Node: node, Start: i, End: i + len(pattern)})
+					i += len(pattern) - 1 // matches don't overlap
+				}
+			}
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(root)
+	return matches
+}
+
+// matchAt tells if the pattern is in the text at i
+func matchAt(text []rune, i int, pattern []rune, options FindOptions) bool {
+	for j, r := range pattern {
+		if text[i+j] != r && (options.CaseSensitive || !equalFoldRune(text[i+j], r)) {
+			return false
+		}
+	}
+	if !options.WholeWord {
+		return true
+	}
+	end := i + len(pattern)
+	return (i == 0 || !isWordRune(text[i-1])) && (end == len(text) || !isWordRune(text[end]))
+}
+
+// equalFoldRune is strings.EqualFold for one rune
+func equalFoldRune(a, b rune) bool {
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+const findPage = `<html><head><title>Go go</title></head><body>
+<p>Go is fun. Gopher goes GO!</p>
+<p>Read the <a href="/doc">go docs</a>, ergo go.</p>
+</body></html>`
+
+// findList is "text[start:end]" of the matches in order
+func findList(matches []FindMatch) string {
+	list := make([]string, len(matches))
+	for i, match := range matches {
+		text := []rune(match.Node.Inner)
+		list[i] = fmt.Sprintf("%s[%d:%d]", string(text[match.Start:match.End]), match.Start, match.End)
+	}
+	return strings.Join(list, ",")
+}
+
+func TestFindInPage(t *testing.T) {
+	root, err := parser.Parse(findPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		query    string
+		options  FindOptions
+		expected string
+	}{
+		{"any case", "go", FindOptions{},
+			"Go[0:2],Go[11:13],go[18:20],GO[23:25],go[0:2],go[4:6],go[7:9]"},
+		{"case sensitive", "go", FindOptions{CaseSensitive: true}, "go[18:20],go[0:2],go[4:6],go[7:9]"},
+		{"whole word", "go", FindOptions{WholeWord: true}, "Go[0:2],GO[23:25],go[0:2],go[7:9]"},
+		{"both", "GO", FindOptions{CaseSensitive: true, WholeWord: true}, "GO[23:25]"},
+		{"across words", "ergo go", FindOptions{}, "ergo go[2:9]"},
+		{"not in the title", "Go go", FindOptions{CaseSensitive: true}, ""},
+		{"nothing to find", "", FindOptions{}, ""},
+		{"unicode", "GÖ", FindOptions{}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := findList(FindInPage(root, test.query, test.options)); got != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+		})
+	}
+
+	// rune offsets, not bytes
+	root = &parser.Node{Tag: parser.Root, Children: []*parser.Node{{Tag: parser.Text, Inner: "ÄÖü öÜ"}}}
+	if got := findList(FindInPage(root, "öü", FindOptions{})); got != "Öü[1:3],öÜ[4:6]" {
+		t.Errorf("Expected: Öü[1:3],öÜ[4:6] | Got: %v", got)
+	}
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithoutFragment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://example.com/page#intro", "https://example.com/page"},
+		{"https://example.com/page?q=1#intro", "https://example.com/page?q=1"},
+		{"https://example.com/page", "https://example.com/page"},
+		{"https://example.com/page#", "https://example.com/page#"}, // empty fragment, nothing to drop
+		{"about:keys#zoom", "about:keys"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			res := withoutFragment(test.input)
+			if res != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestResolveFragment(t *testing.T) {
+	tests := []struct {
+		href     string
+		base     string
+		expected string
+	}{
+		{"#end", "https://example.com/page", "https://example.com/page#end"},
+		{"#end", "https://example.com/page#intro", "https://example.com/page#end"},
+		{"other#end", "https://example.com/dir/page#intro", "https://example.com/dir/other#end"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.href+" from "+test.base, func(t *testing.T) {
+			res, err := ResolveJumpTarget(test.href, test.base)
+			if err != nil || res != test.expected {
+				t.Errorf("Expected: %v | Got: %v (%v)", test.expected, res, err)
+			}
+		})
+	}
+}
+
+func TestFragmentNavigation(t *testing.T) {
+	useTempDataDirs(t)
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>Hit %d</title></head><body><h1 id=\"end\">End</h1></body></html>", hits.Add(1))
+	}))
+	defer server.Close()
+
+	state := NewState()
+	startEngine(t, state)
+	id := state.Snapshot().Selected
+	page := server.URL + "/"
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: page}
+	loaded := waitTabTitle(t, state, "Hit 1")
+
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: page + "#end", Transition: LinkTransition}
+	moved := waitTabUrl(t, state, page+"#end")
+	if moved.Dom.Root != loaded.Dom.Root || hits.Load() != 1 {
+		t.Errorf("Expected: same document without fetching | Got: %v fetches", hits.Load())
+	}
+	// clicking the same anchor again stays at one entry
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: page + "#end", Transition: LinkTransition}
+
+	state.Notifier <- Notification{Type: NavBack, TabID: id}
+	back := waitTabUrl(t, state, page)
+	if back.Dom.Root != loaded.Dom.Root || hits.Load() != 1 {
+		t.Errorf("Expected: back to the same document without fetching | Got: %v fetches", hits.Load())
+	}
+
+	tab := state.tab(id)
+	state.mu.RLock()
+	urls, cur := tab.history.entries()
+	state.mu.RUnlock()
+	if len(urls) != 2 || cur != 0 {
+		t.Errorf("Expected: [%v %v#end] at 0 | Got: %v at %v", page, page, urls, cur)
+	}
+}
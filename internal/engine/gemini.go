@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	urlPkg "net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const geminiDefaultPort = "1965"
+
+// first digit of gemini status codes, the others (4, 5, 6) are failures
+const (
+	geminiInput    = 1
+	geminiSuccess  = 2
+	geminiRedirect = 3
+)
+
+// status 11 is input that shouldn't be shown e.g. password
+const geminiSensitiveInput = 11
+
+var geminiStatusText = map[int]string{
+	40: "Temporary failure",
+	41: "Server unavailable",
+	42: "CGI error",
+	43: "Proxy error",
+	44: "Slow down",
+	50: "Permanent failure",
+	51: "Not found",
+	52: "Gone",
+	53: "Proxy request refused",
+	59: "Bad request",
+	60: "Client certificate required",
+	61: "Certificate not authorised",
+	62: "Certificate not valid",
+}
+
+const knownHostsFile = "known_hosts"
+
+// gemini servers mostly have self-signed certificates, so instead of asking CAs
+// we trust the certificate we see on the first visit and expect the same one next time
+var geminiHosts = newKnownHosts(dataFile(knownHostsFile))
+
+var errInvalidGeminiHeader = errors.New("invalid gemini response header")
+
+// geminiStatus returns the text of the gemini status code
+func geminiStatus(code int) string {
+	if text, ok := geminiStatusText[code]; ok {
+		return text
+	}
+	// unknown code means the same as its x0
+	return geminiStatusText[code/10*10]
+}
+
+// fetchGemini requests url from its gemini server and follows the redirects.
+// Input request becomes a page with a form to answer it.
+func fetchGemini(ctx context.Context, url urlPkg.URL) (*Resource, error) {
+	for redirects := 0; ; redirects++ {
+		status, meta, body, err := geminiRequest(ctx, &url)
+		if err != nil {
+			return nil, newFetchError(url.String(), err)
+		}
+
+		switch status / 10 {
+		case geminiInput:
+			body.Close()
+			return inputPage(&url, meta, status == geminiSensitiveInput), nil
+		case geminiSuccess:
+			contentType, params := "text/gemini", map[string]string{"charset": "utf-8"}
+			if meta != "" {
+				contentType, params, err = mime.ParseMediaType(meta)
+				if err != nil {
+					contentType = mediaType(meta)
+				}
+			}
+			return &Resource{ReadCloser: body, Url: &url, ContentType: contentType, Charset: params["charset"], Length: -1}, nil
+		case geminiRedirect:
+			body.Close()
+			if redirects >= settings.MaxRedirects {
+				return nil, newFetchError(url.String(), errTooManyRedirects)
+			}
+			target, err := url.Parse(meta)
+			if err != nil {
+				return nil, &FetchError{Kind: InvalidUrlError, Url: url.String(), Err: fmt.Errorf("url.Parse: %w", err)}
+			}
+			if target.Scheme != "gemini" {
+				return Fetch(ctx, *target)
+			}
+			url = *target
+		default:
+			body.Close()
+			return nil, &FetchError{Kind: GeminiError, Url: url.String(), StatusCode: status, Err: errors.New(meta)}
+		}
+	}
+}
+
+// geminiRequest sends the request for url and reads the response header,
+// it returns the status code, the meta and the body to read the rest.
+func geminiRequest(ctx context.Context, url *urlPkg.URL) (int, string, io.ReadCloser, error) {
+	request := url.String()
+	if len(request) > 1024 {
+		return 0, "", nil, &FetchError{Kind: InvalidUrlError, Url: request, Err: errors.New("gemini url is longer than 1024 bytes")}
+	}
+
+	host := url.Host
+	if url.Port() == "" {
+		host = net.JoinHostPort(url.Hostname(), geminiDefaultPort)
+	}
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: settings.RequestTimeout},
+		Config: &tls.Config{
+			ServerName: url.Hostname(),
+			MinVersion: tls.VersionTLS12,
+			// we verify it ourselves, see knownHosts
+			InsecureSkipVerify: true,
+			VerifyConnection: func(state tls.ConnectionState) error {
+				return geminiHosts.check(host, state.PeerCertificates)
+			},
+		},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("dialer.DialContext: %w", err)
+	}
+	body := newConnBody(ctx, conn)
+
+	if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+		body.Close()
+		return 0, "", nil, fmt.Errorf("conn.Write: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	body.Reader = reader
+	status, meta, err := readGeminiHeader(reader)
+	if err != nil {
+		body.Close()
+		if ctx.Err() != nil {
+			return 0, "", nil, ctx.Err()
+		}
+		return 0, "", nil, fmt.Errorf("readGeminiHeader: %w", err)
+	}
+	return status, meta, body, nil
+}
+
+// readGeminiHeader reads "<status> <meta>\r\n", meta is at most 1024 bytes
+func readGeminiHeader(reader *bufio.Reader) (int, string, error) {
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return 0, "", errInvalidGeminiHeader
+		}
+		return 0, "", err
+	}
+	header := strings.TrimRight(string(line), "\r\n")
+	if len(header) < 2 || len(header) > 3+1024 {
+		return 0, "", errInvalidGeminiHeader
+	}
+	status, err := strconv.Atoi(header[:2])
+	if err != nil || status < 10 {
+		return 0, "", errInvalidGeminiHeader
+	}
+	meta := strings.TrimSpace(header[2:])
+	return status, meta, nil
+}
+
+// knownHosts pins the certificate of each host the first time we see it (trust on first use).
+// The pins are saved to a file, one "host sha256-fingerprint expiry-unix-time" per line.
+type knownHosts struct {
+	mu   sync.Mutex
+	path string // empty means the pins are only in memory
+	pins map[string]hostPin
+}
+
+type hostPin struct {
+	fingerprint string
+	notAfter    time.Time
+}
+
+// newKnownHosts loads the pins from the file at path, missing file is just no pins
+func newKnownHosts(path string) *knownHosts {
+	hosts := &knownHosts{path: path, pins: make(map[string]hostPin)}
+	if path == "" {
+		return hosts
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Println("newKnownHosts: os.ReadFile:", err)
+		}
+		return hosts
+	}
+	for i, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		// a lost pin trusts whatever the host sends next time, so tell about it
+		if len(fields) != 3 {
+			log.Printf("newKnownHosts: %s:%d: expected host, fingerprint and expiry: %q", path, i+1, line)
+			continue
+		}
+		expiry, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			log.Printf("newKnownHosts: %s:%d: strconv.ParseInt: %v", path, i+1, err)
+			continue
+		}
+		hosts.pins[fields[0]] = hostPin{fingerprint: fields[1], notAfter: time.Unix(expiry, 0)}
+	}
+	return hosts
+}
+
+// check accepts the certificate of host if it's the pinned one, or if there is no pin
+// (first visit or the pinned one has expired) in which case it becomes the pin.
+func (k *knownHosts) check(host string, certs []*x509.Certificate) error {
+	if len(certs) == 0 {
+		return &tls.CertificateVerificationError{Err: errors.New("server sent no certificate")}
+	}
+	leaf := certs[0]
+	now := time.Now()
+	if now.After(leaf.NotAfter) || now.Before(leaf.NotBefore) {
+		return &tls.CertificateVerificationError{UnverifiedCertificates: certs,
+			Err: x509.CertificateInvalidError{Cert: leaf, Reason: x509.Expired}}
+	}
+	sum := sha256.Sum256(leaf.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	pin, ok := k.pins[host]
+	if ok && pin.fingerprint == fingerprint {
+		return nil
+	}
+	if ok && now.Before(pin.notAfter) {
+		return &tls.CertificateVerificationError{UnverifiedCertificates: certs,
+			Err: fmt.Errorf("certificate of %s is not the one we saw first, which is valid until %s",
+				host, pin.notAfter.Format(time.DateOnly))}
+	}
+
+	k.pins[host] = hostPin{fingerprint: fingerprint, notAfter: leaf.NotAfter}
+	if err := k.save(); err != nil {
+		log.Println("knownHosts.check: save:", err)
+	}
+	return nil
+}
+
+// save writes all pins to the file atomically, a half written one would lose pins. k.mu must be held
+func (k *knownHosts) save() error {
+	if k.path == "" {
+		return nil
+	}
+	hosts := make([]string, 0, len(k.pins))
+	for host := range k.pins {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var builder strings.Builder
+	for _, host := range hosts {
+		pin := k.pins[host]
+		fmt.Fprintf(&builder, "%s %s %d\n", host, pin.fingerprint, pin.notAfter.Unix())
+	}
+	return writeFileAtomic(k.path, []byte(builder.String()))
+}
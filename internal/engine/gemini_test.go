@@ -0,0 +1,324 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	urlPkg "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate makes a self-signed certificate like most gemini servers have
+func testCertificate(t *testing.T, notAfter time.Time) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// startGeminiServer starts a local gemini server answering with the response of the
+// requested path (and query), unknown path is 51. It returns the url of the server.
+func startGeminiServer(t *testing.T, cert tls.Certificate, responses map[string]string) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("tls.Listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				url, err := urlPkg.Parse(strings.TrimSpace(line))
+				if err != nil {
+					return
+				}
+				key := url.Path
+				if url.RawQuery != "" {
+					key += "?" + url.RawQuery
+				}
+				response, ok := responses[key]
+				if !ok {
+					response = "51 Not found\r\n"
+				}
+				io.WriteString(conn, response)
+			}()
+		}
+	}()
+	return "gemini://" + listener.Addr().String()
+}
+
+// useKnownHosts swaps the pins for an empty one in a temp file during the test
+func useKnownHosts(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), knownHostsFile)
+	old := geminiHosts
+	geminiHosts = newKnownHosts(path)
+	t.Cleanup(func() { geminiHosts = old })
+	return path
+}
+
+func fingerprint(cert tls.Certificate) string {
+	sum := sha256.Sum256(cert.Leaf.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFetchGemini(t *testing.T) {
+	useKnownHosts(t)
+	server := startGeminiServer(t, testCertificate(t, time.Now().Add(time.Hour)), map[string]string{
+		"/":             "20 text/gemini; charset=utf-8\r\n# Hello\n=> /next Next\n",
+		"/plain":        "20\r\nno meta is gemtext",
+		"/old":          "31 /\r\n",
+		"/loop":         "30 /loop\r\n",
+		"/busy":         "44 60\r\n",
+		"/search":       "10 What to search?\r\n",
+		"/search?gazer": "20 text/plain\r\nfound it",
+		"/broken":       "hello\r\n",
+	})
+
+	tests := []struct {
+		name        string
+		path        string
+		finalPath   string // checked if fetch succeeds
+		contentType string
+		kind        ErrorKind // checked if fetch fails
+		statusCode  int
+		ok          bool
+	}{
+		{"ok", "/", "/", "text/gemini", 0, 0, true},
+		{"no meta", "/plain", "/plain", "text/gemini", 0, 0, true},
+		{"redirect", "/old", "/", "text/gemini", 0, 0, true},
+		{"redirect loop", "/loop", "", "", TooManyRedirectsError, 0, false},
+		{"not found", "/missing", "", "", GeminiError, 51, false},
+		{"slow down", "/busy", "", "", GeminiError, 44, false},
+		{"input", "/search", "/search", "text/html", 0, 0, true},
+		{"input answered", "/search?gazer", "/search?gazer", "text/plain", 0, 0, true},
+		{"broken header", "/broken", "", "", UnknownError, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url, _ := urlPkg.Parse(server + test.path)
+			resource, err := Fetch(context.Background(), *url)
+			if !test.ok {
+				var fetchErr *FetchError
+				if !errors.As(err, &fetchErr) {
+					t.Fatalf("Expected: FetchError | Got: %v", err)
+				}
+				if fetchErr.Kind != test.kind || fetchErr.StatusCode != test.statusCode {
+					t.Errorf("Expected: %v %v | Got: %v %v", test.kind, test.statusCode, fetchErr.Kind, fetchErr.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error | Got: %v", err)
+			}
+			defer resource.Close()
+			if got := resource.Url.String(); got != server+test.finalPath {
+				t.Errorf("Expected: %v | Got: %v", server+test.finalPath, got)
+			}
+			if resource.ContentType != test.contentType {
+				t.Errorf("Expected: %v | Got: %v", test.contentType, resource.ContentType)
+			}
+		})
+	}
+
+	// gemtext becomes a DOM titled by its first heading
+	url, _ := urlPkg.Parse(server + "/")
+	reporter := newProgressReporter(context.Background(), newTab(1), new(fakeWindow), "")
+	dom, _, err := getDom(context.Background(), *url, reporter)
+	if err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	if dom.Kind != GeminiDocument || pageText(dom.Root) != "HelloHelloNext" {
+		t.Errorf("Expected: %v HelloHelloNext | Got: %v %v", GeminiDocument, dom.Kind, pageText(dom.Root))
+	}
+}
+
+func TestGeminiPinning(t *testing.T) {
+	path := useKnownHosts(t)
+	cert := testCertificate(t, time.Now().Add(time.Hour))
+	server := startGeminiServer(t, cert, map[string]string{"/": "20 text/gemini\r\nhi"})
+	url, _ := urlPkg.Parse(server + "/")
+	host := url.Host
+
+	// first visit pins the certificate to the file
+	resource, err := Fetch(context.Background(), *url)
+	if err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	resource.Close()
+	content, _ := os.ReadFile(path)
+	if !strings.Contains(string(content), host+" "+fingerprint(cert)) {
+		t.Fatalf("Expected: pin of %v | Got: %v", host, string(content))
+	}
+
+	// another certificate for the same host is refused while the pinned one is valid
+	other := testCertificate(t, time.Now().Add(time.Hour))
+	geminiHosts = newKnownHosts(path)
+	if err := geminiHosts.check(host, []*x509.Certificate{cert.Leaf}); err != nil {
+		t.Errorf("Expected no error | Got: %v", err)
+	}
+	if err := geminiHosts.check(host, []*x509.Certificate{other.Leaf}); err == nil || classifyError(err) != TlsError {
+		t.Errorf("Expected: %v | Got: %v", TlsError, err)
+	}
+
+	// the server changes its certificate, the fetch fails
+	os.WriteFile(path, []byte(fmt.Sprintf("%s %s %d\n", host, fingerprint(other), time.Now().Add(time.Hour).Unix())), 0o600)
+	geminiHosts = newKnownHosts(path)
+	_, err = Fetch(context.Background(), *url)
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || fetchErr.Kind != TlsError {
+		t.Errorf("Expected: %v | Got: %v", TlsError, err)
+	}
+
+	// after the pinned certificate expires, the new one is pinned
+	os.WriteFile(path, []byte(fmt.Sprintf("%s %s %d\n", host, fingerprint(other), time.Now().Add(-time.Hour).Unix())), 0o600)
+	geminiHosts = newKnownHosts(path)
+	resource, err = Fetch(context.Background(), *url)
+	if err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	resource.Close()
+	if pin := geminiHosts.pins[host]; pin.fingerprint != fingerprint(cert) {
+		t.Errorf("Expected: %v | Got: %v", fingerprint(cert), pin.fingerprint)
+	}
+
+	// expired certificate is never accepted
+	expired := testCertificate(t, time.Now().Add(-time.Minute))
+	if err := geminiHosts.check("new.example:1965", []*x509.Certificate{expired.Leaf}); err == nil {
+		t.Errorf("Expected: error | Got: %v", err)
+	}
+}
+
+func TestKnownHostsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), knownHostsFile)
+	content := "a.example:1965 aaaa 4102444800\n" +
+		"broken line\n" +
+		"b.example:1965 bbbb soon\n" +
+		"\n" +
+		"c.example:1965 cccc 4102444800\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the broken lines are skipped (and logged), the others are still pinned
+	hosts := newKnownHosts(path)
+	if len(hosts.pins) != 2 || hosts.pins["a.example:1965"].fingerprint != "aaaa" || hosts.pins["c.example:1965"].fingerprint != "cccc" {
+		t.Errorf("Expected: pins of a and c | Got: %v", hosts.pins)
+	}
+
+	// saving leaves only the file, no temporary one next to it
+	if err := hosts.save(); err != nil {
+		t.Fatalf("Expected no error | Got: %v", err)
+	}
+	if got := newKnownHosts(path).pins; len(got) != 2 {
+		t.Errorf("Expected: 2 pins | Got: %v", got)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Expected: only %v | Got: %v", knownHostsFile, entries)
+	}
+}
+
+func TestInputAnswer(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		expected  string
+		sensitive bool
+		ok        bool
+	}{
+		{"gemini", "gemini://example.com/search?gazer_input=what+is+%2B+gazer%3F", "gemini://example.com/search?what%20is%20%2B%20gazer%3F", false, true},
+		{"gopher", "gopher://example.com/7/find?gazer_input=go+lang", "gopher://example.com/7/find?go%20lang", false, true},
+		{"sensitive", "gemini://example.com/login?gazer_sensitive_input=s3cret", "gemini://example.com/login?s3cret", true, true},
+		{"empty", "gemini://example.com/search?gazer_input=", "gemini://example.com/search", false, true},
+		{"other fields", "gemini://example.com/search?gazer_input=go&page=2", "gemini://example.com/search?gazer_input=go&page=2", false, false},
+		{"web page form", "https://example.com/search?gazer_input=go", "https://example.com/search?gazer_input=go", false, false},
+		{"isindex is any name", "gemini://example.com/search?isindex=go", "gemini://example.com/search?isindex=go", false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url, _ := urlPkg.Parse(test.url)
+			got, sensitive, ok := inputAnswer(url)
+			if got.String() != test.expected || sensitive != test.sensitive || ok != test.ok {
+				t.Errorf("Expected: %v %v %v | Got: %v %v %v", test.expected, test.sensitive, test.ok, got, sensitive, ok)
+			}
+		})
+	}
+}
+
+func TestSensitiveInputNotRecorded(t *testing.T) {
+	useTempDataDirs(t)
+	useKnownHosts(t)
+	server := startGeminiServer(t, testCertificate(t, time.Now().Add(time.Hour)), map[string]string{
+		"/login":        "11 Password?\r\n",
+		"/login?s3cret": "20 text/gemini\r\n# Welcome\n",
+	})
+
+	state := NewState()
+	startEngine(t, state)
+	id := state.Snapshot().Selected
+	url, _ := urlPkg.Parse(server + "/login")
+	page, _ := io.ReadAll(inputPage(url, "Password?", true))
+	if !strings.Contains(string(page), `type="password" name="gazer_sensitive_input"`) {
+		t.Errorf("Expected: a password input | Got: %s", page)
+	}
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: server + "/login"}
+	waitTabUrl(t, state, server+"/login")
+
+	// the renderer submits the form like any other
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: server + "/login?gazer_sensitive_input=s3cret", Transition: FormTransition}
+	waitTabTitle(t, state, "Welcome")
+	waitTabUrl(t, state, server+"/login?s3cret")
+
+	// the answer is in neither the history nor the session, the session is back at the prompt
+	state.SaveSession()
+	state.history.flush()
+	for _, file := range []string{historyFile, sessionFile} {
+		if content, _ := os.ReadFile(dataFile(file)); strings.Contains(string(content), "s3cret") {
+			t.Errorf("Expected: no answer in %v | Got: %s", file, content)
+		}
+	}
+	saved, _ := loadSession()
+	if tab := saved.Tabs[0]; len(tab.Entries) != 1 || tab.Current != 0 || tab.Entries[0].Url != server+"/login" {
+		t.Errorf("Expected: only the prompt | Got: %+v", tab)
+	}
+	if got := entryUrls(state.QueryHistory(HistoryQuery{})); got != server+"/login" {
+		t.Errorf("Expected: only the prompt | Got: %v", got)
+	}
+}
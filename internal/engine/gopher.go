@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	urlPkg "net/url"
+	"time"
+
+	"github.com/WaronLimsakul/Gazer/internal/gopher"
+)
+
+// made-up media type of gopher menus, it's not a real one but Lynx uses it too
+const gopherMenuType = "application/gopher-menu"
+
+// media types of the gopher item types, others are sniffed
+var gopherTypes = map[byte]string{
+	'0': "text/plain",
+	'1': gopherMenuType,
+	'7': gopherMenuType, // search result is a menu
+	'h': "text/html",
+	'g': "image/gif",
+}
+
+// fetchGopher requests the selector of url from its gopher server.
+// Search item without the search words becomes a page asking for them.
+func fetchGopher(ctx context.Context, url urlPkg.URL) (*Resource, error) {
+	itemType, selector, search := gopher.ParseUrl(&url)
+	if itemType == '7' && search == "" {
+		return inputPage(&url, "Search "+url.Host, false), nil
+	}
+
+	host := url.Host
+	if url.Port() == "" {
+		host = net.JoinHostPort(url.Hostname(), gopher.DefaultPort)
+	}
+	dialer := &net.Dialer{Timeout: settings.RequestTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, newFetchError(url.String(), fmt.Errorf("dialer.DialContext: %w", err))
+	}
+	body := newConnBody(ctx, conn)
+
+	request := selector
+	if search != "" {
+		request += "\t" + search
+	}
+	if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+		body.Close()
+		return nil, newFetchError(url.String(), fmt.Errorf("conn.Write: %w", err))
+	}
+
+	contentType := gopherTypes[itemType]
+	body.Reader = conn
+	if itemType == '0' || contentType == gopherMenuType {
+		// text ends at the "." line
+		body.Reader = lenientDotReader{textproto.NewReader(bufio.NewReader(conn)).DotReader()}
+	}
+	return &Resource{ReadCloser: body, Url: &url, ContentType: contentType, Length: -1}, nil
+}
+
+// lenientDotReader is fine when the text ends without the "." line, many gopher servers just close
+type lenientDotReader struct {
+	io.Reader
+}
+
+func (r lenientDotReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// connBody is the rest of a response read right from the connection,
+// closing it closes the connection
+type connBody struct {
+	io.Reader
+	conn net.Conn
+	stop func() bool // stops closing conn when the context is done
+}
+
+// newConnBody reads nothing until Reader is set. The connection is closed when ctx is done,
+// and like http.Client.Timeout, the whole response has to be read in time.
+func newConnBody(ctx context.Context, conn net.Conn) *connBody {
+	conn.SetDeadline(time.Now().Add(settings.RequestTimeout))
+	return &connBody{conn: conn, stop: context.AfterFunc(ctx, func() { conn.Close() })}
+}
+
+func (b *connBody) Close() error {
+	b.stop()
+	return b.conn.Close()
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	urlPkg "net/url"
+	"strings"
+	"testing"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// startGopherServer starts a local gopher server answering with the response of the
+// requested selector (with "\t<search>" if any), unknown selector is an error menu.
+// It returns the url of the server.
+func startGopherServer(t *testing.T, responses map[string]string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				response, ok := responses[strings.TrimRight(line, "\r\n")]
+				if !ok {
+					response = "3Not found\t\terror.host\t1\r\n.\r\n"
+				}
+				io.WriteString(conn, response)
+			}()
+		}
+	}()
+	return "gopher://" + listener.Addr().String()
+}
+
+// linkHrefs collects the href of all <a> in the DOM tree in order
+func linkHrefs(node *parser.Node) []string {
+	var res []string
+	if node.Tag == parser.A {
+		return []string{node.Attrs["href"]}
+	}
+	for _, child := range node.Children {
+		res = append(res, linkHrefs(child)...)
+	}
+	return res
+}
+
+func TestFetchGopher(t *testing.T) {
+	server := startGopherServer(t, map[string]string{
+		"": "iHello gopher\tfake\t(NULL)\t0\r\n" +
+			"0About\t/about.txt\t127.0.0.1\t70\r\n" +
+			"7Search\t/search\t127.0.0.1\t70\r\n.\r\n",
+		"/about.txt":          "Gazer is a toy browser.\r\n..dot line\r\n.\r\n",
+		"/no-dot.txt":         "servers forget the dot",
+		"/search\tgazer tabs": "0Tabs\t/tabs.txt\t127.0.0.1\t70\r\n.\r\n",
+		"/logo.gif":           "GIF89a...",
+	})
+
+	host := strings.TrimPrefix(server, "gopher://")
+	tests := []struct {
+		name  string
+		path  string
+		kind  DocumentKind
+		text  string   // page text of the DOM, or the source
+		links []string // href of the links in the DOM
+	}{
+		{"root menu", "", GopherMenuDocument, host + "      Hello gopher(TXT) About(?)   Search",
+			[]string{"gopher://127.0.0.1/0/about.txt", "gopher://127.0.0.1/7/search"}},
+		{"text", "/0/about.txt", TextDocument, "Gazer is a toy browser.\n.dot line\n", nil},
+		{"text without the dot", "/0/no-dot.txt", TextDocument, "servers forget the dot", nil},
+		{"search prompt", "/7/search", HtmlDocument, host + "Search " + host, nil},
+		{"search", "/7/search?gazer%20tabs", GopherMenuDocument, "search(TXT) Tabs", []string{"gopher://127.0.0.1/0/tabs.txt"}},
+		{"search in the path", "/7/search%09gazer%20tabs", GopherMenuDocument, "search(TXT) Tabs", []string{"gopher://127.0.0.1/0/tabs.txt"}},
+		{"image", "/g/logo.gif", ImageDocument, "", nil},
+		{"error menu", "/1/missing", GopherMenuDocument, "missing      Not found", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url, _ := urlPkg.Parse(server + test.path)
+			reporter := newProgressReporter(context.Background(), newTab(1), new(fakeWindow), "")
+			dom, _, err := getDom(context.Background(), *url, reporter)
+			if err != nil {
+				t.Fatalf("Expected no error | Got: %v", err)
+			}
+			if dom.Kind != test.kind {
+				t.Errorf("Expected: %v | Got: %v", test.kind, dom.Kind)
+			}
+			text := dom.Source
+			if test.kind != TextDocument {
+				text = pageText(dom.Root)
+			}
+			if test.kind != ImageDocument && text != test.text {
+				t.Errorf("Expected: %q | Got: %q", test.text, text)
+			}
+			if got := strings.Join(linkHrefs(dom.Root), ","); got != strings.Join(test.links, ",") {
+				t.Errorf("Expected: %v | Got: %v", test.links, got)
+			}
+		})
+	}
+
+	// nothing is listening here anymore
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	listener.Close()
+	url, _ := urlPkg.Parse("gopher://" + listener.Addr().String() + "/")
+	_, err := Fetch(context.Background(), *url)
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || fetchErr.Kind != ConnectionError {
+		t.Errorf("Expected: %v | Got: %v", ConnectionError, err)
+	}
+}
@@ -0,0 +1,298 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	urlPkg "net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// Transition is how the user got to a page
+type Transition uint8
+
+const (
+	TypedTransition     Transition = iota // typed in the search bar (or anything else we don't know better)
+	LinkTransition                        // clicked a link
+	FormTransition                        // submitted a form
+	BookmarkTransition                    // clicked a bookmark
+	SensitiveTransition                   // answered a sensitive input e.g. a password is in the url, never recorded
+)
+
+// browsing history of all tabs lives in the data directory
+const historyFile = "history.json"
+
+// an entry only remembers this many of its latest visits, VisitCount still counts them all
+const maxVisitsPerEntry = 50
+
+// HistoryEntry is a page in the browsing history, one per url
+type HistoryEntry struct {
+	Url        string  `json:"url"`
+	Title      string  `json:"title"`
+	VisitCount int     `json:"visit_count"`
+	Visits     []Visit `json:"visits"` // oldest first
+}
+
+type Visit struct {
+	Time       time.Time  `json:"time"`
+	Transition Transition `json:"transition"`
+}
+
+// HistoryQuery filters the history, zero value matches everything
+type HistoryQuery struct {
+	Text   string    // url or title contains it (case insensitive)
+	Prefix string    // url starts with it, with or without the scheme and "www." e.g. "exa" matches https://www.example.com
+	From   time.Time // visited at or after it, zero means no limit
+	To     time.Time // visited before it, zero means no limit
+	Limit  int       // at most this many entries, 0 means no limit
+}
+
+// historyStore is the browsing history shared by all tabs, saved to path by flush.
+// Tabs keep their own back/forward list, but the pages in it are entries here, looked up by url.
+type historyStore struct {
+	mu      sync.Mutex
+	path    string // empty means keep nothing on disk
+	entries map[string]*HistoryEntry
+	dirty   bool // changed since the latest flush
+
+	saveMu sync.Mutex // one writer at a time, so an older history never lands over a newer one
+}
+
+// LastVisit is the time of the latest visit, zero if never visited
+func (e HistoryEntry) LastVisit() time.Time {
+	if len(e.Visits) == 0 {
+		return time.Time{}
+	}
+	return e.Visits[len(e.Visits)-1].Time
+}
+
+func newHistoryStore(path string) *historyStore {
+	store := &historyStore{path: path, entries: make(map[string]*HistoryEntry)}
+	if path == "" {
+		return store
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Println("newHistoryStore: os.ReadFile:", err)
+		}
+		return store
+	}
+	var entries []*HistoryEntry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		log.Println("newHistoryStore: json.Unmarshal:", err)
+		return store
+	}
+	for _, entry := range entries {
+		store.entries[entry.Url] = entry
+	}
+	return store
+}
+
+// visit records a visit of url now, title replaces the old one unless it's empty
+func (h *historyStore) visit(url, title string, transition Transition) {
+	if transition == SensitiveTransition {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry, ok := h.entries[url]
+	if !ok {
+		entry = &HistoryEntry{Url: url}
+		h.entries[url] = entry
+	}
+	if title != "" {
+		entry.Title = title
+	}
+	entry.VisitCount++
+	entry.Visits = append(entry.Visits, Visit{Time: time.Now(), Transition: transition})
+	if len(entry.Visits) > maxVisitsPerEntry {
+		entry.Visits = entry.Visits[len(entry.Visits)-maxVisitsPerEntry:]
+	}
+	h.dirty = true // visits are many, they go to disk with the session autosave
+}
+
+// get returns a copy of the entry of url, false if it's not in the history
+func (h *historyStore) get(url string) (HistoryEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.entries[url]
+	if !ok {
+		return HistoryEntry{}, false
+	}
+	return copyEntry(entry), true
+}
+
+// query returns copies of the entries that match, the latest visited first
+func (h *historyStore) query(query HistoryQuery) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var res []HistoryEntry
+	for _, entry := range h.entries {
+		if query.matches(entry) {
+			res = append(res, copyEntry(entry))
+		}
+	}
+	slices.SortFunc(res, func(a, b HistoryEntry) int {
+		return b.LastVisit().Compare(a.LastVisit())
+	})
+	if query.Limit > 0 && len(res) > query.Limit {
+		res = res[:query.Limit]
+	}
+	return res
+}
+
+// delete forgets the url, it's gone from the disk right away
+func (h *historyStore) delete(url string) {
+	h.mu.Lock()
+	delete(h.entries, url)
+	h.dirty = true
+	h.mu.Unlock()
+	h.flush()
+}
+
+// clear forgets everything, it's gone from the disk right away
+func (h *historyStore) clear() {
+	h.mu.Lock()
+	h.entries = make(map[string]*HistoryEntry)
+	h.dirty = true
+	h.mu.Unlock()
+	h.flush()
+}
+
+// flush writes the history to the file if it changed. Only marshalling holds h.mu,
+// the disk doesn't hold up the queries e.g. the omnibox suggesting while typing.
+func (h *historyStore) flush() {
+	if h.path == "" {
+		return
+	}
+	h.saveMu.Lock()
+	defer h.saveMu.Unlock()
+
+	h.mu.Lock()
+	if !h.dirty {
+		h.mu.Unlock()
+		return
+	}
+	entries := make([]*HistoryEntry, 0, len(h.entries))
+	for _, entry := range h.entries {
+		entries = append(entries, entry)
+	}
+	// same order every time, so the file doesn't shuffle around
+	slices.SortFunc(entries, func(a, b *HistoryEntry) int { return strings.Compare(a.Url, b.Url) })
+
+	content, err := json.Marshal(entries)
+	h.dirty = false
+	h.mu.Unlock()
+	if err != nil {
+		log.Println("historyStore.flush: json.Marshal:", err)
+		return
+	}
+	if err := writeFileAtomic(h.path, content); err != nil {
+		log.Println("historyStore.flush:", err)
+		h.mu.Lock()
+		h.dirty = true // try again next time
+		h.mu.Unlock()
+	}
+}
+
+func (q HistoryQuery) matches(entry *HistoryEntry) bool {
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		if !strings.Contains(strings.ToLower(entry.Url), text) && !strings.Contains(strings.ToLower(entry.Title), text) {
+			return false
+		}
+	}
+	if q.Prefix != "" && !urlHasPrefix(entry.Url, q.Prefix) {
+		return false
+	}
+	if q.From.IsZero() && q.To.IsZero() {
+		return true
+	}
+	// any visit in the range will do
+	for _, visit := range entry.Visits {
+		if (q.From.IsZero() || !visit.Time.Before(q.From)) && (q.To.IsZero() || visit.Time.Before(q.To)) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlHasPrefix tells if url starts with prefix, the scheme and "www." are optional
+// e.g. "example.com/a", "www.ex" and "https://exa" are all prefixes of https://www.example.com/a
+func urlHasPrefix(url, prefix string) bool {
+	url, prefix = strings.ToLower(url), strings.ToLower(prefix)
+	if strings.HasPrefix(url, prefix) {
+		return true
+	}
+	_, rest, found := strings.Cut(url, "://")
+	if !found {
+		return false
+	}
+	return strings.HasPrefix(rest, prefix) || strings.HasPrefix(strings.TrimPrefix(rest, "www."), prefix)
+}
+
+func copyEntry(entry *HistoryEntry) HistoryEntry {
+	res := *entry
+	res.Visits = slices.Clone(entry.Visits)
+	return res
+}
+
+// QueryHistory returns the entries of the browsing history that match, the latest visited first
+func (s *State) QueryHistory(query HistoryQuery) []HistoryEntry {
+	return s.history.query(query)
+}
+
+// historyAction does what the about:history link asks (?delete=<url> or ?clear),
+// false if it asks nothing
+func historyAction(store *historyStore, query urlPkg.Values) bool {
+	if urls, ok := query["delete"]; ok {
+		for _, url := range urls {
+			store.delete(url)
+		}
+		return true
+	}
+	if _, ok := query["clear"]; ok {
+		store.clear()
+		return true
+	}
+	return false
+}
+
+// PageTitle is the text of <title> in the DOM, empty if there is none
+func PageTitle(root *parser.Node) string {
+	head := findHead(root)
+	if head == nil {
+		return ""
+	}
+	var title string
+	for _, node := range head.Children {
+		if node.Tag != parser.Title {
+			continue
+		}
+		for _, child := range node.Children {
+			if child.Tag == parser.Text {
+				title = child.Inner
+			}
+		}
+	}
+	return title
+}
+
+// parseHistoryDate parses the date of the about:history form e.g. "2024-01-31" in local time
+func parseHistoryDate(value string) (time.Time, error) {
+	date, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(value), time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time.ParseInLocation: %v", err)
+	}
+	return date, nil
+}
@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	urlPkg "net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// entryUrls is the urls of the entries in order
+func entryUrls(entries []HistoryEntry) string {
+	urls := make([]string, len(entries))
+	for i, entry := range entries {
+		urls[i] = entry.Url
+	}
+	return strings.Join(urls, ",")
+}
+
+func TestHistoryStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), historyFile)
+	store := newHistoryStore(path)
+	store.visit("https://www.example.com/", "Example", TypedTransition)
+	store.visit("https://gazer.dev/docs", "Gazer docs", LinkTransition)
+	store.visit("gemini://geminiprotocol.net/", "", TypedTransition)
+	store.visit("https://www.example.com/", "", LinkTransition) // title stays
+
+	// pretend the docs were visited last week
+	lastWeek := time.Now().AddDate(0, 0, -7)
+	store.entries["https://gazer.dev/docs"].Visits[0].Time = lastWeek
+
+	tests := []struct {
+		name     string
+		query    HistoryQuery
+		expected string
+	}{
+		{"everything, latest first", HistoryQuery{}, "https://www.example.com/,gemini://geminiprotocol.net/,https://gazer.dev/docs"},
+		{"text in title", HistoryQuery{Text: "DOCS"}, "https://gazer.dev/docs"},
+		{"text in url", HistoryQuery{Text: "protocol"}, "gemini://geminiprotocol.net/"},
+		{"prefix without scheme", HistoryQuery{Prefix: "exa"}, "https://www.example.com/"},
+		{"prefix with www", HistoryQuery{Prefix: "www.example.com/"}, "https://www.example.com/"},
+		{"prefix with scheme", HistoryQuery{Prefix: "gemini://"}, "gemini://geminiprotocol.net/"},
+		{"from", HistoryQuery{From: time.Now().AddDate(0, 0, -1)}, "https://www.example.com/,gemini://geminiprotocol.net/"},
+		{"to", HistoryQuery{To: time.Now().AddDate(0, 0, -1)}, "https://gazer.dev/docs"},
+		{"limit", HistoryQuery{Limit: 1}, "https://www.example.com/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := entryUrls(store.query(test.query)); got != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+		})
+	}
+
+	entry, ok := store.get("https://www.example.com/")
+	if !ok || entry.Title != "Example" || entry.VisitCount != 2 || entry.Visits[1].Transition != LinkTransition {
+		t.Errorf("Expected: Example 2 visits, last by link | Got: %+v", entry)
+	}
+
+	// visits wait for the flush, then it's all on disk for the next start
+	if got := newHistoryStore(path).query(HistoryQuery{}); len(got) != 0 {
+		t.Errorf("Expected: nothing on disk before the flush | Got: %v", entryUrls(got))
+	}
+	store.flush()
+	if got := entryUrls(newHistoryStore(path).query(HistoryQuery{})); got != entryUrls(store.query(HistoryQuery{})) {
+		t.Errorf("Expected: the visits on disk | Got: %v", got)
+	}
+	store.delete("https://gazer.dev/docs")
+	loaded := newHistoryStore(path)
+	if got := entryUrls(loaded.query(HistoryQuery{})); got != "https://www.example.com/,gemini://geminiprotocol.net/" {
+		t.Errorf("Expected: example and gemini | Got: %v", got)
+	}
+	loaded.clear()
+	if got := newHistoryStore(path).query(HistoryQuery{}); len(got) != 0 {
+		t.Errorf("Expected: empty history | Got: %v", entryUrls(got))
+	}
+}
+
+func TestPageTitle(t *testing.T) {
+	tests := []struct {
+		html     string
+		expected string
+	}{
+		{"<html><head><title>Gazer</title></head><body></body></html>", "Gazer"},
+		{"<html><head></head><body><title>not here</title></body></html>", ""},
+		{"<p>no head</p>", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.html, func(t *testing.T) {
+			root, _ := parser.Parse(test.html)
+			if got := PageTitle(root); got != test.expected {
+				t.Errorf("Expected: %q | Got: %q", test.expected, got)
+			}
+		})
+	}
+}
+
+// waitTabUrl waits until the selected tab has loaded the url
+func waitTabUrl(t *testing.T, state *State, url string) TabSnapshot {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		tab, _ := state.Snapshot().SelectedTab()
+		if tab.Url == url && !tab.IsLoading {
+			return tab
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected: %v | Got: %v (loading %v)", url, tab.Url, tab.IsLoading)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBrowsingHistory(t *testing.T) {
+	useTempDataDirs(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>Page %s</title></head><body></body></html>", r.URL.Path)
+	}))
+	defer server.Close()
+
+	state := NewState()
+	startEngine(t, state)
+	id := state.Snapshot().Selected
+
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: server.URL + "/a"}
+	waitTabUrl(t, state, server.URL+"/a")
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: server.URL + "/b", Transition: LinkTransition}
+	waitTabUrl(t, state, server.URL+"/b")
+	// error pages and going back are not visits
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: server.URL + "/missing"}
+	waitTabUrl(t, state, server.URL+"/missing")
+	state.Notifier <- Notification{Type: NavBack, TabID: id}
+	waitTabUrl(t, state, server.URL+"/b")
+
+	entries := state.QueryHistory(HistoryQuery{})
+	if got := entryUrls(entries); got != server.URL+"/b,"+server.URL+"/a" {
+		t.Fatalf("Expected: /b,/a | Got: %v", got)
+	}
+	if entries[0].Title != "Page /b" || entries[0].Visits[0].Transition != LinkTransition {
+		t.Errorf("Expected: Page /b by link | Got: %+v", entries[0])
+	}
+
+	// about:history shows the titles, and deletes with its own link
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: "about:history?q=" + urlPkg.QueryEscape("/a")}
+	tab := waitTabUrl(t, state, "about:history?q=%2Fa")
+	if text := pageText(tab.Dom.Root); !strings.Contains(text, "Page /a") || !strings.Contains(text, "1 visit") {
+		t.Errorf("Expected: Page /a in the page | Got: %v", text)
+	}
+	// the page is shown again in place, still searching, and going back leaves it
+	state.Notifier <- Notification{Type: PageAction, TabID: id, Url: "about:history?delete=" + urlPkg.QueryEscape(server.URL+"/a")}
+	state.Notifier <- Notification{Type: NavBack, TabID: id}
+	waitTabUrl(t, state, server.URL+"/b")
+	if got := entryUrls(state.QueryHistory(HistoryQuery{})); got != server.URL+"/b" {
+		t.Errorf("Expected: /b | Got: %v", got)
+	}
+	state.Notifier <- Notification{Type: NavForth, TabID: id}
+	tab = waitTabUrl(t, state, "about:history?q=%2Fa")
+	if text := pageText(tab.Dom.Root); strings.Contains(text, "Page /a") {
+		t.Errorf("Expected: /a deleted | Got: %v", text)
+	}
+
+	// any other page can link to the action, it only shows the page
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: "about:blank"}
+	waitTabUrl(t, state, "about:blank")
+	state.Notifier <- Notification{Type: PageAction, TabID: id, Url: "about:history?clear=all"}
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: "about:history?clear=all", Transition: LinkTransition}
+	waitTabUrl(t, state, "about:history?clear=all")
+	if got := entryUrls(state.QueryHistory(HistoryQuery{})); got != server.URL+"/b" {
+		t.Errorf("Expected: /b | Got: %v", got)
+	}
+
+	state.Notifier <- Notification{Type: PageAction, TabID: id, Url: "about:history?clear=all"}
+	tab = waitTabUrl(t, state, "about:history")
+	if got := state.QueryHistory(HistoryQuery{}); len(got) != 0 {
+		t.Errorf("Expected: empty history | Got: %v", entryUrls(got))
+	}
+	if text := pageText(tab.Dom.Root); !strings.Contains(text, "Nothing found.") {
+		t.Errorf("Expected: Nothing found. | Got: %v", text)
+	}
+	state.Notifier <- Notification{Type: NavBack, TabID: id}
+	waitTabUrl(t, state, "about:blank")
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	urlPkg "net/url"
+	"strings"
+)
+
+// built-in page asking for the input the server wants e.g. gemini status 1x, gopher search.
+// The form sends ?gazer_input=<answer> to the same url, inputAnswer turns it into the bare query the server wants.
+// Sensitive input is gazer_sensitive_input, so the tab server knows not to record it.
+const inputPageTemplate = `<html>
+<head><title>%s</title></head>
+<body>
+<p>%s</p>
+<form action="%s">
+<input type="%s" name="%s" required>
+<input type="submit" value="Send">
+</form>
+</body>
+</html>`
+
+// names of the input of the input page
+const (
+	inputName          = "gazer_input"
+	sensitiveInputName = "gazer_sensitive_input"
+)
+
+// inputPage is the resource of the page asking for the input of url,
+// sensitive input is hidden like a password
+func inputPage(url *urlPkg.URL, prompt string, sensitive bool) *Resource {
+	inputType, name := "text", inputName
+	if sensitive {
+		inputType, name = "password", sensitiveInputName
+	}
+	page := fmt.Sprintf(inputPageTemplate, escapeText(url.Host), escapeText(prompt), escapeText(url.String()), inputType, name)
+	return &Resource{ReadCloser: io.NopCloser(strings.NewReader(page)), Url: url,
+		ContentType: "text/html", Charset: "utf-8", Length: int64(len(page))}
+}
+
+// inputAnswer turns the form submission of an input page into what the gemini or gopher server wants:
+// the answer is the whole query, spaces are %20 not +. It tells if the answer is sensitive,
+// and false if url isn't one e.g. a form of a web page.
+func inputAnswer(url *urlPkg.URL) (*urlPkg.URL, bool, bool) {
+	if url.Scheme != "gemini" && url.Scheme != "gopher" {
+		return url, false, false
+	}
+	query, err := urlPkg.ParseQuery(url.RawQuery)
+	if err != nil || len(query) != 1 {
+		return url, false, false
+	}
+	name, sensitive := inputName, false
+	if query.Has(sensitiveInputName) {
+		name, sensitive = sensitiveInputName, true
+	}
+	if len(query[name]) != 1 {
+		return url, false, false
+	}
+	answer := *url
+	answer.RawQuery = strings.ReplaceAll(urlPkg.QueryEscape(query.Get(name)), "+", "%20")
+	return &answer, sensitive, true
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type JsonKind uint8
+
+const (
+	JsonObject JsonKind = iota
+	JsonArray
+	JsonValue // string, number, boolean or null
+)
+
+// JsonNode is a node of a parsed JSON document, it keeps the order of the object keys
+type JsonNode struct {
+	Kind     JsonKind
+	Key      string // key in the parent object, empty for array items and the root
+	Value    string // JSON literal e.g. `"hello"`, `3.14`, `null`. Only for JsonValue
+	Children []*JsonNode
+}
+
+// parseJson parses the JSON document into a tree of JsonNode
+func parseJson(source string) (*JsonNode, error) {
+	decoder := json.NewDecoder(strings.NewReader(source))
+	decoder.UseNumber()
+
+	root, err := parseJsonNode(decoder)
+	if err != nil {
+		return nil, err
+	}
+	// only one value is allowed in a JSON document
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("Unexpected data after the JSON value")
+	}
+	return root, nil
+}
+
+// parseJsonNode reads one value (might be object or array) from the decoder
+func parseJsonNode(decoder *json.Decoder) (*JsonNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("decoder.Token: %v", err)
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		node := &JsonNode{Kind: JsonObject}
+		if token == '[' {
+			node.Kind = JsonArray
+		}
+		for decoder.More() {
+			var key string
+			if node.Kind == JsonObject {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, fmt.Errorf("decoder.Token: %v", err)
+				}
+				key, _ = keyToken.(string)
+			}
+			child, err := parseJsonNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			child.Key = key
+			node.Children = append(node.Children, child)
+		}
+		// consume the closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, fmt.Errorf("decoder.Token: %v", err)
+		}
+		return node, nil
+	case string:
+		quoted, _ := json.Marshal(token)
+		return &JsonNode{Kind: JsonValue, Value: string(quoted)}, nil
+	case nil:
+		return &JsonNode{Kind: JsonValue, Value: "null"}, nil
+	default:
+		// json.Number or bool
+		return &JsonNode{Kind: JsonValue, Value: fmt.Sprint(token)}, nil
+	}
+}
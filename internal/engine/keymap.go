@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// KeyAction is what a keyboard shortcut does, the ui does it
+type KeyAction string
+
+const (
+	NewTabAction       KeyAction = "new-tab"
+	CloseTabAction     KeyAction = "close-tab"
+	NextTabAction      KeyAction = "next-tab"
+	PreviousTabAction  KeyAction = "previous-tab"
+	ReopenTabAction    KeyAction = "reopen-tab"
+	SearchTabsAction   KeyAction = "search-tabs"
+	FocusAddressAction KeyAction = "focus-address"
+	BackAction         KeyAction = "back"
+	ForwardAction      KeyAction = "forward"
+	ReloadAction       KeyAction = "reload"
+	HardReloadAction   KeyAction = "hard-reload"
+	StopAction         KeyAction = "stop"
+	CopyAction         KeyAction = "copy"
+	CopyHtmlAction     KeyAction = "copy-html"
+	SelectAllAction    KeyAction = "select-all"
+	FindAction         KeyAction = "find"
+	FindNextAction     KeyAction = "find-next"
+	FindPreviousAction KeyAction = "find-previous"
+	ZoomInAction       KeyAction = "zoom-in"
+	ZoomOutAction      KeyAction = "zoom-out"
+	ZoomResetAction    KeyAction = "zoom-reset"
+	ScrollDownAction   KeyAction = "scroll-down"
+	ScrollUpAction     KeyAction = "scroll-up"
+	PageDownAction     KeyAction = "page-down"
+	PageUpAction       KeyAction = "page-up"
+	ScrollTopAction    KeyAction = "scroll-top"
+	ScrollBottomAction KeyAction = "scroll-bottom"
+	LinkHintsAction    KeyAction = "link-hints"
+)
+
+// user can rebind the keys in keys.json of the data directory
+const keymapFile = "keys.json"
+
+// KeyBinding is an action and the keys doing it
+type KeyBinding struct {
+	Action      KeyAction
+	Description string
+	Keys        []KeyChord
+}
+
+// KeyChord is one key with the modifiers held down, written like "Ctrl+Shift+T"
+type KeyChord struct {
+	Ctrl, Shift, Alt, Super bool
+	Key                     string // e.g. "T", "PageDown", "F5" or "="
+}
+
+// keymap is the bindings in the order of about:keys, keys.json changes the keys
+var keymap = []KeyBinding{
+	{NewTabAction, "Open a new tab", mustChords("Ctrl+T")},
+	{CloseTabAction, "Close the tab", mustChords("Ctrl+W")},
+	{NextTabAction, "Go to the next tab", mustChords("Ctrl+Tab", "Ctrl+PageDown")},
+	{PreviousTabAction, "Go to the previous tab", mustChords("Ctrl+Shift+Tab", "Ctrl+PageUp")},
+	{ReopenTabAction, "Reopen the latest closed tab", mustChords("Ctrl+Shift+T")},
+	{SearchTabsAction, "Search the open tabs", mustChords("Ctrl+Shift+A")},
+	{FocusAddressAction, "Type in the address bar", mustChords("Ctrl+L", "F6")},
+	{BackAction, "Go back", mustChords("Alt+Left")},
+	{ForwardAction, "Go forward", mustChords("Alt+Right")},
+	{ReloadAction, "Reload the page", mustChords("F5", "Ctrl+R")},
+	{HardReloadAction, "Reload the page and everything on it, skipping the caches", mustChords("Ctrl+F5", "Ctrl+Shift+R")},
+	{StopAction, "Stop loading", mustChords("Escape")},
+	{CopyAction, "Copy the selected text", mustChords("Ctrl+C")},
+	{CopyHtmlAction, "Copy the selected text as HTML", mustChords("Ctrl+Shift+C")},
+	{SelectAllAction, "Select all the text of the page", mustChords("Ctrl+A")},
+	{FindAction, "Find in the page", mustChords("Ctrl+F")},
+	{FindNextAction, "Go to the next match", mustChords("F3", "Ctrl+G")},
+	{FindPreviousAction, "Go to the previous match", mustChords("Shift+F3", "Ctrl+Shift+G")},
+	{ZoomInAction, "Zoom in", mustChords("Ctrl+=", "Ctrl++")},
+	{ZoomOutAction, "Zoom out", mustChords("Ctrl+-")},
+	{ZoomResetAction, "Reset the zoom", mustChords("Ctrl+0")},
+	{ScrollDownAction, "Scroll down a line", mustChords("J", "Down")},
+	{ScrollUpAction, "Scroll up a line", mustChords("K", "Up")},
+	{PageDownAction, "Scroll down a page", mustChords("PageDown", "Space")},
+	{PageUpAction, "Scroll up a page", mustChords("PageUp", "Shift+Space")},
+	{ScrollTopAction, "Scroll to the top", mustChords("Home")},
+	{ScrollBottomAction, "Scroll to the bottom", mustChords("End", "Shift+G")},
+	{LinkHintsAction, "Label the links on screen, type a label to follow it", mustChords("F")},
+}
+
+// names of the keys that aren't a character, lowercased alias -> name
+var namedKeys = map[string]string{
+	"left": "Left", "right": "Right", "up": "Up", "down": "Down",
+	"enter": "Enter", "return": "Enter", "escape": "Escape", "esc": "Escape",
+	"home": "Home", "end": "End", "pageup": "PageUp", "pgup": "PageUp", "pagedown": "PageDown", "pgdn": "PageDown",
+	"tab": "Tab", "space": "Space", "backspace": "Backspace", "delete": "Delete", "del": "Delete",
+	"f1": "F1", "f2": "F2", "f3": "F3", "f4": "F4", "f5": "F5", "f6": "F6",
+	"f7": "F7", "f8": "F8", "f9": "F9", "f10": "F10", "f11": "F11", "f12": "F12",
+}
+
+// ParseKeyChord reads a chord like "ctrl+shift+t", the key is the last part.
+// "Ctrl++" is the plus key.
+func ParseKeyChord(raw string) (KeyChord, error) {
+	var chord KeyChord
+	raw = strings.TrimSpace(raw)
+	parts := strings.Split(raw, "+")
+	key := parts[len(parts)-1]
+	modifiers := parts[:len(parts)-1]
+	if key == "" && len(parts) > 1 && parts[len(parts)-2] == "" {
+		key, modifiers = "+", parts[:len(parts)-2]
+	}
+
+	for _, modifier := range modifiers {
+		switch strings.ToLower(strings.TrimSpace(modifier)) {
+		case "ctrl", "control":
+			chord.Ctrl = true
+		case "shift":
+			chord.Shift = true
+		case "alt":
+			chord.Alt = true
+		case "super", "cmd", "command", "meta":
+			chord.Super = true
+		default:
+			return KeyChord{}, fmt.Errorf("unknown modifier %q in %q", modifier, raw)
+		}
+	}
+
+	key = strings.TrimSpace(key)
+	if name, ok := namedKeys[strings.ToLower(key)]; ok {
+		chord.Key = name
+	} else if utf8.RuneCountInString(key) == 1 {
+		chord.Key = strings.ToUpper(key)
+	} else {
+		return KeyChord{}, fmt.Errorf("unknown key %q in %q", key, raw)
+	}
+	return chord, nil
+}
+
+func (c KeyChord) String() string {
+	var parts []string
+	if c.Ctrl {
+		parts = append(parts, "Ctrl")
+	}
+	if c.Shift {
+		parts = append(parts, "Shift")
+	}
+	if c.Alt {
+		parts = append(parts, "Alt")
+	}
+	if c.Super {
+		parts = append(parts, "Super")
+	}
+	return strings.Join(append(parts, c.Key), "+")
+}
+
+// mustChords parses the default keys, they're ours so they must be right
+func mustChords(raws ...string) []KeyChord {
+	chords := make([]KeyChord, len(raws))
+	for i, raw := range raws {
+		chord, err := ParseKeyChord(raw)
+		if err != nil {
+			panic(err)
+		}
+		chords[i] = chord
+	}
+	return chords
+}
+
+// Keymap returns the key bindings in the order of about:keys
+func Keymap() []KeyBinding {
+	bindings := slices.Clone(keymap)
+	for i := range bindings {
+		bindings[i].Keys = slices.Clone(bindings[i].Keys)
+	}
+	return bindings
+}
+
+// LoadKeymap applies keys.json of the data directory on top of the default keys.
+// It maps an action to its keys e.g. {"new-tab": ["Ctrl+T", "Ctrl+N"], "link-hints": []},
+// an action that isn't there keeps its keys. A wrong entry is skipped and reported in the error.
+func LoadKeymap() error {
+	path := dataFile(keymapFile)
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("os.ReadFile: %v", err)
+	}
+
+	var override map[KeyAction][]string
+	if err := json.Unmarshal(content, &override); err != nil {
+		return fmt.Errorf("json.Unmarshal: %v", err)
+	}
+	var errs []error
+	for action, raws := range override {
+		idx := slices.IndexFunc(keymap, func(b KeyBinding) bool { return b.Action == action })
+		if idx < 0 {
+			errs = append(errs, fmt.Errorf("unknown action %q", action))
+			continue
+		}
+		chords := make([]KeyChord, 0, len(raws))
+		for _, raw := range raws {
+			chord, err := ParseKeyChord(raw)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			chords = append(chords, chord)
+		}
+		keymap[idx].Keys = chords
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v: %v", keymapFile, errors.Join(errs...))
+	}
+	return nil
+}
+
+// aboutKeys lists the keyboard shortcuts
+func aboutKeys() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "<p>Change them in %s, e.g. <code>{\"new-tab\": [\"Ctrl+T\", \"Ctrl+N\"]}</code></p>\n",
+		escapeText(dataFile(keymapFile)))
+	builder.WriteString("<table>\n<tr><th>Keys</th><th>Action</th><th>Name</th></tr>\n")
+	for _, binding := range keymap {
+		keys := make([]string, len(binding.Keys))
+		for i, chord := range binding.Keys {
+			keys[i] = chord.String()
+		}
+		fmt.Fprintf(&builder, "<tr><td><code>%s</code></td><td>%s</td><td>%s</td></tr>\n",
+			escapeText(strings.Join(keys, ", ")), escapeText(binding.Description), binding.Action)
+	}
+	builder.WriteString("</table>\n")
+	return builder.String()
+}
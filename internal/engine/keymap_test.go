@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyChord(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+		wantErr  bool
+	}{
+		{"ctrl+shift+t", "Ctrl+Shift+T", false},
+		{" Alt + left ", "Alt+Left", false},
+		{"shift+ctrl+PgDn", "Ctrl+Shift+PageDown", false},
+		{"Ctrl++", "Ctrl++", false},
+		{"Ctrl+=", "Ctrl+=", false},
+		{"cmd+f5", "Super+F5", false},
+		{"esc", "Escape", false},
+		{"Hyper+T", "", true},
+		{"Ctrl+Tabby", "", true},
+		{"", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			chord, err := ParseKeyChord(test.raw)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Expected error: %v | Got: %v", test.wantErr, err)
+			}
+			if err == nil && chord.String() != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, chord.String())
+			}
+		})
+	}
+}
+
+func TestLoadKeymap(t *testing.T) {
+	useTempDataDirs(t)
+	saved := Keymap()
+	t.Cleanup(func() { keymap = saved })
+
+	content := `{"new-tab": ["Ctrl+N", "Ctrl+T"], "link-hints": [], "back": ["Ctrl+Foo", "Alt+B"], "fly": ["Ctrl+Y"]}`
+	if err := os.WriteFile(dataFile(keymapFile), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := LoadKeymap()
+	// wrong entries are reported, the rest still applies
+	if err == nil || !strings.Contains(err.Error(), `"fly"`) || !strings.Contains(err.Error(), `"Foo"`) {
+		t.Errorf("Expected: errors about fly and Foo | Got: %v", err)
+	}
+
+	expected := map[KeyAction]string{
+		NewTabAction:     "[Ctrl+N Ctrl+T]",
+		LinkHintsAction:  "[]",
+		BackAction:       "[Alt+B]",
+		CloseTabAction:   "[Ctrl+W]", // not in the file, keeps the default
+		ReopenTabAction:  "[Ctrl+Shift+T]",
+		ScrollDownAction: "[J Down]",
+	}
+	for _, binding := range Keymap() {
+		if want, ok := expected[binding.Action]; ok && fmt.Sprint(binding.Keys) != want {
+			t.Errorf("Expected: %v %v | Got: %v", binding.Action, want, binding.Keys)
+		}
+	}
+
+	dom, _ := aboutPage("keys", nil, newNavHistory(), nil, nil, nil)
+	if text := pageText(dom.Root); !strings.Contains(text, "Ctrl+N, Ctrl+T") || !strings.Contains(text, "Open a new tab") {
+		t.Errorf("Expected: about:keys lists the new keys | Got: %v", text)
+	}
+}
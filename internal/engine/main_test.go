@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// engine logs every fetched page, too noisy for tests
+	log.SetOutput(io.Discard)
+
+	// don't touch the user's data directory
+	dataDir, err := os.MkdirTemp("", "gazer-test")
+	if err != nil {
+		log.Fatalln("os.MkdirTemp:", err)
+	}
+	settings.DataDir = dataDir
+	geminiHosts = newKnownHosts(dataFile(knownHostsFile))
+
+	code := m.Run()
+	os.RemoveAll(dataDir)
+	os.Exit(code)
+}
+
+// useTempDataDirs gives the test a fresh data directory (history, session...) and download directory of its own,
+// it returns the download directory
+func useTempDataDirs(t *testing.T) string {
+	oldDownload, oldData := settings.DownloadDir, settings.DataDir
+	settings.DownloadDir, settings.DataDir = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { settings.DownloadDir, settings.DataDir = oldDownload, oldData })
+	return settings.DownloadDir
+}
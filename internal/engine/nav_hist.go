@@ -6,9 +6,11 @@ type navHistory struct {
 }
 
 type navHistoryNode struct {
-	url  string
-	prev *navHistoryNode
-	next *navHistoryNode
+	url       string
+	scroll    ScrollPosition // where the user left the page
+	sensitive bool           // the url has a sensitive input in it e.g. a password, it's never saved
+	prev      *navHistoryNode
+	next      *navHistoryNode
 }
 
 func newNavHistory() *navHistory {
@@ -41,6 +43,74 @@ func (n *navHistory) nav(url string) {
 	n.cur = n.cur.next
 }
 
+// replace changes the url of the present without touching past or future
+// e.g. when the page we're retrying gets redirected somewhere else
+func (n *navHistory) replace(url string) {
+	n.cur.url = url
+}
+
+// entries returns all urls in the timeline from the oldest (without the first empty one)
+// and the index of the present, -1 if we're at the empty one
+func (n navHistory) entries() ([]string, int) {
+	first := n.cur
+	for first.prev != nil {
+		first = first.prev
+	}
+
+	var urls []string
+	curIdx := -1
+	for node := first.next; node != nil; node = node.next {
+		if node == n.cur {
+			curIdx = len(urls)
+		}
+		urls = append(urls, node.url)
+	}
+	return urls, curIdx
+}
+
+// sessionEntries is like entries but with the scroll positions, for saving the session.
+// Sensitive entries are left out, the present is then the entry before it.
+func (n navHistory) sessionEntries() ([]sessionEntry, int) {
+	first := n.cur
+	for first.prev != nil {
+		first = first.prev
+	}
+
+	var res []sessionEntry
+	curIdx := -1
+	for node := first.next; node != nil; node = node.next {
+		if node == n.cur {
+			curIdx = len(res)
+			if node.sensitive {
+				curIdx--
+			}
+		}
+		if !node.sensitive {
+			res = append(res, sessionEntry{Url: node.url, Scroll: node.scroll})
+		}
+	}
+	return res, curIdx
+}
+
+// restoreNavHistory builds the timeline back from the saved entries, present is at curIdx (-1 is the empty one)
+func restoreNavHistory(entries []sessionEntry, curIdx int) *navHistory {
+	n := newNavHistory()
+	first := n.cur
+	var present *navHistoryNode
+	for i, entry := range entries {
+		n.nav(entry.Url)
+		n.cur.scroll = entry.Scroll
+		if i == curIdx {
+			present = n.cur
+		}
+	}
+	if present == nil {
+		present = first
+	}
+	n.cur = present
+	return n
+}
+
 func newNavHistoryNode(url string) *navHistoryNode {
 	return &navHistoryNode{url: url}
 }
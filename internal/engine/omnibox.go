@@ -0,0 +1,247 @@
+package engine
+
+import (
+	"net"
+	urlPkg "net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+type SuggestionKind uint8
+
+const (
+	HistorySuggestion SuggestionKind = iota
+	TabSuggestion                    // switch to the open tab instead of loading it again
+	SearchSuggestion                 // search the text with the search engine
+	BookmarkSuggestion
+)
+
+// Suggestion is what the search bar suggests while the user types
+type Suggestion struct {
+	Kind  SuggestionKind
+	Url   string
+	Title string
+	TabID TabID // only for TabSuggestion
+}
+
+// suggestion with its rank
+type candidate struct {
+	Suggestion
+	score float64
+}
+
+// only the latest visits are worth looking at for frecency, like Firefox
+const frecencySampleSize = 10
+
+// open tab and bookmark are a little more interesting than a history entry
+const (
+	tabBoost      = 1.5
+	bookmarkBoost = 1.4
+)
+
+// Suggest returns at most limit suggestions for the text typed in the search bar, best first,
+// and what the text can be completed to inline (empty if nothing).
+// Pages come from the history, the bookmarks (title, url or tag) and the open tabs (but the selected one),
+// ranked by frecency and how well they match. Searching the text is always one of them.
+func (s *State) Suggest(text string, limit int) ([]Suggestion, string) {
+	text = strings.TrimSpace(text)
+	if text == "" || limit <= 0 {
+		return nil, ""
+	}
+
+	now := time.Now()
+	candidates := make(map[string]*candidate)
+	for _, entry := range s.history.query(HistoryQuery{Text: text}) {
+		candidates[entry.Url] = &candidate{
+			Suggestion: Suggestion{Kind: HistorySuggestion, Url: entry.Url, Title: entry.Title},
+			score:      matchBoost(entry.Url, text) * entry.frecency(now),
+		}
+	}
+	bookmarks, _ := s.bookmarks.all()
+	for _, bookmark := range bookmarks {
+		if !bookmarkMatches(bookmark, text) {
+			continue
+		}
+		c, ok := candidates[bookmark.Url]
+		if !ok {
+			// never visited, it's worth a fresh typed visit
+			c = &candidate{score: matchBoost(bookmark.Url, text) * ageWeight(0) * transitionWeight(TypedTransition)}
+			candidates[bookmark.Url] = c
+		} else if c.Kind == BookmarkSuggestion {
+			continue // bookmarked twice
+		}
+		title := bookmark.Title
+		if title == "" {
+			title = c.Title // the one from the history
+		}
+		c.Suggestion = Suggestion{Kind: BookmarkSuggestion, Url: bookmark.Url, Title: title}
+		c.score *= bookmarkBoost
+	}
+	s.mu.RLock()
+	for _, tab := range s.tabs {
+		if tab.id == s.selected || tab.url == "" {
+			continue
+		}
+		title := PageTitle(tab.dom.Root)
+		if !containsFold(tab.url, text) && !containsFold(title, text) {
+			continue
+		}
+		c, ok := candidates[tab.url]
+		if !ok {
+			// never visited (e.g. about: page), it's worth a fresh typed visit
+			c = &candidate{score: matchBoost(tab.url, text) * ageWeight(0) * transitionWeight(TypedTransition)}
+			candidates[tab.url] = c
+		}
+		c.Suggestion = Suggestion{Kind: TabSuggestion, Url: tab.url, Title: title, TabID: tab.id}
+		c.score *= tabBoost
+	}
+	s.mu.RUnlock()
+
+	ranked := make([]*candidate, 0, len(candidates))
+	for _, c := range candidates {
+		ranked = append(ranked, c)
+	}
+	slices.SortFunc(ranked, func(a, b *candidate) int {
+		if a.score != b.score {
+			if a.score > b.score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Url, b.Url)
+	})
+
+	var completion string
+	if !strings.ContainsAny(text, " \t") {
+		for _, c := range ranked {
+			if completed, ok := completeUrl(c.Url, text); ok {
+				completion = completed
+				break
+			}
+		}
+	}
+
+	// words are searched first, address is visited first
+	search := Suggestion{Kind: SearchSuggestion, Url: SearchUrl(text), Title: "Search for " + text}
+	var res []Suggestion
+	if !looksLikeUrl(text) && search.Url != "" {
+		res = append(res, search)
+	}
+	for _, c := range ranked {
+		res = append(res, c.Suggestion)
+	}
+	if looksLikeUrl(text) && search.Url != "" {
+		res = append(res, search)
+	}
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, completion
+}
+
+// frecency scores how often and how recently the page is visited: every sampled visit is worth points
+// by its age and how the user got there, the average is multiplied by the visit count
+func (e HistoryEntry) frecency(now time.Time) float64 {
+	visits := e.Visits[max(len(e.Visits)-frecencySampleSize, 0):]
+	if len(visits) == 0 {
+		return 0
+	}
+	var points float64
+	for _, visit := range visits {
+		points += ageWeight(now.Sub(visit.Time)) * transitionWeight(visit.Transition)
+	}
+	return float64(e.VisitCount) * points / float64(len(visits))
+}
+
+func ageWeight(age time.Duration) float64 {
+	const day = 24 * time.Hour
+	switch {
+	case age <= 4*day:
+		return 100
+	case age <= 14*day:
+		return 70
+	case age <= 31*day:
+		return 50
+	case age <= 90*day:
+		return 30
+	default:
+		return 10
+	}
+}
+
+// typed address (or bookmark) is what the user really wants to go again
+func transitionWeight(transition Transition) float64 {
+	if transition == TypedTransition || transition == BookmarkTransition {
+		return 2
+	}
+	return 1
+}
+
+// matchBoost prefers the url that starts with the text over the one that only contains it
+func matchBoost(url, text string) float64 {
+	if urlHasPrefix(url, text) {
+		return 2
+	}
+	return 1
+}
+
+// completeUrl completes the typed text to the url in the way the user is typing it,
+// e.g. "exa" -> "example.com/", "https://www.ex" -> "https://www.example.com/".
+// false if the url doesn't start with the text.
+func completeUrl(url, text string) (string, bool) {
+	forms := []string{url}
+	if _, rest, found := strings.Cut(url, "://"); found {
+		forms = append(forms, rest, strings.TrimPrefix(rest, "www."))
+	}
+	for _, form := range forms {
+		if len(form) > len(text) && strings.HasPrefix(strings.ToLower(form), strings.ToLower(text)) {
+			// keep what the user typed as it is
+			return text + form[len(text):], true
+		}
+	}
+	return "", false
+}
+
+// looksLikeUrl guesses if the text is an address rather than words to search
+// e.g. "example.com/a", "localhost:8080", "about:blank" are, "golang generics" isn't
+func looksLikeUrl(text string) bool {
+	text = strings.TrimSpace(text)
+	lower := strings.ToLower(text)
+	if strings.Contains(lower, "://") {
+		return true
+	}
+	for _, scheme := range []string{"about:", "data:", "view-source:"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	if text == "" || strings.ContainsAny(text, " \t") {
+		return false
+	}
+
+	host := text
+	if i := strings.IndexAny(host, "/?#"); i != -1 {
+		host = host[:i]
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.Trim(host, "[]") // ipv6
+	if strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil {
+		return true
+	}
+	return strings.Contains(host, ".") && !strings.HasPrefix(host, ".") && !strings.HasSuffix(host, ".")
+}
+
+// SearchUrl is the search engine url searching the text, empty if there is no search engine
+func SearchUrl(text string) string {
+	if settings.SearchEngine == "" {
+		return ""
+	}
+	return strings.ReplaceAll(settings.SearchEngine, "%s", urlPkg.QueryEscape(strings.TrimSpace(text)))
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
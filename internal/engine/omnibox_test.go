@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+func TestSuggest(t *testing.T) {
+	useTempDataDirs(t)
+	state := NewState()
+	state.history = newHistoryStore("")
+	state.bookmarks = newBookmarkStore("")
+	state.bookmarks.add("https://go.dev/tour/", "A Tour of Go", BarFolder, []string{"tutorial"})
+	state.history.visit("https://golang.org/doc/", "Documentation", TypedTransition)
+	state.history.visit("https://go.dev/", "The Go Programming Language", LinkTransition)
+	state.history.visit("https://go.dev/", "", LinkTransition)
+	state.history.visit("https://www.gophers.dev/", "Gophers", LinkTransition)
+	state.history.visit("https://example.com/go", "Example", TypedTransition)
+	// gophers was a long time ago
+	for i := range state.history.entries["https://www.gophers.dev/"].Visits {
+		state.history.entries["https://www.gophers.dev/"].Visits[i].Time = time.Now().AddDate(-1, 0, 0)
+	}
+
+	// open tab with a page in it, it's a tab suggestion instead
+	tab := state.addTab()
+	root, _ := parser.Parse("<html><head><title>Go Blog</title></head><body></body></html>")
+	tab.url, tab.dom = "https://go.dev/blog/", Dom{Root: root}
+	state.selectTab(state.tabs[0].id)
+
+	tests := []struct {
+		name       string
+		text       string
+		limit      int
+		expected   string
+		completion string
+	}{
+		{"ranked by match and frecency", "go", 10,
+			"[{2 https://html.duckduckgo.com/html/?q=go Search for go 0} {1 https://go.dev/blog/ Go Blog 2} " +
+				"{3 https://go.dev/tour/ A Tour of Go 0} {0 https://go.dev/ The Go Programming Language 0} {0 https://golang.org/doc/ Documentation 0} " +
+				"{0 https://example.com/go Example 0} {0 https://www.gophers.dev/ Gophers 0}]",
+			"go.dev/blog/"},
+		{"title", "documentation", 10,
+			"[{2 https://html.duckduckgo.com/html/?q=documentation Search for documentation 0} " +
+				"{0 https://golang.org/doc/ Documentation 0}]", ""},
+		{"address is visited first", "gophers.dev", 1, "[{0 https://www.gophers.dev/ Gophers 0}]", "gophers.dev/"},
+		{"keep the case", "GOLANG", 1, "[{2 https://html.duckduckgo.com/html/?q=GOLANG Search for GOLANG 0}]", "GOLANG.org/doc/"},
+		{"with scheme", "https://www.goph", 1, "[{0 https://www.gophers.dev/ Gophers 0}]", "https://www.gophers.dev/"},
+		{"words aren't completed", "go programming", 10,
+			"[{2 https://html.duckduckgo.com/html/?q=go+programming Search for go programming 0} " +
+				"{0 https://go.dev/ The Go Programming Language 0}]", ""},
+		{"bookmark tag", "tutorial", 10,
+			"[{2 https://html.duckduckgo.com/html/?q=tutorial Search for tutorial 0} {3 https://go.dev/tour/ A Tour of Go 0}]", ""},
+		{"empty", " ", 10, "[]", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			suggestions, completion := state.Suggest(test.text, test.limit)
+			if got := fmt.Sprint(suggestions); got != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+			if completion != test.completion {
+				t.Errorf("Expected: %q | Got: %q", test.completion, completion)
+			}
+		})
+	}
+}
+
+func TestLooksLikeUrl(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected bool
+	}{
+		{"example.com", true},
+		{"example.com/a b", false},
+		{"localhost:8080", true},
+		{"127.0.0.1/index.html", true},
+		{"[::1]:8080", true},
+		{"about:blank", true},
+		{"file:///tmp/a b.html", true},
+		{"golang generics", false},
+		{"gazer", false},
+		{"gazer.", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if got := looksLikeUrl(test.text); got != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type LoadPhase uint8
+
+const (
+	WaitingHost  LoadPhase = iota // request sent, waiting for the host to respond
+	Receiving                     // receiving the document body
+	Subresources                  // loading stylesheets and images
+	Loaded
+)
+
+// Progress is a snapshot of the loading progress of one navigation
+type Progress struct {
+	Phase         LoadPhase
+	Host          string
+	BytesReceived int64
+	BytesTotal    int64 // -1 if the host doesn't tell (no Content-Length)
+	StylesDone    int
+	StylesTotal   int
+	ImagesDone    int
+	ImagesTotal   int
+}
+
+// minimum interval between 2 window invalidations caused by progress update
+const progressInvalidateInterval = 30 * time.Millisecond
+
+// progressReporter collects the progress of one navigation and publishes it to the tab
+type progressReporter struct {
+	ctx            context.Context // navigation context, stop publishing after it's done
+	mu             sync.Mutex
+	progress       Progress
+	tab            *Tab
+	window         Invalidator
+	lastInvalidate time.Time
+}
+
+// countingReader reports every read bytes to the progressReporter
+type countingReader struct {
+	io.Reader
+	reporter *progressReporter
+}
+
+// Fraction returns the overall progress between 0 and 1.
+// Document and subresources are weighted half-half if there is any subresource.
+func (p Progress) Fraction() float32 {
+	switch p.Phase {
+	case WaitingHost:
+		return 0.02
+	case Loaded:
+		return 1
+	}
+
+	var doc float32
+	switch {
+	case p.BytesTotal == 0:
+		doc = 1 // empty body, nothing to wait for
+	case p.BytesTotal > 0:
+		doc = float32(p.BytesReceived) / float32(p.BytesTotal)
+	default:
+		// don't know the size, approach 1 as we receive more (half at 64 KB)
+		doc = float32(p.BytesReceived) / float32(p.BytesReceived+64*1024)
+	}
+	doc = min(max(doc, 0.02), 1)
+
+	if p.Phase == Receiving {
+		// don't know about subresources yet, assume there will be some
+		return doc * 0.5
+	}
+
+	total := p.StylesTotal + p.ImagesTotal
+	if total == 0 {
+		return 1
+	}
+	// a subresource counted twice can't go past the end
+	done := min(p.StylesDone+p.ImagesDone, total)
+	return 0.5 + 0.5*float32(done)/float32(total)
+}
+
+// Status returns a short text describing what's going on e.g. "Loading 3 of 12 images"
+func (p Progress) Status() string {
+	switch p.Phase {
+	case WaitingHost:
+		if p.Host == "" {
+			return "Waiting for host…"
+		}
+		return fmt.Sprintf("Waiting for %s…", p.Host)
+	case Receiving:
+		if p.BytesTotal > 0 {
+			return fmt.Sprintf("Receiving page: %s of %s", formatBytes(p.BytesReceived), formatBytes(p.BytesTotal))
+		}
+		return fmt.Sprintf("Receiving page: %s", formatBytes(p.BytesReceived))
+	case Subresources:
+		if p.StylesDone < p.StylesTotal {
+			return fmt.Sprintf("Loading %d of %d stylesheets", p.StylesDone+1, p.StylesTotal)
+		}
+		if p.ImagesTotal > 0 {
+			return fmt.Sprintf("Loading %d of %d images", min(p.ImagesDone+1, p.ImagesTotal), p.ImagesTotal)
+		}
+		return "Loading…"
+	default:
+		return ""
+	}
+}
+
+func newProgressReporter(ctx context.Context, tab *Tab, window Invalidator, host string) *progressReporter {
+	r := &progressReporter{ctx: ctx, tab: tab, window: window}
+	r.progress = Progress{Phase: WaitingHost, Host: host, BytesTotal: -1}
+	r.publish(true)
+	return r
+}
+
+// update applies f to the progress and publish the new snapshot to the tab
+func (r *progressReporter) update(f func(p *Progress)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	phase := r.progress.Phase
+	f(&r.progress)
+	r.publish(phase != r.progress.Phase)
+}
+
+// publish stores the progress snapshot to the tab and invalidate the window
+// (at most once per progressInvalidateInterval unless force is true).
+// requires: r.mu is locked or r is not shared yet
+func (r *progressReporter) publish(force bool) {
+	if r.ctx.Err() != nil {
+		return // cancelled navigation shouldn't touch the tab
+	}
+	snapshot := r.progress
+	r.tab.progress.Store(&snapshot)
+
+	now := time.Now()
+	if force || now.Sub(r.lastInvalidate) >= progressInvalidateInterval {
+		r.lastInvalidate = now
+		r.window.Invalidate()
+	}
+}
+
+// wrap returns a reader that reports everything read from rc as received bytes
+func (r *progressReporter) wrap(rc io.Reader) io.Reader {
+	return countingReader{Reader: rc, reporter: r}
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	if n > 0 {
+		c.reporter.update(func(p *Progress) { p.BytesReceived += int64(n) })
+	}
+	return n, err
+}
+
+// formatBytes formats byte count into human-readable string e.g. 12.3 KB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,64 @@
+package engine
+
+import "testing"
+
+func TestProgressFraction(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress Progress
+		expected float32
+	}{
+		{"waiting", Progress{Phase: WaitingHost, BytesTotal: -1}, 0.02},
+		{"receiving known length", Progress{Phase: Receiving, BytesReceived: 500, BytesTotal: 1000}, 0.25},
+		{"receiving all of it", Progress{Phase: Receiving, BytesReceived: 1000, BytesTotal: 1000}, 0.5},
+		{"receiving more than told", Progress{Phase: Receiving, BytesReceived: 3000, BytesTotal: 1000}, 0.5},
+		{"receiving unknown length", Progress{Phase: Receiving, BytesReceived: 64 * 1024, BytesTotal: -1}, 0.25},
+		{"nothing received yet", Progress{Phase: Receiving, BytesTotal: -1}, 0.01},
+		{"zero-length body", Progress{Phase: Receiving, BytesTotal: 0}, 0.5},
+		{"no subresources", Progress{Phase: Subresources, BytesTotal: -1}, 1},
+		{"some subresources", Progress{Phase: Subresources, StylesDone: 1, StylesTotal: 2, ImagesDone: 1, ImagesTotal: 2}, 0.75},
+		{"more done than total", Progress{Phase: Subresources, StylesDone: 3, StylesTotal: 1, ImagesTotal: 1}, 1},
+		{"loaded", Progress{Phase: Loaded, BytesReceived: 10, BytesTotal: 1000}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.progress.Fraction(); got != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestProgressStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress Progress
+		expected string
+	}{
+		{"waiting for a host", Progress{Phase: WaitingHost, Host: "example.com"}, "Waiting for example.com…"},
+		{"waiting without a host", Progress{Phase: WaitingHost}, "Waiting for host…"},
+		{"receiving known length", Progress{Phase: Receiving, BytesReceived: 1536, BytesTotal: 3 * 1024 * 1024},
+			"Receiving page: 1.5 KB of 3.0 MB"},
+		{"receiving unknown length", Progress{Phase: Receiving, BytesReceived: 512, BytesTotal: -1}, "Receiving page: 512 B"},
+		{"zero-length body", Progress{Phase: Receiving, BytesTotal: 0}, "Receiving page: 0 B"},
+		{"stylesheets", Progress{Phase: Subresources, StylesDone: 1, StylesTotal: 3, ImagesTotal: 5},
+			"Loading 2 of 3 stylesheets"},
+		{"images", Progress{Phase: Subresources, StylesDone: 3, StylesTotal: 3, ImagesDone: 4, ImagesTotal: 12},
+			"Loading 5 of 12 images"},
+		{"more images done than total", Progress{Phase: Subresources, ImagesDone: 13, ImagesTotal: 12},
+			"Loading 12 of 12 images"},
+		{"more stylesheets done than total", Progress{Phase: Subresources, StylesDone: 4, StylesTotal: 3},
+			"Loading…"},
+		{"no subresources", Progress{Phase: Subresources}, "Loading…"},
+		{"loaded", Progress{Phase: Loaded}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.progress.Status(); got != test.expected {
+				t.Errorf("Expected: %q | Got: %q", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// cacheMode is how a navigation treats what we (and the caches on the way to the server) have
+type cacheMode uint8
+
+const (
+	normalLoad  cacheMode = iota
+	revalidate            // Reload: ask the server if the page changed
+	bypassCache           // HardReload: get everything from the server again
+)
+
+// fetchOptions change the http requests of a navigation, they go to Fetch in the context
+type fetchOptions struct {
+	mode      cacheMode
+	validator string // ETag or Last-Modified of the page we have, the server answers 304 if it's still that
+}
+
+type fetchOptionsKey struct{}
+
+// errNotModified is Fetch telling the content we validated is still the same (304)
+var errNotModified = errors.New("not modified")
+
+func withFetchOptions(ctx context.Context, options fetchOptions) context.Context {
+	return context.WithValue(ctx, fetchOptionsKey{}, options)
+}
+
+// setCacheHeaders sets the request headers of the ctx's fetch options, like other browsers do
+func setCacheHeaders(ctx context.Context, header http.Header) {
+	options, _ := ctx.Value(fetchOptionsKey{}).(fetchOptions)
+	switch options.mode {
+	case revalidate:
+		header.Set("Cache-Control", "max-age=0")
+	case bypassCache:
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Pragma", "no-cache")
+	}
+	if options.validator == "" {
+		return
+	}
+	// responseValidator gives an ETag (it's quoted) or else Last-Modified
+	if strings.HasPrefix(options.validator, `"`) {
+		header.Set("If-None-Match", options.validator)
+	} else {
+		header.Set("If-Modified-Since", options.validator)
+	}
+}
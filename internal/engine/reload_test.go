@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	useTempDataDirs(t)
+	var mu sync.Mutex
+	var requests []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Header.Clone())
+		hits := len(requests)
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>Hit %d</title></head><body></body></html>", hits)
+	}))
+	defer server.Close()
+	state := NewState()
+	startEngine(t, state)
+	id := state.Snapshot().Selected
+
+	// waitRequests waits until the server got n requests and the tab is done with them
+	waitRequests := func(n int) TabSnapshot {
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			mu.Lock()
+			got := len(requests)
+			mu.Unlock()
+			tab, _ := state.Snapshot().SelectedTab()
+			if got >= n && !tab.IsLoading {
+				return tab
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected: %v requests | Got: %v (loading %v)", n, got, tab.IsLoading)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	state.Notifier <- Notification{Type: Search, TabID: id, Url: server.URL + "/"}
+	loaded := waitTabTitle(t, state, "Hit 1")
+
+	// the page didn't change, keep ours
+	state.Notifier <- Notification{Type: Reload, TabID: id}
+	reloaded := waitRequests(2)
+	if reloaded.Dom.Root != loaded.Dom.Root {
+		t.Errorf("Expected: the cached page | Got: %v", PageTitle(reloaded.Dom.Root))
+	}
+	mu.Lock()
+	header := requests[1]
+	mu.Unlock()
+	if header.Get("If-None-Match") != `"v1"` || header.Get("Cache-Control") != "max-age=0" {
+		t.Errorf("Expected: revalidating headers | Got: %v", header)
+	}
+
+	state.Notifier <- Notification{Type: HardReload, TabID: id}
+	waitTabTitle(t, state, "Hit 3")
+	mu.Lock()
+	header = requests[2]
+	mu.Unlock()
+	if header.Get("If-None-Match") != "" || header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("Expected: no-cache headers | Got: %v", header)
+	}
+
+	tab := state.tab(id)
+	state.mu.RLock()
+	urls, cur := tab.history.entries()
+	state.mu.RUnlock()
+	if len(urls) != 1 || cur != 0 {
+		t.Errorf("Expected: reloading doesn't add to back/forward | Got: %v %v", urls, cur)
+	}
+}
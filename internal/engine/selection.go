@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"fmt"
+	"html"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// TextPoint is a place in the page's text: a rune offset in the Inner of a text node, like FindMatch
+type TextPoint struct {
+	Node   *parser.Node
+	Offset int
+}
+
+// TextRange is what the user selected on the page, Start is at or before End in document order
+type TextRange struct {
+	Start, End TextPoint
+}
+
+// SelectedText returns the plain text of the range. A block in the page (e.g. <p> or <li>) and <br> start a new line.
+func SelectedText(root *parser.Node, r TextRange) string {
+	if root == nil || r.Start.Node == nil || r.End.Node == nil {
+		return ""
+	}
+	var builder strings.Builder
+	inRange, done := false, false
+	newLine := func() {
+		if inRange && builder.Len() > 0 && !strings.HasSuffix(builder.String(), "\n") {
+			builder.WriteByte('\n')
+		}
+	}
+	var walk func(node *parser.Node)
+	walk = func(node *parser.Node) {
+		if done || node.Tag == parser.Head {
+			return
+		}
+		switch {
+		case node.Tag == parser.Text:
+			start, end := 0, len([]rune(node.Inner))
+			if node == r.Start.Node {
+				inRange, start = true, r.Start.Offset
+			}
+			if node == r.End.Node {
+				done, end = true, r.End.Offset
+			}
+			if inRange {
+				text := runeSlice(node.Inner, start, end)
+				if needSpace(builder.String(), text) {
+					builder.WriteByte(' ')
+				}
+				builder.WriteString(text)
+			}
+			return
+		case node.Tag == parser.Br:
+			if inRange {
+				builder.WriteByte('\n')
+			}
+			return
+		case !parser.InlineElements[node.Tag]:
+			newLine()
+			defer newLine()
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(root)
+	return strings.Trim(builder.String(), "\n")
+}
+
+// SelectedHTML returns the markup of the range, with the elements the text is in so links and emphasis stay.
+// It starts from the element holding both ends, the text cut at the ends.
+func SelectedHTML(root *parser.Node, r TextRange) string {
+	if root == nil || r.Start.Node == nil || r.End.Node == nil {
+		return ""
+	}
+	// where each node is in document order, and where its last descendant is
+	first, last := make(map[*parser.Node]int), make(map[*parser.Node]int)
+	count := 0
+	var number func(node *parser.Node)
+	number = func(node *parser.Node) {
+		first[node] = count
+		count++
+		for _, child := range node.Children {
+			number(child)
+		}
+		last[node] = count - 1
+	}
+	number(root)
+	startPos, ok := first[r.Start.Node]
+	endPos, endOk := first[r.End.Node]
+	if !ok || !endOk {
+		return ""
+	}
+
+	var builder strings.Builder
+	lastText := "" // to space the text like SelectedText does
+	var write func(node *parser.Node)
+	write = func(node *parser.Node) {
+		if last[node] < startPos || first[node] > endPos || node.Tag == parser.Head {
+			return // nothing of it is selected
+		}
+		if node.Tag == parser.Text {
+			start, end := 0, len([]rune(node.Inner))
+			if node == r.Start.Node {
+				start = r.Start.Offset
+			}
+			if node == r.End.Node {
+				end = r.End.Offset
+			}
+			text := runeSlice(node.Inner, start, end)
+			if needSpace(lastText, text) {
+				builder.WriteByte(' ')
+			}
+			builder.WriteString(html.EscapeString(text))
+			lastText = text
+			return
+		}
+		fmt.Fprintf(&builder, "<%s", node.Tag)
+		for _, name := range slices.Sorted(maps.Keys(node.Attrs)) {
+			fmt.Fprintf(&builder, " %s=\"%s\"", name, html.EscapeString(node.Attrs[name]))
+		}
+		builder.WriteByte('>')
+		if !parser.InlineElements[node.Tag] {
+			lastText = ""
+		}
+		if parser.VoidElements[node.Tag] {
+			return
+		}
+		for _, child := range node.Children {
+			write(child)
+		}
+		fmt.Fprintf(&builder, "</%s>", node.Tag)
+	}
+
+	// the lowest element holding both ends, the document itself doesn't count
+	common := r.Start.Node
+	for common.Parent != nil && last[common] < endPos {
+		common = common.Parent
+	}
+	switch common.Tag {
+	case parser.Root, parser.Html, parser.Body:
+		for _, child := range common.Children {
+			write(child)
+		}
+	default:
+		write(common)
+	}
+	return builder.String()
+}
+
+// WordAt returns where the word around the rune offset starts and ends in the text.
+// Off a word, it's the run of the other characters there e.g. the spaces between two words.
+func WordAt(text string, offset int) (int, int) {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return 0, 0
+	}
+	offset = min(max(offset, 0), len(runes)-1)
+	isWord := isWordRune(runes[offset])
+	start, end := offset, offset+1
+	for start > 0 && isWordRune(runes[start-1]) == isWord {
+		start--
+	}
+	for end < len(runes) && isWordRune(runes[end]) == isWord {
+		end++
+	}
+	return start, end
+}
+
+// needSpace tells if a space goes between two text runs of the page. The parser trims the text, the renderer puts
+// the runs next to each other so "<b>fish</b> in" is "fish" and "in", but "<a>oil</a>." is "oil" and ".".
+func needSpace(before, after string) bool {
+	last, _ := utf8.DecodeLastRuneInString(before)
+	first, _ := utf8.DecodeRuneInString(after)
+	return before != "" && after != "" && isWordRune(last) && isWordRune(first)
+}
+
+// runeSlice is text[start:end] in runes, the ends are kept in the text
+func runeSlice(text string, start, end int) string {
+	runes := []rune(text)
+	end = min(max(end, 0), len(runes))
+	start = min(max(start, 0), end)
+	return string(runes[start:end])
+}
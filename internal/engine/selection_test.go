@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+const selectionPage = `<html><head><title>Picked</title></head><body>` +
+	`<h1>Fish & chips</h1>` +
+	`<p>Fry the <b>fish</b> in <a href="/oil" title="hot">oil</a>.<br>Then the chips.</p>` +
+	`<ul><li>Salt</li><li>Vinegar</li></ul>` +
+	`</body></html>`
+
+// textNode finds the text node starting with the prefix
+func textNode(t *testing.T, root *parser.Node, prefix string) *parser.Node {
+	t.Helper()
+	var found *parser.Node
+	var walk func(node *parser.Node)
+	walk = func(node *parser.Node) {
+		if found == nil && node.Tag == parser.Text && strings.HasPrefix(node.Inner, prefix) {
+			found = node
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(root)
+	if found == nil {
+		t.Fatalf("no text node starts with %q", prefix)
+	}
+	return found
+}
+
+func TestSelectedText(t *testing.T) {
+	root, err := parser.Parse(selectionPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := func(prefix string, offset int) TextPoint {
+		return TextPoint{Node: textNode(t, root, prefix), Offset: offset}
+	}
+	tests := []struct {
+		name          string
+		start, end    TextPoint
+		text, htmlStr string
+	}{
+		{"in one text", point("Fry", 4), point("Fry", 7), "the", "the"},
+		{"across inline elements", point("Fry", 4), point("oil", 3), "the fish in oil",
+			`<p>the <b>fish</b> in <a href="/oil" title="hot">oil</a></p>`},
+		{"across a break", point("oil", 0), point("Then", 4), "oil.\nThen",
+			`<p><a href="/oil" title="hot">oil</a>.<br>Then</p>`},
+		{"across blocks", point("Fish", 7), point("Fry", 3), "chips\nFry",
+			"<h1>chips</h1><p>Fry</p>"},
+		{"list items", point("Salt", 0), point("Vinegar", 7), "Salt\nVinegar",
+			"<ul><li>Salt</li><li>Vinegar</li></ul>"},
+		{"escaped", point("Fish", 0), point("Fish", 6), "Fish &", "Fish &amp;"},
+		{"nothing", TextPoint{}, TextPoint{}, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := TextRange{Start: test.start, End: test.end}
+			if got := SelectedText(root, r); got != test.text {
+				t.Errorf("Expected: %q | Got: %q", test.text, got)
+			}
+			if got := SelectedHTML(root, r); got != test.htmlStr {
+				t.Errorf("Expected: %q | Got: %q", test.htmlStr, got)
+			}
+		})
+	}
+}
+
+func TestWordAt(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		offset     int
+		start, end int
+	}{
+		{"in a word", "fish and chips", 6, 5, 8},
+		{"word start", "fish and chips", 9, 9, 14},
+		{"between words", "fish  and", 5, 4, 6},
+		{"past the end", "fish", 10, 0, 4},
+		{"unicode", "größe maß", 7, 6, 9},
+		{"empty", "", 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, end := WordAt(test.text, test.offset)
+			if start != test.start || end != test.end {
+				t.Errorf("Expected: %v-%v | Got: %v-%v", test.start, test.end, start, end)
+			}
+		})
+	}
+}
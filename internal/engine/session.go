@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+// open tabs are saved to the data directory, to come back on the next start
+const sessionFile = "session.json"
+
+// a crash loses at most this much of the session
+const sessionSaveInterval = 2 * time.Second
+
+// this many closed tabs can be reopened
+const maxClosedTabs = 25
+
+// ScrollPosition is where a page is scrolled to, the ui keeps it in the same shape as its page list
+type ScrollPosition struct {
+	First  int `json:"first"`  // index of the first visible line of the page
+	Offset int `json:"offset"` // pixels the first visible line is scrolled out
+}
+
+// session is the content of session.json
+type session struct {
+	Tabs     []sessionTab `json:"tabs"` // in display order
+	Selected int          `json:"selected"`
+	Closed   []sessionTab `json:"closed"` // latest closed last
+}
+
+// sessionTab is the back/forward list of a tab
+type sessionTab struct {
+	Entries []sessionEntry `json:"entries"` // oldest first
+	Current int            `json:"current"` // index of the present entry, -1 is the blank page before them
+	Pinned  bool           `json:"pinned,omitempty"`
+}
+
+type sessionEntry struct {
+	Url    string         `json:"url"`
+	Scroll ScrollPosition `json:"scroll"`
+}
+
+// sessionTabOf saves the tab's back/forward list
+// requires: s.mu is locked
+func sessionTabOf(tab *Tab) sessionTab {
+	entries, curIdx := tab.history.sessionEntries()
+	return sessionTab{Entries: entries, Current: curIdx, Pinned: tab.pinned}
+}
+
+// SaveSession writes the open tabs (and the closed ones) to the data directory,
+// the engine does it every few seconds by itself, the client calls it before quitting
+func (s *State) SaveSession() {
+	path := dataFile(sessionFile)
+	if path == "" {
+		return
+	}
+
+	s.mu.RLock()
+	current := session{Selected: s.indexOf(s.selected), Closed: s.closed}
+	for _, tab := range s.tabs {
+		current.Tabs = append(current.Tabs, sessionTabOf(tab))
+	}
+	content, err := json.Marshal(current)
+	s.mu.RUnlock()
+	if err != nil {
+		log.Println("SaveSession: json.Marshal:", err)
+		return
+	}
+
+	// one writer at a time, and don't touch the disk if nothing changed
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	if bytes.Equal(content, s.savedSession) {
+		return
+	}
+	if err := writeFileAtomic(path, content); err != nil {
+		log.Println("SaveSession:", err)
+		return
+	}
+	s.savedSession = content
+}
+
+// autosaveSession saves the session (and the visits of the history) every sessionSaveInterval until done is closed
+func (s *State) autosaveSession(done <-chan struct{}) {
+	ticker := time.NewTicker(sessionSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.SaveSession()
+			s.history.flush()
+		case <-done:
+			return
+		}
+	}
+}
+
+// loadSession reads the session saved by the last run, false if there is none
+func loadSession() (session, bool) {
+	path := dataFile(sessionFile)
+	if path == "" {
+		return session{}, false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Println("loadSession: os.ReadFile:", err)
+		}
+		return session{}, false
+	}
+	var saved session
+	if err := json.Unmarshal(content, &saved); err != nil {
+		log.Println("loadSession: json.Unmarshal:", err)
+		return session{}, false
+	}
+	return saved, len(saved.Tabs) > 0
+}
+
+// restoreSession opens the saved tabs. Their pages aren't loaded yet,
+// a tab server loads the page of its restored tab as soon as it starts.
+func (s *State) restoreSession(saved session) {
+	for _, savedTab := range saved.Tabs {
+		s.restoreTab(savedTab)
+	}
+	if saved.Selected >= 0 && saved.Selected < len(s.tabs) {
+		s.selectTab(s.tabs[saved.Selected].id)
+	}
+	s.mu.Lock()
+	s.closed = saved.Closed
+	s.mu.Unlock()
+}
+
+// restoreTab adds a tab with the saved back/forward list and selects it
+func (s *State) restoreTab(saved sessionTab) *Tab {
+	tab := s.addTab()
+	s.updateTab(tab, func(t *Tab) {
+		t.history = restoreNavHistory(saved.Entries, saved.Current)
+		t.url = t.history.getUrl()
+	})
+	if saved.Pinned {
+		s.pinTab(tab.id, true)
+	}
+	s.emit(Event{Type: UrlChanged, TabID: tab.id, Url: tab.url})
+	return tab
+}
+
+// reopenTab opens the latest closed tab again, nil if there is none
+func (s *State) reopenTab() *Tab {
+	s.mu.Lock()
+	if len(s.closed) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	saved := s.closed[len(s.closed)-1]
+	s.closed = s.closed[:len(s.closed)-1]
+	s.mu.Unlock()
+	return s.restoreTab(saved)
+}
+
+// setScroll remembers where the user scrolled the page at url in the tab,
+// it's ignored if the tab has moved on to another page
+func (s *State) setScroll(id TabID, url string, scroll ScrollPosition) {
+	tab := s.tab(id)
+	if tab == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tab.history.getUrl() == url {
+		tab.history.cur.scroll = scroll
+	}
+}
@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRestoreNavHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []string
+		curIdx   int
+		expected string
+	}{
+		{"middle", []string{"a", "b", "c"}, 1, "[a b c] 1"},
+		{"blank before them", []string{"a"}, -1, "[a] -1"},
+		{"out of range", []string{"a"}, 5, "[a] -1"},
+		{"nothing", nil, -1, "[] -1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries := make([]sessionEntry, len(test.entries))
+			for i, url := range test.entries {
+				entries[i] = sessionEntry{Url: url, Scroll: ScrollPosition{First: i}}
+			}
+			history := restoreNavHistory(entries, test.curIdx)
+			urls, cur := history.entries()
+			if got := fmt.Sprint(urls, " ", cur); got != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+			// scroll comes back with the entry
+			if saved, cur := history.sessionEntries(); cur >= 0 && saved[cur] != entries[cur] {
+				t.Errorf("Expected: %v | Got: %v", entries[cur], saved[cur])
+			}
+		})
+	}
+}
+
+// startEngine runs the engine until the test ends, then waits for it to save the session
+func startEngine(t *testing.T, state *State) {
+	done := make(chan struct{})
+	go func() {
+		Start(state, new(fakeWindow))
+		close(done)
+	}()
+	t.Cleanup(func() {
+		select {
+		case <-done:
+		default:
+			close(state.Notifier)
+			<-done
+		}
+	})
+}
+
+// waitTabTitle waits until the selected tab shows the page with the title,
+// restored tab knows its url before the page arrives
+func waitTabTitle(t *testing.T, state *State, title string) TabSnapshot {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		tab, _ := state.Snapshot().SelectedTab()
+		if PageTitle(tab.Dom.Root) == title && !tab.IsLoading {
+			return tab
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected: %v | Got: %v (loading %v)", title, PageTitle(tab.Dom.Root), tab.IsLoading)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSession(t *testing.T) {
+	useTempDataDirs(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>Page %s</title></head><body></body></html>", r.URL.Path)
+	}))
+	defer server.Close()
+
+	state := NewState()
+	done := make(chan struct{})
+	go func() {
+		Start(state, new(fakeWindow))
+		close(done)
+	}()
+	first := state.Snapshot().Selected
+	state.Notifier <- Notification{Type: Search, TabID: first, Url: server.URL + "/a"}
+	waitTabUrl(t, state, server.URL+"/a")
+	state.Notifier <- Notification{Type: Search, TabID: first, Url: server.URL + "/b"}
+	waitTabUrl(t, state, server.URL+"/b")
+	state.Notifier <- Notification{Type: Scroll, TabID: first, Url: server.URL + "/b", Scroll: ScrollPosition{First: 3, Offset: 7}}
+	// stale scroll of another page is ignored
+	state.Notifier <- Notification{Type: Scroll, TabID: first, Url: server.URL + "/a", Scroll: ScrollPosition{First: 9}}
+
+	state.Notifier <- Notification{Type: AddTab}
+	state.Notifier <- Notification{Type: AddTab}
+	deadline := time.Now().Add(3 * time.Second)
+	for len(state.Snapshot().Tabs) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected: 3 tabs | Got: %v", len(state.Snapshot().Tabs))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	second, third := state.Snapshot().Tabs[1].ID, state.Snapshot().Tabs[2].ID
+	state.Notifier <- Notification{Type: Search, TabID: third, Url: server.URL + "/c"}
+	waitTabUrl(t, state, server.URL+"/c")
+	state.Notifier <- Notification{Type: CloseTab, TabID: third}
+	state.Notifier <- Notification{Type: CloseTab, TabID: second} // blank, nothing to reopen
+	state.Notifier <- Notification{Type: ChangeTab, TabID: first}
+	// quitting saves the session
+	close(state.Notifier)
+	<-done
+
+	restored := NewState()
+	snapshot := restored.Snapshot()
+	if len(snapshot.Tabs) != 1 || snapshot.Closed != 1 {
+		t.Fatalf("Expected: 1 tab and 1 closed | Got: %v tabs and %v closed", len(snapshot.Tabs), snapshot.Closed)
+	}
+	startEngine(t, restored)
+	tab := waitTabTitle(t, restored, "Page /b")
+	if tab.Scroll != (ScrollPosition{First: 3, Offset: 7}) || PageTitle(tab.Dom.Root) != "Page /b" {
+		t.Errorf("Expected: Page /b scrolled to {3 7} | Got: %v %+v", PageTitle(tab.Dom.Root), tab.Scroll)
+	}
+	restored.Notifier <- Notification{Type: NavBack, TabID: tab.ID}
+	waitTabTitle(t, restored, "Page /a")
+
+	restored.Notifier <- Notification{Type: ReopenTab}
+	reopened := waitTabTitle(t, restored, "Page /c")
+	if reopened.ID == tab.ID || restored.Snapshot().Closed != 0 {
+		t.Errorf("Expected: /c reopened in a new tab | Got: %+v", restored.Snapshot())
+	}
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Settings are the knobs of the engine, shown in about:settings
+type Settings struct {
+	UserAgent               string
+	RequestTimeout          time.Duration
+	MaxRedirects            int
+	MaxConcurrentImageFetch int
+	DataDir                 string // where Gazer keeps its files, empty means keep nothing
+	DownloadDir             string // where downloaded files go
+	SearchEngine            string // url searching the typed words, %s is the words, empty means no search
+}
+
+var settings = Settings{
+	UserAgent:               "Gazer",
+	RequestTimeout:          3 * time.Second,
+	MaxRedirects:            10,
+	MaxConcurrentImageFetch: 4,
+	DataDir:                 defaultDataDir(),
+	DownloadDir:             defaultDownloadDir(),
+	SearchEngine:            "https://html.duckduckgo.com/html/?q=%s", // works without javascript
+}
+
+// user can override some settings in settings.json of the data directory
+const settingsFile = "settings.json"
+
+// settingsOverride is the content of settings.json, missing field keeps the default
+type settingsOverride struct {
+	UserAgent    *string `json:"user_agent"`
+	DownloadDir  *string `json:"download_dir"`
+	SearchEngine *string `json:"search_engine"`
+}
+
+// defaultDataDir is gazer/ in the user config directory e.g. ~/.config/gazer
+func defaultDataDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "gazer")
+}
+
+// dataFile is the path of the file in the data directory, empty if there is no data directory
+func dataFile(name string) string {
+	if settings.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(settings.DataDir, name)
+}
+
+// writeFileAtomic writes the content to a temporary file next to path then renames it over path,
+// so a crash in the middle leaves the old file as it was instead of half of the new one
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("os.MkdirAll: %v", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp: %v", err)
+	}
+	defer os.Remove(file.Name()) // fails harmlessly once it's renamed
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return fmt.Errorf("file.Write: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("file.Sync: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("file.Close: %v", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("os.Rename: %v", err)
+	}
+	return nil
+}
+
+// defaultDownloadDir is ~/Downloads, or downloads/ in the data directory if there is no home
+func defaultDownloadDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(defaultDataDir(), "downloads")
+	}
+	return filepath.Join(home, "Downloads")
+}
+
+// LoadSettings applies settings.json of the data directory on top of the defaults.
+// No file is fine, it's the defaults then.
+func LoadSettings() error {
+	path := dataFile(settingsFile)
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("os.ReadFile: %v", err)
+	}
+
+	var override settingsOverride
+	if err := json.Unmarshal(content, &override); err != nil {
+		return fmt.Errorf("json.Unmarshal: %v", err)
+	}
+	if override.UserAgent != nil {
+		settings.UserAgent = *override.UserAgent
+	}
+	if override.DownloadDir != nil {
+		settings.DownloadDir = *override.DownloadDir
+	}
+	if override.SearchEngine != nil {
+		settings.SearchEngine = *override.SearchEngine
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// sniffContentType decides the real media type of the content (without parameters)
+// following the WHATWG MIME sniffing algorithm (roughly):
+//   - no sniffing if the server says nosniff
+//   - unknown or missing type is sniffed from the content
+//   - text/plain is only checked for being binary
+//   - image type is checked against the image signature
+func sniffContentType(declared string, content []byte, noSniff bool) string {
+	declared = strings.ToLower(declared)
+	if noSniff && declared != "" {
+		return declared
+	}
+
+	switch {
+	case declared == "" || declared == "unknown/unknown" || declared == "application/unknown" || declared == "*/*":
+		sniffed := mediaType(http.DetectContentType(content))
+		// DetectContentType doesn't know JSON, it's still text/plain for it
+		if sniffed == "text/plain" && isJson(content) {
+			return "application/json"
+		}
+		return sniffed
+	case declared == "text/plain":
+		if mediaType(http.DetectContentType(content)) == "application/octet-stream" {
+			return "application/octet-stream" // binary pretending to be text
+		}
+		return declared
+	case strings.HasPrefix(declared, "image/"):
+		if sniffed := mediaType(http.DetectContentType(content)); strings.HasPrefix(sniffed, "image/") {
+			return sniffed
+		}
+		return declared
+	default:
+		return declared
+	}
+}
+
+// media types of the extensions we care about, so we don't depend on
+// what the system mime database (or the lack of it) says
+var extensionTypes = map[string]string{
+	".html":     "text/html",
+	".htm":      "text/html",
+	".xhtml":    "application/xhtml+xml",
+	".md":       "text/markdown",
+	".markdown": "text/markdown",
+	".txt":      "text/plain",
+	".log":      "text/plain",
+	".css":      "text/css",
+	".js":       "text/javascript",
+	".mjs":      "text/javascript",
+	".json":     "application/json",
+	".xml":      "application/xml",
+	".png":      "image/png",
+	".jpg":      "image/jpeg",
+	".jpeg":     "image/jpeg",
+	".gif":      "image/gif",
+}
+
+// typeByExtension guesses the media type of a local file from its name, empty if unknown
+func typeByExtension(filePath string) string {
+	ext := strings.ToLower(path.Ext(filePath))
+	if ext == "" {
+		return ""
+	}
+	if contentType, ok := extensionTypes[ext]; ok {
+		return contentType
+	}
+	return mediaType(mime.TypeByExtension(ext))
+}
+
+// mediaType strips the parameters from the content type e.g. "text/html; charset=utf-8" -> "text/html"
+func mediaType(contentType string) string {
+	res, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return res
+}
+
+func isJson(content []byte) bool {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return false
+	}
+	return json.Valid(trimmed)
+}
@@ -0,0 +1,400 @@
+package engine
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// TabID is a stable identity of a tab, it never changes or gets reused
+// no matter how the tabs are added, closed or moved.
+type TabID uint64
+
+type EventType uint8
+
+const (
+	TabAdded        EventType = iota
+	TabClosed                 // Url is empty
+	TabSelected               // Url is empty
+	UrlChanged                // engine changed the url by itself (e.g. history navigation, cancelled navigation)
+	DownloadStarted           // TabID is empty, Url is what's downloaded
+)
+
+// Event tells the client what the engine has changed in the state
+type Event struct {
+	Type  EventType
+	TabID TabID
+	Url   string
+}
+
+// Invalidator is something that can be told to redraw e.g. *app.Window
+type Invalidator interface {
+	Invalidate()
+}
+
+// represent the program logic state
+// only engine modules can change this, client reads it via State.Snapshot
+// and keep up with the change via State.PollEvents
+type State struct {
+	// a channel for client to notify the engine with the event
+	Notifier chan Notification
+
+	mu       sync.RWMutex // guards everything below and all the tabs' fields
+	tabs     []*Tab
+	selected TabID
+	nextId   TabID
+	events   []Event      // events that client hasn't polled
+	closed   []sessionTab // recently closed tabs to reopen, latest last
+
+	downloads      []*download // oldest first
+	nextDownloadId DownloadID
+	saveMu         sync.Mutex // one download history writer at a time
+
+	history   *historyStore  // browsing history of all tabs, it has its own lock
+	bookmarks *bookmarkStore // it has its own lock too
+
+	sessionMu    sync.Mutex // one session writer at a time
+	savedSession []byte     // what's in session.json now
+
+	window Invalidator
+}
+
+// Tab is the engine-side state of a tab.
+// Its fields are written by its own tab server only (opener by the manager), under State.mu
+type Tab struct {
+	id        TabID
+	url       string // processed URL
+	dom       Dom
+	isLoading bool
+	history   *navHistory
+	// tab that opened it from a link, closing it goes back there.
+	// 0 if there's none or the user has gone to another tab since.
+	opener TabID
+	pinned bool // pinned tabs are small and stay in front of the others
+	// latest loading progress snapshot, read it with Tab.Progress
+	progress atomic.Pointer[Progress]
+}
+
+// Snapshot is an immutable copy of the state at one point of time, one per frame.
+// Dom inside is shared with the engine but engine never mutates a committed Dom.
+type Snapshot struct {
+	Tabs      []TabSnapshot // in display order
+	Selected  TabID
+	Downloads []Download // oldest first
+	Bookmarks []Bookmark // in the order they were added
+	Closed    int        // how many closed tabs can be reopened
+}
+
+type TabSnapshot struct {
+	ID        TabID
+	Url       string
+	Dom       Dom
+	IsLoading bool
+	Progress  Progress
+	Scroll    ScrollPosition // where the user left the current page
+	Pinned    bool
+}
+
+func NewState() *State {
+	s := State{}
+	s.Notifier = make(chan Notification, 16)
+	s.history = newHistoryStore(dataFile(historyFile))
+	s.bookmarks = newBookmarkStore(dataFile(bookmarksFile))
+	s.downloads = loadDownloads()
+	for _, d := range s.downloads {
+		s.nextDownloadId = max(s.nextDownloadId, d.ID)
+	}
+	if saved, ok := loadSession(); ok {
+		s.restoreSession(saved)
+	} else {
+		s.addTab()
+	}
+	return &s
+}
+
+// Snapshot copies the current state for the client to read without worrying about the engine
+func (s *State) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tabs := make([]TabSnapshot, len(s.tabs))
+	for i, tab := range s.tabs {
+		tabs[i] = TabSnapshot{
+			ID:        tab.id,
+			Url:       tab.url,
+			Dom:       tab.dom,
+			IsLoading: tab.isLoading,
+			Progress:  tab.Progress(),
+			Scroll:    tab.history.cur.scroll,
+			Pinned:    tab.pinned,
+		}
+	}
+	downloads := make([]Download, len(s.downloads))
+	for i, d := range s.downloads {
+		downloads[i] = d.Download
+	}
+	bookmarks, _ := s.bookmarks.all()
+	return Snapshot{Tabs: tabs, Selected: s.selected, Downloads: downloads, Bookmarks: bookmarks, Closed: len(s.closed)}
+}
+
+// PollEvents returns all the events happened since the last poll, oldest first
+func (s *State) PollEvents() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.events
+	s.events = nil
+	return events
+}
+
+// SelectedTab returns the snapshot of the selected tab, false if there is no tab
+func (s Snapshot) SelectedTab() (TabSnapshot, bool) {
+	idx := s.TabIndex(s.Selected)
+	if idx == -1 {
+		return TabSnapshot{}, false
+	}
+	return s.Tabs[idx], true
+}
+
+// TabIndex returns the display index of the tab, -1 if not found
+func (s Snapshot) TabIndex(id TabID) int {
+	for i, tab := range s.Tabs {
+		if tab.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// FindTabs returns the tabs with the query in their title or url in display order, all of them if it's empty
+func (s Snapshot) FindTabs(query string) []TabSnapshot {
+	query = strings.TrimSpace(query)
+	var res []TabSnapshot
+	for _, tab := range s.Tabs {
+		if containsFold(tab.Url, query) || containsFold(PageTitle(tab.Dom.Root), query) {
+			res = append(res, tab)
+		}
+	}
+	return res
+}
+
+// addTab creates a new tab at the end and selects it
+func (s *State) addTab() *Tab {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextId++
+	tab := newTab(s.nextId)
+	s.tabs = append(s.tabs, tab)
+	s.events = append(s.events, Event{Type: TabAdded, TabID: tab.id})
+	s.setSelected(tab.id)
+	return tab
+}
+
+// openTab creates a tab for a link opened from the opener, right after the opener and the tabs it opened before
+// but never among the pinned tabs. Background tab isn't selected.
+func (s *State) openTab(opener TabID, background bool) *Tab {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextId++
+	tab := newTab(s.nextId)
+	tab.opener = opener
+	idx := s.indexOf(opener)
+	if idx == -1 {
+		idx = len(s.tabs)
+	} else {
+		idx++
+		for idx < len(s.tabs) && s.tabs[idx].opener == opener {
+			idx++
+		}
+		idx = max(idx, s.pinnedCount())
+	}
+	s.tabs = slices.Insert(s.tabs, idx, tab)
+	s.events = append(s.events, Event{Type: TabAdded, TabID: tab.id})
+	if !background {
+		s.setSelected(tab.id)
+	}
+	return tab
+}
+
+// duplicateTab opens a copy of the tab right after it and selects it, nil if not found.
+// The copy has the same back/forward list and shows the same page, without loading it again.
+func (s *State) duplicateTab(id TabID) *Tab {
+	s.mu.Lock()
+	idx := s.indexOf(id)
+	if idx == -1 {
+		s.mu.Unlock()
+		return nil
+	}
+	original := s.tabs[idx]
+	saved := sessionTabOf(original)
+	s.nextId++
+	tab := newTab(s.nextId)
+	tab.history = restoreNavHistory(saved.Entries, saved.Current)
+	tab.url, tab.dom, tab.pinned = original.url, original.dom, original.pinned
+	s.tabs = slices.Insert(s.tabs, idx+1, tab)
+	s.events = append(s.events, Event{Type: TabAdded, TabID: tab.id})
+	s.setSelected(tab.id)
+	s.mu.Unlock()
+
+	s.emit(Event{Type: UrlChanged, TabID: tab.id, Url: tab.url})
+	return tab
+}
+
+// moveTab moves the tab to the display index, pinned tabs stay in front of the others
+// so it only goes as far as the end of its group
+func (s *State) moveTab(id TabID, to int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.indexOf(id)
+	if idx == -1 {
+		return
+	}
+	tab := s.tabs[idx]
+	s.tabs = slices.Delete(s.tabs, idx, idx+1)
+	pinned := s.pinnedCount()
+	if tab.pinned {
+		to = min(max(to, 0), pinned)
+	} else {
+		to = min(max(to, pinned), len(s.tabs))
+	}
+	s.tabs = slices.Insert(s.tabs, to, tab)
+}
+
+// pinTab pins or unpins the tab, it goes to the end of the pinned tabs either way
+func (s *State) pinTab(id TabID, pinned bool) {
+	s.mu.Lock()
+	idx := s.indexOf(id)
+	if idx == -1 || s.tabs[idx].pinned == pinned {
+		s.mu.Unlock()
+		return
+	}
+	s.tabs[idx].pinned = pinned
+	to := s.pinnedCount()
+	if pinned {
+		to-- // it's counted already
+	}
+	s.mu.Unlock()
+	s.moveTab(id, to)
+}
+
+// closeTab removes the tab, if it's the selected one, select its opener or its neighbor instead
+func (s *State) closeTab(id TabID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.indexOf(id)
+	if idx == -1 {
+		return
+	}
+	// remember it for reopening, unless there is nothing to remember
+	if closed := sessionTabOf(s.tabs[idx]); len(closed.Entries) > 0 {
+		s.closed = append(s.closed, closed)
+		if len(s.closed) > maxClosedTabs {
+			s.closed = s.closed[len(s.closed)-maxClosedTabs:]
+		}
+	}
+	opener := s.tabs[idx].opener
+	s.tabs = append(s.tabs[:idx], s.tabs[idx+1:]...)
+	s.events = append(s.events, Event{Type: TabClosed, TabID: id})
+
+	if s.selected == id && len(s.tabs) > 0 {
+		s.selected = s.tabs[min(idx, len(s.tabs)-1)].id
+		if s.indexOf(opener) != -1 {
+			s.selected = opener // back to where the user opened it from
+		}
+		s.events = append(s.events, Event{Type: TabSelected, TabID: s.selected})
+	}
+}
+
+func (s *State) selectTab(id TabID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexOf(id) == -1 || s.selected == id {
+		return
+	}
+	s.setSelected(id)
+}
+
+// setSelected selects the tab. The tab the user leaves forgets its opener,
+// closing it later shouldn't jump back there.
+// requires: s.mu is locked
+func (s *State) setSelected(id TabID) {
+	if idx := s.indexOf(s.selected); idx != -1 {
+		s.tabs[idx].opener = 0
+	}
+	s.selected = id
+	s.events = append(s.events, Event{Type: TabSelected, TabID: id})
+}
+
+// tab returns the tab with the id, nil if not found (e.g. already closed)
+func (s *State) tab(id TabID) *Tab {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	idx := s.indexOf(id)
+	if idx == -1 {
+		return nil
+	}
+	return s.tabs[idx]
+}
+
+// updateTab applies f to the tab under the lock and let the window redraw
+func (s *State) updateTab(tab *Tab, f func(t *Tab)) {
+	s.mu.Lock()
+	f(tab)
+	s.mu.Unlock()
+	s.invalidate()
+}
+
+// emit queues the event for the client to poll
+func (s *State) emit(event Event) {
+	s.mu.Lock()
+	s.events = append(s.events, event)
+	s.mu.Unlock()
+	s.invalidate()
+}
+
+func (s *State) invalidate() {
+	if s.window != nil {
+		s.window.Invalidate()
+	}
+}
+
+// pinnedCount returns how many tabs are pinned, they're the first ones
+// requires: s.mu is locked
+func (s *State) pinnedCount() int {
+	count := 0
+	for _, tab := range s.tabs {
+		if tab.pinned {
+			count++
+		}
+	}
+	return count
+}
+
+// indexOf returns the index of the tab in s.tabs, -1 if not found
+// requires: s.mu is locked
+func (s *State) indexOf(id TabID) int {
+	for i, tab := range s.tabs {
+		if tab.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func newTab(id TabID) *Tab {
+	return &Tab{id: id, history: newNavHistory()}
+}
+
+// Progress returns the latest loading progress snapshot of the tab without blocking
+func (t *Tab) Progress() Progress {
+	progress := t.progress.Load()
+	if progress == nil {
+		return Progress{Phase: Loaded}
+	}
+	return *progress
+}
@@ -0,0 +1,472 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// fakeWindow counts the invalidations instead of redrawing
+type fakeWindow struct {
+	count atomic.Int64
+}
+
+func (w *fakeWindow) Invalidate() {
+	w.count.Add(1)
+}
+
+func TestCloseTab(t *testing.T) {
+	useTempDataDirs(t)
+	state := NewState()
+	first := state.Snapshot().Selected
+	second := state.addTab().id
+	third := state.addTab().id
+	state.PollEvents()
+
+	state.selectTab(second)
+	state.closeTab(second)
+	snapshot := state.Snapshot()
+	if len(snapshot.Tabs) != 2 {
+		t.Fatalf("Expected 2 tabs | Got %d", len(snapshot.Tabs))
+	}
+	// the tab that takes the closed tab's place is selected
+	if snapshot.Selected != third {
+		t.Errorf("Expected tab %d to be selected | Got %d", third, snapshot.Selected)
+	}
+
+	state.closeTab(third)
+	if selected := state.Snapshot().Selected; selected != first {
+		t.Errorf("Expected tab %d to be selected | Got %d", first, selected)
+	}
+
+	expected := []Event{
+		{Type: TabSelected, TabID: second},
+		{Type: TabClosed, TabID: second},
+		{Type: TabSelected, TabID: third},
+		{Type: TabClosed, TabID: third},
+		{Type: TabSelected, TabID: first},
+	}
+	events := state.PollEvents()
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Errorf("Expected events %v | Got %v", expected, events)
+	}
+	if events := state.PollEvents(); len(events) != 0 {
+		t.Errorf("Expected no event after polling | Got %v", events)
+	}
+
+	// closed id is never reused
+	if id := state.addTab().id; id == second || id == third {
+		t.Errorf("Expected new tab id | Got reused id %d", id)
+	}
+}
+
+func TestOpenTab(t *testing.T) {
+	useTempDataDirs(t)
+	state := NewState()
+	opener := state.Snapshot().Selected
+	other := state.addTab().id
+	state.selectTab(opener)
+
+	// links opened in the background go after the opener, in the order they're opened
+	first := state.openTab(opener, true).id
+	second := state.openTab(opener, true).id
+	snapshot := state.Snapshot()
+	order := []TabID{snapshot.Tabs[0].ID, snapshot.Tabs[1].ID, snapshot.Tabs[2].ID, snapshot.Tabs[3].ID}
+	if fmt.Sprint(order) != fmt.Sprint([]TabID{opener, first, second, other}) {
+		t.Errorf("Expected: %v | Got: %v", []TabID{opener, first, second, other}, order)
+	}
+	if snapshot.Selected != opener {
+		t.Errorf("Expected: tab %d stays selected | Got: %d", opener, snapshot.Selected)
+	}
+
+	// closing the child goes back to the opener instead of its neighbor
+	state.selectTab(second)
+	state.closeTab(second)
+	if selected := state.Snapshot().Selected; selected != opener {
+		t.Errorf("Expected: opener %d | Got: %d", opener, selected)
+	}
+
+	// the user went somewhere else in between, so it's the neighbor
+	foreground := state.openTab(opener, false).id
+	if selected := state.Snapshot().Selected; selected != foreground {
+		t.Errorf("Expected: new tab %d | Got: %d", foreground, selected)
+	}
+	state.selectTab(other)
+	state.selectTab(foreground)
+	state.closeTab(foreground)
+	if selected := state.Snapshot().Selected; selected != other {
+		t.Errorf("Expected: neighbor %d | Got: %d", other, selected)
+	}
+}
+
+func TestOpenLinkInNewTab(t *testing.T) {
+	useTempDataDirs(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>Page %s</title></head><body></body></html>", r.URL.Path)
+	}))
+	defer server.Close()
+
+	state := NewState()
+	startEngine(t, state)
+	opener := state.Snapshot().Selected
+	state.Notifier <- Notification{Type: AddTab, TabID: opener, Url: server.URL + "/a", Background: true}
+	state.Notifier <- Notification{Type: AddTab, TabID: opener, Url: server.URL + "/b"}
+	tab := waitTabTitle(t, state, "Page /b")
+
+	snapshot := state.Snapshot()
+	if len(snapshot.Tabs) != 3 || snapshot.Tabs[2].ID != tab.ID {
+		t.Fatalf("Expected: the foreground tab last of 3 | Got: %v tabs", len(snapshot.Tabs))
+	}
+	// the background one loads too
+	deadline := time.Now().Add(3 * time.Second)
+	for PageTitle(state.Snapshot().Tabs[1].Dom.Root) != "Page /a" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected: Page /a in the background tab | Got: %v", PageTitle(state.Snapshot().Tabs[1].Dom.Root))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// tabOrder is the ids of the tabs in display order
+func tabOrder(state *State) []TabID {
+	var order []TabID
+	for _, tab := range state.Snapshot().Tabs {
+		order = append(order, tab.ID)
+	}
+	return order
+}
+
+func TestMoveAndPinTab(t *testing.T) {
+	useTempDataDirs(t)
+	state := NewState()
+	a := state.Snapshot().Selected
+	b, c, d := state.addTab().id, state.addTab().id, state.addTab().id
+
+	tests := []struct {
+		name     string
+		do       func()
+		expected []TabID
+	}{
+		{"move forward", func() { state.moveTab(a, 2) }, []TabID{b, c, a, d}},
+		{"move past the end", func() { state.moveTab(b, 10) }, []TabID{c, a, d, b}},
+		{"pin goes to the front", func() { state.pinTab(d, true) }, []TabID{d, c, a, b}},
+		{"pin goes after the pinned ones", func() { state.pinTab(b, true) }, []TabID{d, b, c, a}},
+		{"unpinned can't go among the pinned", func() { state.moveTab(a, 0) }, []TabID{d, b, a, c}},
+		{"pinned can't go among the unpinned", func() { state.moveTab(d, 3) }, []TabID{b, d, a, c}},
+		{"unpin goes after the pinned ones", func() { state.pinTab(b, false) }, []TabID{d, b, a, c}},
+		{"link from a pinned tab opens after the pinned ones", func() { state.openTab(d, true) }, []TabID{d, 5, b, a, c}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.do()
+			if order := tabOrder(state); fmt.Sprint(order) != fmt.Sprint(test.expected) {
+				t.Errorf("Expected: %v | Got: %v", test.expected, order)
+			}
+		})
+	}
+
+	// pinned tabs stay pinned in the next session
+	state.SaveSession()
+	restored := NewState().Snapshot()
+	if len(restored.Tabs) != 5 || !restored.Tabs[0].Pinned || restored.Tabs[1].Pinned {
+		t.Errorf("Expected: only the first of 5 tabs pinned | Got: %+v", restored.Tabs)
+	}
+}
+
+func TestDuplicateTab(t *testing.T) {
+	useTempDataDirs(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>Page %s</title></head><body></body></html>", r.URL.Path)
+	}))
+	defer server.Close()
+
+	state := NewState()
+	startEngine(t, state)
+	original := state.Snapshot().Selected
+	state.Notifier <- Notification{Type: Search, TabID: original, Url: server.URL + "/a"}
+	waitTabUrl(t, state, server.URL+"/a")
+	state.Notifier <- Notification{Type: Search, TabID: original, Url: server.URL + "/b"}
+	waitTabUrl(t, state, server.URL+"/b")
+	state.Notifier <- Notification{Type: AddTab}
+	state.Notifier <- Notification{Type: DuplicateTab, TabID: original}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(state.Snapshot().Tabs) != 3 || state.Snapshot().Selected == original {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected: the copy selected | Got: %+v", state.Snapshot())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// the copy is selected right after the original with the same page
+	copied := waitTabTitle(t, state, "Page /b")
+	order := tabOrder(state)
+	if copied.ID == original || len(order) != 3 || order[1] != copied.ID {
+		t.Fatalf("Expected: the copy of %v second of 3 tabs | Got: %v in %v", original, copied.ID, order)
+	}
+	// with the same back/forward list
+	state.Notifier <- Notification{Type: NavBack, TabID: copied.ID}
+	waitTabTitle(t, state, "Page /a")
+	if tab := state.Snapshot().Tabs[0]; tab.Url != server.URL+"/b" {
+		t.Errorf("Expected: the original stays at /b | Got: %v", tab.Url)
+	}
+}
+
+func TestFindTabs(t *testing.T) {
+	root, _ := parser.Parse("<html><head><title>Go Examples</title></head><body></body></html>")
+	snapshot := Snapshot{Tabs: []TabSnapshot{
+		{ID: 1, Url: "https://go.dev/doc"},
+		{ID: 2, Url: "about:blank"},
+		{ID: 3, Url: "https://example.com/", Dom: Dom{Root: root}},
+	}}
+	tests := []struct {
+		query    string
+		expected []TabID
+	}{
+		{"", []TabID{1, 2, 3}},
+		{"go", []TabID{1, 3}},
+		{"  EXAMPLES ", []TabID{3}},
+		{"rust", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			var got []TabID
+			for _, tab := range snapshot.FindTabs(test.query) {
+				got = append(got, tab.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.expected) {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+		})
+	}
+}
+
+// TestConcurrentStress hammers the engine with notifications while reading snapshots
+// and polling events concurrently. Run with -race.
+func TestConcurrentStress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-time.After(50 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><title>%s</title></head><body><p>%s</p><img src="/img.png"></body></html>`,
+			r.URL.Path, r.URL.Path)
+	}))
+	defer server.Close()
+
+	useTempDataDirs(t)
+	state := NewState()
+	window := new(fakeWindow)
+	engineDone := make(chan struct{})
+	go func() {
+		Start(state, window)
+		close(engineDone)
+	}()
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	var added, closed atomic.Int64
+
+	// reader: keep reading snapshots like the renderer does every frame
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Microsecond): // don't starve the engine on a single core
+			}
+			snapshot := state.Snapshot()
+			for _, tab := range snapshot.Tabs {
+				_ = tab.Url
+				_ = tab.IsLoading
+				_ = tab.Progress.Fraction()
+				if tab.Dom.Root != nil {
+					_ = len(tab.Dom.Root.Children)
+				}
+			}
+			snapshot.SelectedTab()
+		}
+	}()
+
+	// event poller
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Microsecond): // don't starve the engine on a single core
+			}
+			for _, event := range state.PollEvents() {
+				switch event.Type {
+				case TabAdded:
+					added.Add(1)
+				case TabClosed:
+					closed.Add(1)
+				}
+			}
+		}
+	}()
+
+	paths := []string{"/a", "/b", "/slow", "/c"}
+	rng := rand.New(rand.NewSource(1))
+	for range 500 {
+		snapshot := state.Snapshot()
+		var id TabID
+		if len(snapshot.Tabs) > 0 {
+			id = snapshot.Tabs[rng.Intn(len(snapshot.Tabs))].ID
+		}
+
+		switch rng.Intn(10) {
+		case 0:
+			state.Notifier <- Notification{Type: AddTab}
+		case 1:
+			if len(snapshot.Tabs) > 1 {
+				state.Notifier <- Notification{Type: CloseTab, TabID: id}
+			}
+		case 2:
+			state.Notifier <- Notification{Type: ChangeTab, TabID: id}
+		case 3:
+			state.Notifier <- Notification{Type: NavBack, TabID: id}
+		case 4:
+			state.Notifier <- Notification{Type: NavForth, TabID: id}
+		case 5:
+			state.Notifier <- Notification{Type: Stop, TabID: id}
+		default:
+			url := server.URL + paths[rng.Intn(len(paths))]
+			state.Notifier <- Notification{Type: Search, TabID: id, Url: url}
+		}
+	}
+
+	close(state.Notifier)
+	select {
+	case <-engineDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("engine didn't stop after notifier is closed")
+	}
+	close(stop)
+	readers.Wait()
+
+	for _, event := range state.PollEvents() {
+		switch event.Type {
+		case TabAdded:
+			added.Add(1)
+		case TabClosed:
+			closed.Add(1)
+		}
+	}
+
+	snapshot := state.Snapshot()
+	if int64(len(snapshot.Tabs)) != added.Load()-closed.Load() {
+		t.Errorf("Expected %d tabs from events | Got %d", added.Load()-closed.Load(), len(snapshot.Tabs))
+	}
+	seen := make(map[TabID]bool)
+	for _, tab := range snapshot.Tabs {
+		if seen[tab.ID] {
+			t.Errorf("Duplicated tab id %d", tab.ID)
+		}
+		seen[tab.ID] = true
+	}
+	if _, ok := snapshot.SelectedTab(); !ok {
+		t.Errorf("Expected a selected tab")
+	}
+	if window.count.Load() == 0 {
+		t.Errorf("Expected the engine to invalidate the window")
+	}
+}
+
+func TestStopNavigation(t *testing.T) {
+	// the blocking paths hang until the request is cancelled, they tell when they start and when they're cancelled
+	started, cancelled := make(chan string, 8), make(chan string, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow", "/style.css", "/img.png":
+			started <- r.URL.Path
+			<-r.Context().Done()
+			cancelled <- r.URL.Path
+			return
+		}
+		// ?style= and ?img= put a stylesheet and an image on the page
+		head, body := "", ""
+		if style := r.URL.Query().Get("style"); style != "" {
+			head = fmt.Sprintf(`<link rel="stylesheet" href="%s">`, style)
+		}
+		if img := r.URL.Query().Get("img"); img != "" {
+			body = fmt.Sprintf(`<img src="%s">`, img)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>%s</title>%s</head><body><p>%s</p>%s</body></html>", r.URL.Path, head, r.URL.Path, body)
+	}))
+	defer server.Close()
+
+	waitPath := func(ch chan string, expected string) {
+		t.Helper()
+		select {
+		case got := <-ch:
+			if got != expected {
+				t.Errorf("Expected: %v | Got: %v", expected, got)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Expected: %v | Got: nothing", expected)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		path    string // the page that never loads
+		blocked string // what it hangs on
+		next    string // what the user does then: a Stop, or a Search to it
+	}{
+		{"stop page", "/slow", "/slow", ""},
+		{"stop stylesheet", "/b?style=/style.css", "/style.css", ""},
+		{"stop image", "/b?img=/img.png", "/img.png", ""},
+		{"another search", "/slow", "/slow", "/c"},
+	}
+
+	useTempDataDirs(t)
+	state := NewState()
+	startEngine(t, state)
+	id := state.Snapshot().Selected
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state.Notifier <- Notification{Type: Search, TabID: id, Url: server.URL + "/a"}
+			waitTabUrl(t, state, server.URL+"/a")
+
+			state.Notifier <- Notification{Type: Search, TabID: id, Url: server.URL + test.path}
+			waitPath(started, test.blocked)
+			if tab, _ := state.Snapshot().SelectedTab(); !tab.IsLoading || tab.Url != server.URL+"/a" {
+				t.Errorf("Expected: loading at /a | Got: %v at %v", tab.IsLoading, tab.Url)
+			}
+
+			expected, title := server.URL+"/a", "/a"
+			if test.next == "" {
+				state.Notifier <- Notification{Type: Stop, TabID: id}
+			} else {
+				state.Notifier <- Notification{Type: Search, TabID: id, Url: server.URL + test.next}
+				expected, title = server.URL+test.next, test.next
+			}
+			waitPath(cancelled, test.blocked)
+			tab := waitTabUrl(t, state, expected)
+			if got := PageTitle(tab.Dom.Root); got != title {
+				t.Errorf("Expected: %v | Got: %v", title, got)
+			}
+		})
+	}
+}
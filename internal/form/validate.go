@@ -0,0 +1,321 @@
+package form
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+type Node = parser.Node
+
+// Violation is the reason a form control fails its constraint validation.
+// Names follow the ValidityState flags in the HTML spec.
+type Violation uint8
+
+const (
+	Valid Violation = iota
+	ValueMissing
+	TypeMismatch
+	PatternMismatch
+	TooLong
+	TooShort
+	RangeUnderflow
+	RangeOverflow
+	BadInput
+)
+
+// Validity is the result of validating one form control
+type Validity struct {
+	Violation Violation
+	Message   string // message to show to the user, empty if valid
+}
+
+// Invalid is a control that fails the validation with its validity
+type Invalid struct {
+	Control  *Node
+	Validity Validity
+}
+
+// spec's "valid email address" production
+var emailRegex = regexp.MustCompile(
+	"^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?" +
+		`(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// spec's "valid floating-point number", strconv.ParseFloat also takes NaN, Inf, hex and underscores
+var floatRegex = regexp.MustCompile(`^-?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)(?:[eE][+-]?[0-9]+)?$`)
+
+// input types that are never validated (barred from constraint validation)
+var barredTypes = map[string]bool{
+	"hidden": true,
+	"submit": true,
+	"reset":  true,
+	"button": true,
+}
+
+func (v Validity) Valid() bool {
+	return v.Violation == Valid
+}
+
+// Validate checks the value of a control node against the constraints in its attributes
+// (required, type, pattern, minlength, maxlength, min, max) and returns its validity.
+func Validate(control *Node, value string) Validity {
+	if !IsValidated(control) {
+		return Validity{}
+	}
+
+	inputType := inputTypeOf(control)
+	if _, ok := control.Attrs["required"]; ok && value == "" {
+		return Validity{ValueMissing, "Please fill out this field."}
+	}
+	// the other constraints only apply to non-empty value
+	if value == "" {
+		return Validity{}
+	}
+
+	switch inputType {
+	case "email":
+		if !isValidEmails(value, hasAttr(control, "multiple")) {
+			return Validity{TypeMismatch, "Please enter an email address."}
+		}
+	case "url":
+		if !isValidAbsoluteUrl(value) {
+			return Validity{TypeMismatch, "Please enter a URL."}
+		}
+	case "number":
+		if _, ok := parseFloat(value); !ok {
+			return Validity{BadInput, "Please enter a number."}
+		}
+	}
+
+	if pattern, ok := control.Attrs["pattern"]; ok && patternApplies(inputType) {
+		// pattern must match the entire value. Invalid pattern is ignored.
+		regex, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err == nil && !regex.MatchString(value) {
+			msg := "Please match the requested format."
+			if title := control.Attrs["title"]; title != "" {
+				msg += " " + title
+			}
+			return Validity{PatternMismatch, msg}
+		}
+	}
+
+	// length is counted in UTF-16 code units, same as the browsers
+	length := len(utf16.Encode([]rune(value)))
+	if maxLen, ok := intAttr(control, "maxlength"); ok && length > maxLen {
+		return Validity{TooLong, fmt.Sprintf(
+			"Please shorten this text to %d characters or less (you are currently using %d characters).",
+			maxLen, length)}
+	}
+	if minLen, ok := intAttr(control, "minlength"); ok && length < minLen {
+		return Validity{TooShort, fmt.Sprintf(
+			"Please lengthen this text to %d characters or more (you are currently using %d characters).",
+			minLen, length)}
+	}
+
+	if inputType == "number" {
+		num, _ := parseFloat(value) // already checked above
+		if min, ok := floatAttr(control, "min"); ok && num < min {
+			return Validity{RangeUnderflow, "Value must be greater than or equal to " + control.Attrs["min"] + "."}
+		}
+		if max, ok := floatAttr(control, "max"); ok && num > max {
+			return Validity{RangeOverflow, "Value must be less than or equal to " + control.Attrs["max"] + "."}
+		}
+	}
+
+	return Validity{}
+}
+
+// ValidateForm validates every control of the form node with the values (control -> current value)
+// and returns the invalid ones in tree order. Control without value in values uses its value attribute.
+// It returns nil if the form is valid or has novalidate attribute.
+func ValidateForm(form *Node, values map[*Node]string) []Invalid {
+	if form == nil || hasAttr(form, "novalidate") {
+		return nil
+	}
+
+	var res []Invalid
+	for _, control := range Controls(form) {
+		validity := Validate(control, valueOf(control, values))
+		if !validity.Valid() {
+			res = append(res, Invalid{Control: control, Validity: validity})
+		}
+	}
+	return res
+}
+
+// IsValidated returns whether the control is a candidate for constraint validation
+func IsValidated(control *Node) bool {
+	if control == nil || control.Tag != parser.Input {
+		return false
+	}
+	if hasAttr(control, "disabled") || hasAttr(control, "readonly") {
+		return false
+	}
+	return !barredTypes[inputTypeOf(control)]
+}
+
+// Controls returns all <input> nodes inside the form node in tree order
+func Controls(form *Node) []*Node {
+	var res []*Node
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		for _, child := range node.Children {
+			if child.Tag == parser.Input {
+				res = append(res, child)
+			}
+			walk(child)
+		}
+	}
+	if form != nil {
+		walk(form)
+	}
+	return res
+}
+
+// FindForm returns the nearest <form> ancestor of the node, nil if not found
+func FindForm(node *Node) *Node {
+	for cur := node; cur != nil; cur = cur.Parent {
+		if cur.Tag == parser.Form {
+			return cur
+		}
+	}
+	return nil
+}
+
+// IsSubmitter returns whether clicking the node submits its form.
+// <button> submits by default, <input> only with type=submit.
+func IsSubmitter(node *Node) bool {
+	if node == nil {
+		return false
+	}
+	switch node.Tag {
+	case parser.Button:
+		btnType := strings.ToLower(node.Attrs["type"])
+		return btnType == "" || btnType == "submit"
+	case parser.Input:
+		return inputTypeOf(node) == "submit"
+	}
+	return false
+}
+
+// SubmissionUrl builds the url that the form submits the values to.
+// The form action is resolved against base (the document url) and the values
+// are encoded as a query, submitter's name=value is included if it has a name.
+// NOTE: we only support GET submission, method=post is also sent as GET.
+func SubmissionUrl(form *Node, submitter *Node, values map[*Node]string, base string) (string, error) {
+	if form == nil {
+		return "", fmt.Errorf("nil form")
+	}
+	baseUrl, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse: %v", err)
+	}
+	action, err := baseUrl.Parse(form.Attrs["action"])
+	if err != nil {
+		return "", fmt.Errorf("baseUrl.Parse: %v", err)
+	}
+
+	query := url.Values{}
+	for _, control := range Controls(form) {
+		name := control.Attrs["name"]
+		if name == "" || hasAttr(control, "disabled") {
+			continue
+		}
+		// only the submitter that actually submit the form is included
+		if IsSubmitter(control) || inputTypeOf(control) == "reset" || inputTypeOf(control) == "button" {
+			continue
+		}
+		query.Add(name, valueOf(control, values))
+	}
+	if submitter != nil && submitter.Attrs["name"] != "" {
+		query.Add(submitter.Attrs["name"], submitter.Attrs["value"])
+	}
+
+	action.RawQuery = query.Encode()
+	action.Fragment = ""
+	return action.String(), nil
+}
+
+func valueOf(control *Node, values map[*Node]string) string {
+	if val, ok := values[control]; ok {
+		return val
+	}
+	return control.Attrs["value"]
+}
+
+func inputTypeOf(control *Node) string {
+	inputType := strings.ToLower(strings.TrimSpace(control.Attrs["type"]))
+	if inputType == "" {
+		return "text"
+	}
+	return inputType
+}
+
+func hasAttr(node *Node, attr string) bool {
+	_, ok := node.Attrs[attr]
+	return ok
+}
+
+func intAttr(node *Node, attr string) (int, bool) {
+	raw, ok := node.Attrs[attr]
+	if !ok {
+		return 0, false
+	}
+	res, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || res < 0 {
+		return 0, false
+	}
+	return res, true
+}
+
+func floatAttr(node *Node, attr string) (float64, bool) {
+	raw, ok := node.Attrs[attr]
+	if !ok {
+		return 0, false
+	}
+	return parseFloat(strings.TrimSpace(raw))
+}
+
+// parseFloat parses a valid floating-point number, one too big for a float64 isn't
+func parseFloat(raw string) (float64, bool) {
+	if !floatRegex.MatchString(raw) {
+		return 0, false
+	}
+	res, err := strconv.ParseFloat(raw, 64)
+	return res, err == nil
+}
+
+// patternApplies returns whether the pattern attribute applies to the input type
+func patternApplies(inputType string) bool {
+	switch inputType {
+	case "text", "search", "url", "tel", "email", "password":
+		return true
+	}
+	return false
+}
+
+// isValidEmails checks an email address, or comma-separated list of them if multiple is true.
+func isValidEmails(value string, multiple bool) bool {
+	if !multiple {
+		return emailRegex.MatchString(value)
+	}
+	for _, email := range strings.Split(value, ",") {
+		if !emailRegex.MatchString(strings.TrimSpace(email)) {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidAbsoluteUrl(value string) bool {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return parsed.Scheme != "" && (parsed.Host != "" || parsed.Opaque != "" || parsed.Path != "")
+}
@@ -0,0 +1,143 @@
+package form
+
+import (
+	"testing"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+const testForm = `<!DOCTYPE html>
+<html>
+<body>
+	<form action="/search" method="get">
+		<input name="user" required minlength=3 maxlength=8>
+		<input name="email" type=email>
+		<input name="site" type="url">
+		<input name="age" type="number" min="18" max=99>
+		<input name="code" pattern="[A-Z]{3}[0-9]+" title="e.g. ABC123">
+		<input name="note" disabled required>
+		<input type="submit" name="go" value="Go">
+	</form>
+</body>
+</html>`
+
+// parseTestForm parses the test html and returns the form node with
+// its controls keyed by name
+func parseTestForm(t *testing.T) (*Node, map[string]*Node) {
+	return parseForm(t, testForm)
+}
+
+// parseForm parses the html and returns its form and the controls by name
+func parseForm(t *testing.T, html string) (*Node, map[string]*Node) {
+	root, err := parser.Parse(html)
+	if err != nil {
+		t.Fatalf("parser.Parse: %v", err)
+	}
+	var form *Node
+	var find func(node *Node)
+	find = func(node *Node) {
+		if node.Tag == parser.Form {
+			form = node
+		}
+		for _, child := range node.Children {
+			find(child)
+		}
+	}
+	find(root)
+	if form == nil {
+		t.Fatalf("form not found")
+	}
+
+	controls := make(map[string]*Node)
+	for _, control := range Controls(form) {
+		controls[control.Attrs["name"]] = control
+	}
+	return form, controls
+}
+
+func TestValidate(t *testing.T) {
+	_, controls := parseTestForm(t)
+
+	cases := []struct {
+		name     string
+		control  string
+		value    string
+		expected Violation
+	}{
+		{"required empty", "user", "", ValueMissing},
+		{"required ok", "user", "waron", Valid},
+		{"too short", "user", "wa", TooShort},
+		{"too long", "user", "waronlimsakul", TooLong},
+		{"optional empty", "email", "", Valid},
+		{"email ok", "email", "gazer@example.com", Valid},
+		{"email bad", "email", "gazer@", TypeMismatch},
+		{"url ok", "site", "https://example.com/a?b=c", Valid},
+		{"url relative", "site", "example.com", TypeMismatch},
+		{"number bad input", "age", "abc", BadInput},
+		{"number underflow", "age", "17", RangeUnderflow},
+		{"number overflow", "age", "100", RangeOverflow},
+		{"number ok", "age", "18", Valid},
+		{"number decimal", "age", "18.5e0", Valid},
+		{"number NaN", "age", "NaN", BadInput},
+		{"number Inf", "age", "Inf", BadInput},
+		{"number +Inf", "age", "+Inf", BadInput},
+		{"number plus sign", "age", "+20", BadInput},
+		{"number hex float", "age", "0x1p4", BadInput},
+		{"number underscore", "age", "2_0", BadInput},
+		{"number trailing dot", "age", "20.", BadInput},
+		{"number too big", "age", "1e999", BadInput},
+		{"pattern ok", "code", "ABC123", Valid},
+		{"pattern partial match", "code", "xABC123", PatternMismatch},
+		{"disabled is not validated", "note", "", Valid},
+		{"submit is not validated", "go", "", Valid},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := Validate(controls[tc.control], tc.value)
+			if actual.Violation != tc.expected {
+				t.Errorf("Expected: %v | Got: %v (%q)", tc.expected, actual.Violation, actual.Message)
+			}
+			if actual.Valid() != (actual.Message == "") {
+				t.Errorf("Message should be set if and only if invalid, got %q", actual.Message)
+			}
+		})
+	}
+}
+
+func TestValidateForm(t *testing.T) {
+	form, controls := parseTestForm(t)
+
+	invalids := ValidateForm(form, map[*Node]string{
+		controls["user"]:  "",
+		controls["email"]: "not an email",
+		controls["age"]:   "30",
+	})
+	if len(invalids) != 2 {
+		t.Fatalf("Expected 2 invalid controls | Got %d: %v", len(invalids), invalids)
+	}
+	if invalids[0].Control != controls["user"] || invalids[1].Control != controls["email"] {
+		t.Errorf("Expected invalid controls in tree order | Got %v", invalids)
+	}
+
+	form.Attrs["novalidate"] = ""
+	if invalids := ValidateForm(form, nil); invalids != nil {
+		t.Errorf("Expected novalidate form to be valid | Got %v", invalids)
+	}
+}
+
+func TestSubmissionUrl(t *testing.T) {
+	form, controls := parseTestForm(t)
+
+	actual, err := SubmissionUrl(form, controls["go"], map[*Node]string{
+		controls["user"]: "waron",
+		controls["code"]: "ABC 1",
+	}, "https://example.com/path/page.html#top")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := "https://example.com/search?age=&code=ABC+1&email=&go=Go&site=&user=waron"
+	if actual != expected {
+		t.Errorf("Expected: %v | Got: %v", expected, actual)
+	}
+}
@@ -0,0 +1,125 @@
+// Package gemini converts text/gemini (gemtext) into a DOM tree,
+// so a gemini page goes through the same style and render pipeline as html.
+package gemini
+
+import (
+	"strings"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// Parse converts the gemtext source into the DOM of
+// <html><head><title>title</title></head><body>...</body></html>.
+// The first heading is the title if there is one, otherwise it's fallbackTitle.
+func Parse(source, fallbackTitle string) *parser.Node {
+	root := newNode(parser.Root, nil)
+	html := newNode(parser.Html, root)
+	head := newNode(parser.Head, html)
+	body := newNode(parser.Body, html)
+
+	title := ""
+	var pre, list, quote *parser.Node // the block the line might continue
+	var preLines []string
+	for _, line := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(line, "```") {
+			if pre == nil {
+				// the rest of the line is alt text, nothing to show it with
+				pre = newNode(parser.Pre, body)
+			} else {
+				newTextNode(strings.Join(preLines, "\n"), pre)
+				pre, preLines = nil, nil
+			}
+			list, quote = nil, nil
+			continue
+		}
+		if pre != nil {
+			preLines = append(preLines, line)
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "* "):
+			quote = nil
+			if list == nil {
+				list = newNode(parser.Ul, body)
+			}
+			newTextNode(strings.TrimSpace(line[2:]), newNode(parser.Li, list))
+			continue
+		case strings.HasPrefix(line, ">"):
+			list = nil
+			if quote == nil {
+				quote = newNode(parser.Blockquote, body)
+			} else {
+				newNode(parser.Br, quote)
+			}
+			newTextNode(strings.TrimSpace(line[1:]), quote)
+			continue
+		}
+		list, quote = nil, nil
+
+		switch {
+		case strings.HasPrefix(line, "=>"):
+			link := newNode(parser.A, newNode(parser.P, body))
+			url, label := splitLinkLine(line)
+			link.Attrs["href"] = url
+			newTextNode(label, link)
+		case strings.HasPrefix(line, "#"):
+			level := len(line) - len(strings.TrimLeft(line, "#"))
+			tag := parser.H3 // ### is the smallest, more # is still a level 3 heading
+			if level == 1 {
+				tag = parser.H1
+			} else if level == 2 {
+				tag = parser.H2
+			}
+			text := strings.TrimSpace(line[min(level, 3):])
+			if title == "" {
+				title = text
+			}
+			newTextNode(text, newNode(tag, body))
+		case strings.TrimSpace(line) == "":
+			// blank lines only separate, paragraphs have margins already
+		default:
+			newTextNode(line, newNode(parser.P, body))
+		}
+	}
+
+	if pre != nil {
+		// the source ends without closing it
+		newTextNode(strings.Join(preLines, "\n"), pre)
+	}
+
+	if title == "" {
+		title = fallbackTitle
+	}
+	if title != "" {
+		newTextNode(title, newNode(parser.Title, head))
+	}
+	return root
+}
+
+// splitLinkLine splits "=> url label" into the url and the label, the label is the url if not given
+func splitLinkLine(line string) (string, string) {
+	rest := strings.TrimSpace(line[2:])
+	url, label, _ := strings.Cut(rest, " ")
+	if i := strings.IndexByte(url, '\t'); i >= 0 {
+		url, label = url[:i], url[i+1:]+" "+label
+	}
+	label = strings.TrimSpace(label)
+	if label == "" {
+		label = url
+	}
+	return url, label
+}
+
+// newNode creates a node and appends it to parent if given
+func newNode(tag parser.Tag, parent *parser.Node) *parser.Node {
+	node := &parser.Node{Tag: tag, Attrs: make(map[string]string), Children: make([]*parser.Node, 0), Parent: parent}
+	if parent != nil {
+		parent.Children = append(parent.Children, node)
+	}
+	return node
+}
+
+func newTextNode(text string, parent *parser.Node) {
+	newNode(parser.Text, parent).Inner = text
+}
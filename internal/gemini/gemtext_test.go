@@ -0,0 +1,66 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// domString shows the DOM tree in one line like "p(a[href=/](text(home)))"
+func domString(node *parser.Node) string {
+	if node.Tag == parser.Text {
+		return fmt.Sprintf("text(%s)", node.Inner)
+	}
+	parts := make([]string, 0, len(node.Children))
+	for _, child := range node.Children {
+		if child.Parent != node {
+			return "wrong parent"
+		}
+		parts = append(parts, domString(child))
+	}
+	res := node.Tag.String()
+	if href, ok := node.Attrs["href"]; ok {
+		res += fmt.Sprintf("[href=%s]", href)
+	}
+	if len(parts) > 0 {
+		res += "(" + strings.Join(parts, " ") + ")"
+	}
+	return res
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		gemtext  string
+		title    string
+		expected string // DOM of the <body>
+	}{
+		{"text", "hello\r\n\r\nworld", "page.gmi", "body(p(text(hello)) p(text(world)))"},
+		{"headings", "## Intro\n# Gazer\n### a\n#### b", "Intro", "body(h2(text(Intro)) h1(text(Gazer)) h3(text(a)) h3(text(# b)))"},
+		{"links", "=> gemini://example.com/  Example home\n=>/about\n=> /x\tTab label", "page.gmi",
+			"body(p(a[href=gemini://example.com/](text(Example home))) p(a[href=/about](text(/about))) p(a[href=/x](text(Tab label))))"},
+		{"list", "* one\n* two\n\n* three", "page.gmi", "body(ul(li(text(one)) li(text(two))) ul(li(text(three))))"},
+		{"quote", "> a\n>b\nc", "page.gmi", "body(blockquote(text(a) br text(b)) p(text(c)))"},
+		{"preformatted", "```ascii art\n# not heading\n\n  => not link\n```\n* item", "page.gmi",
+			"body(pre(text(# not heading\n\n  => not link)) ul(li(text(item))))"},
+		{"unclosed preformatted", "```\na", "page.gmi", "body(pre(text(a)))"},
+		{"list is not *bold", "*bold*", "page.gmi", "body(p(text(*bold*)))"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := Parse(test.gemtext, "page.gmi")
+			html := root.Children[0]
+			head, body := html.Children[0], html.Children[1]
+			expectedHead := fmt.Sprintf("head(title(text(%s)))", test.title)
+			if got := domString(head); got != expectedHead {
+				t.Errorf("Expected: %v | Got: %v", expectedHead, got)
+			}
+			if got := domString(body); got != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Package gopher converts gopher menus into a DOM tree of links,
+// so a menu goes through the same style and render pipeline as html.
+package gopher
+
+import (
+	"net"
+	urlPkg "net/url"
+	"strings"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+const DefaultPort = "70"
+
+// item types that are just text
+const (
+	infoItem  = 'i'
+	errorItem = '3'
+)
+
+// text icons of the item types, like Lynx shows them.
+// They are all the same width so the menu stays aligned.
+var icons = map[byte]string{
+	'0': "(TXT) ",
+	'1': "(DIR) ",
+	'2': "(CSO) ",
+	'4': "(BIN) ",
+	'5': "(BIN) ",
+	'6': "(BIN) ",
+	'7': "(?)   ",
+	'8': "(TEL) ",
+	'9': "(BIN) ",
+	'T': "(TEL) ",
+	'g': "(IMG) ",
+	'I': "(IMG) ",
+	'p': "(IMG) ",
+	'h': "(HTM) ",
+	's': "(SND) ",
+	'd': "(DOC) ",
+}
+
+const unknownIcon = "(?!)  "
+
+// no icon, but the text still lines up with the items
+const noIcon = "      "
+
+// item is a line of a gopher menu: <type><display>\t<selector>\t<host>\t<port>
+type item struct {
+	itemType byte
+	display  string
+	selector string
+	host     string
+	port     string
+}
+
+// ParseMenu converts the menu source into the DOM of
+// <html><head><title>title</title></head><body>...</body></html>,
+// every line of the menu is a <pre> with the icon and a link to the item.
+func ParseMenu(source, title string) *parser.Node {
+	root := newNode(parser.Root, nil)
+	html := newNode(parser.Html, root)
+	head := newNode(parser.Head, html)
+	if title != "" {
+		newTextNode(title, newNode(parser.Title, head))
+	}
+	body := newNode(parser.Body, html)
+
+	for _, item := range parseItems(source) {
+		line := newNode(parser.Pre, body)
+		icon, ok := icons[item.itemType]
+		if !ok {
+			icon = unknownIcon
+		}
+		if item.itemType == infoItem || item.itemType == errorItem {
+			icon = noIcon
+		}
+		href := item.url()
+		if href == "" {
+			newTextNode(icon+item.display, line)
+			continue
+		}
+		newTextNode(icon, line)
+		link := newNode(parser.A, line)
+		link.Attrs["href"] = href
+		newTextNode(item.display, link)
+	}
+	return root
+}
+
+// parseItems parses the lines of the menu until the "." line
+func parseItems(source string) []item {
+	var res []item
+	for _, line := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n") {
+		if line == "." {
+			break
+		}
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line[1:], "\t")
+		// missing fields are fine, some servers are lazy with info lines
+		for len(fields) < 4 {
+			fields = append(fields, "")
+		}
+		res = append(res, item{itemType: line[0], display: fields[0], selector: fields[1], host: fields[2], port: strings.TrimSpace(fields[3])})
+	}
+	return res
+}
+
+// url is where the item links to, empty if it doesn't link anywhere we can go
+func (i item) url() string {
+	switch i.itemType {
+	case infoItem, errorItem, '8', 'T': // no telnet client here
+		return ""
+	case 'h':
+		// link to the web e.g. "URL:https://example.com"
+		if target, ok := strings.CutPrefix(i.selector, "URL:"); ok {
+			return target
+		}
+	}
+	if i.host == "" {
+		return ""
+	}
+	host := i.host
+	if i.port != "" && i.port != DefaultPort {
+		host = net.JoinHostPort(i.host, i.port)
+	}
+	url := urlPkg.URL{Scheme: "gopher", Host: host, Path: "/" + string(i.itemType) + i.selector}
+	return url.String()
+}
+
+// ParseUrl splits the gopher url "gopher://host/<type><selector>%09<search>" into its parts.
+// Empty path is the root menu. The search can also be the query, that's what a form submits.
+func ParseUrl(url *urlPkg.URL) (byte, string, string) {
+	itemType, selector := byte('1'), ""
+	if path := strings.TrimPrefix(url.Path, "/"); path != "" {
+		itemType, selector = path[0], path[1:]
+	}
+	selector, search, found := strings.Cut(selector, "\t")
+	if !found && url.RawQuery != "" {
+		search, _ = urlPkg.QueryUnescape(url.RawQuery)
+	}
+	return itemType, selector, search
+}
+
+// newNode creates a node and appends it to parent if given
+func newNode(tag parser.Tag, parent *parser.Node) *parser.Node {
+	node := &parser.Node{Tag: tag, Attrs: make(map[string]string), Children: make([]*parser.Node, 0), Parent: parent}
+	if parent != nil {
+		parent.Children = append(parent.Children, node)
+	}
+	return node
+}
+
+func newTextNode(text string, parent *parser.Node) {
+	newNode(parser.Text, parent).Inner = text
+}
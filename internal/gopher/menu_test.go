@@ -0,0 +1,100 @@
+package gopher
+
+import (
+	"fmt"
+	urlPkg "net/url"
+	"strings"
+	"testing"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// domString shows the DOM tree in one line like "pre(text((DIR) ) a[href=/](text(home)))"
+func domString(node *parser.Node) string {
+	if node.Tag == parser.Text {
+		return fmt.Sprintf("text(%s)", node.Inner)
+	}
+	parts := make([]string, 0, len(node.Children))
+	for _, child := range node.Children {
+		if child.Parent != node {
+			return "wrong parent"
+		}
+		parts = append(parts, domString(child))
+	}
+	res := node.Tag.String()
+	if href, ok := node.Attrs["href"]; ok {
+		res += fmt.Sprintf("[href=%s]", href)
+	}
+	if len(parts) > 0 {
+		res += "(" + strings.Join(parts, " ") + ")"
+	}
+	return res
+}
+
+func TestParseMenu(t *testing.T) {
+	menu := strings.Join([]string{
+		"iWelcome to Gazer\tfake\t(NULL)\t0",
+		"1Docs\t/docs\texample.com\t70",
+		"0About me\t/about.txt\texample.com\t7070",
+		"7Search\t/search\texample.com\t70",
+		"hWeb\tURL:https://example.com/\texample.com\t70",
+		"8Old BBS\t\tbbs.example.com\t23",
+		"xWhat\t/what\texample.com\t70",
+		"3Oops",
+		".",
+		"1After the end\t/\texample.com\t70",
+	}, "\r\n")
+
+	expected := []string{
+		"pre(text(      Welcome to Gazer))",
+		"pre(text((DIR) ) a[href=gopher://example.com/1/docs](text(Docs)))",
+		"pre(text((TXT) ) a[href=gopher://example.com:7070/0/about.txt](text(About me)))",
+		"pre(text((?)   ) a[href=gopher://example.com/7/search](text(Search)))",
+		"pre(text((HTM) ) a[href=https://example.com/](text(Web)))",
+		"pre(text((TEL) Old BBS))",
+		"pre(text((?!)  ) a[href=gopher://example.com/x/what](text(What)))",
+		"pre(text(      Oops))",
+	}
+
+	root := ParseMenu(menu, "example.com")
+	html := root.Children[0]
+	head, body := html.Children[0], html.Children[1]
+	if got := domString(head); got != "head(title(text(example.com)))" {
+		t.Errorf("Expected: head(title(text(example.com))) | Got: %v", got)
+	}
+	if len(body.Children) != len(expected) {
+		t.Fatalf("Expected: %v lines | Got: %v", len(expected), domString(body))
+	}
+	for i, line := range body.Children {
+		if got := domString(line); got != expected[i] {
+			t.Errorf("Expected: %v | Got: %v", expected[i], got)
+		}
+	}
+}
+
+func TestParseUrl(t *testing.T) {
+	tests := []struct {
+		url      string
+		itemType byte
+		selector string
+		search   string
+	}{
+		{"gopher://example.com", '1', "", ""},
+		{"gopher://example.com/", '1', "", ""},
+		{"gopher://example.com/0/about.txt", '0', "/about.txt", ""},
+		{"gopher://example.com/1/what%3F", '1', "/what?", ""},
+		{"gopher://example.com/7/search%09gazer%20browser", '7', "/search", "gazer browser"},
+		{"gopher://example.com/7/search?gazer%20browser", '7', "/search", "gazer browser"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			url, _ := urlPkg.Parse(test.url)
+			itemType, selector, search := ParseUrl(url)
+			if itemType != test.itemType || selector != test.selector || search != test.search {
+				t.Errorf("Expected: %c %v %v | Got: %c %v %v",
+					test.itemType, test.selector, test.search, itemType, selector, search)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+// Package highlight splits source code into colored spans for view-source
+package highlight
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Kind uint8
+
+const (
+	Text      Kind = iota // text content, whitespace and anything else
+	Tag                   // <tag, </tag, > and />
+	AttrName              // attribute name
+	AttrValue             // attribute value with its quotes
+	Comment               // <!-- ... -->
+	Doctype               // <!DOCTYPE ...>
+)
+
+// Span is a piece of source with the same kind, never contains a line break
+type Span struct {
+	Text string
+	Kind Kind
+	// for href and src attribute value: the value without quotes, empty otherwise
+	Link string
+}
+
+// attributes whose value is a url worth clicking
+var linkAttrs = map[string]bool{
+	"href": true,
+	"src":  true,
+}
+
+// elements whose content is raw text (no tag inside)
+var rawTextElements = map[string]bool{
+	"script": true,
+	"style":  true,
+}
+
+// Html highlights html source and returns the spans of each line
+func Html(source string) [][]Span {
+	h := htmlHighlighter{src: source}
+	h.run()
+	return splitLines(h.spans)
+}
+
+type htmlHighlighter struct {
+	src   string
+	pos   int
+	spans []Span
+}
+
+func (h *htmlHighlighter) emit(text string, kind Kind, link string) {
+	if text == "" {
+		return
+	}
+	h.spans = append(h.spans, Span{Text: text, Kind: kind, Link: link})
+}
+
+func (h *htmlHighlighter) run() {
+	for h.pos < len(h.src) {
+		rest := h.src[h.pos:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			h.emit(h.until("-->"), Comment, "")
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			h.emit(h.until(">"), Doctype, "")
+		case isTagStart(rest):
+			name := h.tag()
+			if rawTextElements[name] {
+				h.rawText(name)
+			}
+		default:
+			// text until the next tag-like thing
+			end := strings.IndexByte(rest[1:], '<')
+			if end == -1 {
+				end = len(rest)
+			} else {
+				end++
+			}
+			h.emit(rest[:end], Text, "")
+			h.pos += end
+		}
+	}
+}
+
+// until consumes everything until (and including) end, or to the end of the source
+func (h *htmlHighlighter) until(end string) string {
+	rest := h.src[h.pos:]
+	idx := strings.Index(rest, end)
+	if idx == -1 {
+		h.pos = len(h.src)
+		return rest
+	}
+	h.pos += idx + len(end)
+	return rest[:idx+len(end)]
+}
+
+// tag consumes a whole open or close tag with its attributes and returns the lower-case tag name
+func (h *htmlHighlighter) tag() string {
+	start := h.pos
+	h.pos++ // <
+	if h.pos < len(h.src) && h.src[h.pos] == '/' {
+		h.pos++
+	}
+	nameStart := h.pos
+	for h.pos < len(h.src) && !isTagNameEnd(h.src[h.pos]) {
+		h.pos++
+	}
+	name := strings.ToLower(h.src[nameStart:h.pos])
+	isClose := h.src[start+1] == '/'
+	h.emit(h.src[start:h.pos], Tag, "")
+
+	for h.pos < len(h.src) {
+		char := h.src[h.pos]
+		switch {
+		case char == '>':
+			h.emit(">", Tag, "")
+			h.pos++
+			if isClose {
+				return ""
+			}
+			return name
+		case strings.HasPrefix(h.src[h.pos:], "/>"):
+			h.emit("/>", Tag, "")
+			h.pos += 2
+			return "" // self-closed, no content
+		case isSpace(char) || char == '/':
+			h.emit(string(char), Text, "")
+			h.pos++
+		default:
+			h.attribute()
+		}
+	}
+	return ""
+}
+
+// attribute consumes name, name=value, name="value" or name='value'
+func (h *htmlHighlighter) attribute() {
+	nameStart := h.pos
+	for h.pos < len(h.src) && !isSpace(h.src[h.pos]) && !strings.ContainsRune("=>/", rune(h.src[h.pos])) {
+		h.pos++
+	}
+	if h.pos == nameStart {
+		// stray character like a lone quote, don't get stuck on it
+		h.pos++
+	}
+	name := strings.ToLower(h.src[nameStart:h.pos])
+	h.emit(h.src[nameStart:h.pos], AttrName, "")
+
+	// optional spaces around =
+	eq := h.pos
+	for eq < len(h.src) && isSpace(h.src[eq]) {
+		eq++
+	}
+	if eq >= len(h.src) || h.src[eq] != '=' {
+		return // boolean attribute
+	}
+	valueStart := eq + 1
+	for valueStart < len(h.src) && isSpace(h.src[valueStart]) {
+		valueStart++
+	}
+	h.emit(h.src[h.pos:valueStart], Text, "")
+	h.pos = valueStart
+	if h.pos >= len(h.src) {
+		return
+	}
+
+	var value, unquoted string
+	if quote := h.src[h.pos]; quote == '"' || quote == '\'' {
+		end := strings.IndexByte(h.src[h.pos+1:], quote)
+		if end == -1 {
+			end = len(h.src) - h.pos - 1
+			value = h.src[h.pos:]
+		} else {
+			value = h.src[h.pos : h.pos+end+2]
+		}
+		unquoted = strings.Trim(value, string(quote))
+	} else {
+		end := h.pos
+		for end < len(h.src) && !isSpace(h.src[end]) && h.src[end] != '>' {
+			end++
+		}
+		value = h.src[h.pos:end]
+		unquoted = value
+	}
+	h.pos += len(value)
+
+	link := ""
+	if linkAttrs[name] {
+		link = strings.TrimSpace(unquoted)
+	}
+	h.emit(value, AttrValue, link)
+}
+
+// rawText consumes the content of <script> or <style> until its close tag
+func (h *htmlHighlighter) rawText(name string) {
+	rest := h.src[h.pos:]
+	end := strings.Index(strings.ToLower(rest), "</"+name)
+	if end == -1 {
+		end = len(rest)
+	}
+	h.emit(rest[:end], Text, "")
+	h.pos += end
+}
+
+// splitLines breaks the spans at the line breaks
+func splitLines(spans []Span) [][]Span {
+	lines := [][]Span{{}}
+	for _, span := range spans {
+		parts := strings.Split(span.Text, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				lines = append(lines, []Span{})
+			}
+			if part != "" {
+				last := len(lines) - 1
+				lines[last] = append(lines[last], Span{Text: part, Kind: span.Kind, Link: span.Link})
+			}
+		}
+	}
+	return lines
+}
+
+func isTagStart(s string) bool {
+	if len(s) < 2 || s[0] != '<' {
+		return false
+	}
+	next := rune(s[1])
+	if next == '/' && len(s) > 2 {
+		next = rune(s[2])
+	}
+	return unicode.IsLetter(next)
+}
+
+func isTagNameEnd(char byte) bool {
+	return isSpace(char) || char == '>' || char == '/'
+}
+
+func isSpace(char byte) bool {
+	return char == ' ' || char == '\t' || char == '\n' || char == '\r' || char == '\f'
+}
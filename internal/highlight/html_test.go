@@ -0,0 +1,142 @@
+package highlight
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// format shows the spans like "Tag(<a) AttrName(href)" to compare easily
+func format(lines [][]Span) string {
+	var res []string
+	for _, line := range lines {
+		var parts []string
+		for _, span := range line {
+			part := fmt.Sprintf("%d(%s)", span.Kind, span.Text)
+			if span.Link != "" {
+				part += "->" + span.Link
+			}
+			parts = append(parts, part)
+		}
+		res = append(res, strings.Join(parts, " "))
+	}
+	return strings.Join(res, "\n")
+}
+
+func TestHtml(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected [][]Span
+	}{
+		{
+			"text only",
+			"hello, world",
+			[][]Span{{{Text: "hello, world"}}},
+		},
+		{
+			"link",
+			`<a href="/about" class=nav>About</a>`,
+			[][]Span{{
+				{Text: "<a", Kind: Tag},
+				{Text: " "},
+				{Text: "href", Kind: AttrName},
+				{Text: "="},
+				{Text: `"/about"`, Kind: AttrValue, Link: "/about"},
+				{Text: " "},
+				{Text: "class", Kind: AttrName},
+				{Text: "="},
+				{Text: "nav", Kind: AttrValue},
+				{Text: ">", Kind: Tag},
+				{Text: "About"},
+				{Text: "</a", Kind: Tag},
+				{Text: ">", Kind: Tag},
+			}},
+		},
+		{
+			"doctype, comment and lines",
+			"<!DOCTYPE html>\n<!-- a\nb -->\n<br/>",
+			[][]Span{
+				{{Text: "<!DOCTYPE html>", Kind: Doctype}, {}},
+				{{Text: "<!-- a", Kind: Comment}},
+				{{Text: "b -->", Kind: Comment}},
+				{{Text: "<br", Kind: Tag}, {Text: "/>", Kind: Tag}},
+			},
+		},
+		{
+			"boolean attribute and single quote",
+			`<img src='cat.png' hidden>`,
+			[][]Span{{
+				{Text: "<img", Kind: Tag},
+				{Text: " "},
+				{Text: "src", Kind: AttrName},
+				{Text: "="},
+				{Text: "'cat.png'", Kind: AttrValue, Link: "cat.png"},
+				{Text: " "},
+				{Text: "hidden", Kind: AttrName},
+				{Text: ">", Kind: Tag},
+			}},
+		},
+		{
+			"script is raw text",
+			`<script>if (a < b) {}</script>`,
+			[][]Span{{
+				{Text: "<script", Kind: Tag},
+				{Text: ">", Kind: Tag},
+				{Text: "if (a < b) {}"},
+				{Text: "</script", Kind: Tag},
+				{Text: ">", Kind: Tag},
+			}},
+		},
+		{
+			"less than in text",
+			`1 < 2`,
+			[][]Span{{{Text: "1 "}, {Text: "< 2"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// empty spans in expected are just for readability
+			var expected [][]Span
+			for _, line := range test.expected {
+				var cleaned []Span
+				for _, span := range line {
+					if span.Text != "" {
+						cleaned = append(cleaned, span)
+					}
+				}
+				expected = append(expected, cleaned)
+			}
+
+			got := Html(test.source)
+			if format(got) != format(expected) {
+				t.Errorf("Expected:\n%v\nGot:\n%v", format(expected), format(got))
+			}
+		})
+	}
+}
+
+func TestHtmlKeepsSource(t *testing.T) {
+	// whatever the input is, joining the spans back gives the same source
+	sources := []string{
+		`<p class="a" id='b' data-x=1 checked>hi</p>`,
+		"<div\n  title=\"multi\nline\">x</div>",
+		`<a href="unterminated`,
+		`<p =oops>`,
+		"<style>p { color: red }</style><p>ok</p>",
+	}
+	for _, source := range sources {
+		var lines []string
+		for _, line := range Html(source) {
+			var builder strings.Builder
+			for _, span := range line {
+				builder.WriteString(span.Text)
+			}
+			lines = append(lines, builder.String())
+		}
+		if got := strings.Join(lines, "\n"); got != source {
+			t.Errorf("Expected: %v | Got: %v", source, got)
+		}
+	}
+}
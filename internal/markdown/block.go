@@ -0,0 +1,811 @@
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type blockKind uint8
+
+const (
+	documentBlock blockKind = iota
+	paragraphBlock
+	headingBlock
+	codeBlock
+	quoteBlock
+	listBlock
+	itemBlock
+	thematicBreakBlock
+	htmlBlock
+	tableBlock
+)
+
+// block is a node of the block structure, leaf blocks keep their raw lines
+// until they're closed then parsed into inlines
+type block struct {
+	kind     blockKind
+	parent   *block
+	children []*block
+	open     bool
+	// raw lines of paragraph, heading, code, html and table
+	lines []string
+	// last line of the block was blank, for deciding tight or loose list
+	lastLineBlank bool
+	startLine     int
+
+	inlines *inline // parsed content of paragraph and heading
+
+	level int // heading level 1-6
+
+	// code block
+	fenced      bool
+	fenceChar   byte
+	fenceLen    int
+	fenceOffset int
+	info        string
+	content     string // also the content of html block
+
+	htmlType int // html block start condition 1-7
+
+	// list and list item
+	list *listData
+
+	// table
+	aligns []string   // "", "left", "center", "right" of each column
+	cells  [][]string // raw cells of each row, the first row is the header
+	rows   [][]*inline
+}
+
+type listData struct {
+	ordered      bool
+	bulletChar   byte
+	delimiter    byte // . or ) of ordered list
+	start        int
+	tight        bool
+	markerOffset int // indent before the marker
+	padding      int // marker width + spaces after it
+}
+
+var (
+	reAtxHeading      = regexp.MustCompile(`^#{1,6}(?:[ \t]+|$)`)
+	reAtxClosing      = regexp.MustCompile(`(?:^|[ \t]+)#+[ \t]*$`)
+	reCodeFence       = regexp.MustCompile("^`{3,}|^~{3,}")
+	reClosingFence    = regexp.MustCompile("^(?:`{3,}|~{3,})[ \t]*$")
+	reSetextHeading   = regexp.MustCompile(`^(?:=+|-+)[ \t]*$`)
+	reThematicBreak   = regexp.MustCompile(`^(?:\*[ \t]*){3,}$|^(?:_[ \t]*){3,}$|^(?:-[ \t]*){3,}$`)
+	reBulletMarker    = regexp.MustCompile(`^[*+-]`)
+	reOrderedMarker   = regexp.MustCompile(`^(\d{1,9})([.)])`)
+	reTableDelimiter  = regexp.MustCompile(`^:?-+:?$`)
+	reHtmlBlockStarts = []*regexp.Regexp{
+		nil, // conditions are 1-indexed like the spec
+		regexp.MustCompile(`(?i)^<(?:script|pre|textarea|style)(?:\s|>|$)`),
+		regexp.MustCompile(`^<!--`),
+		regexp.MustCompile(`^<[?]`),
+		regexp.MustCompile(`^<![A-Za-z]`),
+		regexp.MustCompile(`^<!\[CDATA\[`),
+		regexp.MustCompile(`(?i)^<[/]?(?:address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h[123456]|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul)(?:\s|[/]?[>]|$)`),
+		regexp.MustCompile(`(?i)^(?:` + openTag + `|` + closeTag + `)\s*$`),
+	}
+	reHtmlBlockEnds = []*regexp.Regexp{
+		nil,
+		regexp.MustCompile(`(?i)</(?:script|pre|textarea|style)>`),
+		regexp.MustCompile(`-->`),
+		regexp.MustCompile(`\?>`),
+		regexp.MustCompile(`>`),
+		regexp.MustCompile(`\]\]>`),
+	}
+)
+
+// blockParser splits the source into blocks line by line, following the
+// two-phase strategy of the CommonMark spec appendix
+type blockParser struct {
+	doc          *block
+	tip          *block // deepest open block
+	oldTip       *block
+	lastMatched  *block
+	allClosed    bool
+	line         string
+	lineNumber   int
+	offset       int
+	nextNonspace int
+	indent       int
+	indented     bool
+	blank        bool
+	refs         map[string]linkRef
+}
+
+func parseBlocks(source string) (*block, map[string]linkRef) {
+	doc := &block{kind: documentBlock, open: true}
+	p := &blockParser{doc: doc, tip: doc, refs: make(map[string]linkRef), allClosed: true}
+
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+	source = strings.ReplaceAll(source, "\x00", "�")
+	lines := strings.Split(source, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1] // source ends with a line break
+	}
+	for _, line := range lines {
+		p.incorporateLine(expandTabs(line))
+	}
+	for p.tip != nil {
+		p.finalize(p.tip)
+	}
+	p.parseInlines(doc)
+	return doc, p.refs
+}
+
+// expandTabs replaces tabs with spaces to the next tab stop (4 columns)
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var builder strings.Builder
+	column := 0
+	for _, r := range line {
+		if r == '\t' {
+			spaces := 4 - column%4
+			builder.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+			continue
+		}
+		builder.WriteRune(r)
+		column++
+	}
+	return builder.String()
+}
+
+func (p *blockParser) incorporateLine(line string) {
+	p.line = line
+	p.lineNumber++
+	p.offset = 0
+
+	container := p.doc
+	p.oldTip = p.tip
+
+	// phase 1: go through the open blocks, each has to match something at the start of the line
+	allMatched := true
+	for {
+		last := container.lastChild()
+		if last == nil || !last.open {
+			break
+		}
+		container = last
+		p.findNextNonspace()
+
+		switch p.continueBlock(container) {
+		case continueMatched:
+		case continueFailed:
+			allMatched = false
+			container = container.parent
+		case continueLineDone:
+			return
+		}
+		if !allMatched {
+			break
+		}
+	}
+
+	p.allClosed = container == p.oldTip
+	p.lastMatched = container
+
+	// phase 2: look for new block starts, paragraph and table can be interrupted
+	matchedLeaf := container.kind != paragraphBlock && container.kind != tableBlock && acceptsLines(container.kind)
+	for !matchedLeaf {
+		p.findNextNonspace()
+		if !p.indented && !strings.ContainsRune("#`~*+_=<>0123456789-|:", rune(p.charAt(p.nextNonspace))) {
+			p.advanceNextNonspace()
+			break
+		}
+
+		res := startNone
+		for _, start := range blockStarts {
+			if res = start(p, container); res != startNone {
+				break
+			}
+		}
+		if res == startContainer {
+			container = p.tip
+			continue
+		}
+		if res == startLeaf {
+			container = p.tip
+			matchedLeaf = true
+			break
+		}
+		p.advanceNextNonspace()
+		break
+	}
+
+	// phase 3: the rest of the line is the content of the deepest block
+	if !p.allClosed && !p.blank && p.tip.kind == paragraphBlock {
+		// lazy continuation line of paragraph
+		p.addLine()
+		return
+	}
+
+	p.closeUnmatchedBlocks()
+	if p.blank && container.lastChild() != nil {
+		container.lastChild().lastLineBlank = true
+	}
+
+	lastLineBlank := p.blank &&
+		!(container.kind == quoteBlock ||
+			(container.kind == codeBlock && container.fenced) ||
+			(container.kind == itemBlock && len(container.children) == 0 && container.startLine == p.lineNumber))
+	for cont := container; cont != nil; cont = cont.parent {
+		cont.lastLineBlank = lastLineBlank
+	}
+
+	if acceptsLines(container.kind) {
+		p.addLine()
+		if container.kind == htmlBlock && container.htmlType >= 1 && container.htmlType <= 5 &&
+			reHtmlBlockEnds[container.htmlType].MatchString(p.line[p.offset:]) {
+			p.finalize(container)
+		}
+	} else if p.offset < len(p.line) && !p.blank {
+		p.addChild(paragraphBlock)
+		p.advanceNextNonspace()
+		p.addLine()
+	}
+}
+
+const (
+	continueMatched = iota
+	continueFailed
+	continueLineDone
+)
+
+// continueBlock tells whether the open block goes on at this line, and consumes its marker
+func (p *blockParser) continueBlock(container *block) int {
+	switch container.kind {
+	case quoteBlock:
+		if !p.indented && p.charAt(p.nextNonspace) == '>' {
+			p.advanceNextNonspace()
+			p.advanceOffset(1)
+			if p.charAt(p.offset) == ' ' {
+				p.advanceOffset(1)
+			}
+			return continueMatched
+		}
+		return continueFailed
+	case itemBlock:
+		if p.blank {
+			if len(container.children) == 0 {
+				return continueFailed // blank line right after an empty item ends it
+			}
+			p.advanceNextNonspace()
+			return continueMatched
+		}
+		if p.indent >= container.list.markerOffset+container.list.padding {
+			p.advanceOffset(container.list.markerOffset + container.list.padding)
+			return continueMatched
+		}
+		return continueFailed
+	case headingBlock, thematicBreakBlock:
+		return continueFailed
+	case codeBlock:
+		if container.fenced {
+			rest := p.line[p.nextNonspace:]
+			if p.indent <= 3 && len(rest) > 0 && rest[0] == container.fenceChar && reClosingFence.MatchString(rest) &&
+				len(strings.TrimRight(rest, " \t")) >= container.fenceLen {
+				p.finalize(container)
+				return continueLineDone
+			}
+			// skip the indentation of the opening fence
+			for i := container.fenceOffset; i > 0 && p.charAt(p.offset) == ' '; i-- {
+				p.advanceOffset(1)
+			}
+			return continueMatched
+		}
+		if p.indent >= 4 {
+			p.advanceOffset(4)
+		} else if p.blank {
+			p.advanceNextNonspace()
+		} else {
+			return continueFailed
+		}
+		return continueMatched
+	case htmlBlock:
+		if p.blank && (container.htmlType == 6 || container.htmlType == 7) {
+			return continueFailed
+		}
+		return continueMatched
+	case paragraphBlock, tableBlock:
+		if p.blank {
+			return continueFailed
+		}
+		return continueMatched
+	}
+	return continueMatched
+}
+
+const (
+	startNone      = iota
+	startContainer // a container block started, keep looking for more
+	startLeaf      // a leaf block started, the rest of the line is its content
+)
+
+type blockStart func(p *blockParser, container *block) int
+
+// the order matters, e.g. thematic break before list item for "* * *"
+var blockStarts = []blockStart{
+	startBlockQuote,
+	startAtxHeading,
+	startFencedCode,
+	startHtmlBlock,
+	startSetextHeading,
+	startThematicBreak,
+	startListItem,
+	startIndentedCode,
+	startTable,
+}
+
+func startBlockQuote(p *blockParser, container *block) int {
+	if p.indented || p.charAt(p.nextNonspace) != '>' {
+		return startNone
+	}
+	p.advanceNextNonspace()
+	p.advanceOffset(1)
+	if p.charAt(p.offset) == ' ' {
+		p.advanceOffset(1)
+	}
+	p.closeUnmatchedBlocks()
+	p.addChild(quoteBlock)
+	return startContainer
+}
+
+func startAtxHeading(p *blockParser, container *block) int {
+	if p.indented {
+		return startNone
+	}
+	match := reAtxHeading.FindString(p.line[p.nextNonspace:])
+	if match == "" {
+		return startNone
+	}
+	p.advanceNextNonspace()
+	p.advanceOffset(len(match))
+	p.closeUnmatchedBlocks()
+	heading := p.addChild(headingBlock)
+	heading.level = len(strings.TrimRight(match, " \t"))
+	content := reAtxClosing.ReplaceAllString(p.line[p.offset:], "")
+	heading.lines = []string{strings.TrimSpace(content)}
+	p.offset = len(p.line)
+	return startLeaf
+}
+
+func startFencedCode(p *blockParser, container *block) int {
+	if p.indented {
+		return startNone
+	}
+	rest := p.line[p.nextNonspace:]
+	match := reCodeFence.FindString(rest)
+	if match == "" {
+		return startNone
+	}
+	info := strings.TrimSpace(rest[len(match):])
+	if match[0] == '`' && strings.Contains(info, "`") {
+		return startNone // backtick fence can't have backtick in info string
+	}
+	p.closeUnmatchedBlocks()
+	code := p.addChild(codeBlock)
+	code.fenced = true
+	code.fenceChar = match[0]
+	code.fenceLen = len(match)
+	code.fenceOffset = p.indent
+	code.info = unescapeString(info)
+	p.offset = len(p.line)
+	return startLeaf
+}
+
+func startHtmlBlock(p *blockParser, container *block) int {
+	if p.indented || p.charAt(p.nextNonspace) != '<' {
+		return startNone
+	}
+	rest := p.line[p.nextNonspace:]
+	for htmlType := 1; htmlType <= 7; htmlType++ {
+		// type 7 can't interrupt a paragraph
+		if htmlType == 7 && container.kind == paragraphBlock {
+			break
+		}
+		if reHtmlBlockStarts[htmlType].MatchString(rest) {
+			p.closeUnmatchedBlocks()
+			html := p.addChild(htmlBlock)
+			html.htmlType = htmlType
+			// the line (with its indentation) is the content, offset stays
+			return startLeaf
+		}
+	}
+	return startNone
+}
+
+func startSetextHeading(p *blockParser, container *block) int {
+	if p.indented || container.kind != paragraphBlock || !reSetextHeading.MatchString(p.line[p.nextNonspace:]) {
+		return startNone
+	}
+	p.closeUnmatchedBlocks()
+	// reference definitions are not heading content
+	for len(container.lines) > 0 && p.extractReference(container) {
+	}
+	if len(container.lines) == 0 {
+		return startNone
+	}
+
+	heading := &block{kind: headingBlock, open: true, parent: container.parent, lines: container.lines, startLine: container.startLine}
+	heading.level = 2
+	if p.charAt(p.nextNonspace) == '=' {
+		heading.level = 1
+	}
+	siblings := container.parent.children
+	siblings[len(siblings)-1] = heading
+	p.tip = heading
+	p.offset = len(p.line)
+	return startLeaf
+}
+
+func startThematicBreak(p *blockParser, container *block) int {
+	if p.indented || !reThematicBreak.MatchString(p.line[p.nextNonspace:]) {
+		return startNone
+	}
+	p.closeUnmatchedBlocks()
+	p.addChild(thematicBreakBlock)
+	p.offset = len(p.line)
+	return startLeaf
+}
+
+func startListItem(p *blockParser, container *block) int {
+	if p.indented && container.kind != listBlock {
+		return startNone
+	}
+	data := p.parseListMarker(container)
+	if data == nil {
+		return startNone
+	}
+	p.closeUnmatchedBlocks()
+	if p.tip.kind != listBlock || !listsMatch(container.list, data) {
+		list := p.addChild(listBlock)
+		list.list = data
+	}
+	item := p.addChild(itemBlock)
+	item.list = data
+	return startContainer
+}
+
+func startIndentedCode(p *blockParser, container *block) int {
+	if !p.indented || p.tip.kind == paragraphBlock || p.blank {
+		return startNone
+	}
+	p.advanceOffset(4)
+	p.closeUnmatchedBlocks()
+	p.addChild(codeBlock)
+	return startLeaf
+}
+
+// startTable turns the last line of the paragraph into a table header (GFM)
+// when this line is the delimiter row with the same number of cells
+func startTable(p *blockParser, container *block) int {
+	if p.indented || container.kind != paragraphBlock || len(container.lines) == 0 {
+		return startNone
+	}
+	// without a pipe, "---" is a setext heading or thematic break
+	if !strings.Contains(p.line, "|") {
+		return startNone
+	}
+	delimiters := splitTableRow(p.line[p.nextNonspace:])
+	if len(delimiters) == 0 {
+		return startNone
+	}
+	aligns := make([]string, len(delimiters))
+	for i, delimiter := range delimiters {
+		if !reTableDelimiter.MatchString(delimiter) {
+			return startNone
+		}
+		left, right := strings.HasPrefix(delimiter, ":"), strings.HasSuffix(delimiter, ":")
+		switch {
+		case left && right:
+			aligns[i] = "center"
+		case left:
+			aligns[i] = "left"
+		case right:
+			aligns[i] = "right"
+		}
+	}
+	header := splitTableRow(container.lines[len(container.lines)-1])
+	if len(header) != len(delimiters) {
+		return startNone
+	}
+
+	p.closeUnmatchedBlocks()
+	container.lines = container.lines[:len(container.lines)-1]
+	parent := container.parent
+	if len(container.lines) == 0 {
+		// the whole paragraph is the header, the table takes its place
+		parent.children = parent.children[:len(parent.children)-1]
+		p.tip = parent
+	} else {
+		p.finalize(container)
+	}
+	table := p.addChild(tableBlock)
+	table.aligns = aligns
+	table.cells = [][]string{header}
+	p.offset = len(p.line)
+	return startLeaf
+}
+
+// splitTableRow splits "| a | b |" into ["a", "b"], escaped pipe is part of the cell
+func splitTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '\\' && i+1 < len(row):
+			cell.WriteString(row[i : i+2])
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	cells = append(cells, strings.TrimSpace(cell.String()))
+	if len(cells) == 1 && cells[0] == "" {
+		return nil
+	}
+	return cells
+}
+
+// parseListMarker parses the list marker at the start of the line, nil if there is none
+func (p *blockParser) parseListMarker(container *block) *listData {
+	rest := p.line[p.nextNonspace:]
+	data := &listData{tight: true, markerOffset: p.indent}
+	markerLen := 0
+
+	if match := reBulletMarker.FindString(rest); match != "" {
+		data.bulletChar = match[0]
+		markerLen = 1
+	} else if match := reOrderedMarker.FindStringSubmatch(rest); match != nil &&
+		(container.kind != paragraphBlock || match[1] == "1") {
+		data.ordered = true
+		data.start, _ = strconv.Atoi(match[1])
+		data.delimiter = match[2][0]
+		markerLen = len(match[0])
+	} else {
+		return nil
+	}
+
+	// marker has to be followed by space or the end of the line
+	next := p.charAt(p.nextNonspace + markerLen)
+	if next != 0 && next != ' ' {
+		return nil
+	}
+	// empty item can't interrupt a paragraph
+	if container.kind == paragraphBlock && strings.TrimSpace(rest[markerLen:]) == "" {
+		return nil
+	}
+
+	p.advanceNextNonspace()
+	p.advanceOffset(markerLen)
+	spacesStart := p.offset
+	for p.offset-spacesStart < 5 && p.charAt(p.offset) == ' ' {
+		p.advanceOffset(1)
+	}
+	spaces := p.offset - spacesStart
+	blankItem := p.offset >= len(p.line)
+	if spaces >= 5 || spaces < 1 || blankItem {
+		// content starts right after the marker and one space,
+		// the rest of the spaces are part of the content (e.g. indented code)
+		data.padding = markerLen + 1
+		p.offset = spacesStart
+		if p.charAt(p.offset) == ' ' {
+			p.advanceOffset(1)
+		}
+	} else {
+		data.padding = markerLen + spaces
+	}
+	return data
+}
+
+func listsMatch(a, b *listData) bool {
+	return a != nil && a.ordered == b.ordered && a.delimiter == b.delimiter && a.bulletChar == b.bulletChar
+}
+
+func (p *blockParser) findNextNonspace() {
+	i := p.offset
+	for i < len(p.line) && p.line[i] == ' ' {
+		i++
+	}
+	p.nextNonspace = i
+	p.indent = i - p.offset
+	p.indented = p.indent >= 4
+	p.blank = i >= len(p.line)
+}
+
+func (p *blockParser) advanceNextNonspace() {
+	p.offset = p.nextNonspace
+}
+
+func (p *blockParser) advanceOffset(count int) {
+	p.offset = min(p.offset+count, len(p.line))
+}
+
+func (p *blockParser) charAt(pos int) byte {
+	if pos >= len(p.line) {
+		return 0
+	}
+	return p.line[pos]
+}
+
+func (p *blockParser) addLine() {
+	p.tip.lines = append(p.tip.lines, p.line[p.offset:])
+}
+
+// addChild adds a new block to the tip, closing the blocks that can't contain it
+func (p *blockParser) addChild(kind blockKind) *block {
+	for !canContain(p.tip.kind, kind) {
+		p.finalize(p.tip)
+	}
+	child := &block{kind: kind, parent: p.tip, open: true, startLine: p.lineNumber}
+	p.tip.children = append(p.tip.children, child)
+	p.tip = child
+	return child
+}
+
+func (p *blockParser) closeUnmatchedBlocks() {
+	if p.allClosed {
+		return
+	}
+	for p.oldTip != p.lastMatched {
+		parent := p.oldTip.parent
+		p.finalize(p.oldTip)
+		p.oldTip = parent
+	}
+	p.allClosed = true
+}
+
+// finalize closes the block and does the work needed when its content is complete
+func (p *blockParser) finalize(b *block) {
+	b.open = false
+	p.tip = b.parent
+
+	switch b.kind {
+	case paragraphBlock:
+		for len(b.lines) > 0 && p.extractReference(b) {
+		}
+		if len(b.lines) == 0 {
+			// only reference definitions, not a paragraph
+			siblings := b.parent.children
+			for i, sibling := range siblings {
+				if sibling == b {
+					b.parent.children = append(siblings[:i], siblings[i+1:]...)
+					break
+				}
+			}
+		}
+	case codeBlock:
+		lines := b.lines
+		if b.fenced && len(lines) > 0 {
+			lines = lines[1:] // the opening fence line, its info string is already taken
+		} else if !b.fenced {
+			for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+		}
+		if len(lines) > 0 {
+			b.content = strings.Join(lines, "\n") + "\n"
+		}
+	case htmlBlock:
+		b.content = strings.Join(b.lines, "\n")
+	case tableBlock:
+		// the first line is the delimiter row
+		for _, line := range b.lines[1:] {
+			row := splitTableRow(line)
+			// rows have exactly as many cells as the header
+			cells := make([]string, len(b.aligns))
+			copy(cells, row)
+			b.cells = append(b.cells, cells)
+		}
+	case listBlock:
+		b.list.tight = isTight(b)
+	}
+}
+
+// isTight tells if the list is tight: no blank line between items or between blocks in an item
+func isTight(list *block) bool {
+	for i, item := range list.children {
+		lastItem := i == len(list.children)-1
+		if endsWithBlankLine(item) && !lastItem {
+			return false
+		}
+		for j, sub := range item.children {
+			if endsWithBlankLine(sub) && (!lastItem || j < len(item.children)-1) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func endsWithBlankLine(b *block) bool {
+	for b != nil {
+		if b.lastLineBlank {
+			return true
+		}
+		if b.kind != listBlock && b.kind != itemBlock {
+			return false
+		}
+		b = b.lastChild()
+	}
+	return false
+}
+
+func acceptsLines(kind blockKind) bool {
+	return kind == paragraphBlock || kind == codeBlock || kind == htmlBlock || kind == tableBlock
+}
+
+func canContain(parent, child blockKind) bool {
+	switch parent {
+	case documentBlock, quoteBlock, itemBlock:
+		return child != itemBlock
+	case listBlock:
+		return child == itemBlock
+	}
+	return false
+}
+
+func (b *block) lastChild() *block {
+	if len(b.children) == 0 {
+		return nil
+	}
+	return b.children[len(b.children)-1]
+}
+
+// extractReference parses one link reference definition at the start of
+// the paragraph and removes it, false if there is none
+func (p *blockParser) extractReference(b *block) bool {
+	content := strings.Join(b.lines, "\n")
+	def, length := parseReference(content)
+	if length == 0 {
+		return false
+	}
+	if _, exists := p.refs[def.label]; !exists {
+		// the first definition wins
+		p.refs[def.label] = def.ref
+	}
+	rest := content[length:]
+	if rest == "" {
+		b.lines = nil
+	} else {
+		b.lines = strings.Split(rest, "\n")
+	}
+	return true
+}
+
+// parseInlines parses the content of every leaf block into inlines
+func (p *blockParser) parseInlines(b *block) {
+	switch b.kind {
+	case paragraphBlock, headingBlock:
+		b.inlines = parseInline(strings.TrimSpace(strings.Join(b.lines, "\n")), p.refs)
+	case tableBlock:
+		for _, row := range b.cells {
+			parsed := make([]*inline, len(row))
+			for i, cell := range row {
+				parsed[i] = parseInline(cell, p.refs)
+			}
+			b.rows = append(b.rows, parsed)
+		}
+	}
+	for _, child := range b.children {
+		p.parseInlines(child)
+	}
+}
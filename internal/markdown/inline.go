@@ -0,0 +1,779 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type inlineKind uint8
+
+const (
+	containerInline inlineKind = iota // root of the inlines of a block
+	textInline
+	softBreakInline
+	hardBreakInline
+	codeInline
+	emphInline
+	strongInline
+	linkInline
+	imageInline
+	htmlInline
+)
+
+// inline is a node of the inline content, siblings are linked so
+// emphasis and links can wrap a range of them
+type inline struct {
+	kind       inlineKind
+	text       string // literal of text, code and html
+	dest       string // link and image
+	title      string
+	parent     *inline
+	firstChild *inline
+	lastChild  *inline
+	prev       *inline
+	next       *inline
+}
+
+type linkRef struct {
+	dest  string
+	title string
+}
+
+func (n *inline) appendChild(child *inline) {
+	child.unlink()
+	child.parent = n
+	if n.lastChild != nil {
+		n.lastChild.next = child
+		child.prev = n.lastChild
+	} else {
+		n.firstChild = child
+	}
+	n.lastChild = child
+}
+
+func (n *inline) insertAfter(sibling *inline) {
+	sibling.unlink()
+	sibling.next = n.next
+	if sibling.next != nil {
+		sibling.next.prev = sibling
+	}
+	sibling.prev = n
+	n.next = sibling
+	sibling.parent = n.parent
+	if sibling.next == nil && sibling.parent != nil {
+		sibling.parent.lastChild = sibling
+	}
+}
+
+func (n *inline) unlink() {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else if n.parent != nil {
+		n.parent.firstChild = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else if n.parent != nil {
+		n.parent.lastChild = n.prev
+	}
+	n.parent, n.next, n.prev = nil, nil, nil
+}
+
+// delimiter is a run of * or _ that might open or close emphasis
+type delimiter struct {
+	char      byte
+	count     int
+	origCount int
+	node      *inline
+	canOpen   bool
+	canClose  bool
+	prev      *delimiter
+	next      *delimiter
+}
+
+// bracket is [ or ![ that might open a link or image
+type bracket struct {
+	node          *inline
+	prev          *bracket
+	prevDelimiter *delimiter
+	index         int // position right after the bracket
+	image         bool
+	active        bool
+	bracketAfter  bool
+}
+
+const (
+	escapable = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+	attrName  = `[a-zA-Z_:][a-zA-Z0-9_.:-]*`
+	attrValue = "(?:[^\"'=<>`\\x00-\\x20]+|'[^']*'|\"[^\"]*\")"
+	attribute = `(?:\s+` + attrName + `(?:\s*=\s*` + attrValue + `)?)`
+	openTag   = `<[A-Za-z][A-Za-z0-9-]*` + attribute + `*\s*/?>`
+	closeTag  = `</[A-Za-z][A-Za-z0-9-]*\s*[>]`
+)
+
+var (
+	reEntity        = regexp.MustCompile(`(?i)^&(?:#x[a-f0-9]{1,6}|#[0-9]{1,7}|[a-z][a-z0-9]{1,31});`)
+	reAutolink      = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9.+-]{1,31}:[^<>\x00-\x20]*)>`)
+	reEmailAutolink = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	reHtmlTag       = regexp.MustCompile(`^(?:` + openTag + `|` + closeTag + `|<!-->|<!--->|<!--[\s\S]*?-->|<[?][\s\S]*?[?]>|<![A-Za-z][^>]*>|<!\[CDATA\[[\s\S]*?\]\]>)`)
+	reLinkLabel     = regexp.MustCompile(`^\[(?:[^\\\[\]]|\\.){0,999}\]`)
+	reLinkTitle     = regexp.MustCompile(`^(?:"(?:\\[\s\S]|[^\\"])*"|'(?:\\[\s\S]|[^\\'])*'|\((?:\\[\s\S]|[^\\()])*\))`)
+	reAngleDest     = regexp.MustCompile(`^<(?:[^<>\n\\]|\\.)*>`)
+	reBackticks     = regexp.MustCompile("`+")
+	reWhitespace    = regexp.MustCompile(`[ \t\r\n]+`)
+)
+
+// inlineParser parses the content of a leaf block into inlines
+type inlineParser struct {
+	subject    string
+	pos        int
+	refs       map[string]linkRef
+	delimiters *delimiter // top of the delimiter stack
+	brackets   *bracket   // top of the bracket stack
+}
+
+func parseInline(subject string, refs map[string]linkRef) *inline {
+	root := &inline{kind: containerInline}
+	p := &inlineParser{subject: subject, refs: refs}
+	for p.pos < len(p.subject) {
+		p.parseOne(root)
+	}
+	p.processEmphasis(nil)
+	mergeText(root)
+	return root
+}
+
+func (p *inlineParser) peek() byte {
+	if p.pos < len(p.subject) {
+		return p.subject[p.pos]
+	}
+	return 0
+}
+
+func (p *inlineParser) parseOne(block *inline) {
+	switch c := p.peek(); c {
+	case '\n':
+		p.parseNewline(block)
+	case '\\':
+		p.parseBackslash(block)
+	case '`':
+		p.parseBackticks(block)
+	case '*', '_':
+		p.parseDelimiters(block, c)
+	case '[':
+		p.pos++
+		node := text("[")
+		block.appendChild(node)
+		p.addBracket(node, p.pos, false)
+	case '!':
+		p.pos++
+		if p.peek() == '[' {
+			p.pos++
+			node := text("![")
+			block.appendChild(node)
+			p.addBracket(node, p.pos, true)
+		} else {
+			block.appendChild(text("!"))
+		}
+	case ']':
+		p.parseCloseBracket(block)
+	case '<':
+		p.parseAutolinkOrHtml(block)
+	case '&':
+		p.parseEntity(block)
+	default:
+		p.parseText(block)
+	}
+}
+
+func text(s string) *inline {
+	return &inline{kind: textInline, text: s}
+}
+
+// parseText consumes a run of ordinary characters
+func (p *inlineParser) parseText(block *inline) {
+	end := p.pos + 1
+	for end < len(p.subject) && !strings.ContainsRune("\n\\`*_[]!<&", rune(p.subject[end])) {
+		end++
+	}
+	block.appendChild(text(p.subject[p.pos:end]))
+	p.pos = end
+}
+
+// parseNewline makes a soft or hard line break, hard if the line ends with 2 spaces
+func (p *inlineParser) parseNewline(block *inline) {
+	p.pos++
+	last := block.lastChild
+	if last != nil && last.kind == textInline && strings.HasSuffix(last.text, " ") {
+		hard := strings.HasSuffix(last.text, "  ")
+		last.text = strings.TrimRight(last.text, " ")
+		if hard {
+			block.appendChild(&inline{kind: hardBreakInline})
+		} else {
+			block.appendChild(&inline{kind: softBreakInline})
+		}
+	} else {
+		block.appendChild(&inline{kind: softBreakInline})
+	}
+	// leading spaces of the next line are ignored
+	for p.peek() == ' ' {
+		p.pos++
+	}
+}
+
+func (p *inlineParser) parseBackslash(block *inline) {
+	p.pos++
+	c := p.peek()
+	switch {
+	case c == '\n':
+		p.pos++
+		block.appendChild(&inline{kind: hardBreakInline})
+		for p.peek() == ' ' {
+			p.pos++
+		}
+	case c != 0 && strings.IndexByte(escapable, c) >= 0:
+		p.pos++
+		block.appendChild(text(string(c)))
+	default:
+		block.appendChild(text("\\"))
+	}
+}
+
+// parseBackticks parses a code span, or the literal backticks if there's no closing one
+func (p *inlineParser) parseBackticks(block *inline) {
+	ticks := reBackticks.FindString(p.subject[p.pos:])
+	afterOpen := p.pos + len(ticks)
+	for _, loc := range reBackticks.FindAllStringIndex(p.subject[afterOpen:], -1) {
+		if loc[1]-loc[0] != len(ticks) {
+			continue
+		}
+		content := strings.ReplaceAll(p.subject[afterOpen:afterOpen+loc[0]], "\n", " ")
+		if len(content) > 2 && content[0] == ' ' && content[len(content)-1] == ' ' && strings.Trim(content, " ") != "" {
+			content = content[1 : len(content)-1]
+		}
+		block.appendChild(&inline{kind: codeInline, text: content})
+		p.pos = afterOpen + loc[1]
+		return
+	}
+	block.appendChild(text(ticks))
+	p.pos = afterOpen
+}
+
+// parseDelimiters pushes the run of * or _ to the delimiter stack
+func (p *inlineParser) parseDelimiters(block *inline, c byte) {
+	start := p.pos
+	for p.peek() == c {
+		p.pos++
+	}
+	count := p.pos - start
+
+	before, after := '\n', '\n'
+	if start > 0 {
+		before, _ = utf8.DecodeLastRuneInString(p.subject[:start])
+	}
+	if p.pos < len(p.subject) {
+		after, _ = utf8.DecodeRuneInString(p.subject[p.pos:])
+	}
+	beforeSpace, afterSpace := unicode.IsSpace(before), unicode.IsSpace(after)
+	beforePunct, afterPunct := isPunct(before), isPunct(after)
+	leftFlanking := !afterSpace && (!afterPunct || beforeSpace || beforePunct)
+	rightFlanking := !beforeSpace && (!beforePunct || afterSpace || afterPunct)
+
+	canOpen, canClose := leftFlanking, rightFlanking
+	if c == '_' {
+		canOpen = leftFlanking && (!rightFlanking || beforePunct)
+		canClose = rightFlanking && (!leftFlanking || afterPunct)
+	}
+
+	node := text(p.subject[start:p.pos])
+	block.appendChild(node)
+	if canOpen || canClose {
+		p.delimiters = &delimiter{char: c, count: count, origCount: count, node: node,
+			canOpen: canOpen, canClose: canClose, prev: p.delimiters}
+		if p.delimiters.prev != nil {
+			p.delimiters.prev.next = p.delimiters
+		}
+	}
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+func (p *inlineParser) removeDelimiter(d *delimiter) {
+	if d.prev != nil {
+		d.prev.next = d.next
+	}
+	if d.next != nil {
+		d.next.prev = d.prev
+	} else {
+		p.delimiters = d.prev // top of the stack
+	}
+}
+
+// processEmphasis matches the delimiters above stackBottom into emphasis and strong emphasis
+func (p *inlineParser) processEmphasis(stackBottom *delimiter) {
+	// lower bound of the opener search, by closer char, whether it can open and length mod 3
+	openersBottom := make(map[[3]int]*delimiter)
+
+	closer := p.delimiters
+	for closer != nil && closer.prev != stackBottom {
+		closer = closer.prev
+	}
+	for closer != nil {
+		if !closer.canClose {
+			closer = closer.next
+			continue
+		}
+
+		bottomKey := [3]int{int(closer.char), 0, closer.origCount % 3}
+		if closer.canOpen {
+			bottomKey[1] = 1
+		}
+		opener := closer.prev
+		found := false
+		for opener != nil && opener != stackBottom && opener != openersBottom[bottomKey] {
+			oddMatch := (closer.canOpen || opener.canClose) && closer.origCount%3 != 0 &&
+				(opener.origCount+closer.origCount)%3 == 0
+			if opener.char == closer.char && opener.canOpen && !oddMatch {
+				found = true
+				break
+			}
+			opener = opener.prev
+		}
+
+		oldCloser := closer
+		if found {
+			used := 1
+			if closer.count >= 2 && opener.count >= 2 {
+				used = 2
+			}
+			opener.count -= used
+			closer.count -= used
+			opener.node.text = opener.node.text[:len(opener.node.text)-used]
+			closer.node.text = closer.node.text[:len(closer.node.text)-used]
+
+			emph := &inline{kind: emphInline}
+			if used == 2 {
+				emph.kind = strongInline
+			}
+			for node := opener.node.next; node != nil && node != closer.node; {
+				next := node.next
+				emph.appendChild(node)
+				node = next
+			}
+			opener.node.insertAfter(emph)
+
+			// delimiters between opener and closer can't match anymore
+			for d := closer.prev; d != nil && d != opener; {
+				prev := d.prev
+				p.removeDelimiter(d)
+				d = prev
+			}
+			if opener.count == 0 {
+				opener.node.unlink()
+				p.removeDelimiter(opener)
+			}
+			if closer.count == 0 {
+				next := closer.next
+				closer.node.unlink()
+				p.removeDelimiter(closer)
+				closer = next
+			}
+		} else {
+			closer = closer.next
+			openersBottom[bottomKey] = oldCloser.prev
+			if !oldCloser.canOpen {
+				p.removeDelimiter(oldCloser)
+			}
+		}
+	}
+
+	for p.delimiters != nil && p.delimiters != stackBottom {
+		p.removeDelimiter(p.delimiters)
+	}
+}
+
+func (p *inlineParser) addBracket(node *inline, index int, image bool) {
+	if p.brackets != nil {
+		p.brackets.bracketAfter = true
+	}
+	p.brackets = &bracket{node: node, prev: p.brackets, prevDelimiter: p.delimiters,
+		index: index, image: image, active: true}
+}
+
+func (p *inlineParser) removeBracket() {
+	p.brackets = p.brackets.prev
+}
+
+// parseCloseBracket tries to close the last [ or ![ as a link or image
+func (p *inlineParser) parseCloseBracket(block *inline) {
+	start := p.pos
+	p.pos++
+
+	opener := p.brackets
+	if opener == nil {
+		block.appendChild(text("]"))
+		return
+	}
+	if !opener.active {
+		block.appendChild(text("]"))
+		p.removeBracket()
+		return
+	}
+
+	var dest, title string
+	matched := false
+	savePos := p.pos
+
+	// inline link [text](dest "title")
+	if p.peek() == '(' {
+		p.pos++
+		p.skipSpaceAndNewline()
+		if d, ok := p.parseLinkDestination(); ok {
+			dest = d
+			beforeTitle := p.pos
+			p.skipSpaceAndNewline()
+			// title has to be separated from the destination by whitespace
+			if p.pos != beforeTitle {
+				if t, ok := p.parseLinkTitle(); ok {
+					title = t
+				}
+			}
+			p.skipSpaceAndNewline()
+			if p.peek() == ')' {
+				p.pos++
+				matched = true
+			}
+		}
+		if !matched {
+			p.pos = savePos
+		}
+	}
+
+	// reference link [text][label], [label][] or [label]
+	if !matched {
+		beforeLabel := p.pos
+		n := len(reLinkLabel.FindString(p.subject[p.pos:]))
+		label := ""
+		if n > 2 {
+			label = p.subject[beforeLabel : beforeLabel+n]
+			p.pos += n
+		} else if !opener.bracketAfter {
+			// empty or missing second label uses the first one
+			label = p.subject[opener.index:start]
+			if n == 2 {
+				p.pos += n
+			}
+		}
+		if ref, ok := p.refs[normalizeLabel(label)]; ok && label != "" {
+			dest, title = ref.dest, ref.title
+			matched = true
+		} else {
+			p.pos = savePos
+		}
+	}
+
+	if !matched {
+		p.removeBracket()
+		p.pos = start + 1
+		block.appendChild(text("]"))
+		return
+	}
+
+	node := &inline{kind: linkInline, dest: dest, title: title}
+	if opener.image {
+		node.kind = imageInline
+	}
+	for child := opener.node.next; child != nil; {
+		next := child.next
+		node.appendChild(child)
+		child = next
+	}
+	block.appendChild(node)
+	p.processEmphasis(opener.prevDelimiter)
+	p.removeBracket()
+	opener.node.unlink()
+
+	// no link in link
+	if !opener.image {
+		for b := p.brackets; b != nil; b = b.prev {
+			if !b.image {
+				b.active = false
+			}
+		}
+	}
+}
+
+func (p *inlineParser) skipSpaceAndNewline() {
+	for p.peek() == ' ' {
+		p.pos++
+	}
+	if p.peek() == '\n' {
+		p.pos++
+	}
+	for p.peek() == ' ' {
+		p.pos++
+	}
+}
+
+// parseLinkDestination parses <dest> or dest with balanced parentheses
+func (p *inlineParser) parseLinkDestination() (string, bool) {
+	dest, n, ok := linkDestination(p.subject[p.pos:])
+	p.pos += n
+	return dest, ok
+}
+
+func linkDestination(s string) (string, int, bool) {
+	if strings.HasPrefix(s, "<") {
+		match := reAngleDest.FindString(s)
+		if match == "" {
+			return "", 0, false
+		}
+		return normalizeUri(unescapeString(match[1 : len(match)-1])), len(match), true
+	}
+
+	depth, i := 0, 0
+	for i < len(s) {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && strings.IndexByte(escapable, s[i+1]) >= 0 {
+			i += 2
+			continue
+		}
+		if c == '(' {
+			depth++
+		} else if c == ')' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		} else if c <= ' ' {
+			break
+		}
+		i++
+	}
+	if depth != 0 || (i == 0 && (len(s) == 0 || s[0] != ')')) {
+		return "", 0, false
+	}
+	return normalizeUri(unescapeString(s[:i])), i, true
+}
+
+func (p *inlineParser) parseLinkTitle() (string, bool) {
+	match := reLinkTitle.FindString(p.subject[p.pos:])
+	if match == "" {
+		return "", false
+	}
+	p.pos += len(match)
+	return unescapeString(match[1 : len(match)-1]), true
+}
+
+func (p *inlineParser) parseAutolinkOrHtml(block *inline) {
+	rest := p.subject[p.pos:]
+	if match := reAutolink.FindStringSubmatch(rest); match != nil {
+		link := &inline{kind: linkInline, dest: normalizeUri(match[1])}
+		link.appendChild(text(match[1]))
+		block.appendChild(link)
+		p.pos += len(match[0])
+		return
+	}
+	if match := reEmailAutolink.FindStringSubmatch(rest); match != nil {
+		link := &inline{kind: linkInline, dest: "mailto:" + normalizeUri(match[1])}
+		link.appendChild(text(match[1]))
+		block.appendChild(link)
+		p.pos += len(match[0])
+		return
+	}
+	if match := reHtmlTag.FindString(rest); match != "" {
+		block.appendChild(&inline{kind: htmlInline, text: match})
+		p.pos += len(match)
+		return
+	}
+	block.appendChild(text("<"))
+	p.pos++
+}
+
+func (p *inlineParser) parseEntity(block *inline) {
+	if match := reEntity.FindString(p.subject[p.pos:]); match != "" {
+		p.pos += len(match)
+		block.appendChild(text(decodeEntity(match)))
+		return
+	}
+	block.appendChild(text("&"))
+	p.pos++
+}
+
+// decodeEntity decodes &name; &#123; or &#x1F; and keeps unknown or invalid ones as they are
+func decodeEntity(entity string) string {
+	decoded := html.UnescapeString(entity)
+	if decoded == "\x00" || (strings.HasPrefix(entity, "&#") && decoded == entity) {
+		return "�"
+	}
+	return decoded
+}
+
+// unescapeString removes backslash escapes and decodes entities
+func unescapeString(s string) string {
+	if !strings.ContainsAny(s, "\\&") {
+		return s
+	}
+	var builder strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && strings.IndexByte(escapable, s[i+1]) >= 0:
+			builder.WriteByte(s[i+1])
+			i++
+		case s[i] == '&':
+			if match := reEntity.FindString(s[i:]); match != "" {
+				builder.WriteString(decodeEntity(match))
+				i += len(match) - 1
+			} else {
+				builder.WriteByte('&')
+			}
+		default:
+			builder.WriteByte(s[i])
+		}
+	}
+	return builder.String()
+}
+
+// normalizeUri percent-encodes the characters that are not allowed in url, keeps the existing escapes
+func normalizeUri(uri string) string {
+	const safe = ";/?:@&=+$,-_.!~*'()#"
+	var builder strings.Builder
+	for i := 0; i < len(uri); i++ {
+		c := uri[i]
+		switch {
+		case c == '%' && i+2 < len(uri) && isHex(uri[i+1]) && isHex(uri[i+2]):
+			builder.WriteString(uri[i : i+3])
+			i += 2
+		case c < 0x80 && (isAlnum(c) || strings.IndexByte(safe, c) >= 0):
+			builder.WriteByte(c)
+		default:
+			builder.WriteString("%" + strings.ToUpper(hex2(c)))
+		}
+	}
+	return builder.String()
+}
+
+func hex2(c byte) string {
+	const digits = "0123456789abcdef"
+	return string([]byte{digits[c>>4], digits[c&0xf]})
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func isAlnum(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// normalizeLabel makes the label of a reference case-insensitive and whitespace-insensitive
+func normalizeLabel(label string) string {
+	label = strings.TrimSuffix(strings.TrimPrefix(label, "["), "]")
+	label = strings.TrimSpace(reWhitespace.ReplaceAllString(label, " "))
+	// ToUpper then ToLower folds like ß -> SS -> ss
+	return strings.ToLower(strings.ToUpper(label))
+}
+
+// referenceDef is a link reference definition [label]: dest "title"
+type referenceDef struct {
+	label string
+	ref   linkRef
+}
+
+// parseReference parses a link reference definition at the start of s
+// and returns it with its length (including the line break), 0 if there is none
+func parseReference(s string) (referenceDef, int) {
+	label := reLinkLabel.FindString(s)
+	if label == "" || len(label) <= 2 || !strings.HasPrefix(s[len(label):], ":") {
+		return referenceDef{}, 0
+	}
+	normalized := normalizeLabel(label)
+	if normalized == "" {
+		return referenceDef{}, 0
+	}
+
+	p := &inlineParser{subject: s, pos: len(label) + 1}
+	p.skipSpaceAndNewline()
+	dest, ok := p.parseLinkDestination()
+	if !ok || (dest == "" && !strings.HasPrefix(s[p.pos-2:], "<>")) {
+		return referenceDef{}, 0
+	}
+
+	beforeTitle := p.pos
+	p.skipSpaceAndNewline()
+	title := ""
+	if p.pos != beforeTitle {
+		if t, ok := p.parseLinkTitle(); ok {
+			title = t
+		} else {
+			p.pos = beforeTitle
+		}
+	}
+
+	// the rest of the line has to be empty, otherwise try again without the title
+	end, atLineEnd := lineEnd(s, p.pos)
+	if !atLineEnd && title != "" {
+		title = ""
+		end, atLineEnd = lineEnd(s, beforeTitle)
+	}
+	if !atLineEnd {
+		return referenceDef{}, 0
+	}
+	return referenceDef{label: normalized, ref: linkRef{dest: dest, title: title}}, end
+}
+
+// lineEnd returns the position after the line break if there's only spaces from pos to the end of the line
+func lineEnd(s string, pos int) (int, bool) {
+	for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t') {
+		pos++
+	}
+	if pos == len(s) {
+		return pos, true
+	}
+	if s[pos] == '\n' {
+		return pos + 1, true
+	}
+	return pos, false
+}
+
+// mergeText joins adjacent text nodes and drops the empty ones left by the delimiters
+func mergeText(n *inline) {
+	for child := n.firstChild; child != nil; {
+		next := child.next
+		if child.kind == textInline {
+			for next != nil && next.kind == textInline {
+				child.text += next.text
+				after := next.next
+				next.unlink()
+				next = after
+			}
+			if child.text == "" {
+				child.unlink()
+			}
+		} else {
+			mergeText(child)
+		}
+		child = next
+	}
+}
+
+// plainText is the text of the inline and its children, for the alt of images
+func plainText(n *inline) string {
+	var builder strings.Builder
+	for child := n.firstChild; child != nil; child = child.next {
+		switch child.kind {
+		case textInline, codeInline:
+			builder.WriteString(child.text)
+		case softBreakInline, hardBreakInline:
+			builder.WriteString(" ")
+		default:
+			builder.WriteString(plainText(child))
+		}
+	}
+	return builder.String()
+}
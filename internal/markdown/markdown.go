@@ -0,0 +1,168 @@
+// Package markdown converts CommonMark (with GFM tables) into a DOM tree,
+// so a markdown document goes through the same style and render pipeline as html.
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// inline html we can show without an html parser
+var reBreakTag = regexp.MustCompile(`(?i)^<br\s*/?>$`)
+
+// Parse converts the markdown source into the DOM of
+// <html><head><title>title</title></head><body>...</body></html>
+func Parse(source, title string) *parser.Node {
+	doc, _ := parseBlocks(source)
+
+	root := newNode(parser.Root, nil)
+	html := newNode(parser.Html, root)
+	head := newNode(parser.Head, html)
+	if title != "" {
+		newTextNode(title, newNode(parser.Title, head))
+	}
+	body := newNode(parser.Body, html)
+	for _, child := range doc.children {
+		appendBlock(body, child, false)
+	}
+	return root
+}
+
+// newNode creates a node and appends it to parent if given
+func newNode(tag parser.Tag, parent *parser.Node) *parser.Node {
+	node := &parser.Node{Tag: tag, Attrs: make(map[string]string), Children: make([]*parser.Node, 0), Parent: parent}
+	if parent != nil {
+		parent.Children = append(parent.Children, node)
+	}
+	return node
+}
+
+// newTextNode appends the text to parent, merging with the text before it
+func newTextNode(text string, parent *parser.Node) {
+	if n := len(parent.Children); n > 0 && parent.Children[n-1].Tag == parser.Text {
+		parent.Children[n-1].Inner += text
+		return
+	}
+	newNode(parser.Text, parent).Inner = text
+}
+
+// appendBlock appends the DOM of the block to parent.
+// Paragraphs in a tight list don't get <p>, their inlines go right into <li>.
+func appendBlock(parent *parser.Node, b *block, tight bool) {
+	switch b.kind {
+	case paragraphBlock:
+		if tight {
+			appendInlines(parent, b.inlines)
+			return
+		}
+		appendInlines(newNode(parser.P, parent), b.inlines)
+	case headingBlock:
+		// we only have h1-h5
+		headings := []parser.Tag{parser.H1, parser.H2, parser.H3, parser.H4, parser.H5, parser.H5}
+		appendInlines(newNode(headings[b.level-1], parent), b.inlines)
+	case codeBlock:
+		pre := newNode(parser.Pre, parent)
+		if lang := strings.Fields(b.info); len(lang) > 0 {
+			pre.Attrs["class"] = "language-" + lang[0]
+		}
+		newTextNode(strings.TrimSuffix(b.content, "\n"), pre)
+	case htmlBlock:
+		appendHtml(parent, b.content)
+	case thematicBreakBlock:
+		newNode(parser.Hr, parent)
+	case quoteBlock:
+		quote := newNode(parser.Blockquote, parent)
+		for _, child := range b.children {
+			appendBlock(quote, child, false)
+		}
+	case listBlock:
+		tag := parser.Ul
+		if b.list.ordered {
+			tag = parser.Ol
+		}
+		list := newNode(tag, parent)
+		if b.list.ordered && b.list.start != 1 {
+			list.Attrs["start"] = strconv.Itoa(b.list.start)
+		}
+		for _, item := range b.children {
+			li := newNode(parser.Li, list)
+			for _, child := range item.children {
+				appendBlock(li, child, b.list.tight)
+			}
+		}
+	case tableBlock:
+		table := newNode(parser.Table, parent)
+		for i, row := range b.rows {
+			section, cellTag := parser.Tbody, parser.Td
+			if i == 0 {
+				section, cellTag = parser.Thead, parser.Th
+			}
+			if i <= 1 {
+				newNode(section, table)
+			}
+			tr := newNode(parser.Tr, table.Children[len(table.Children)-1])
+			for j, cell := range row {
+				td := newNode(cellTag, tr)
+				if b.aligns[j] != "" {
+					td.Attrs["align"] = b.aligns[j]
+				}
+				appendInlines(td, cell)
+			}
+		}
+	}
+}
+
+// appendInlines appends the DOM of the children of n to parent
+func appendInlines(parent *parser.Node, n *inline) {
+	for child := n.firstChild; child != nil; child = child.next {
+		switch child.kind {
+		case textInline:
+			newTextNode(child.text, parent)
+		case softBreakInline:
+			// like html, a line break in a paragraph is just a space
+			newTextNode(" ", parent)
+		case hardBreakInline:
+			newNode(parser.Br, parent)
+		case codeInline:
+			newTextNode(child.text, newNode(parser.Code, parent))
+		case emphInline:
+			appendInlines(newNode(parser.I, parent), child)
+		case strongInline:
+			appendInlines(newNode(parser.B, parent), child)
+		case linkInline:
+			a := newNode(parser.A, parent)
+			a.Attrs["href"] = child.dest
+			if child.title != "" {
+				a.Attrs["title"] = child.title
+			}
+			appendInlines(a, child)
+		case imageInline:
+			img := newNode(parser.Img, parent)
+			img.Attrs["src"] = child.dest
+			img.Attrs["alt"] = plainText(child)
+			if child.title != "" {
+				img.Attrs["title"] = child.title
+			}
+		case htmlInline:
+			// no html parser for pieces of a paragraph, only <br> is worth keeping
+			if reBreakTag.MatchString(child.text) {
+				newNode(parser.Br, parent)
+			}
+		}
+	}
+}
+
+// appendHtml parses the html block and appends its nodes to parent
+func appendHtml(parent *parser.Node, content string) {
+	root, err := parser.Parse(content)
+	if err != nil {
+		return // e.g. <!DOCTYPE> that isn't html, nothing to show
+	}
+	for _, child := range root.Children {
+		child.Parent = parent
+		parent.Children = append(parent.Children, child)
+	}
+}
@@ -0,0 +1,425 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+)
+
+// toHtml renders the block tree like the CommonMark reference implementation,
+// so the spec examples can be compared as they are
+func toHtml(source string) string {
+	doc, _ := parseBlocks(source)
+	var builder strings.Builder
+	for _, child := range doc.children {
+		blockHtml(&builder, child, false)
+	}
+	return builder.String()
+}
+
+// cr starts a new line unless we're already at one
+func cr(builder *strings.Builder) {
+	if s := builder.String(); len(s) > 0 && s[len(s)-1] != '\n' {
+		builder.WriteByte('\n')
+	}
+}
+
+func escapeHtml(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+func blockHtml(builder *strings.Builder, b *block, tight bool) {
+	switch b.kind {
+	case paragraphBlock:
+		if tight {
+			inlineHtml(builder, b.inlines)
+			return
+		}
+		cr(builder)
+		builder.WriteString("<p>")
+		inlineHtml(builder, b.inlines)
+		builder.WriteString("</p>")
+		cr(builder)
+	case headingBlock:
+		cr(builder)
+		fmt.Fprintf(builder, "<h%d>", b.level)
+		inlineHtml(builder, b.inlines)
+		fmt.Fprintf(builder, "</h%d>", b.level)
+		cr(builder)
+	case codeBlock:
+		cr(builder)
+		builder.WriteString("<pre><code")
+		if lang := strings.Fields(b.info); len(lang) > 0 {
+			fmt.Fprintf(builder, ` class="language-%s"`, escapeHtml(lang[0]))
+		}
+		builder.WriteString(">" + escapeHtml(b.content) + "</code></pre>")
+		cr(builder)
+	case htmlBlock:
+		cr(builder)
+		builder.WriteString(b.content)
+		cr(builder)
+	case thematicBreakBlock:
+		cr(builder)
+		builder.WriteString("<hr />")
+		cr(builder)
+	case quoteBlock:
+		cr(builder)
+		builder.WriteString("<blockquote>")
+		cr(builder)
+		for _, child := range b.children {
+			blockHtml(builder, child, false)
+		}
+		cr(builder)
+		builder.WriteString("</blockquote>")
+		cr(builder)
+	case listBlock:
+		tag := "ul"
+		cr(builder)
+		if b.list.ordered {
+			tag = "ol"
+			if b.list.start != 1 {
+				fmt.Fprintf(builder, `<ol start="%d">`, b.list.start)
+			} else {
+				builder.WriteString("<ol>")
+			}
+		} else {
+			builder.WriteString("<ul>")
+		}
+		cr(builder)
+		for _, item := range b.children {
+			builder.WriteString("<li>")
+			for _, child := range item.children {
+				blockHtml(builder, child, b.list.tight)
+			}
+			builder.WriteString("</li>")
+			cr(builder)
+		}
+		cr(builder)
+		builder.WriteString("</" + tag + ">")
+		cr(builder)
+	case tableBlock:
+		cr(builder)
+		builder.WriteString("<table>\n<thead>\n")
+		for i, row := range b.rows {
+			if i == 1 {
+				builder.WriteString("<tbody>\n")
+			}
+			cellTag := "td"
+			if i == 0 {
+				cellTag = "th"
+			}
+			builder.WriteString("<tr>\n")
+			for j, cell := range row {
+				if b.aligns[j] != "" {
+					fmt.Fprintf(builder, `<%s align="%s">`, cellTag, b.aligns[j])
+				} else {
+					builder.WriteString("<" + cellTag + ">")
+				}
+				inlineHtml(builder, cell)
+				builder.WriteString("</" + cellTag + ">\n")
+			}
+			builder.WriteString("</tr>\n")
+			if i == 0 {
+				builder.WriteString("</thead>\n")
+			}
+		}
+		if len(b.rows) > 1 {
+			builder.WriteString("</tbody>\n")
+		}
+		builder.WriteString("</table>\n")
+	}
+}
+
+func inlineHtml(builder *strings.Builder, n *inline) {
+	for child := n.firstChild; child != nil; child = child.next {
+		switch child.kind {
+		case textInline:
+			builder.WriteString(escapeHtml(child.text))
+		case softBreakInline:
+			builder.WriteString("\n")
+		case hardBreakInline:
+			builder.WriteString("<br />\n")
+		case codeInline:
+			builder.WriteString("<code>" + escapeHtml(child.text) + "</code>")
+		case emphInline:
+			builder.WriteString("<em>")
+			inlineHtml(builder, child)
+			builder.WriteString("</em>")
+		case strongInline:
+			builder.WriteString("<strong>")
+			inlineHtml(builder, child)
+			builder.WriteString("</strong>")
+		case linkInline:
+			fmt.Fprintf(builder, `<a href="%s"`, escapeHtml(child.dest))
+			if child.title != "" {
+				fmt.Fprintf(builder, ` title="%s"`, escapeHtml(child.title))
+			}
+			builder.WriteString(">")
+			inlineHtml(builder, child)
+			builder.WriteString("</a>")
+		case imageInline:
+			fmt.Fprintf(builder, `<img src="%s" alt="%s"`, escapeHtml(child.dest), escapeHtml(plainText(child)))
+			if child.title != "" {
+				fmt.Fprintf(builder, ` title="%s"`, escapeHtml(child.title))
+			}
+			builder.WriteString(" />")
+		case htmlInline:
+			builder.WriteString(child.text)
+		}
+	}
+}
+
+// examples from the CommonMark spec (0.31.2) and the GFM spec for tables,
+// named after their section and example number
+var specExamples = []struct {
+	name     string
+	markdown string
+	html     string
+}{
+	// tabs
+	{"tabs 1", "\tfoo\tbaz\t\tbim\n", "<pre><code>foo baz     bim\n</code></pre>\n"},
+	{"tabs 5", "- foo\n\n\t\tbar\n", "<ul>\n<li>\n<p>foo</p>\n<pre><code>  bar\n</code></pre>\n</li>\n</ul>\n"},
+	// backslash escapes
+	{"backslash escapes 12", "\\*not emphasized*\n\\<br/> not a tag\n\\[not a link](/foo)\n\\`not code`\n1\\. not a list\n\\* not a list\n\\# not a heading\n\\[foo]: /url \"not a reference\"\n\\&ouml; not a character entity\n",
+		"<p>*not emphasized*\n&lt;br/&gt; not a tag\n[not a link](/foo)\n`not code`\n1. not a list\n* not a list\n# not a heading\n[foo]: /url &quot;not a reference&quot;\n&amp;ouml; not a character entity</p>\n"},
+	{"backslash escapes 15", "foo\\\nbar\n", "<p>foo<br />\nbar</p>\n"},
+	{"backslash escapes 16", "`` \\[\\` ``\n", "<p><code>\\[\\`</code></p>\n"},
+	{"backslash escapes 22", "[foo](/bar\\* \"ti\\*tle\")\n", "<p><a href=\"/bar*\" title=\"ti*tle\">foo</a></p>\n"},
+	// entities
+	{"entity references 25", "&nbsp; &amp; &copy; &AElig; &Dcaron;\n&frac34; &HilbertSpace; &DifferentialD;\n&ClockwiseContourIntegral; &ngE;\n",
+		"<p>\u00a0 &amp; © Æ Ď\n¾ ℋ ⅆ\n∲ ≧̸</p>\n"},
+	{"entity references 26", "&#35; &#1234; &#992; &#0;\n", "<p># Ӓ Ϡ �</p>\n"},
+	{"entity references 29", "&copy\n", "<p>&amp;copy</p>\n"},
+	// thematic breaks
+	{"thematic breaks 43", "***\n---\n___\n", "<hr />\n<hr />\n<hr />\n"},
+	{"thematic breaks 44", "+++\n", "<p>+++</p>\n"},
+	{"thematic breaks 50", " - - -\n", "<hr />\n"},
+	{"thematic breaks 57", "- foo\n***\n- bar\n", "<ul>\n<li>foo</li>\n</ul>\n<hr />\n<ul>\n<li>bar</li>\n</ul>\n"},
+	{"thematic breaks 59", "Foo\n---\nbar\n", "<h2>Foo</h2>\n<p>bar</p>\n"},
+	{"thematic breaks 61", "- Foo\n- * * *\n", "<ul>\n<li>Foo</li>\n<li>\n<hr />\n</li>\n</ul>\n"},
+	// atx headings
+	{"atx headings 62", "# foo\n## foo\n### foo\n#### foo\n##### foo\n###### foo\n",
+		"<h1>foo</h1>\n<h2>foo</h2>\n<h3>foo</h3>\n<h4>foo</h4>\n<h5>foo</h5>\n<h6>foo</h6>\n"},
+	{"atx headings 63", "####### foo\n", "<p>####### foo</p>\n"},
+	{"atx headings 64", "#5 bolt\n\n#hashtag\n", "<p>#5 bolt</p>\n<p>#hashtag</p>\n"},
+	{"atx headings 67", "#                  foo                     \n", "<h1>foo</h1>\n"},
+	{"atx headings 71", "## foo ##\n  ###   bar    ###\n", "<h2>foo</h2>\n<h3>bar</h3>\n"},
+	{"atx headings 76", "### foo \\###\n## foo #\\##\n# foo \\#\n", "<h3>foo ###</h3>\n<h2>foo ###</h2>\n<h1>foo #</h1>\n"},
+	{"atx headings 79", "## \n#\n### ###\n", "<h2></h2>\n<h1></h1>\n<h3></h3>\n"},
+	// setext headings
+	{"setext headings 80", "Foo *bar*\n=========\n\nFoo *bar*\n---------\n", "<h1>Foo <em>bar</em></h1>\n<h2>Foo <em>bar</em></h2>\n"},
+	{"setext headings 81", "Foo *bar\nbaz*\n====\n", "<h1>Foo <em>bar\nbaz</em></h1>\n"},
+	{"setext headings 93", "> Foo\n---\n", "<blockquote>\n<p>Foo</p>\n</blockquote>\n<hr />\n"},
+	{"setext headings 98", "\n====\n", "<p>====</p>\n"},
+	// indented code blocks
+	{"indented code blocks 107", "    a simple\n      indented code block\n", "<pre><code>a simple\n  indented code block\n</code></pre>\n"},
+	{"indented code blocks 108", "  - foo\n\n    bar\n", "<ul>\n<li>\n<p>foo</p>\n<p>bar</p>\n</li>\n</ul>\n"},
+	{"indented code blocks 111", "    chunk1\n\n    chunk2\n  \n \n \n    chunk3\n", "<pre><code>chunk1\n\nchunk2\n\n\n\nchunk3\n</code></pre>\n"},
+	{"indented code blocks 113", "Foo\n    bar\n", "<p>Foo\nbar</p>\n"},
+	// fenced code blocks
+	{"fenced code blocks 119", "```\n<\n >\n```\n", "<pre><code>&lt;\n &gt;\n</code></pre>\n"},
+	{"fenced code blocks 122", "```\naaa\n~~~\n```\n", "<pre><code>aaa\n~~~\n</code></pre>\n"},
+	{"fenced code blocks 126", "```\n", "<pre><code></code></pre>\n"},
+	{"fenced code blocks 128", "> ```\n> aaa\n\nbbb\n", "<blockquote>\n<pre><code>aaa\n</code></pre>\n</blockquote>\n<p>bbb</p>\n"},
+	{"fenced code blocks 132", "   ```\n   aaa\n    aaa\n  aaa\n   ```\n", "<pre><code>aaa\n aaa\naaa\n</code></pre>\n"},
+	{"fenced code blocks 142", "```ruby\ndef foo(x)\n  return 3\nend\n```\n", "<pre><code class=\"language-ruby\">def foo(x)\n  return 3\nend\n</code></pre>\n"},
+	{"fenced code blocks 145", "``` aa ```\nfoo\n", "<p><code>aa</code>\nfoo</p>\n"},
+	// html blocks
+	{"html blocks 148", "<table><tr><td>\n<pre>\n**Hello**,\n\n_world_.\n</pre>\n</td></tr></table>\n",
+		"<table><tr><td>\n<pre>\n**Hello**,\n<p><em>world</em>.\n</pre></p>\n</td></tr></table>\n"},
+	{"html blocks 161", "<div>\n*foo*\n\n*bar*\n", "<div>\n*foo*\n<p><em>bar</em></p>\n"},
+	{"html blocks 178", "<!-- Foo\n\nbar\n   baz -->\nokay\n", "<!-- Foo\n\nbar\n   baz -->\n<p>okay</p>\n"},
+	// link reference definitions
+	{"link reference definitions 192", "[foo]: /url \"title\"\n\n[foo]\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+	{"link reference definitions 193", "   [foo]: \n      /url  \n           'the title'  \n\n[foo]\n", "<p><a href=\"/url\" title=\"the title\">foo</a></p>\n"},
+	{"link reference definitions 201", "[foo]: /url\\bar\\*baz \"foo\\\"bar\\baz\"\n\n[foo]\n", "<p><a href=\"/url%5Cbar*baz\" title=\"foo&quot;bar\\baz\">foo</a></p>\n"},
+	{"link reference definitions 204", "[FOO]: /url\n\n[Foo]\n", "<p><a href=\"/url\">Foo</a></p>\n"},
+	{"link reference definitions 206", "[foo]: /url\n", ""},
+	{"link reference definitions 209", "[foo]: /url \"title\" ok\n", "<p>[foo]: /url &quot;title&quot; ok</p>\n"},
+	// paragraphs
+	{"paragraphs 219", "aaa\n\nbbb\n", "<p>aaa</p>\n<p>bbb</p>\n"},
+	{"paragraphs 222", "aaa\n             bbb\n                                       ccc\n", "<p>aaa\nbbb\nccc</p>\n"},
+	{"paragraphs 225", "aaa     \nbbb     \n", "<p>aaa<br />\nbbb</p>\n"},
+	// block quotes
+	{"block quotes 228", "> # Foo\n> bar\n> baz\n", "<blockquote>\n<h1>Foo</h1>\n<p>bar\nbaz</p>\n</blockquote>\n"},
+	{"block quotes 233", "> - foo\n- bar\n", "<blockquote>\n<ul>\n<li>foo</li>\n</ul>\n</blockquote>\n<ul>\n<li>bar</li>\n</ul>\n"},
+	{"block quotes 232", "> bar\nbaz\n> foo\n", "<blockquote>\n<p>bar\nbaz\nfoo</p>\n</blockquote>\n"},
+	{"block quotes 238", "> foo\n\n> bar\n", "<blockquote>\n<p>foo</p>\n</blockquote>\n<blockquote>\n<p>bar</p>\n</blockquote>\n"},
+	{"block quotes 250", "> > > foo\nbar\n", "<blockquote>\n<blockquote>\n<blockquote>\n<p>foo\nbar</p>\n</blockquote>\n</blockquote>\n</blockquote>\n"},
+	// list items
+	{"list items 253", "A paragraph\nwith two lines.\n\n    indented code\n\n> A block quote.\n",
+		"<p>A paragraph\nwith two lines.</p>\n<pre><code>indented code\n</code></pre>\n<blockquote>\n<p>A block quote.</p>\n</blockquote>\n"},
+	{"list items 254", "1.  A paragraph\n    with two lines.\n\n        indented code\n\n    > A block quote.\n",
+		"<ol>\n<li>\n<p>A paragraph\nwith two lines.</p>\n<pre><code>indented code\n</code></pre>\n<blockquote>\n<p>A block quote.</p>\n</blockquote>\n</li>\n</ol>\n"},
+	{"list items 255", "- one\n\n two\n", "<ul>\n<li>one</li>\n</ul>\n<p>two</p>\n"},
+	{"list items 256", "- one\n\n  two\n", "<ul>\n<li>\n<p>one</p>\n<p>two</p>\n</li>\n</ul>\n"},
+	{"list items 261", "-one\n\n2.two\n", "<p>-one</p>\n<p>2.two</p>\n"},
+	{"list items 265", "123456789. ok\n", "<ol start=\"123456789\">\n<li>ok</li>\n</ol>\n"},
+	{"list items 266", "1234567890. not ok\n", "<p>1234567890. not ok</p>\n"},
+	{"list items 278", "-\n  foo\n-\n  ```\n  bar\n  ```\n-\n      baz\n",
+		"<ul>\n<li>foo</li>\n<li>\n<pre><code>bar\n</code></pre>\n</li>\n<li>\n<pre><code>baz\n</code></pre>\n</li>\n</ul>\n"},
+	{"list items 280", "-\n\n  foo\n", "<ul>\n<li></li>\n</ul>\n<p>foo</p>\n"},
+	{"list items 285", "foo\n*\n\nfoo\n1.\n", "<p>foo\n*</p>\n<p>foo\n1.</p>\n"},
+	{"list items 294", "- foo\n  - bar\n    - baz\n      - boo\n",
+		"<ul>\n<li>foo\n<ul>\n<li>bar\n<ul>\n<li>baz\n<ul>\n<li>boo</li>\n</ul>\n</li>\n</ul>\n</li>\n</ul>\n</li>\n</ul>\n"},
+	{"list items 295", "- foo\n - bar\n  - baz\n   - boo\n", "<ul>\n<li>foo</li>\n<li>bar</li>\n<li>baz</li>\n<li>boo</li>\n</ul>\n"},
+	{"list items 300", "- # Foo\n- Bar\n  ---\n  baz\n", "<ul>\n<li>\n<h1>Foo</h1>\n</li>\n<li>\n<h2>Bar</h2>\nbaz</li>\n</ul>\n"},
+	// lists
+	{"lists 301", "- foo\n- bar\n+ baz\n", "<ul>\n<li>foo</li>\n<li>bar</li>\n</ul>\n<ul>\n<li>baz</li>\n</ul>\n"},
+	{"lists 304", "The number of windows in my house is\n14.  The number of doors is 6.\n", "<p>The number of windows in my house is\n14.  The number of doors is 6.</p>\n"},
+	{"lists 306", "- foo\n\n- bar\n\n\n- baz\n", "<ul>\n<li>\n<p>foo</p>\n</li>\n<li>\n<p>bar</p>\n</li>\n<li>\n<p>baz</p>\n</li>\n</ul>\n"},
+	{"lists 314", "- a\n- b\n\n- c\n", "<ul>\n<li>\n<p>a</p>\n</li>\n<li>\n<p>b</p>\n</li>\n<li>\n<p>c</p>\n</li>\n</ul>\n"},
+	{"lists 318", "- a\n- ```\n  b\n\n\n  ```\n- c\n", "<ul>\n<li>a</li>\n<li>\n<pre><code>b\n\n\n</code></pre>\n</li>\n<li>c</li>\n</ul>\n"},
+	{"lists 319", "- a\n  - b\n\n    c\n- d\n", "<ul>\n<li>a\n<ul>\n<li>\n<p>b</p>\n<p>c</p>\n</li>\n</ul>\n</li>\n<li>d</li>\n</ul>\n"},
+	{"lists 321", "- a\n  > b\n  ```\n  c\n  ```\n- d\n", "<ul>\n<li>a\n<blockquote>\n<p>b</p>\n</blockquote>\n<pre><code>c\n</code></pre>\n</li>\n<li>d</li>\n</ul>\n"},
+	{"lists 326", "* foo\n  * bar\n\n  baz\n", "<ul>\n<li>\n<p>foo</p>\n<ul>\n<li>bar</li>\n</ul>\n<p>baz</p>\n</li>\n</ul>\n"},
+	// code spans
+	{"code spans 328", "`foo`\n", "<p><code>foo</code></p>\n"},
+	{"code spans 329", "`` foo ` bar ``\n", "<p><code>foo ` bar</code></p>\n"},
+	{"code spans 330", "` `` `\n", "<p><code>``</code></p>\n"},
+	{"code spans 335", "``\nfoo\nbar  \nbaz\n``\n", "<p><code>foo bar   baz</code></p>\n"},
+	{"code spans 338", "`foo\\`bar`\n", "<p><code>foo\\</code>bar`</p>\n"},
+	{"code spans 341", "*foo`*`\n", "<p>*foo<code>*</code></p>\n"},
+	{"code spans 349", "`foo``bar``\n", "<p>`foo<code>bar</code></p>\n"},
+	// emphasis and strong emphasis
+	{"emphasis 350", "*foo bar*\n", "<p><em>foo bar</em></p>\n"},
+	{"emphasis 351", "a * foo bar*\n", "<p>a * foo bar*</p>\n"},
+	{"emphasis 354", "foo*bar*\n", "<p>foo<em>bar</em></p>\n"},
+	{"emphasis 359", "foo_bar_\n", "<p>foo_bar_</p>\n"},
+	{"emphasis 362", "foo-_(bar)_\n", "<p>foo-<em>(bar)</em></p>\n"},
+	{"emphasis 366", "*(*foo*)*\n", "<p><em>(<em>foo</em>)</em></p>\n"},
+	{"emphasis 376", "_foo_bar\n", "<p>_foo_bar</p>\n"},
+	{"emphasis 378", "**foo bar**\n", "<p><strong>foo bar</strong></p>\n"},
+	{"emphasis 403", "*foo [bar](/url)*\n", "<p><em>foo <a href=\"/url\">bar</a></em></p>\n"},
+	{"emphasis 411", "*foo**bar**baz*\n", "<p><em>foo<strong>bar</strong>baz</em></p>\n"},
+	{"emphasis 412", "*foo**bar*\n", "<p><em>foo**bar</em></p>\n"},
+	{"emphasis 418", "foo***bar***baz\n", "<p>foo<em><strong>bar</strong></em>baz</p>\n"},
+	{"emphasis 419", "foo******bar*********baz\n", "<p>foo<strong><strong><strong>bar</strong></strong></strong>***baz</p>\n"},
+	{"emphasis 446", "**foo*\n", "<p>*<em>foo</em></p>\n"},
+	{"emphasis 453", "*foo**\n", "<p><em>foo</em>*</p>\n"},
+	{"emphasis 470", "*foo _bar* baz_\n", "<p><em>foo _bar</em> baz_</p>\n"},
+	{"emphasis 471", "*foo __bar *baz bim__ bam*\n", "<p><em>foo <strong>bar *baz bim</strong> bam</em></p>\n"},
+	{"emphasis 473", "*a `*`*\n", "<p><em>a <code>*</code></em></p>\n"},
+	{"emphasis 477", "**a<http://foo.bar/?q=**>\n", "<p>**a<a href=\"http://foo.bar/?q=**\">http://foo.bar/?q=**</a></p>\n"},
+	// links
+	{"links 482", "[link](/uri \"title\")\n", "<p><a href=\"/uri\" title=\"title\">link</a></p>\n"},
+	{"links 484", "[](./target.md)\n", "<p><a href=\"./target.md\"></a></p>\n"},
+	{"links 485", "[link]()\n", "<p><a href=\"\">link</a></p>\n"},
+	{"links 488", "[link](/my uri)\n", "<p>[link](/my uri)</p>\n"},
+	{"links 489", "[link](</my uri>)\n", "<p><a href=\"/my%20uri\">link</a></p>\n"},
+	{"links 495", "[link](foo(and(bar)))\n", "<p><a href=\"foo(and(bar))\">link</a></p>\n"},
+	{"links 502", "[link](foo%20b&auml;)\n", "<p><a href=\"foo%20b%C3%A4\">link</a></p>\n"},
+	{"links 513", "[link [foo [bar]]](/uri)\n", "<p><a href=\"/uri\">link [foo [bar]]</a></p>\n"},
+	{"links 518", "[link *foo **bar** `#`*](/uri)\n", "<p><a href=\"/uri\">link <em>foo <strong>bar</strong> <code>#</code></em></a></p>\n"},
+	{"links 520", "[foo [bar](/uri)](/uri)\n", "<p>[foo <a href=\"/uri\">bar</a>](/uri)</p>\n"},
+	{"links 522", "![[[foo](uri1)](uri2)](uri3)\n", "<p><img src=\"uri3\" alt=\"[foo](uri2)\" /></p>\n"},
+	{"links 523", "*[foo*](/uri)\n", "<p>*<a href=\"/uri\">foo*</a></p>\n"},
+	{"links 526", "[foo`](/uri)`\n", "<p>[foo<code>](/uri)</code></p>\n"},
+	{"links 528", "[foo][bar]\n\n[bar]: /url \"title\"\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+	{"links 540", "[Foo\n  bar]: /url\n\n[Baz][Foo bar]\n", "<p><a href=\"/url\">Baz</a></p>\n"},
+	{"links 553", "[foo][]\n\n[foo]: /url \"title\"\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+	{"links 558", "[foo]\n\n[foo]: /url \"title\"\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+	{"links 568", "[foo][bar][baz]\n\n[baz]: /url\n", "<p>[foo]<a href=\"/url\">bar</a></p>\n"},
+	// images
+	{"images 572", "![foo](/url \"title\")\n", "<p><img src=\"/url\" alt=\"foo\" title=\"title\" /></p>\n"},
+	{"images 573", "![foo *bar*]\n\n[foo *bar*]: train.jpg \"train & tracks\"\n", "<p><img src=\"train.jpg\" alt=\"foo bar\" title=\"train &amp; tracks\" /></p>\n"},
+	{"images 578", "My ![foo bar](/path/to/train.jpg  \"title\"   )\n", "<p>My <img src=\"/path/to/train.jpg\" alt=\"foo bar\" title=\"title\" /></p>\n"},
+	// autolinks
+	{"autolinks 594", "<http://foo.bar.baz>\n", "<p><a href=\"http://foo.bar.baz\">http://foo.bar.baz</a></p>\n"},
+	{"autolinks 603", "<foo@bar.example.com>\n", "<p><a href=\"mailto:foo@bar.example.com\">foo@bar.example.com</a></p>\n"},
+	{"autolinks 606", "<>\n", "<p>&lt;&gt;</p>\n"},
+	// raw html
+	{"raw html 613", "<a><bab><c2c>\n", "<p><a><bab><c2c></p>\n"},
+	{"raw html 619", "<33> <__>\n", "<p>&lt;33&gt; &lt;__&gt;</p>\n"},
+	// hard line breaks
+	{"hard line breaks 633", "foo  \nbar\n", "<p>foo<br />\nbar</p>\n"},
+	{"hard line breaks 634", "foo\\\nbar\n", "<p>foo<br />\nbar</p>\n"},
+	{"hard line breaks 640", "`code  \nspan`\n", "<p><code>code   span</code></p>\n"},
+	{"hard line breaks 643", "foo\\\n", "<p>foo\\</p>\n"},
+	{"hard line breaks 645", "### foo\\\n", "<h3>foo\\</h3>\n"},
+	// soft line breaks
+	{"soft line breaks 648", "foo \n baz\n", "<p>foo\nbaz</p>\n"},
+	// GFM tables
+	{"tables 198", "| foo | bar |\n| --- | --- |\n| baz | bim |\n",
+		"<table>\n<thead>\n<tr>\n<th>foo</th>\n<th>bar</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>baz</td>\n<td>bim</td>\n</tr>\n</tbody>\n</table>\n"},
+	{"tables 199", "| abc | defghi |\n:-: | -----------:\nbar | baz\n",
+		"<table>\n<thead>\n<tr>\n<th align=\"center\">abc</th>\n<th align=\"right\">defghi</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td align=\"center\">bar</td>\n<td align=\"right\">baz</td>\n</tr>\n</tbody>\n</table>\n"},
+	{"tables 200", "| f\\|oo  |\n| ------ |\n| b `\\|` az |\n| b **\\|** im |\n",
+		"<table>\n<thead>\n<tr>\n<th>f|oo</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>b <code>|</code> az</td>\n</tr>\n<tr>\n<td>b <strong>|</strong> im</td>\n</tr>\n</tbody>\n</table>\n"},
+	{"tables 201", "| abc | def |\n| --- | --- |\n| bar | baz |\n> bar\n",
+		"<table>\n<thead>\n<tr>\n<th>abc</th>\n<th>def</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>bar</td>\n<td>baz</td>\n</tr>\n</tbody>\n</table>\n<blockquote>\n<p>bar</p>\n</blockquote>\n"},
+	{"tables 203", "| abc | def |\n| --- |\n| bar |\n", "<p>| abc | def |\n| --- |\n| bar |</p>\n"},
+	{"tables 204", "| abc | def |\n| --- | --- |\n| bar |\n| bar | baz | boo |\n",
+		"<table>\n<thead>\n<tr>\n<th>abc</th>\n<th>def</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>bar</td>\n<td></td>\n</tr>\n<tr>\n<td>bar</td>\n<td>baz</td>\n</tr>\n</tbody>\n</table>\n"},
+	{"tables 205", "| abc | def |\n| --- | --- |\n", "<table>\n<thead>\n<tr>\n<th>abc</th>\n<th>def</th>\n</tr>\n</thead>\n</table>\n"},
+}
+
+func TestSpecExamples(t *testing.T) {
+	for _, example := range specExamples {
+		t.Run(example.name, func(t *testing.T) {
+			got := toHtml(example.markdown)
+			if got != example.html {
+				t.Errorf("Markdown:\n%q\nExpected:\n%q\nGot:\n%q", example.markdown, example.html, got)
+			}
+		})
+	}
+}
+
+// domString shows the DOM tree in one line like "p(text(a) b(text(b)))"
+func domString(node *parser.Node) string {
+	if node.Tag == parser.Text {
+		return fmt.Sprintf("text(%s)", node.Inner)
+	}
+	parts := make([]string, 0, len(node.Children))
+	for _, child := range node.Children {
+		if child.Parent != node {
+			return "wrong parent"
+		}
+		parts = append(parts, domString(child))
+	}
+	res := node.Tag.String()
+	for _, key := range []string{"href", "src", "alt", "start", "class", "align"} {
+		if value, ok := node.Attrs[key]; ok {
+			res += fmt.Sprintf("[%s=%s]", key, value)
+		}
+	}
+	if len(parts) > 0 {
+		res += "(" + strings.Join(parts, " ") + ")"
+	}
+	return res
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		expected string // DOM of the <body>
+	}{
+		{"heading and paragraph", "# Gazer\nA *toy*\nbrowser.", "body(h1(text(Gazer)) p(text(A ) i(text(toy)) text( browser.)))"},
+		{"h6 is h5", "###### small", "body(h5(text(small)))"},
+		{"tight list", "- a\n- **b**", "body(ul(li(text(a)) li(b(text(b)))))"},
+		{"loose ordered list", "3. a\n\n4. b", "body(ol[start=3](li(p(text(a))) li(p(text(b)))))"},
+		{"code", "Run `go test`:\n```go\ngo test ./...\n```", "body(p(text(Run ) code(text(go test)) text(:)) pre[class=language-go](text(go test ./...)))"},
+		{"link and image", "[home](/) ![logo](logo.png)", "body(p(a[href=/](text(home)) text( ) img[src=logo.png][alt=logo]))"},
+		{"quote and break", "> a  \n> b\n\n---", "body(blockquote(p(text(a) br text(b))) hr)"},
+		{"table", "| a | b |\n|:-|-:|\n| 1 | 2 |", "body(table(thead(tr(th[align=left](text(a)) th[align=right](text(b)))) tbody(tr(td[align=left](text(1)) td[align=right](text(2))))))"},
+		{"html block", "<div>\n<b>hi</b>\n</div>", "body(div(b(text(hi))))"},
+		{"inline html", "a<br>b <span>c</span>", "body(p(text(a) br text(b c)))"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := Parse(test.markdown, "notes.md")
+			html := root.Children[0]
+			head, body := html.Children[0], html.Children[1]
+			if got := domString(head); got != "head(title(text(notes.md)))" {
+				t.Errorf("Expected: head(title(text(notes.md))) | Got: %v", got)
+			}
+			if got := domString(body); got != test.expected {
+				t.Errorf("Expected: %v | Got: %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -99,21 +99,27 @@ type attrParsingState uint8
 
 const (
 	Keying    attrParsingState = iota // processing key part
+	KeyEnded                          // key is done, observe whether it has a value or it's a boolean attribute
 	Observing                         // observe wheter it will be key=value or key="value" format
-	QValuing                          // processing value in key="value" format
+	QValuing                          // processing value in key="value" (or key='value') format
 	Valuing                           // processing value in key=value format
 )
 
 // assignAttrs takes a map and raw string in the attribute part of HTML tag
 // then assign all of them to the map
+// NOTE: attribute without value (e.g. <input required>) is assigned with empty string
 func assignAttrs(attrs *map[string]string, s string) {
 	s = strings.TrimSpace(s)
 	var key, val string
+	var quote rune // the quote character that opens the current QValuing
 	state := Keying
 	for _, char := range s {
 		switch state {
 		case Keying:
 			if unicode.IsSpace(char) {
+				if len(key) > 0 {
+					state = KeyEnded
+				}
 				continue
 			}
 
@@ -123,19 +129,34 @@ func assignAttrs(attrs *map[string]string, s string) {
 				// key is case-insensitive
 				key += strings.ToLower(string(char))
 			}
+		case KeyEnded:
+			if unicode.IsSpace(char) {
+				continue
+			}
+
+			if char == '=' {
+				state = Observing
+			} else {
+				// previous key is a boolean attribute, this char starts a new key
+				(*attrs)[key] = ""
+				key = strings.ToLower(string(char))
+				state = Keying
+			}
 		case Observing:
 			if unicode.IsSpace(char) {
 				continue
 			}
 
-			if char == '"' {
+			val = ""
+			if char == '"' || char == '\'' {
+				quote = char
 				state = QValuing
 			} else {
+				val += string(char)
 				state = Valuing
 			}
-			val = ""
 		case QValuing:
-			if char == '"' {
+			if char == quote {
 				(*attrs)[key] = val
 				state = Keying
 				key = ""
@@ -152,6 +173,16 @@ func assignAttrs(attrs *map[string]string, s string) {
 			}
 		}
 	}
+
+	// flush whatever left at the end of the string
+	switch state {
+	case Keying, KeyEnded:
+		if len(key) > 0 {
+			(*attrs)[key] = ""
+		}
+	case Valuing:
+		(*attrs)[key] = val
+	}
 }
 
 // getTagFromContent takes string content of the tag and appropriate Tag
@@ -28,18 +28,20 @@ func TestAssignAttrs(t *testing.T) {
 		`  id=main   class="container fluid"  disabled=true `,
 		`data-x="" title='hello world' tabindex=0`,
 		`width=100 width=200 height=50`,
+		`type=email required placeholder="you@example.com" disabled`,
 	}
 	expected := []map[string]string{
 		{"style": "color:red", "height": "100", "width": "200px"},
 		{"id": "main", "class": "container fluid", "disabled": "true"},
 		{"data-x": "", "title": "hello world", "tabindex": "0"},
 		{"width": "200", "height": "50"},
+		{"type": "email", "required": "", "placeholder": "you@example.com", "disabled": ""},
 	}
 
 	for i, test := range testCases {
 		dummy := make(map[string]string)
 		assignAttrs(&dummy, test)
-		if maps.Equal(dummy, expected[i]) {
+		if !maps.Equal(dummy, expected[i]) {
 			t.Errorf("#%d: Expected %v | Got %v", i, expected[i], dummy)
 		}
 	}
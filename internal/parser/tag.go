@@ -28,6 +28,7 @@ const (
 	Main
 	Article
 	Footer
+	Blockquote
 
 	H1
 	H2
@@ -35,10 +36,13 @@ const (
 	H4
 	H5
 	P
+	Pre
+	Code
 	I
 	B
 	A
 
+	Form
 	Button
 	Input
 
@@ -48,6 +52,13 @@ const (
 
 	Img
 
+	Table
+	Thead
+	Tbody
+	Tr
+	Th
+	Td
+
 	Br
 	Hr
 
@@ -56,39 +67,50 @@ const (
 
 // TagMap map case-insensitive (lower-case) for parsing html tag string to Tag.
 var TagMap = map[string]Tag{
-	"html":    Html,
-	"head":    Head,
-	"body":    Body,
-	"title":   Title,
-	"meta":    Meta,
-	"link":    Link,
-	"style":   Style,
-	"div":     Div,
-	"span":    Span,
-	"section": Section,
-	"header":  Header,
-	"main":    Main,
-	"article": Article,
-	"footer":  Footer,
-	"h1":      H1,
-	"h2":      H2,
-	"h3":      H3,
-	"h4":      H4,
-	"h5":      H5,
-	"p":       P,
-	"i":       I,
-	"em":      I,
-	"b":       B,
-	"a":       A,
-	"button":  Button,
-	"input":   Input,
-	"ul":      Ul,
-	"ol":      Ol,
-	"li":      Li,
-	"strong":  B,
-	"br":      Br,
-	"hr":      Hr,
-	"img":     Img,
+	"html":       Html,
+	"head":       Head,
+	"body":       Body,
+	"title":      Title,
+	"meta":       Meta,
+	"link":       Link,
+	"style":      Style,
+	"div":        Div,
+	"span":       Span,
+	"section":    Section,
+	"header":     Header,
+	"main":       Main,
+	"article":    Article,
+	"footer":     Footer,
+	"blockquote": Blockquote,
+	"form":       Form,
+	"h1":         H1,
+	"h2":         H2,
+	"h3":         H3,
+	"h4":         H4,
+	"h5":         H5,
+	"p":          P,
+	"pre":        Pre,
+	"code":       Code,
+	"i":          I,
+	"em":         I,
+	"b":          B,
+	"a":          A,
+	"button":     Button,
+	"input":      Input,
+	"ul":         Ul,
+	"ol":         Ol,
+	"li":         Li,
+	"strong":     B,
+	"br":         Br,
+	"hr":         Hr,
+	"img":        Img,
+	"table":      Table,
+	"thead":      Thead,
+	"tbody":      Tbody,
+	"tfoot":      Tbody,
+	"tr":         Tr,
+	"th":         Th,
+	"td":         Td,
 }
 
 func (t Tag) String() string {
@@ -123,6 +145,8 @@ func (t Tag) String() string {
 		return "article"
 	case Footer:
 		return "footer"
+	case Blockquote:
+		return "blockquote"
 	case H1:
 		return "h1"
 	case H2:
@@ -135,12 +159,18 @@ func (t Tag) String() string {
 		return "h5"
 	case P:
 		return "p"
+	case Pre:
+		return "pre"
+	case Code:
+		return "code"
 	case I:
 		return "i"
 	case B:
 		return "b"
 	case A:
 		return "a"
+	case Form:
+		return "form"
 	case Button:
 		return "button"
 	case Input:
@@ -159,6 +189,18 @@ func (t Tag) String() string {
 		return "text"
 	case Img:
 		return "img"
+	case Table:
+		return "table"
+	case Thead:
+		return "thead"
+	case Tbody:
+		return "tbody"
+	case Tr:
+		return "tr"
+	case Th:
+		return "th"
+	case Td:
+		return "td"
 	default:
 		return "unknown"
 	}
@@ -182,6 +224,8 @@ var TextElements = map[Tag]bool{
 	H4:     true,
 	H5:     true,
 	P:      true,
+	Pre:    true,
+	Code:   true,
 	I:      true,
 	B:      true,
 	A:      true,
@@ -194,18 +238,21 @@ var TextElements = map[Tag]bool{
 
 // elements that are supposed to be containers of others
 var ContainerElements = map[Tag]bool{
-	Div:     true,
-	Span:    true,
-	Section: true,
-	Header:  true,
-	Main:    true,
-	Article: true,
-	Footer:  true,
+	Div:        true,
+	Span:       true,
+	Section:    true,
+	Header:     true,
+	Main:       true,
+	Article:    true,
+	Footer:     true,
+	Form:       true,
+	Blockquote: true,
 }
 
 // inline elements = element that will not break line when
 // being child of another text element. E.g. <p>hello, <i>world</i></p> is one line
 var InlineElements = map[Tag]bool{
+	Code:   true,
 	I:      true,
 	B:      true,
 	A:      true,
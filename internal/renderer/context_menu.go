@@ -0,0 +1,143 @@
+package renderer
+
+import (
+	"io"
+	"strings"
+
+	"gioui.org/io/clipboard"
+	"gioui.org/io/pointer"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+)
+
+// contextTarget is what the user right-clicked on the page, nothing is the page itself
+type contextTarget struct {
+	link  string // resolved url of the link
+	image string // src of the image
+	text  string // selected text
+}
+
+// menuAction is an item of the context menu
+type menuAction uint8
+
+const (
+	openLinkInNewTab menuAction = iota
+	copyLink
+	openImage
+	saveImage
+	copyImageUrl
+	copyText
+	copyHtml
+	searchText
+	goBack
+	reloadPage
+	viewSource
+	savePage
+	// the tab menu's
+	duplicateTab
+	pinTab
+	unpinTab
+	closeTheTab
+)
+
+var menuLabels = map[menuAction]string{
+	openLinkInNewTab: "Open link in new tab",
+	copyLink:         "Copy link",
+	openImage:        "Open image",
+	saveImage:        "Save image",
+	copyImageUrl:     "Copy image address",
+	copyText:         "Copy",
+	copyHtml:         "Copy as HTML",
+	searchText:       "Search for it",
+	goBack:           "Back",
+	reloadPage:       "Reload",
+	viewSource:       "View page source",
+	savePage:         "Save page as",
+	duplicateTab:     "Duplicate",
+	pinTab:           "Pin",
+	unpinTab:         "Unpin",
+	closeTheTab:      "Close",
+}
+
+// actions are the menu items for the target, an image in a link has both
+func (t contextTarget) actions() []menuAction {
+	var res []menuAction
+	if t.link != "" {
+		res = append(res, openLinkInNewTab, copyLink)
+	}
+	if t.image != "" {
+		res = append(res, openImage, saveImage, copyImageUrl)
+	}
+	if len(res) > 0 {
+		return res
+	}
+	if t.text != "" {
+		return []menuAction{copyText, copyHtml, searchText}
+	}
+	return []menuAction{goBack, reloadPage, viewSource, savePage}
+}
+
+// tabActions are the menu items of a tab
+func tabActions(pinned bool) []menuAction {
+	if pinned {
+		return []menuAction{duplicateTab, unpinTab, closeTheTab}
+	}
+	return []menuAction{duplicateTab, pinTab, closeTheTab}
+}
+
+func menuItems(actions []menuAction) []string {
+	items := make([]string, len(actions))
+	for i, action := range actions {
+		items[i] = menuLabels[action]
+	}
+	return items
+}
+
+// contextTarget returns what's under the right-click of this frame and forgets it for the next one.
+// Links know their right-clicks from linkClicked, the images are asked here.
+func (dr *DomRenderer) contextTarget(gtx C) contextTarget {
+	for _, img := range dr.imgs {
+		for {
+			ev, ok := gtx.Event(pointer.Filter{Target: img, Kinds: pointer.Press})
+			if !ok {
+				break
+			}
+			if pointerEv, ok := ev.(pointer.Event); ok && pointerEv.Buttons == pointer.ButtonSecondary {
+				dr.rightClicked.image = img.Src()
+			}
+		}
+	}
+	target := dr.rightClicked
+	dr.rightClicked = contextTarget{}
+	if target.link == "" && target.image == "" {
+		target.text = dr.selectedText()
+	}
+	return target
+}
+
+// hoveredLink returns where the link under the pointer goes, empty if there's none
+func (dr *DomRenderer) hoveredLink() string {
+	for node, clickable := range dr.linkClickables {
+		if clickable.Hovered() {
+			if url, err := engine.ResolveJumpTarget(node.Attrs["href"], dr.renderedUrl); err == nil {
+				return url
+			}
+		}
+	}
+	for clickable, target := range dr.sourceLinks {
+		if clickable.Hovered() {
+			return target
+		}
+	}
+	return ""
+}
+
+// writeClipboard puts the text in the clipboard
+func writeClipboard(gtx C, text string) {
+	gtx.Execute(clipboard.WriteCmd{Type: "application/text", Data: io.NopCloser(strings.NewReader(text))})
+}
+
+// writeClipboardHtml puts the markup in the clipboard as html. The clipboard holds one of them at a time,
+// and the platforms gio supports take it as text anyway.
+func writeClipboardHtml(gtx C, markup string) {
+	gtx.Execute(clipboard.WriteCmd{Type: "text/html", Data: io.NopCloser(strings.NewReader(markup))})
+}
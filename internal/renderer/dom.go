@@ -2,15 +2,20 @@ package renderer
 
 import (
 	"fmt"
+	"log"
 	urlPkg "net/url"
 	"strings"
 	"unicode"
 
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
 	"gioui.org/layout"
 	"gioui.org/unit"
 	"gioui.org/widget"
 	"gioui.org/widget/material"
 	"github.com/WaronLimsakul/Gazer/internal/css"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+	"github.com/WaronLimsakul/Gazer/internal/form"
 	"github.com/WaronLimsakul/Gazer/internal/parser"
 	"github.com/WaronLimsakul/Gazer/internal/ui"
 )
@@ -25,6 +30,10 @@ type DomRenderer struct {
 	// have to save the currentlyRenderedUrl in case
 	// of the components want need it
 	renderedUrl string
+	// root of the currently rendered dom, it's the key of the cache
+	renderedRoot *Node
+	// fetched images of the currently rendered dom
+	images map[string][]byte
 	// Cache the matrix of elements with root node pointer.
 	// Can cache it because engine also cache by pointer
 	// (same url + same tab = same root ptr).
@@ -32,26 +41,63 @@ type DomRenderer struct {
 	// All Texts' selectables elements based on its pointer.
 	// These pointers will not be cleaned because the map still refer to it.
 	selectables      map[*Node]*widget.Selectable
+	imgs             map[*Node]*ui.Img
 	linkClickables   map[*Node]*widget.Clickable
 	buttonClickables map[*Node]*widget.Clickable
 	inputEditors     map[*Node]*widget.Editor
+	// constraint validation message of each input, empty string = valid
+	inputMessages map[*Node]*string
+	// viewers of non-html documents
+	imageViewers  map[string]*ui.ImageViewer // image url -> viewer
+	jsonToggles   map[*JsonNode]*widget.Clickable
+	jsonCollapsed map[*JsonNode]bool
+	// links in view-source, clickable -> view-source: url of the target
+	sourceLinks map[*widget.Clickable]string
+	// letters over the links to follow them with the keyboard
+	hints *ui.LinkHints
+	// link the middle button is pressed on, releasing it there opens the link in a new tab
+	middlePressed *widget.Clickable
+	// what the user right-clicked this frame, for the context menu
+	rightClicked contextTarget
+	// find in page's matches and their highlights in the labels
+	found      pageFind
+	highlights *ui.Highlights
+	// the text the user selected, its labels are the cached lines' and the nodes of their text (nil in a viewer)
+	selection      *ui.Selection
+	selectionLines *[][]Element
+	selectionNodes []*Node
+	// where each text of the rendered page is, built when find or an anchor needs it
+	spots     map[*widget.Selectable]lineSpot
+	spotsRoot *Node
 }
 
 func newDomRenderer(thm *material.Theme, tab *ui.Tab) *DomRenderer {
 	return &DomRenderer{thm: thm, tab: tab, cache: make(map[*Node]*[][]Element),
 		selectables:      make(map[*Node]*widget.Selectable),
+		imgs:             make(map[*Node]*ui.Img),
 		linkClickables:   make(map[*Node]*widget.Clickable),
 		buttonClickables: make(map[*Node]*widget.Clickable),
 		inputEditors:     make(map[*Node]*widget.Editor),
+		inputMessages:    make(map[*Node]*string),
+		imageViewers:     make(map[string]*ui.ImageViewer),
+		jsonToggles:      make(map[*JsonNode]*widget.Clickable),
+		jsonCollapsed:    make(map[*JsonNode]bool),
+		sourceLinks:      make(map[*widget.Clickable]string),
+		hints:            ui.NewLinkHints(),
+		highlights:       ui.NewHighlights(),
+		selection:        ui.NewSelection(),
 	}
 }
 
-// renderDOM takes a DOM root node and return [][]Element
+// renderDOM takes a DOM and return [][]Element
 // First layer (outer) is each horizontal line of rendering.
 // Second layer (inner) is each element in that line from left to right.
-// TODO: doc
-func (dr *DomRenderer) render(root *Node, styles *StyleSet, url string) [][]Element {
+// Non-html document (text, image, json, etc.) is shown by its own viewer.
+func (dr *DomRenderer) render(dom engine.Dom, url string) [][]Element {
 	dr.renderedUrl = url // save currently rendered url
+	dr.renderedRoot = dom.Root
+	dr.images = dom.Images
+	root := dom.Root
 	res := make([][]Element, 0)
 	// expect to be Root node
 	if root == nil || root.Tag != parser.Root {
@@ -62,9 +108,30 @@ func (dr *DomRenderer) render(root *Node, styles *StyleSet, url string) [][]Elem
 		return *cachedRes
 	}
 
+	switch dom.Kind {
+	case engine.HtmlDocument, engine.MarkdownDocument, engine.GeminiDocument, engine.GopherMenuDocument:
+		res = dr.renderHtml(root, dom.Styles)
+	case engine.TextDocument:
+		res = dr.renderTextDocument(dom.Source)
+	case engine.SourceDocument:
+		res = dr.renderSourceDocument(dom.Source)
+	case engine.ImageDocument:
+		res = dr.renderImageDocument(url)
+	case engine.JsonDocument:
+		res = dr.renderJsonDocument(dom.Json)
+	case engine.ViewSourceDocument:
+		res = dr.renderViewSourceDocument(dom.Source, url)
+	}
+
+	dr.cache[root] = &res
+	return res
+}
+
+// renderHtml renders the DOM tree of html document
+func (dr *DomRenderer) renderHtml(root *Node, styles *StyleSet) [][]Element {
+	res := make([][]Element, 0)
 	// expect root node to only have HTML tag
-	// len(root.Children) != 1 ||
-	if root.Children[0].Tag != parser.Html {
+	if len(root.Children) == 0 || root.Children[0].Tag != parser.Html {
 		return res
 	}
 
@@ -72,8 +139,6 @@ func (dr *DomRenderer) render(root *Node, styles *StyleSet, url string) [][]Elem
 	for _, child := range htmlNode.Children {
 		res = append(res, dr.renderNode(child, styles, newRenderingContext())...)
 	}
-
-	dr.cache[root] = &res
 	return res
 }
 
@@ -97,7 +162,12 @@ func (dr *DomRenderer) renderNode(node *Node, styles *StyleSet, rctx RenderingCo
 		}
 		res = append(res, []Element{img})
 	case parser.Input:
-		res = append(res, []Element{dr.renderInput(node)})
+		if strings.EqualFold(strings.TrimSpace(node.Attrs["type"]), "hidden") {
+			break // not shown, only submitted with the form
+		}
+		res = append(res, []Element{dr.renderInput(node, rctx)})
+	case parser.Table:
+		res = append(res, []Element{dr.renderTable(node, styles, rctx)})
 	}
 
 	if parser.ContainerElements[node.Tag] {
@@ -127,7 +197,13 @@ func (dr *DomRenderer) renderContainer(node *Node, styles *StyleSet, rctx Render
 		inlineStyle = css.ParseStyle(inlineStyleStr)
 	}
 
-	curStyle := css.AddStyle(inlineStyle, css.AddStyle(localStyle, rctx.base))
+	// default style of the tag, the page's css wins over it
+	var tagStyle css.Style
+	if node.Tag == parser.Blockquote {
+		tagStyle.Margin = &layout.Inset{Top: unit.Dp(5), Bottom: unit.Dp(5), Left: unit.Dp(30)}
+	}
+
+	curStyle := css.AddStyle(inlineStyle, css.AddStyle(localStyle, css.AddStyle(tagStyle, rctx.base)))
 
 	childrenRctx := rctx
 	childrenRctx.base = containerInheritStyle(curStyle)
@@ -136,6 +212,41 @@ func (dr *DomRenderer) renderContainer(node *Node, styles *StyleSet, rctx Render
 	return ui.NewDiv(dr.thm, curStyle, children)
 }
 
+// renderTable renders <table> with its rows, rows in <thead>, <tbody> and <tfoot> are in order
+func (dr *DomRenderer) renderTable(node *Node, styles *StyleSet, rctx RenderingContext) Element {
+	rows := make([][]ui.TableCell, 0)
+	var collectRows func(node *Node)
+	collectRows = func(node *Node) {
+		for _, child := range node.Children {
+			switch child.Tag {
+			case parser.Thead, parser.Tbody:
+				collectRows(child)
+			case parser.Tr:
+				rows = append(rows, dr.renderTableRow(child, styles, rctx))
+			}
+		}
+	}
+	collectRows(node)
+	return ui.NewTable(dr.thm, rows)
+}
+
+// renderTableRow renders the <th> and <td> of a <tr>, <th> is bold
+func (dr *DomRenderer) renderTableRow(node *Node, styles *StyleSet, rctx RenderingContext) []ui.TableCell {
+	cells := make([]ui.TableCell, 0, len(node.Children))
+	for _, child := range node.Children {
+		if child.Tag != parser.Th && child.Tag != parser.Td {
+			continue
+		}
+		cellRctx := rctx
+		if child.Tag == parser.Th {
+			cellRctx.updateLabelStyle(ui.B(dr.thm, cellRctx.getLabelStyle()))
+		}
+		children := dr.gatherElements(child, styles, cellRctx)
+		cells = append(cells, ui.TableCell{Children: children, Header: child.Tag == parser.Th})
+	}
+	return cells
+}
+
 // renderText returns [][]Element needs for rendering a text node and its children.
 // requires: node must be of the text type (check by using parser.TextElements)
 // TODO: doc
@@ -149,7 +260,10 @@ func (dr *DomRenderer) renderText(node *Node, styles *StyleSet, rctx RenderingCo
 			dr.selectables[node] = selectable
 		}
 
-		return [][]Element{{ui.NewLabel(dr.thm, rctx.getLabelStyle(), selectable, node.Inner)}}
+		lstyle := rctx.getLabelStyle()
+		lstyle.Extra.Highlights = dr.highlights
+		lstyle.Extra.Selection = dr.selection
+		return [][]Element{{ui.NewLabel(dr.thm, lstyle, selectable, node.Inner)}}
 	}
 
 	// recursive case: decorate the label style
@@ -161,7 +275,9 @@ func (dr *DomRenderer) renderText(node *Node, styles *StyleSet, rctx RenderingCo
 			clickable = new(widget.Clickable)
 			dr.linkClickables[node] = clickable
 		}
-		rctx.updateLabelStyle(ui.A(clickable, rctx.getLabelStyle()))
+		lstyle := ui.A(clickable, rctx.getLabelStyle())
+		lstyle.Extra.Hints = dr.hints
+		rctx.updateLabelStyle(lstyle)
 	case parser.Button:
 		// TODO: v8 just wrap all text around and treat it like one big button
 		clickable, ok := dr.buttonClickables[node]
@@ -186,6 +302,10 @@ func (dr *DomRenderer) renderText(node *Node, styles *StyleSet, rctx RenderingCo
 		rctx.updateLabelStyle(ui.H5(dr.thm, rctx.getLabelStyle()))
 	case parser.P:
 		rctx.updateLabelStyle(ui.P(dr.thm, rctx.getLabelStyle()))
+	case parser.Pre:
+		rctx.updateLabelStyle(ui.Pre(dr.thm, rctx.getLabelStyle()))
+	case parser.Code:
+		rctx.updateLabelStyle(ui.Code(dr.thm, rctx.getLabelStyle()))
 	case parser.I:
 		rctx.updateLabelStyle(ui.I(dr.thm, rctx.getLabelStyle()))
 	case parser.B:
@@ -237,75 +357,60 @@ func (dr *DomRenderer) renderImg(node *Node) (Element, error) {
 	if err != nil {
 		return empty, fmt.Errorf("baseUrl.Parse: %v", err)
 	}
-	img, err := ui.NewImg(imgUrl.String())
+	img, err := ui.NewImg(imgUrl.String(), dr.images[imgUrl.String()])
 	if err != nil {
 		return empty, fmt.Errorf("ui.NewImg: %v", err)
 	}
+	dr.imgs[node] = img
 	return img, nil
 }
 
 // renderInput receive Input tag node and return Input ui element.
 // Input is void element, don't have to gather more.
 // requires: node must not be nil and have input tag
-func (dr *DomRenderer) renderInput(node *Node) Element {
+func (dr *DomRenderer) renderInput(node *Node, rctx RenderingContext) Element {
+	inputTypeStr := strings.ToLower(node.Attrs["type"])
+
+	// <input type="submit"> looks and behaves like a button
+	if inputTypeStr == "submit" {
+		clickable, ok := dr.buttonClickables[node]
+		if !ok {
+			clickable = new(widget.Clickable)
+			dr.buttonClickables[node] = clickable
+		}
+		label, ok := node.Attrs["value"]
+		if !ok {
+			label = "Submit"
+		}
+		lstyle := ui.Button(dr.thm, clickable, rctx.getLabelStyle())
+		return ui.NewLabel(dr.thm, lstyle, nil, label)
+	}
+
 	editor, ok := dr.inputEditors[node]
 	if !ok {
 		editor = new(widget.Editor)
+		editor.SetText(node.Attrs["value"])
 		dr.inputEditors[node] = editor
 	}
+	message, ok := dr.inputMessages[node]
+	if !ok {
+		message = new(string)
+		dr.inputMessages[node] = message
+	}
 
-	inputTypeStr := strings.ToLower(node.Attrs["type"])
 	inputType, ok := ui.InputTypes[inputTypeStr]
 	if !ok {
 		inputType = ui.TextInput
 	}
 
 	hint := node.Attrs["placeholder"]
-	return ui.NewInput(dr.thm, inputType, editor, hint)
+	return ui.NewInput(dr.thm, inputType, editor, hint, message)
 }
 
 // handleHead set the tabview data by processing <head> node in the DOM tree (except css-related)
 func (dr *DomRenderer) handleHead(root *Node) {
-	head := dr.findHead(root)
-	if head == nil {
-		dr.tab.Title = ""
-		return
-	}
-
-	var titleSet bool
-	for _, node := range head.Children {
-		// handle title tag
-		switch node.Tag {
-		case parser.Title:
-			for _, titleChild := range node.Children {
-				if titleChild.Tag == parser.Text {
-					titleSet = true
-					dr.tab.Title = titleChild.Inner
-				}
-			}
-			// TODO: support some other links
-		}
-	}
-
-	if !titleSet {
-		dr.tab.Title = ""
-	}
-}
-
-func (dr DomRenderer) findHead(node *Node) *Node {
-	if node == nil {
-		return nil
-	}
-	if node.Tag == parser.Head {
-		return node
-	}
-	for _, child := range node.Children {
-		found := dr.findHead(child)
-		if found != nil {
-			return found
-		}
-	}
-	return nil
+	// same title as the one in the browsing history
+	dr.tab.Title = engine.PageTitle(root)
 }
 
 // gaterElements recieves a node and gather all elements of the node's children
@@ -332,15 +437,174 @@ func (dr DomRenderer) gatherElements(node *Node, styles *StyleSet, rctx Renderin
 	return res
 }
 
+// linkOpening is where a followed link opens
+type linkOpening uint8
+
+const (
+	inThisTab linkOpening = iota
+	inBackgroundTab
+	inForegroundTab
+)
+
 // linkClicked return whether the link in the page is clicked and
-// if so, what does it linked to.
-func (dr *DomRenderer) linkClicked(gtx C) (bool, string) {
+// if so, what does it linked to and where to open it.
+// Middle-click and Ctrl+click open it in a background tab (Shift brings it to the front),
+// a link with target="_blank" opens in a new tab anyway.
+// A right-click on a link is kept for the context menu.
+func (dr *DomRenderer) linkClicked(gtx C) (bool, string, linkOpening) {
+	// every link's events are read, a right-click can be on any of them
+	jump, href, opening := false, "", inThisTab
+	for node, clickable := range dr.linkClickables {
+		linkOpening, clicked, rightClicked := dr.linkUpdate(gtx, clickable)
+		if clicked && !jump {
+			if linkOpening == inThisTab && node.Attrs["target"] == "_blank" {
+				linkOpening = inForegroundTab
+			}
+			jump, href, opening = true, node.Attrs["href"], linkOpening
+		}
+		if rightClicked {
+			dr.rightClicked.link, _ = engine.ResolveJumpTarget(node.Attrs["href"], dr.renderedUrl)
+		}
+	}
+	for clickable, target := range dr.sourceLinks {
+		linkOpening, clicked, rightClicked := dr.linkUpdate(gtx, clickable)
+		if clicked && !jump {
+			jump, href, opening = true, target, linkOpening
+		}
+		if rightClicked {
+			dr.rightClicked.link = target
+		}
+	}
+	return jump, href, opening
+}
+
+// linkUpdate tells if the link is clicked by the primary or the middle button and where the click opens it,
+// and if it's right-clicked
+func (dr *DomRenderer) linkUpdate(gtx C, link *widget.Clickable) (linkOpening, bool, bool) {
+	clicked, rightClicked, opening := false, false, inThisTab
+	for {
+		click, ok := link.Update(gtx)
+		if !ok {
+			break
+		}
+		clicked, opening = true, inThisTab
+		if click.Modifiers.Contain(key.ModShortcut) {
+			opening = newTabOpening(click.Modifiers)
+		}
+	}
+	// the label lays out an area for the other buttons, a middle-click is a press and a release on the link
+	for {
+		ev, ok := gtx.Event(pointer.Filter{Target: link, Kinds: pointer.Press | pointer.Release | pointer.Cancel})
+		if !ok {
+			break
+		}
+		pointerEv, ok := ev.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch {
+		case pointerEv.Kind == pointer.Press && pointerEv.Buttons == pointer.ButtonTertiary:
+			dr.middlePressed = link
+		case pointerEv.Kind == pointer.Press && pointerEv.Buttons == pointer.ButtonSecondary:
+			rightClicked = true
+		case pointerEv.Kind == pointer.Release && dr.middlePressed == link:
+			dr.middlePressed = nil
+			clicked, opening = true, newTabOpening(pointerEv.Modifiers)
+		case pointerEv.Kind == pointer.Cancel:
+			dr.middlePressed = nil
+		}
+	}
+	return opening, clicked, rightClicked
+}
+
+// newTabOpening is a link opened in a new tab, in the background unless Shift is held
+func newTabOpening(modifiers key.Modifiers) linkOpening {
+	if modifiers.Contain(key.ModShift) {
+		return inForegroundTab
+	}
+	return inBackgroundTab
+}
+
+// hintedLink returns where the link with the clickable goes, the one the user picked by its hint
+func (dr *DomRenderer) hintedLink(link *widget.Clickable) (string, bool) {
 	for node, clickable := range dr.linkClickables {
-		if clickable.Clicked(gtx) {
-			return true, node.Attrs["href"]
+		if clickable == link {
+			return node.Attrs["href"], true
+		}
+	}
+	target, ok := dr.sourceLinks[link]
+	return target, ok
+}
+
+// editing tells if the user is typing in an input of the page
+func (dr *DomRenderer) editing(gtx C) bool {
+	for _, editor := range dr.inputEditors {
+		if gtx.Focused(editor) {
+			return true
+		}
+	}
+	return false
+}
+
+// formSubmitted returns whether a form in the page is submitted and passes
+// the constraint validation, if so, what url it is submitted to.
+// A form is submitted by clicking its submit button or pressing enter in its input.
+// If the form is invalid, it sets the validation message to the invalid inputs instead.
+func (dr *DomRenderer) formSubmitted(gtx C) (bool, string) {
+	var formNode, submitter *Node
+	for node, clickable := range dr.buttonClickables {
+		if clickable.Clicked(gtx) && form.IsSubmitter(node) {
+			formNode, submitter = form.FindForm(node), node
+		}
+	}
+
+	for node, editor := range dr.inputEditors {
+		for {
+			ev, ok := editor.Update(gtx)
+			if !ok {
+				break
+			}
+			switch ev.(type) {
+			case widget.ChangeEvent:
+				// user is fixing it, don't nag anymore
+				*dr.inputMessages[node] = ""
+			case widget.SubmitEvent:
+				formNode = form.FindForm(node)
+			}
 		}
 	}
-	return false, ""
+
+	if formNode == nil {
+		return false, ""
+	}
+
+	values := make(map[*Node]string)
+	for _, control := range form.Controls(formNode) {
+		if editor, ok := dr.inputEditors[control]; ok {
+			values[control] = editor.Text()
+		}
+	}
+
+	invalids := form.ValidateForm(formNode, values)
+	if len(invalids) > 0 {
+		for _, invalid := range invalids {
+			if message, ok := dr.inputMessages[invalid.Control]; ok {
+				*message = invalid.Validity.Message
+			}
+		}
+		// focus the first invalid input like other browsers do
+		if editor, ok := dr.inputEditors[invalids[0].Control]; ok {
+			gtx.Execute(key.FocusCmd{Tag: editor})
+		}
+		return false, ""
+	}
+
+	url, err := form.SubmissionUrl(formNode, submitter, values, dr.renderedUrl)
+	if err != nil {
+		log.Println("form.SubmissionUrl:", err)
+		return false, ""
+	}
+	return true, url
 }
 
 // getNodeStyleFromStyleSet return a css.Style of the node according to
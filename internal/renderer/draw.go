@@ -4,8 +4,10 @@ import (
 	"image/color"
 	"log"
 	"os"
+	"slices"
 
 	"gioui.org/app"
+	"gioui.org/io/key"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/text"
@@ -37,37 +39,66 @@ func Draw(window *app.Window, state *engine.State) {
 	pageNav := ui.NewPageNav(thm) // those 2 back and forth arrow button
 	hLine := ui.HorizontalLine{Thm: thm, Width: WINDOW_WIDTH, Height: unit.Dp(1)}
 	page := ui.NewPage(thm)     // page doesn't depend on the tab
-	tabsView := ui.NewTabs(thm) // will have another "tabs" from state
+	tabsView := ui.NewTabs(thm) // ui data of the tabs in the state
+	downloads := ui.NewDownloads(thm)
+	bookmarksBar := ui.NewBookmarksBar(thm)
+	findBar := ui.NewFindBar(thm) // one for all tabs, each tab finds in its own page
+	contextMenu := ui.NewContextMenu(thm)
+	// what the open context menu is about
+	var menuTarget contextTarget
+	var menuActions []menuAction
+	var menuTab engine.TabID
+	tabMenu := ui.NewContextMenu(thm) // right-click on a tab, it goes around the whole window
+	var tabMenuActions []menuAction
+	var tabMenuTab engine.TabID
 	domRenderers := map[*ui.Tab]*DomRenderer{}
+	shortcuts := newShortcuts(engine.Keymap())
 
 	for {
 		switch ev := window.Event().(type) {
 		case app.FrameEvent:
 			gtx := app.NewContext(&ops, ev)
 
-			tabView := tabsView.SelectedTab()
+			// catch up with what engine has changed
+			for _, event := range state.PollEvents() {
+				switch event.Type {
+				case engine.UrlChanged:
+					ui.NewSearchBar(thm, tabsView.View(event.TabID).SearchEditor).SetText(event.Url)
+				case engine.TabClosed:
+					delete(domRenderers, tabsView.View(event.TabID))
+					tabsView.Remove(event.TabID)
+				case engine.DownloadStarted:
+					// show that something is happening, the page stays the same
+					downloads.SetOpen(true)
+				}
+			}
 
-			tabs := state.Tabs
-			tab := tabs[tabsView.Selected]
+			snapshot := state.Snapshot()
+			tab, ok := snapshot.SelectedTab()
+			if !ok {
+				os.Exit(0) // no tab left
+			}
+			tabView := tabsView.View(tab.ID)
 
 			searchBar := ui.NewSearchBar(thm, tabView.SearchEditor)
 
 			// handle search bar event
 			if searchBar.Searched(gtx) {
-				state.Notifier <- Noti{
-					Type:   engine.Search,
-					TabIdx: tabsView.Selected,
-					Url:    searchBar.Text(),
+				picked, ok := searchBar.Picked()
+				switch {
+				case ok && picked.Kind == engine.TabSuggestion:
+					// the page is already open, go there and leave this tab as it is
+					searchBar.SetText(tab.Url)
+					state.Notifier <- Noti{Type: engine.ChangeTab, TabID: picked.TabID}
+				case ok:
+					state.Notifier <- Noti{Type: engine.Search, TabID: tab.ID, Url: picked.Url}
+				default:
+					state.Notifier <- Noti{Type: engine.Search, TabID: tab.ID, Url: searchBar.Text()}
 				}
 			}
-
-			// handle when engine change the tab's url
-			if tab.UrlChanged {
-				searchBar.SetText(tab.Url)
-				state.Notifier <- Noti{
-					Type:   engine.AcknowledgeUrlChanged,
-					TabIdx: tabsView.Selected,
-				}
+			// suggest while the user types
+			if text, ok := searchBar.Typed(); ok {
+				searchBar.SetSuggestions(state.Suggest(text, ui.MaxSuggestions))
 			}
 
 			// get the cached dom renderer
@@ -77,83 +108,380 @@ func Draw(window *app.Window, state *engine.State) {
 				domRenderers[tabView] = domRenderer
 			}
 
-			// handle hyperlink clicking event
-			jump, href := domRenderer.linkClicked(gtx)
-			if jump {
+			anchor := "" // #fragment to scroll to this frame
+
+			// followLink goes to where the link in the page points, in this tab or a new one
+			followLink := func(href string, opening linkOpening) {
 				href, err := engine.ResolveJumpTarget(href, tab.Url)
-				if err == nil {
+				if err == nil && opening != inThisTab {
+					// the new tab remembers this one, closing it comes back here
+					state.Notifier <- Noti{Type: engine.AddTab, TabID: tab.ID, Url: href,
+						Background: opening == inBackgroundTab}
+				} else if err == nil && engine.IsPageAction(tab.Url, href) {
+					// e.g. Delete in about:history, the page does it and shows itself again
+					state.Notifier <- Noti{Type: engine.PageAction, TabID: tab.ID, Url: href}
+				} else if err == nil {
+					if href == tab.Url {
+						anchor = urlFragment(href) // the url stays, so scroll here e.g. clicking it again
+					}
 					searchBar.SetText(href)
 					state.Notifier <- Noti{
-						Type:   engine.Search,
-						TabIdx: tabsView.Selected,
-						Url:    href,
+						Type:       engine.Search,
+						TabID:      tab.ID,
+						Url:        href,
+						Transition: engine.LinkTransition,
 					}
 				}
 			}
 
+			// closeTab closes the tab, or the app if it's the last one
+			closeTab := func(id engine.TabID) {
+				if len(snapshot.Tabs) == 1 {
+					state.SaveSession() // come back to this tab next time
+					os.Exit(0)          // close app
+				}
+				state.Notifier <- Noti{Type: engine.CloseTab, TabID: id}
+			}
+
+			// handle hyperlink clicking event
+			if jump, href, opening := domRenderer.linkClicked(gtx); jump {
+				followLink(href, opening)
+			}
+
+			// handle the context menu, after the links tell if they're right-clicked
+			target := domRenderer.contextTarget(gtx)
+			if pos, ok := contextMenu.Update(gtx); ok {
+				menuTarget, menuActions, menuTab = target, target.actions(), tab.ID
+				contextMenu.Open(pos, menuItems(menuActions))
+			}
+			if menuTab != tab.ID {
+				contextMenu.Close() // it's about another tab's page
+			}
+			if idx, ok := contextMenu.Clicked(gtx); ok {
+				switch menuActions[idx] {
+				case openLinkInNewTab:
+					state.Notifier <- Noti{Type: engine.AddTab, TabID: tab.ID, Url: menuTarget.link, Background: true}
+				case copyLink:
+					writeClipboard(gtx, menuTarget.link)
+				case openImage:
+					followLink(menuTarget.image, inThisTab)
+				case saveImage:
+					state.Notifier <- Noti{Type: engine.SaveUrl, Url: menuTarget.image}
+				case copyImageUrl:
+					writeClipboard(gtx, menuTarget.image)
+				case copyText:
+					writeClipboard(gtx, menuTarget.text)
+				case copyHtml:
+					writeClipboardHtml(gtx, domRenderer.selectedHtml())
+				case searchText:
+					url := engine.SearchUrl(menuTarget.text)
+					if url == "" {
+						url = menuTarget.text // no search engine, the address bar would do the same
+					}
+					state.Notifier <- Noti{Type: engine.AddTab, TabID: tab.ID, Url: url}
+				case goBack:
+					state.Notifier <- Noti{Type: engine.NavBack, TabID: tab.ID}
+				case reloadPage:
+					state.Notifier <- Noti{Type: engine.Reload, TabID: tab.ID}
+				case viewSource:
+					state.Notifier <- Noti{Type: engine.AddTab, TabID: tab.ID, Url: "view-source:" + tab.Url}
+				case savePage:
+					state.Notifier <- Noti{Type: engine.SaveUrl, Url: tab.Url}
+				}
+			}
+
+			// handle the keyboard, link hints take the letters while they're on
+			editing := searchBar.Focused(gtx) || findBar.Focused(gtx) || tabsView.SearchFocused(gtx) || domRenderer.editing(gtx)
+			if editing {
+				domRenderer.hints.Stop()
+			}
+			if domRenderer.hints.Active() {
+				for _, name := range hintKeys(gtx) {
+					switch name {
+					case key.NameEscape:
+						domRenderer.hints.Stop()
+					case key.NameDeleteBackward:
+						domRenderer.hints.Backspace()
+					default:
+						link, ok := domRenderer.hints.Type(string(name))
+						if href, isLink := domRenderer.hintedLink(link); ok && isLink {
+							followLink(href, inThisTab)
+						}
+					}
+				}
+			}
+			findStep := 0 // to the next or previous match
+			for _, action := range shortcuts.pressed(gtx, editing || domRenderer.hints.Active()) {
+				switch action {
+				case engine.NewTabAction:
+					state.Notifier <- Noti{Type: engine.AddTab}
+				case engine.CloseTabAction:
+					closeTab(tab.ID)
+				case engine.NextTabAction, engine.PreviousTabAction:
+					step := 1
+					if action == engine.PreviousTabAction {
+						step = -1
+					}
+					idx := slices.IndexFunc(snapshot.Tabs, func(t engine.TabSnapshot) bool { return t.ID == tab.ID })
+					next := snapshot.Tabs[(idx+step+len(snapshot.Tabs))%len(snapshot.Tabs)]
+					state.Notifier <- Noti{Type: engine.ChangeTab, TabID: next.ID}
+				case engine.ReopenTabAction:
+					state.Notifier <- Noti{Type: engine.ReopenTab}
+				case engine.SearchTabsAction:
+					tabsView.OpenSearch(gtx)
+				case engine.FocusAddressAction:
+					searchBar.Focus(gtx)
+				case engine.BackAction:
+					state.Notifier <- Noti{Type: engine.NavBack, TabID: tab.ID}
+				case engine.ForwardAction:
+					state.Notifier <- Noti{Type: engine.NavForth, TabID: tab.ID}
+				case engine.ReloadAction:
+					state.Notifier <- Noti{Type: engine.Reload, TabID: tab.ID}
+				case engine.HardReloadAction:
+					state.Notifier <- Noti{Type: engine.HardReload, TabID: tab.ID}
+				case engine.StopAction:
+					state.Notifier <- Noti{Type: engine.Stop, TabID: tab.ID}
+				case engine.CopyAction:
+					if text := domRenderer.selectedText(); text != "" {
+						writeClipboard(gtx, text)
+					}
+				case engine.CopyHtmlAction:
+					if markup := domRenderer.selectedHtml(); markup != "" {
+						writeClipboardHtml(gtx, markup)
+					}
+				case engine.SelectAllAction:
+					domRenderer.selection.SelectAll()
+				case engine.FindAction:
+					findBar.Open(gtx)
+				case engine.FindNextAction, engine.FindPreviousAction:
+					if !findBar.IsOpen() {
+						findBar.Open(gtx)
+					}
+					findStep = 1
+					if action == engine.FindPreviousAction {
+						findStep = -1
+					}
+				case engine.ZoomInAction:
+					tabView.ZoomIn()
+				case engine.ZoomOutAction:
+					tabView.ZoomOut()
+				case engine.ZoomResetAction:
+					tabView.ResetZoom()
+				case engine.ScrollDownAction:
+					page.ScrollLines(1)
+				case engine.ScrollUpAction:
+					page.ScrollLines(-1)
+				case engine.PageDownAction:
+					page.ScrollPages(1)
+				case engine.PageUpAction:
+					page.ScrollPages(-1)
+				case engine.ScrollTopAction:
+					page.ScrollToTop()
+				case engine.ScrollBottomAction:
+					page.ScrollToBottom()
+				case engine.LinkHintsAction:
+					domRenderer.hints.Start()
+				}
+			}
+
+			// handle collapsing/expanding json nodes in json viewer
+			domRenderer.jsonToggled(gtx)
+
+			// handle form submission event (only valid form is submitted)
+			submitted, submitUrl := domRenderer.formSubmitted(gtx)
+			if submitted && engine.IsPageAction(tab.Url, submitUrl) {
+				state.Notifier <- Noti{Type: engine.PageAction, TabID: tab.ID, Url: submitUrl}
+			} else if submitted {
+				searchBar.SetText(submitUrl)
+				state.Notifier <- Noti{
+					Type:       engine.Search,
+					TabID:      tab.ID,
+					Url:        submitUrl,
+					Transition: engine.FormTransition,
+				}
+			}
+
 			// handle clicking add tab button
 			if tabsView.AddTabClicked(gtx) {
-				tabsView.AddTab()
-				tabsView.Select(len(tabsView.Tabs) - 1)
 				state.Notifier <- Noti{Type: engine.AddTab}
 			}
 
-			tabClosed := tabsView.TabClosed(gtx)
-			if tabClosed != -1 {
-				if len(tabsView.Tabs) == 1 {
-					os.Exit(0) // close app
-				}
-				tabsView.DeleteTab(tabClosed)
-				state.Notifier <- Noti{Type: engine.CloseTab, TabIdx: tabClosed}
+			if closedId, ok := tabsView.TabClosed(gtx); ok {
+				closeTab(closedId)
+			}
+
+			if tabsView.ReopenClicked(gtx) {
+				state.Notifier <- Noti{Type: engine.ReopenTab}
 			}
 
 			// handle clicking tab
-			tabClicked := tabsView.TabClicked(gtx)
-			if tabClicked != -1 {
-				tabsView.Select(tabClicked)
-				window.Invalidate()
+			if clickedId, ok := tabsView.TabClicked(gtx); ok {
+				state.Notifier <- Noti{Type: engine.ChangeTab, TabID: clickedId}
+			}
+			if pickedId, ok := tabsView.SearchPicked(gtx); ok {
+				state.Notifier <- Noti{Type: engine.ChangeTab, TabID: pickedId}
+			}
+			if movedId, idx, ok := tabsView.TabMoved(gtx, snapshot); ok {
+				state.Notifier <- Noti{Type: engine.MoveTab, TabID: movedId, Index: idx}
+			}
+
+			// handle the tab menu, the window's right-clicks are only its if they're on a tab
+			rightClickedTab, onTab := tabsView.TabRightClicked(gtx)
+			if pos, ok := tabMenu.Update(gtx); ok && onTab {
+				idx := snapshot.TabIndex(rightClickedTab)
+				if idx != -1 {
+					tabMenuActions, tabMenuTab = tabActions(snapshot.Tabs[idx].Pinned), rightClickedTab
+					tabMenu.Open(pos, menuItems(tabMenuActions))
+				}
+			} else if ok {
+				tabMenu.Close()
+			}
+			if idx, ok := tabMenu.Clicked(gtx); ok {
+				switch tabMenuActions[idx] {
+				case duplicateTab:
+					state.Notifier <- Noti{Type: engine.DuplicateTab, TabID: tabMenuTab}
+				case pinTab:
+					state.Notifier <- Noti{Type: engine.PinTab, TabID: tabMenuTab}
+				case unpinTab:
+					state.Notifier <- Noti{Type: engine.UnpinTab, TabID: tabMenuTab}
+				case closeTheTab:
+					closeTab(tabMenuTab)
+				}
 			}
 
 			// handle page navigation back/forth buttons clicked
 			navBackClicked := pageNav.BackClicked(gtx)
 			if navBackClicked {
 				state.Notifier <- engine.Notification{
-					Type:   engine.NavBack,
-					TabIdx: tabsView.Selected}
+					Type:  engine.NavBack,
+					TabID: tab.ID}
 			}
 			navForthClicked := pageNav.ForthClicked(gtx)
 			if navForthClicked {
 				state.Notifier <- engine.Notification{
-					Type:   engine.NavForth,
-					TabIdx: tabsView.Selected}
+					Type:  engine.NavForth,
+					TabID: tab.ID}
+			}
+			if pageNav.StopClicked(gtx) {
+				state.Notifier <- engine.Notification{
+					Type:  engine.Stop,
+					TabID: tab.ID}
+			}
+			if clicked, hard := pageNav.ReloadClicked(gtx); clicked {
+				reloadType := engine.Reload
+				if hard {
+					reloadType = engine.HardReload
+				}
+				state.Notifier <- engine.Notification{Type: reloadType, TabID: tab.ID}
+			}
+			pageNav.SetLoading(tab.IsLoading)
+
+			// handle the bookmark star and the bookmarks bar
+			bookmark, bookmarked := snapshot.Bookmark(tab.Url)
+			if bookmarksBar.StarClicked(gtx) {
+				if bookmarked {
+					state.Notifier <- Noti{Type: engine.RemoveBookmark, BookmarkID: bookmark.ID}
+				} else {
+					state.Notifier <- Noti{Type: engine.AddBookmark, TabID: tab.ID}
+				}
+			}
+			bookmarksBar.SetBookmarked(bookmarked)
+			if url, ok := bookmarksBar.Clicked(gtx); ok {
+				searchBar.SetText(url)
+				state.Notifier <- Noti{
+					Type:       engine.Search,
+					TabID:      tab.ID,
+					Url:        url,
+					Transition: engine.BookmarkTransition,
+				}
+			}
+
+			// handle the downloads panel
+			if downloads.ToggleClicked(gtx) {
+				downloads.SetOpen(!downloads.IsOpen())
+			}
+			if downloads.ClearClicked(gtx) {
+				state.Notifier <- Noti{Type: engine.ClearDownloads}
+			}
+			if id, ok := downloads.PauseClicked(gtx); ok {
+				state.Notifier <- Noti{Type: engine.PauseDownload, DownloadID: id}
+			}
+			if id, ok := downloads.ResumeClicked(gtx); ok {
+				state.Notifier <- Noti{Type: engine.ResumeDownload, DownloadID: id}
+			}
+			if id, ok := downloads.CancelClicked(gtx); ok {
+				state.Notifier <- Noti{Type: engine.CancelDownload, DownloadID: id}
 			}
 
 			// start render app
 			appFlex := layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}
 			appFlexChildren := []layout.FlexChild{
-				layout.Rigid(func(gtx C) D { return tabsView.Layout(gtx, tabs) }),
-				layout.Rigid(func(gtx C) D { return ui.NewTopBar(searchBar, pageNav).Layout(gtx) }),
+				layout.Rigid(func(gtx C) D { return tabsView.Layout(gtx, snapshot) }),
+				layout.Rigid(func(gtx C) D { return ui.NewTopBar(searchBar, pageNav, bookmarksBar, downloads).Layout(gtx) }),
+				layout.Rigid(func(gtx C) D { return bookmarksBar.Layout(gtx, snapshot) }),
+				layout.Rigid(func(gtx C) D { return downloads.Layout(gtx, snapshot) }),
+				layout.Rigid(findBar.Layout),
 			}
 
-			// if loading the page, replace horizontal line with progress bar
+			// if loading the page, replace horizontal line with progress bar and status text
 			if tab.IsLoading {
-				progress := <-tab.LoadProgress
-				appFlexChildren = append(appFlexChildren, ui.Rigid(material.ProgressBar(thm, progress)))
+				appFlexChildren = append(appFlexChildren,
+					ui.Rigid(material.ProgressBar(thm, tab.Progress.Fraction())),
+					ui.Rigid(ui.NewStatusText(thm, tab.Progress.Status())),
+				)
 			} else {
 				appFlexChildren = append(appFlexChildren, ui.Rigid(hLine))
 			}
 
 			// handle page rendering
 			domRenderer.handleHead(tab.Dom.Root) // set tab data
-			pageElements := domRenderer.render(tab.Dom.Root, tab.Dom.Styles, tab.Url)
+			pageElements := domRenderer.render(tab.Dom, tab.Url)
+			if page.Show(tab) { // scroll to where the user left this page
+				anchor = urlFragment(tab.Url)
+			}
+			domRenderer.updateSelection()
+			if spot, ok := domRenderer.anchorSpot(anchor); ok {
+				page.ScrollLineToTop(spot.line, spot.fraction)
+			}
+
+			// handle find in page, after rendering so it knows where the texts are
+			findChanged, barStep := findBar.Update(gtx)
+			if barStep != 0 {
+				findStep = barStep
+			}
+			domRenderer.find(findBar.Query(), findBar.Options())
+			if findStep != 0 {
+				domRenderer.findStep(findStep)
+			}
+			if spot, ok := domRenderer.activeMatchSpot(); ok && (findChanged || findStep != 0) {
+				page.ScrollToLine(spot.line, spot.fraction)
+			}
+			findBar.SetResult(domRenderer.findResult())
+			page.SetZoom(tabView.Zoom())
+			domRenderer.hints.NewFrame()
+			hovered := domRenderer.hoveredLink()
 			appFlexChildren = append(appFlexChildren, layout.Rigid(func(gtx C) D {
-				return page.Layout(gtx, pageElements)
+				// where the hovered link goes is over the bottom of the page
+				return layout.Stack{Alignment: layout.SW}.Layout(gtx,
+					layout.Expanded(func(gtx C) D {
+						return contextMenu.Layout(gtx, func(gtx C) D {
+							return domRenderer.selection.Layout(gtx, func(gtx C) D { return page.Layout(gtx, pageElements) })
+						})
+					}),
+					layout.Stacked(ui.NewLinkStatus(thm, hovered).Layout),
+				)
 			}))
 
-			appFlex.Layout(gtx, appFlexChildren...)
+			tabMenu.Layout(gtx, func(gtx C) D { return appFlex.Layout(gtx, appFlexChildren...) })
+
+			// remember the scroll for the session and back/forward, not while the page is being replaced
+			if scroll, ok := page.Scrolled(); ok && !tab.IsLoading && tab.Dom.Root != nil {
+				state.Notifier <- Noti{Type: engine.Scroll, TabID: tab.ID, Url: tab.Url, Scroll: scroll}
+			}
 
 			ev.Frame(gtx.Ops)
 		case app.DestroyEvent:
+			state.SaveSession()
 			os.Exit(0)
 		}
 	}
@@ -0,0 +1,69 @@
+package renderer
+
+import (
+	"gioui.org/widget"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+	"github.com/WaronLimsakul/Gazer/internal/ui"
+)
+
+// pageFind is find in page of the rendered page
+type pageFind struct {
+	query   string
+	options engine.FindOptions
+	root    *Node // page the matches are in
+	matches []engine.FindMatch
+	active  int
+}
+
+// find finds the query in the rendered page, again only if the query, options or page changed.
+// Empty query clears it.
+func (dr *DomRenderer) find(query string, options engine.FindOptions) {
+	f := &dr.found
+	if query == f.query && options == f.options && dr.renderedRoot == f.root {
+		return
+	}
+	f.query, f.options, f.root, f.active = query, options, dr.renderedRoot, 0
+	f.matches = f.matches[:0]
+	for _, match := range engine.FindInPage(dr.renderedRoot, query, options) {
+		// only what is rendered, e.g. not the text of a hidden input
+		if _, ok := dr.selectables[match.Node]; ok {
+			f.matches = append(f.matches, match)
+		}
+	}
+	dr.highlightMatches()
+}
+
+// findStep goes to the next match (step 1) or the previous one (step -1), around at the ends
+func (dr *DomRenderer) findStep(step int) {
+	f := &dr.found
+	if len(f.matches) == 0 {
+		return
+	}
+	f.active = (f.active + step%len(f.matches) + len(f.matches)) % len(f.matches)
+	dr.highlightMatches()
+}
+
+// findResult returns the active match's index and the number of matches
+func (dr *DomRenderer) findResult() (int, int) {
+	return dr.found.active, len(dr.found.matches)
+}
+
+// activeMatchSpot returns where the active match is on the page, false if there is no match
+func (dr *DomRenderer) activeMatchSpot() (lineSpot, bool) {
+	f := &dr.found
+	if len(f.matches) == 0 {
+		return lineSpot{}, false
+	}
+	return dr.textSpot(f.matches[f.active].Node)
+}
+
+// highlightMatches tells the labels which parts of their text to highlight
+func (dr *DomRenderer) highlightMatches() {
+	ranges := make(map[*widget.Selectable][]ui.Highlight)
+	for i, match := range dr.found.matches {
+		selectable := dr.selectables[match.Node]
+		ranges[selectable] = append(ranges[selectable],
+			ui.Highlight{Start: match.Start, End: match.End, Active: i == dr.found.active})
+	}
+	dr.highlights.Set(ranges)
+}
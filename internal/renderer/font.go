@@ -5,6 +5,9 @@ import (
 
 	"gioui.org/font"
 	"gioui.org/font/opentype"
+	"github.com/WaronLimsakul/Gazer/internal/ui"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
 )
 
 //go:embed fonts/inter.ttf
@@ -40,10 +43,23 @@ func loadFont() ([]font.FontFace, error) {
 		return nil, err
 	}
 
+	// monospace font for <pre> and source/text viewers
+	goMono, err := opentype.Parse(gomono.TTF)
+	if err != nil {
+		return nil, err
+	}
+
+	goMonoBold, err := opentype.Parse(gomonobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+
 	return []font.FontFace{
 		{Font: font.Font{Typeface: "Inter", Style: font.Regular, Weight: font.Normal}, Face: inter},
 		{Font: font.Font{Typeface: "Inter", Style: font.Italic, Weight: font.Normal}, Face: interItalic},
 		{Font: font.Font{Typeface: "Inter", Style: font.Regular, Weight: font.Bold}, Face: interBold},
 		{Font: font.Font{Typeface: "Inter", Style: font.Italic, Weight: font.Bold}, Face: interBoldItalic},
+		{Font: font.Font{Typeface: ui.MonospaceTypeface, Style: font.Regular, Weight: font.Normal}, Face: goMono},
+		{Font: font.Font{Typeface: ui.MonospaceTypeface, Style: font.Regular, Weight: font.Bold}, Face: goMonoBold},
 	}, nil
 }
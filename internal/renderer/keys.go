@@ -0,0 +1,124 @@
+package renderer
+
+import (
+	"strings"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+)
+
+// gio's names of the keys that aren't a character, by engine.KeyChord's name
+var gioKeyNames = map[string]key.Name{
+	"Left": key.NameLeftArrow, "Right": key.NameRightArrow, "Up": key.NameUpArrow, "Down": key.NameDownArrow,
+	"Enter": key.NameReturn, "Escape": key.NameEscape, "Home": key.NameHome, "End": key.NameEnd,
+	"PageUp": key.NamePageUp, "PageDown": key.NamePageDown, "Tab": key.NameTab, "Space": key.NameSpace,
+	"Backspace": key.NameDeleteBackward, "Delete": key.NameDeleteForward,
+}
+
+// shortcut is a key binding as gio sees it
+type shortcut struct {
+	filter key.Filter
+	action engine.KeyAction
+	global bool // works while typing in an editor too
+}
+
+// shortcuts turns the engine's keymap into gio key filters, and reads the pressed ones every frame
+type shortcuts struct {
+	all []shortcut
+}
+
+func newShortcuts(keymap []engine.KeyBinding) *shortcuts {
+	s := new(shortcuts)
+	for _, binding := range keymap {
+		for _, chord := range binding.Keys {
+			name, ok := gioKeyNames[chord.Key]
+			if !ok {
+				name = key.Name(chord.Key) // F1..F12 and characters are the same
+			}
+			var modifiers key.Modifiers
+			if chord.Ctrl {
+				modifiers |= key.ModCtrl
+			}
+			if chord.Shift {
+				modifiers |= key.ModShift
+			}
+			if chord.Alt {
+				modifiers |= key.ModAlt
+			}
+			if chord.Super {
+				modifiers |= key.ModSuper
+			}
+			// a key with Ctrl, Alt or Super, or an F key isn't typing, so it's a shortcut even in an editor
+			global := modifiers&^key.ModShift != 0 || (len(chord.Key) > 1 && strings.HasPrefix(chord.Key, "F"))
+			s.all = append(s.all, shortcut{
+				filter: key.Filter{Name: name, Required: modifiers},
+				action: binding.Action,
+				global: global,
+			})
+		}
+	}
+	return s
+}
+
+// pressed returns the actions of the shortcuts pressed since the last frame.
+// While the user is typing, only the global ones count, the other keys are the editor's.
+func (s *shortcuts) pressed(gtx C, editing bool) []engine.KeyAction {
+	var filters []key.Filter
+	for _, sc := range s.all {
+		if sc.global || !editing {
+			filters = append(filters, sc.filter)
+		}
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+
+	var actions []engine.KeyAction
+	for {
+		ev, ok := gtx.Event(toEventFilters(filters)...)
+		if !ok {
+			break
+		}
+		keyEv, ok := ev.(key.Event)
+		if !ok || keyEv.State != key.Press {
+			continue
+		}
+		for _, sc := range s.all {
+			if sc.filter.Name == keyEv.Name && sc.filter.Required == keyEv.Modifiers {
+				actions = append(actions, sc.action)
+				break
+			}
+		}
+	}
+	return actions
+}
+
+// hintKeys returns the keys typed for the link hints: letters, Escape and Backspace
+func hintKeys(gtx C) []key.Name {
+	filters := []key.Filter{{Name: key.NameEscape}, {Name: key.NameDeleteBackward}}
+	for _, letter := range "ABCDEFGHIJKLMNOPQRSTUVWXYZ" {
+		filters = append(filters, key.Filter{Name: key.Name(letter), Optional: key.ModShift})
+	}
+
+	var names []key.Name
+	for {
+		ev, ok := gtx.Event(toEventFilters(filters)...)
+		if !ok {
+			break
+		}
+		if keyEv, ok := ev.(key.Event); ok && keyEv.State == key.Press {
+			names = append(names, keyEv.Name)
+		}
+	}
+	return names
+}
+
+// toEventFilters is needed because gtx.Event takes event.Filter
+func toEventFilters(filters []key.Filter) []event.Filter {
+	res := make([]event.Filter, len(filters))
+	for i, f := range filters {
+		res[i] = f
+	}
+	return res
+}
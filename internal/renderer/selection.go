@@ -0,0 +1,87 @@
+package renderer
+
+import (
+	"html"
+
+	"gioui.org/widget"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+	"github.com/WaronLimsakul/Gazer/internal/ui"
+)
+
+// updateSelection tells the selection the labels of the rendered page, again only if they changed
+func (dr *DomRenderer) updateSelection() {
+	lines, ok := dr.cache[dr.renderedRoot]
+	if !ok || lines == dr.selectionLines {
+		return
+	}
+	dr.selectionLines = lines
+
+	nodes := make(map[*widget.Selectable]*Node, len(dr.selectables))
+	for node, selectable := range dr.selectables {
+		nodes[selectable] = node
+	}
+	blocks := make(map[*Node]int) // block element -> its id
+	var labels []ui.TextLabel
+	dr.selectionNodes = dr.selectionNodes[:0]
+	for i, line := range *lines {
+		for _, element := range line {
+			for _, label := range ui.TextLabels(element) {
+				node := nodes[label.Selectable]
+				label.Block = -i - 1 // viewers' labels: a line is a block
+				if block := blockOf(node); block != nil {
+					if _, ok := blocks[block]; !ok {
+						blocks[block] = len(blocks)
+					}
+					label.Block = blocks[block]
+				}
+				labels = append(labels, label)
+				dr.selectionNodes = append(dr.selectionNodes, node)
+			}
+		}
+	}
+	dr.selection.SetLabels(labels)
+}
+
+// blockOf returns the element starting the line the text node is in e.g. <p> or <li>, nil for no node
+func blockOf(node *Node) *Node {
+	if node == nil {
+		return nil
+	}
+	for node.Parent != nil && parser.InlineElements[node.Parent.Tag] {
+		node = node.Parent
+	}
+	return node.Parent
+}
+
+// selectedRange returns the selection as text nodes of the page, false if it isn't in a page of html
+func (dr *DomRenderer) selectedRange() (engine.TextRange, bool) {
+	start, end, ok := dr.selection.Range()
+	if !ok {
+		return engine.TextRange{}, false
+	}
+	startNode, endNode := dr.selectionNodes[start.Label], dr.selectionNodes[end.Label]
+	if startNode == nil || endNode == nil {
+		return engine.TextRange{}, false
+	}
+	return engine.TextRange{
+		Start: engine.TextPoint{Node: startNode, Offset: start.Offset},
+		End:   engine.TextPoint{Node: endNode, Offset: end.Offset},
+	}, true
+}
+
+// selectedText is the text the user selected on the page
+func (dr *DomRenderer) selectedText() string {
+	if r, ok := dr.selectedRange(); ok {
+		return engine.SelectedText(dr.renderedRoot, r)
+	}
+	return dr.selection.Text()
+}
+
+// selectedHtml is the markup of what the user selected, the text of a viewer is escaped
+func (dr *DomRenderer) selectedHtml() string {
+	if r, ok := dr.selectedRange(); ok {
+		return engine.SelectedHTML(dr.renderedRoot, r)
+	}
+	return html.EscapeString(dr.selection.Text())
+}
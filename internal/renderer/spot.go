@@ -0,0 +1,87 @@
+package renderer
+
+import (
+	urlPkg "net/url"
+
+	"gioui.org/widget"
+	"github.com/WaronLimsakul/Gazer/internal/parser"
+	"github.com/WaronLimsakul/Gazer/internal/ui"
+)
+
+// lineSpot is where a text is on the page: the line of the page list and how far down in it
+type lineSpot struct {
+	line     int
+	fraction float32
+}
+
+// textSpot returns where the text node is on the rendered page, false if it isn't rendered
+func (dr *DomRenderer) textSpot(node *Node) (lineSpot, bool) {
+	if dr.spotsRoot != dr.renderedRoot || dr.spots == nil {
+		dr.spots, dr.spotsRoot = make(map[*widget.Selectable]lineSpot), dr.renderedRoot
+		if lines, ok := dr.cache[dr.renderedRoot]; ok {
+			for i, line := range *lines {
+				var selectables []*widget.Selectable
+				for _, element := range line {
+					selectables = append(selectables, ui.Selectables(element)...)
+				}
+				for j, selectable := range selectables {
+					dr.spots[selectable] = lineSpot{line: i, fraction: float32(j) / float32(len(selectables))}
+				}
+			}
+		}
+	}
+	selectable, ok := dr.selectables[node]
+	if !ok {
+		return lineSpot{}, false
+	}
+	spot, ok := dr.spots[selectable]
+	return spot, ok
+}
+
+// anchorSpot returns where the #fragment points on the rendered page: the element with that id
+// or <a name>. Only texts have a spot, so it's the first text from the element on.
+// "top" without such an element is the top of the page.
+func (dr *DomRenderer) anchorSpot(fragment string) (lineSpot, bool) {
+	if fragment == "" || dr.renderedRoot == nil {
+		return lineSpot{}, false
+	}
+	found := false
+	var spot lineSpot
+	var walk func(node *Node) bool
+	walk = func(node *Node) bool {
+		if node.Tag == parser.Head {
+			return false
+		}
+		if !found && (node.Attrs["id"] == fragment || (node.Tag == parser.A && node.Attrs["name"] == fragment)) {
+			found = true
+		}
+		if found && node.Tag == parser.Text {
+			if s, ok := dr.textSpot(node); ok {
+				spot = s
+				return true
+			}
+		}
+		for _, child := range node.Children {
+			if walk(child) {
+				return true
+			}
+		}
+		return false
+	}
+	if walk(dr.renderedRoot) {
+		return spot, true
+	}
+	if !found && fragment == "top" {
+		return lineSpot{}, true
+	}
+	return lineSpot{}, false
+}
+
+// urlFragment returns the #fragment of the url, empty if there's none
+func urlFragment(raw string) string {
+	url, err := urlPkg.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return url.Fragment
+}
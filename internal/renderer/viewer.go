@@ -0,0 +1,211 @@
+package renderer
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+	"github.com/WaronLimsakul/Gazer/internal/highlight"
+	"github.com/WaronLimsakul/Gazer/internal/ui"
+)
+
+type JsonNode = engine.JsonNode
+
+// colors of the json viewer
+var (
+	jsonKeyColor     = color.NRGBA{R: 136, G: 19, B: 145, A: 255}
+	jsonStringColor  = color.NRGBA{R: 26, G: 127, B: 55, A: 255}
+	jsonLiteralColor = color.NRGBA{R: 28, G: 0, B: 207, A: 255} // number, boolean, null
+	lineNumberColor  = color.NRGBA{R: 150, G: 155, B: 165, A: 255}
+)
+
+// colors of the view-source viewer, by the kind of span
+var sourceColors = map[highlight.Kind]color.NRGBA{
+	highlight.Tag:       {R: 136, G: 18, B: 128, A: 255},
+	highlight.AttrName:  {R: 153, G: 69, B: 0, A: 255},
+	highlight.AttrValue: {R: 26, G: 26, B: 166, A: 255},
+	highlight.Comment:   {R: 35, G: 110, B: 37, A: 255},
+	highlight.Doctype:   {R: 128, G: 128, B: 128, A: 255},
+}
+
+// json nodes are indented by this much per level
+const jsonIndent = unit.Dp(20)
+
+// renderTextDocument shows plain text as it is, one element per line
+func (dr *DomRenderer) renderTextDocument(source string) [][]Element {
+	lines := strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+	res := make([][]Element, len(lines))
+	for i, line := range lines {
+		res[i] = []Element{dr.monoLabel(line, nil)}
+	}
+	return res
+}
+
+// renderSourceDocument shows source code (CSS, JavaScript) with line numbers
+func (dr *DomRenderer) renderSourceDocument(source string) [][]Element {
+	lines := strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+	width := len(strconv.Itoa(len(lines)))
+	res := make([][]Element, len(lines))
+	for i, line := range lines {
+		// monospace font, so padding with spaces aligns the numbers
+		number := fmt.Sprintf("%*d  ", width, i+1)
+		res[i] = []Element{dr.monoLabel(number, &lineNumberColor), dr.monoLabel(line, nil)}
+	}
+	return res
+}
+
+// renderViewSourceDocument shows highlighted html source with line numbers.
+// url is the view-source: url, href and src values link to the view-source of their target.
+func (dr *DomRenderer) renderViewSourceDocument(source, url string) [][]Element {
+	baseUrl := strings.TrimPrefix(url, "view-source:")
+	lines := highlight.Html(strings.TrimSuffix(source, "\n"))
+	width := len(strconv.Itoa(len(lines)))
+	res := make([][]Element, len(lines))
+	for i, spans := range lines {
+		number := fmt.Sprintf("%*d  ", width, i+1)
+		line := []Element{dr.monoLabel(number, &lineNumberColor)}
+		for _, span := range spans {
+			if span.Link != "" {
+				target, err := engine.ResolveJumpTarget(span.Link, baseUrl)
+				if err == nil {
+					clickable := new(widget.Clickable)
+					dr.sourceLinks[clickable] = "view-source:" + target
+					lstyle := ui.LabelStyle{Extra: ui.LabelExtraStyle{Monospace: true, Hints: dr.hints}}
+					line = append(line, ui.NewLabel(dr.thm, ui.A(clickable, lstyle), nil, span.Text))
+					continue
+				}
+			}
+			var spanColor *color.NRGBA
+			if c, ok := sourceColors[span.Kind]; ok {
+				spanColor = &c
+			}
+			line = append(line, dr.monoLabel(span.Text, spanColor))
+		}
+		res[i] = line
+	}
+	return res
+}
+
+// renderImageDocument shows the image at url in the image viewer
+func (dr *DomRenderer) renderImageDocument(url string) [][]Element {
+	viewer, ok := dr.imageViewers[url]
+	if !ok {
+		img, err := ui.NewImg(url, dr.images[url])
+		if err != nil {
+			return [][]Element{{dr.monoLabel(fmt.Sprintf("Can't show the image: %v", err), nil)}}
+		}
+		viewer = ui.NewImageViewer(dr.thm, img)
+		dr.imageViewers[url] = viewer
+	}
+	return [][]Element{{viewer}}
+}
+
+// renderJsonDocument shows the json tree pretty-printed, object and array can be collapsed
+func (dr *DomRenderer) renderJsonDocument(root *JsonNode) [][]Element {
+	res := make([][]Element, 0)
+	if root != nil {
+		dr.renderJsonNode(root, "", 0, true, &res)
+	}
+	return res
+}
+
+// renderJsonNode appends the lines of the node to res.
+// label is the key (or the index in array) shown in front of the node, empty for root.
+func (dr *DomRenderer) renderJsonNode(node *JsonNode, label string, depth int, last bool, res *[][]Element) {
+	comma := ","
+	if last {
+		comma = ""
+	}
+	indent := layout.Spacer{Width: jsonIndent * unit.Dp(depth)}
+	line := []Element{indent}
+
+	if node.Kind == engine.JsonValue {
+		// values have no toggle, leave the space so they line up with the others
+		line = append(line, dr.monoLabel("  ", nil))
+		if label != "" {
+			line = append(line, dr.monoLabel(label+": ", &jsonKeyColor))
+		}
+		valueColor := &jsonLiteralColor
+		if strings.HasPrefix(node.Value, `"`) {
+			valueColor = &jsonStringColor
+		}
+		line = append(line, dr.monoLabel(node.Value, valueColor), dr.monoLabel(comma, nil))
+		*res = append(*res, line)
+		return
+	}
+
+	openBracket, closeBracket := "{", "}"
+	noun := "keys"
+	if node.Kind == engine.JsonArray {
+		openBracket, closeBracket = "[", "]"
+		noun = "items"
+	}
+
+	clickable, ok := dr.jsonToggles[node]
+	if !ok {
+		clickable = new(widget.Clickable)
+		dr.jsonToggles[node] = clickable
+	}
+	collapsed := dr.jsonCollapsed[node]
+	toggle := "▾ "
+	if collapsed {
+		toggle = "▸ "
+	}
+
+	lstyle := ui.LabelStyle{Extra: ui.LabelExtraStyle{Monospace: true}}
+	line = append(line, ui.NewLabel(dr.thm, ui.A(clickable, lstyle), nil, toggle))
+	if label != "" {
+		line = append(line, dr.monoLabel(label+": ", &jsonKeyColor))
+	}
+
+	if collapsed || len(node.Children) == 0 {
+		summary := openBracket + closeBracket
+		if len(node.Children) > 0 {
+			summary = fmt.Sprintf("%s…%s  %d %s", openBracket, closeBracket, len(node.Children), noun)
+		}
+		line = append(line, dr.monoLabel(summary+comma, nil))
+		*res = append(*res, line)
+		return
+	}
+
+	line = append(line, dr.monoLabel(openBracket, nil))
+	*res = append(*res, line)
+	for i, child := range node.Children {
+		childLabel := strconv.Itoa(i)
+		if node.Kind == engine.JsonObject {
+			childLabel = strconv.Quote(child.Key)
+		}
+		dr.renderJsonNode(child, childLabel, depth+1, i == len(node.Children)-1, res)
+	}
+	// line up the closing bracket with the opening one (after the toggle)
+	*res = append(*res, []Element{indent, dr.monoLabel("  "+closeBracket+comma, nil)})
+}
+
+// jsonToggled handles clicking the toggles in the json viewer
+// and tells whether any node is collapsed or expanded.
+func (dr *DomRenderer) jsonToggled(gtx C) bool {
+	toggled := false
+	for node, clickable := range dr.jsonToggles {
+		if clickable.Clicked(gtx) {
+			dr.jsonCollapsed[node] = !dr.jsonCollapsed[node]
+			toggled = true
+		}
+	}
+	if toggled {
+		// lines changed, build them again
+		delete(dr.cache, dr.renderedRoot)
+	}
+	return toggled
+}
+
+// monoLabel creates a selectable monospace label, color is optional
+func (dr *DomRenderer) monoLabel(text string, textColor *color.NRGBA) Element {
+	lstyle := ui.LabelStyle{Extra: ui.LabelExtraStyle{Monospace: true, Selection: dr.selection}}
+	lstyle.Base.Color = textColor
+	return ui.NewLabel(dr.thm, lstyle, new(widget.Selectable), text)
+}
@@ -0,0 +1,152 @@
+package ui
+
+import (
+	"log"
+	urlPkg "net/url"
+	"strings"
+
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+	"golang.org/x/exp/shiny/materialdesign/icons"
+)
+
+// BookmarksBar is the bar under the top bar with the bookmarks and the folders in engine.BarFolder,
+// plus the star button in the top bar that bookmarks the current page.
+// A folder opens about:bookmarks at that folder instead of a menu.
+type BookmarksBar struct {
+	thm        *Theme
+	star       *widget.Clickable
+	bookmarked bool                                    // current page is bookmarked, the star is filled
+	bookmarks  map[engine.BookmarkID]*widget.Clickable // by the bookmark's engine id
+	folders    map[string]*widget.Clickable            // by the folder path
+	urls       map[*widget.Clickable]string            // where each clickable goes
+	manager    *widget.Clickable                       // "All bookmarks"
+}
+
+// longer title is cut so a few bookmarks fit the bar
+const bookmarkTitleMaxLen = 24
+
+func NewBookmarksBar(thm *Theme) *BookmarksBar {
+	return &BookmarksBar{thm: thm, star: new(widget.Clickable), manager: new(widget.Clickable),
+		bookmarks: make(map[engine.BookmarkID]*widget.Clickable), folders: make(map[string]*widget.Clickable),
+		urls: make(map[*widget.Clickable]string)}
+}
+
+func (b *BookmarksBar) Layout(gtx C, snapshot engine.Snapshot) D {
+	var items []layout.FlexChild
+	// subfolders of the bar first, then the bookmarks, like other browsers
+	seen := make(map[string]bool)
+	for _, bookmark := range snapshot.Bookmarks {
+		rest, ok := strings.CutPrefix(bookmark.Folder, engine.BarFolder+"/")
+		if !ok {
+			continue
+		}
+		folder := engine.BarFolder + "/" + strings.Split(rest, "/")[0]
+		if seen[folder] {
+			continue
+		}
+		seen[folder] = true
+		clickable, ok := b.folders[folder]
+		if !ok {
+			clickable = new(widget.Clickable)
+			b.folders[folder] = clickable
+		}
+		b.urls[clickable] = "about:bookmarks?folder=" + urlPkg.QueryEscape(folder)
+		items = append(items, layout.Rigid(b.item(clickable, icons.FileFolder, folder[len(engine.BarFolder)+1:])))
+	}
+	for _, bookmark := range snapshot.Bookmarks {
+		if bookmark.Folder != engine.BarFolder {
+			continue
+		}
+		clickable, ok := b.bookmarks[bookmark.ID]
+		if !ok {
+			clickable = new(widget.Clickable)
+			b.bookmarks[bookmark.ID] = clickable
+		}
+		b.urls[clickable] = bookmark.Url
+		title := bookmark.Title
+		if title == "" {
+			title = bookmark.Url
+		}
+		if runes := []rune(title); len(runes) > bookmarkTitleMaxLen {
+			title = string(runes[:bookmarkTitleMaxLen-1]) + "…"
+		}
+		items = append(items, layout.Rigid(b.item(clickable, icons.ActionBookmark, title)))
+	}
+	if len(items) == 0 {
+		return D{} // nothing in the bar, no bar
+	}
+
+	b.urls[b.manager] = "about:bookmarks"
+	items = append(items, layout.Flexed(1, func(gtx C) D { return D{Size: gtx.Constraints.Min} }),
+		layout.Rigid(b.item(b.manager, icons.ActionBookmark, "All bookmarks")))
+
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	return layout.Inset{Left: unit.Dp(10), Right: unit.Dp(10), Bottom: unit.Dp(2)}.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Alignment: layout.Middle}.Layout(gtx, items...)
+	})
+}
+
+// item is a flat button with a small icon and the text
+func (b *BookmarksBar) item(clickable *widget.Clickable, iconData []byte, text string) layout.Widget {
+	return func(gtx C) D {
+		if clickable.Hovered() {
+			pointer.CursorPointer.Add(gtx.Ops)
+		}
+		icon, err := widget.NewIcon(iconData)
+		if err != nil {
+			log.Fatalf("Couldn't create %v icon", text)
+		}
+		return material.Clickable(gtx, clickable, func(gtx C) D {
+			return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						gtx.Constraints.Min.X = gtx.Dp(unit.Dp(14))
+						return icon.Layout(gtx, b.thm.Fg)
+					}),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(4)}.Layout),
+					layout.Rigid(material.Body2(b.thm, text).Layout),
+				)
+			})
+		})
+	}
+}
+
+// layoutStar is the star button in the top bar, filled if the current page is bookmarked
+func (b *BookmarksBar) layoutStar(gtx C) D {
+	iconData, description := icons.ToggleStarBorder, "Bookmark this page"
+	if b.bookmarked {
+		iconData, description = icons.ToggleStar, "Remove bookmark"
+	}
+	icon, err := widget.NewIcon(iconData)
+	if err != nil {
+		log.Fatal("Couldn't create new star icon")
+	}
+	button := material.IconButton(b.thm, b.star, icon, description)
+	button.Size = unit.Dp(25)
+	button.Inset = layout.UniformInset(unit.Dp(5))
+	return layout.UniformInset(unit.Dp(5)).Layout(gtx, button.Layout)
+}
+
+// SetBookmarked tells the bar whether the current page is bookmarked
+func (b *BookmarksBar) SetBookmarked(bookmarked bool) {
+	b.bookmarked = bookmarked
+}
+
+func (b BookmarksBar) StarClicked(gtx C) bool {
+	return b.star.Clicked(gtx)
+}
+
+// Clicked returns the url of the bookmark (or folder) that got clicked and true if exist
+func (b BookmarksBar) Clicked(gtx C) (string, bool) {
+	for clickable, url := range b.urls {
+		if clickable.Clicked(gtx) {
+			return url, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,156 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// ContextMenu is a right-click menu e.g. of the page. It wraps what it's for to know where the user right-clicks,
+// the renderer decides what's in the menu. A click anywhere else or Escape closes it.
+type ContextMenu struct {
+	thm        *Theme
+	items      []string
+	clickables []*widget.Clickable
+	isOpen     bool
+	pos        image.Point     // where it was opened, in the page
+	rect       image.Rectangle // where it was laid out last frame, a press in there isn't outside
+}
+
+var menuBg = color.NRGBA{R: 250, G: 250, B: 250, A: 255}
+
+func NewContextMenu(thm *Theme) *ContextMenu {
+	return &ContextMenu{thm: thm}
+}
+
+// Update handles the presses on the page. It returns where the user right-clicked this frame, if they did.
+func (m *ContextMenu) Update(gtx C) (image.Point, bool) {
+	var pos image.Point
+	rightClicked := false
+	for {
+		ev, ok := gtx.Event(pointer.Filter{Target: m, Kinds: pointer.Press})
+		if !ok {
+			break
+		}
+		pointerEv, ok := ev.(pointer.Event)
+		if !ok {
+			continue
+		}
+		press := pointerEv.Position.Round()
+		if m.isOpen && press.In(m.rect) {
+			continue // one of the items, it's their click
+		}
+		if pointerEv.Buttons == pointer.ButtonSecondary {
+			pos, rightClicked = press, true
+		} else {
+			m.isOpen = false
+		}
+	}
+	for m.isOpen {
+		ev, ok := gtx.Event(key.Filter{Name: key.NameEscape})
+		if !ok {
+			break
+		}
+		if keyEv, ok := ev.(key.Event); ok && keyEv.State == key.Press {
+			m.isOpen = false
+		}
+	}
+	return pos, rightClicked
+}
+
+// Open shows the items at pos of the page
+func (m *ContextMenu) Open(pos image.Point, items []string) {
+	m.isOpen, m.pos, m.items, m.rect = true, pos, items, image.Rectangle{}
+	for len(m.clickables) < len(items) {
+		m.clickables = append(m.clickables, new(widget.Clickable))
+	}
+}
+
+func (m *ContextMenu) Close() {
+	m.isOpen = false
+}
+
+func (m *ContextMenu) IsOpen() bool {
+	return m.isOpen
+}
+
+// Clicked returns the index of the item the user clicked, the menu closes then
+func (m *ContextMenu) Clicked(gtx C) (int, bool) {
+	if !m.isOpen {
+		return 0, false
+	}
+	for i := range m.items {
+		if m.clickables[i].Clicked(gtx) {
+			m.isOpen = false
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Layout lays the page out with the menu over it
+func (m *ContextMenu) Layout(gtx C, page layout.Widget) D {
+	// the area goes around the page so its presses come here too
+	pageMacro := op.Record(gtx.Ops)
+	dims := page(gtx)
+	pageOp := pageMacro.Stop()
+	area := clip.Rect{Max: dims.Size}.Push(gtx.Ops)
+	event.Op(gtx.Ops, m)
+	pageOp.Add(gtx.Ops)
+	area.Pop()
+	if !m.isOpen {
+		return dims
+	}
+
+	menuMacro := op.Record(gtx.Ops)
+	menuGtx := gtx
+	menuGtx.Constraints.Min = image.Point{}
+	menuDims := m.layoutMenu(menuGtx)
+	menuOp := menuMacro.Stop()
+	// keep it on the page
+	pos := image.Point{
+		X: max(0, min(m.pos.X, dims.Size.X-menuDims.Size.X)),
+		Y: max(0, min(m.pos.Y, dims.Size.Y-menuDims.Size.Y)),
+	}
+	m.rect = image.Rectangle{Min: pos, Max: pos.Add(menuDims.Size)}
+	defer op.Offset(pos).Push(gtx.Ops).Pop()
+	op.Defer(gtx.Ops, menuOp) // over everything on the page
+	return dims
+}
+
+func (m *ContextMenu) layoutMenu(gtx C) D {
+	border := widget.Border{Color: m.thm.Fg, CornerRadius: unit.Dp(2), Width: unit.Dp(1)}
+	return border.Layout(gtx, func(gtx C) D {
+		return layout.Background{}.Layout(gtx,
+			func(gtx C) D {
+				defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+				paint.ColorOp{Color: menuBg}.Add(gtx.Ops)
+				paint.PaintOp{}.Add(gtx.Ops)
+				return D{Size: gtx.Constraints.Min}
+			},
+			func(gtx C) D {
+				items := make([]layout.FlexChild, len(m.items))
+				for i, item := range m.items {
+					items[i] = layout.Rigid(func(gtx C) D {
+						gtx.Constraints.Min.X = gtx.Dp(unit.Dp(180))
+						return material.Clickable(gtx, m.clickables[i], func(gtx C) D {
+							inset := layout.Inset{Top: unit.Dp(6), Bottom: unit.Dp(6), Left: unit.Dp(12), Right: unit.Dp(12)}
+							return inset.Layout(gtx, material.Body2(m.thm, item).Layout)
+						})
+					})
+				}
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx, items...)
+			},
+		)
+	})
+}
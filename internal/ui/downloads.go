@@ -0,0 +1,206 @@
+package ui
+
+import (
+	"image/color"
+	"log"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+	"golang.org/x/exp/shiny/materialdesign/icons"
+)
+
+// Downloads is the downloads panel under the top bar, it shows the downloads of the engine
+// state newest first. Like Tabs, ui data of each download is kept by the download's engine id.
+type Downloads struct {
+	thm    *Theme
+	isOpen bool
+	toggle *widget.Clickable // the download button in the top bar
+	clear  *widget.Clickable
+	views  map[engine.DownloadID]*downloadView
+	list   *widget.List
+}
+
+type downloadView struct {
+	pause  *widget.Clickable
+	resume *widget.Clickable // also retries the failed one
+	cancel *widget.Clickable
+}
+
+// the panel doesn't push the page too far down, it scrolls instead
+const downloadsMaxHeight = unit.Dp(200)
+
+func NewDownloads(thm *Theme) *Downloads {
+	return &Downloads{thm: thm, toggle: new(widget.Clickable), clear: new(widget.Clickable),
+		views: make(map[engine.DownloadID]*downloadView), list: &widget.List{List: layout.List{Axis: layout.Vertical}}}
+}
+
+func (d *Downloads) Layout(gtx C, snapshot engine.Snapshot) D {
+	if !d.isOpen {
+		return D{}
+	}
+	// TODO: use new theme system
+	panelBg := color.NRGBA{R: 245, G: 245, B: 245, A: 255}
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	gtx.Constraints.Max.Y = min(gtx.Constraints.Max.Y, gtx.Dp(downloadsMaxHeight))
+
+	return layout.Background{}.Layout(gtx,
+		func(gtx C) D {
+			defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+			paint.ColorOp{Color: panelBg}.Add(gtx.Ops)
+			paint.PaintOp{}.Add(gtx.Ops)
+			return D{Size: gtx.Constraints.Min}
+		},
+		func(gtx C) D {
+			margin := layout.Inset{Top: unit.Dp(5), Bottom: unit.Dp(5), Left: unit.Dp(10), Right: unit.Dp(10)}
+			return margin.Layout(gtx, func(gtx C) D {
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(d.layoutHeader),
+					layout.Rigid(func(gtx C) D { return d.layoutList(gtx, snapshot.Downloads) }),
+				)
+			})
+		},
+	)
+}
+
+func (d *Downloads) layoutHeader(gtx C) D {
+	clearButton := material.Button(d.thm, d.clear, "Clear")
+	clearButton.TextSize = unit.Sp(12)
+	clearButton.Inset = layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8), Right: unit.Dp(8)}
+	return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+		layout.Flexed(1, material.H6(d.thm, "Downloads").Layout),
+		layout.Rigid(clearButton.Layout),
+	)
+}
+
+func (d *Downloads) layoutList(gtx C, downloads []engine.Download) D {
+	if len(downloads) == 0 {
+		return layout.UniformInset(unit.Dp(5)).Layout(gtx, material.Body2(d.thm, "Nothing downloaded yet").Layout)
+	}
+	return material.List(d.thm, d.list).Layout(gtx, len(downloads), func(gtx C, i int) D {
+		download := downloads[len(downloads)-1-i] // newest first
+		return d.layoutDownload(gtx, download)
+	})
+}
+
+func (d *Downloads) layoutDownload(gtx C, download engine.Download) D {
+	view := d.view(download.ID)
+	buttons := []layout.FlexChild{}
+	switch download.Status {
+	case engine.Downloading:
+		buttons = append(buttons, layout.Rigid(d.iconButton(view.pause, icons.AVPause, "Pause")))
+	case engine.Paused, engine.Failed:
+		buttons = append(buttons, layout.Rigid(d.iconButton(view.resume, icons.AVPlayArrow, "Resume")))
+	}
+	if download.Status != engine.Completed && download.Status != engine.Cancelled {
+		buttons = append(buttons, layout.Rigid(d.iconButton(view.cancel, icons.NavigationClose, "Cancel")))
+	}
+
+	row := []layout.FlexChild{
+		layout.Flexed(1, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(material.Body1(d.thm, download.Name()).Layout),
+				layout.Rigid(material.Caption(d.thm, download.StatusText()).Layout),
+			)
+		}),
+	}
+	row = append(row, buttons...)
+
+	return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx, row...)
+			}),
+			layout.Rigid(func(gtx C) D {
+				if download.Status != engine.Downloading && download.Status != engine.Paused {
+					return D{}
+				}
+				return material.ProgressBar(d.thm, download.Fraction()).Layout(gtx)
+			}),
+		)
+	})
+}
+
+func (d *Downloads) iconButton(clickable *widget.Clickable, iconData []byte, description string) layout.Widget {
+	icon, err := widget.NewIcon(iconData)
+	if err != nil {
+		log.Fatalf("Couldn't create %v icon", description)
+	}
+	button := material.IconButton(d.thm, clickable, icon, description)
+	button.Size = unit.Dp(18)
+	button.Inset = layout.UniformInset(unit.Dp(4))
+	return button.Layout
+}
+
+// layoutButton is the download button that opens and closes the panel
+func (d *Downloads) layoutButton(gtx C) D {
+	icon, err := widget.NewIcon(icons.FileFileDownload)
+	if err != nil {
+		log.Fatal("Couldn't create new download icon")
+	}
+	button := material.IconButton(d.thm, d.toggle, icon, "Downloads")
+	button.Size = unit.Dp(25)
+	button.Inset = layout.UniformInset(unit.Dp(5))
+	return layout.UniformInset(unit.Dp(5)).Layout(gtx, button.Layout)
+}
+
+// view returns the ui data of the download with the id, create a new one if not exists yet
+func (d *Downloads) view(id engine.DownloadID) *downloadView {
+	view, ok := d.views[id]
+	if !ok {
+		view = &downloadView{pause: new(widget.Clickable), resume: new(widget.Clickable), cancel: new(widget.Clickable)}
+		d.views[id] = view
+	}
+	return view
+}
+
+// SetOpen opens or closes the panel
+func (d *Downloads) SetOpen(isOpen bool) {
+	d.isOpen = isOpen
+}
+
+func (d Downloads) IsOpen() bool {
+	return d.isOpen
+}
+
+func (d Downloads) ToggleClicked(gtx C) bool {
+	return d.toggle.Clicked(gtx)
+}
+
+func (d Downloads) ClearClicked(gtx C) bool {
+	return d.clear.Clicked(gtx)
+}
+
+// PauseClicked returns id of the download that got "pause" clicked and true if exist
+func (d Downloads) PauseClicked(gtx C) (engine.DownloadID, bool) {
+	for id, view := range d.views {
+		if view.pause.Clicked(gtx) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// ResumeClicked returns id of the download that got "resume" clicked and true if exist
+func (d Downloads) ResumeClicked(gtx C) (engine.DownloadID, bool) {
+	for id, view := range d.views {
+		if view.resume.Clicked(gtx) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// CancelClicked returns id of the download that got "cancel" clicked and true if exist
+func (d Downloads) CancelClicked(gtx C) (engine.DownloadID, bool) {
+	for id, view := range d.views {
+		if view.cancel.Clicked(gtx) {
+			return id, true
+		}
+	}
+	return 0, false
+}
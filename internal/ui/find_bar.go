@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+	"golang.org/x/exp/shiny/materialdesign/icons"
+)
+
+// FindBar is the find in page bar under the top bar, it's only there after the user opens it.
+// Enter goes to the next match, Escape closes it.
+type FindBar struct {
+	thm           *Theme
+	editor        *widget.Editor
+	next          *widget.Clickable
+	previous      *widget.Clickable
+	close         *widget.Clickable
+	caseSensitive *widget.Bool
+	wholeWord     *widget.Bool
+	isOpen        bool
+	active, total int // "active of total" matches, active counts from 0
+}
+
+func NewFindBar(thm *Theme) *FindBar {
+	editor := new(widget.Editor)
+	editor.SingleLine, editor.Submit = true, true
+	return &FindBar{thm: thm, editor: editor, next: new(widget.Clickable), previous: new(widget.Clickable),
+		close: new(widget.Clickable), caseSensitive: new(widget.Bool), wholeWord: new(widget.Bool)}
+}
+
+// Open shows the bar and moves the keyboard there with the last query selected
+func (f *FindBar) Open(gtx C) {
+	f.isOpen = true
+	gtx.Execute(key.FocusCmd{Tag: f.editor})
+	f.editor.SetCaret(f.editor.Len(), 0)
+}
+
+func (f *FindBar) IsOpen() bool {
+	return f.isOpen
+}
+
+// Focused tells if the user is typing in the find bar
+func (f *FindBar) Focused(gtx C) bool {
+	return gtx.Focused(f.editor)
+}
+
+// Update handles the bar's events. It returns whether what to find has changed (closing clears it)
+// and the step to another match: 1 is the next one, -1 the previous one, 0 stays.
+func (f *FindBar) Update(gtx C) (bool, int) {
+	changed, step := false, 0
+	for {
+		ev, ok := f.editor.Update(gtx)
+		if !ok {
+			break
+		}
+		switch ev.(type) {
+		case widget.SubmitEvent:
+			step = 1
+		case widget.ChangeEvent:
+			changed = true
+		}
+	}
+	for {
+		ev, ok := gtx.Event(key.Filter{Focus: f.editor, Name: key.NameEscape})
+		if !ok {
+			break
+		}
+		if keyEv, ok := ev.(key.Event); ok && keyEv.State == key.Press {
+			f.isOpen, changed = false, true
+		}
+	}
+	if f.close.Clicked(gtx) {
+		f.isOpen, changed = false, true
+	}
+	caseChanged, wordChanged := f.caseSensitive.Update(gtx), f.wholeWord.Update(gtx)
+	if caseChanged || wordChanged {
+		changed = true
+	}
+	if f.next.Clicked(gtx) {
+		step = 1
+	}
+	if f.previous.Clicked(gtx) {
+		step = -1
+	}
+	return changed, step
+}
+
+// Query is what to find, empty when the bar is closed
+func (f *FindBar) Query() string {
+	if !f.isOpen {
+		return ""
+	}
+	return f.editor.Text()
+}
+
+func (f *FindBar) Options() engine.FindOptions {
+	return engine.FindOptions{CaseSensitive: f.caseSensitive.Value, WholeWord: f.wholeWord.Value}
+}
+
+// SetResult tells the bar which match is active out of how many
+func (f *FindBar) SetResult(active, total int) {
+	f.active, f.total = active, total
+}
+
+func (f *FindBar) Layout(gtx C) D {
+	if !f.isOpen {
+		return D{}
+	}
+
+	editor := material.Editor(f.thm, f.editor, "Find in page")
+	border := widget.Border{Color: f.thm.Fg, CornerRadius: unit.Dp(2), Width: unit.Dp(1)}
+	result := ""
+	switch {
+	case f.total > 0:
+		result = fmt.Sprintf("%d of %d", f.active+1, f.total)
+	case f.editor.Len() > 0:
+		result = "No matches"
+	}
+
+	return layout.Inset{Left: unit.Dp(10), Right: unit.Dp(10), Bottom: unit.Dp(2)}.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(unit.Dp(300))
+				gtx.Constraints.Max.X = gtx.Constraints.Min.X
+				return border.Layout(gtx, func(gtx C) D {
+					return layout.UniformInset(unit.Dp(5)).Layout(gtx, editor.Layout)
+				})
+			}),
+			layout.Rigid(func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(unit.Dp(90)) // the buttons don't move while typing
+				return layout.Inset{Left: unit.Dp(10)}.Layout(gtx, material.Body2(f.thm, result).Layout)
+			}),
+			layout.Rigid(f.iconButton(f.previous, icons.NavigationExpandLess, "Previous match")),
+			layout.Rigid(f.iconButton(f.next, icons.NavigationExpandMore, "Next match")),
+			layout.Rigid(material.CheckBox(f.thm, f.caseSensitive, "Match case").Layout),
+			layout.Rigid(material.CheckBox(f.thm, f.wholeWord, "Whole words").Layout),
+			layout.Flexed(1, func(gtx C) D { return D{Size: gtx.Constraints.Min} }),
+			layout.Rigid(f.iconButton(f.close, icons.NavigationClose, "Close find bar")),
+		)
+	})
+}
+
+func (f *FindBar) iconButton(clickable *widget.Clickable, iconData []byte, description string) layout.Widget {
+	icon, err := widget.NewIcon(iconData)
+	if err != nil {
+		log.Fatalf("Couldn't create %v icon", description)
+	}
+	button := material.IconButton(f.thm, clickable, icon, description)
+	button.Size = unit.Dp(18)
+	button.Inset = layout.UniformInset(unit.Dp(5))
+	return func(gtx C) D {
+		return layout.UniformInset(unit.Dp(2)).Layout(gtx, button.Layout)
+	}
+}
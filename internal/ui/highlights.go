@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"image/color"
+
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+)
+
+// Highlight is a range of a label's text to paint behind, in runes
+type Highlight struct {
+	Start, End int
+	Active     bool // the one find in page is at
+}
+
+// Highlights are find in page's matches, painted behind the labels' text.
+// One per DomRenderer, labels share it through their LabelStyle.
+type Highlights struct {
+	ranges  map[*widget.Selectable][]Highlight // by the label's selectable
+	regions []widget.Region                    // reused every frame
+}
+
+var (
+	highlightColor       = color.NRGBA{R: 255, G: 235, B: 59, A: 255}
+	activeHighlightColor = color.NRGBA{R: 255, G: 150, B: 50, A: 255}
+)
+
+func NewHighlights() *Highlights {
+	return &Highlights{ranges: make(map[*widget.Selectable][]Highlight)}
+}
+
+// Set replaces all highlights, nil clears them
+func (h *Highlights) Set(ranges map[*widget.Selectable][]Highlight) {
+	clear(h.ranges)
+	for selectable, highlights := range ranges {
+		h.ranges[selectable] = highlights
+	}
+}
+
+// layout paints the highlights of the label's text, the text must be laid out already
+func (h *Highlights) layout(gtx C, selectable *widget.Selectable) {
+	for _, highlight := range h.ranges[selectable] {
+		fill := highlightColor
+		if highlight.Active {
+			fill = activeHighlightColor
+		}
+		h.regions = selectable.Regions(highlight.Start, highlight.End, h.regions[:0])
+		for _, region := range h.regions {
+			paint.FillShape(gtx.Ops, fill, clip.Rect(region.Bounds).Op())
+		}
+	}
+}
+
+// Selectables returns the selectables of the text in the element (and in the elements inside it) in order
+func Selectables(element Element) []*widget.Selectable {
+	labels := TextLabels(element)
+	res := make([]*widget.Selectable, len(labels))
+	for i, label := range labels {
+		res[i] = label.Selectable
+	}
+	return res
+}
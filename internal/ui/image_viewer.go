@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// zoom levels the zoom buttons go through
+var zoomLevels = []float32{0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 3, 4, 8}
+
+// ImageViewer shows a standalone image (e.g. opening a .png url) centered with zoom controls.
+// Clicking the image switches between fit-to-page and actual size.
+type ImageViewer struct {
+	thm              *material.Theme
+	img              *Img
+	zoom             float32 // 0 means fit the image to the page width
+	lastScale        float32 // scale used in the last layout, for zooming from fit
+	zoomInClickable  *widget.Clickable
+	zoomOutClickable *widget.Clickable
+	fitClickable     *widget.Clickable
+	imgClickable     *widget.Clickable
+}
+
+func NewImageViewer(thm *material.Theme, img *Img) *ImageViewer {
+	return &ImageViewer{thm: thm, img: img, zoomInClickable: new(widget.Clickable),
+		zoomOutClickable: new(widget.Clickable), fitClickable: new(widget.Clickable),
+		imgClickable: new(widget.Clickable)}
+}
+
+func (v *ImageViewer) Layout(gtx C) D {
+	v.update(gtx)
+
+	zoomText := "Fit"
+	if v.zoom != 0 {
+		zoomText = fmt.Sprintf("%.0f%%", v.zoom*100)
+	}
+
+	// center everything horizontally
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+				Rigid(material.Button(v.thm, v.zoomOutClickable, "−")),
+				Rigid(layout.Spacer{Width: unit.Dp(5)}),
+				Rigid(material.Button(v.thm, v.fitClickable, zoomText)),
+				Rigid(layout.Spacer{Width: unit.Dp(5)}),
+				Rigid(material.Button(v.thm, v.zoomInClickable, "+")),
+			)
+		}),
+		Rigid(layout.Spacer{Height: unit.Dp(10)}),
+		layout.Rigid(func(gtx C) D {
+			return v.imgClickable.Layout(gtx, v.layoutImg)
+		}),
+	)
+}
+
+// update handles the clicks on the buttons and the image
+func (v *ImageViewer) update(gtx C) {
+	if v.zoomInClickable.Clicked(gtx) {
+		v.zoom = nextZoomLevel(v.currentScale(), true)
+	}
+	if v.zoomOutClickable.Clicked(gtx) {
+		v.zoom = nextZoomLevel(v.currentScale(), false)
+	}
+	if v.fitClickable.Clicked(gtx) {
+		v.zoom = 0
+	}
+	if v.imgClickable.Clicked(gtx) {
+		if v.zoom == 0 {
+			v.zoom = 1
+		} else {
+			v.zoom = 0
+		}
+	}
+}
+
+func (v *ImageViewer) currentScale() float32 {
+	if v.zoom != 0 {
+		return v.zoom
+	}
+	return v.lastScale
+}
+
+// layoutImg paints the image scaled by the zoom
+func (v *ImageViewer) layoutImg(gtx C) D {
+	frame := v.img.frame(gtx)
+	imgOp := paint.NewImageOp(frame)
+	size := imgOp.Size()
+
+	scale := v.zoom
+	if scale == 0 {
+		// fit the width but never enlarge a small image
+		scale = min(1, float32(gtx.Constraints.Max.X)/float32(max(size.X, 1)))
+	}
+	v.lastScale = scale
+
+	scaledSize := image.Pt(int(float32(size.X)*scale), int(float32(size.Y)*scale))
+	defer clip.Rect{Max: scaledSize}.Push(gtx.Ops).Pop()
+	defer op.Affine(f32.Affine2D{}.Scale(f32.Point{}, f32.Pt(scale, scale))).Push(gtx.Ops).Pop()
+	imgOp.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	return D{Size: scaledSize}
+}
+
+// nextZoomLevel returns the zoom level after (or before) the scale
+func nextZoomLevel(scale float32, in bool) float32 {
+	if in {
+		for _, level := range zoomLevels {
+			if level > scale+0.001 {
+				return level
+			}
+		}
+		return zoomLevels[len(zoomLevels)-1]
+	}
+	for i := len(zoomLevels) - 1; i >= 0; i-- {
+		if zoomLevels[i] < scale-0.001 {
+			return zoomLevels[i]
+		}
+	}
+	return zoomLevels[0]
+}
@@ -1,11 +1,10 @@
 package ui
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"io"
-	"net/url"
-	"strings"
 	"time"
 
 	"image/draw"
@@ -13,13 +12,12 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 
+	"gioui.org/io/event"
 	"gioui.org/op"
+	"gioui.org/op/clip"
 	"gioui.org/op/paint"
-	"github.com/WaronLimsakul/Gazer/internal/engine"
 )
 
-var imgFormats = []string{".jpg", ".jpeg", ".png", ".gif"}
-
 type Img struct {
 	src    string
 	format string
@@ -41,47 +39,28 @@ type GifImg struct {
 	composedFrames []image.Image
 }
 
-// NewImg creates a new Img component from legal URL src
-func NewImg(src string) (*Img, error) {
-	parsedUrl, err := url.Parse(src)
-	if err != nil {
-		return nil, fmt.Errorf("url.Parse: %v", err)
-	}
-
-	// check if the format is supported
-	var imgFormat string
-	for _, format := range imgFormats {
-		if strings.HasSuffix(parsedUrl.Path, format) {
-			imgFormat = format
-			break
-		}
-	}
-	if len(imgFormat) == 0 {
-		fmt.Println("not supported file format:", parsedUrl.Path)
-		return nil, fmt.Errorf("Not supported file format")
+// NewImg creates a new Img component from its src url and the fetched content.
+// The format is detected from the content itself.
+func NewImg(src string, content []byte) (*Img, error) {
+	if len(content) == 0 {
+		return nil, fmt.Errorf("Empty image content")
 	}
 
-	// fetch the image content
-	imgReader, err := engine.Fetch(*parsedUrl)
-	if err != nil {
-		fmt.Println("engine fetch error:", err)
-		return nil, err
-	}
-	defer imgReader.Close()
-
 	// decode the image
 	var img image.Image
 	var format string
 	var gifImg *GifImg
 	var isGif bool
-	if imgFormat == ".gif" {
+	var err error
+	if bytes.HasPrefix(content, []byte("GIF8")) {
 		isGif = true
-		gifImg, err = newGifImg(imgReader)
+		format = "gif"
+		gifImg, err = newGifImg(bytes.NewReader(content))
 		if err != nil {
 			return nil, fmt.Errorf("newGifImg: %v", err)
 		}
 	} else {
-		img, format, err = image.Decode(imgReader)
+		img, format, err = image.Decode(bytes.NewReader(content))
 		if err != nil {
 			return nil, fmt.Errorf("image.Decode: %v", err)
 		}
@@ -90,22 +69,41 @@ func NewImg(src string) (*Img, error) {
 	return &Img{src: src, format: format, img: img, isGif: isGif, gifImg: gifImg}, nil
 }
 
-func (i Img) Layout(gtx C) D {
+func (i *Img) Layout(gtx C) D {
 	var size image.Point
-	var img image.Image
-	if i.isGif {
-		now := time.Now()
-		img = i.gifImg.getGifFrame(now)
-		op.InvalidateCmd{At: i.gifImg.getNextFrameTime(now)}.ImplementsCommand()
-	} else {
-		img = i.img
-	}
+	img := i.frame(gtx)
 	imgOp := paint.NewImageOp(img)
 	size = imgOp.Size()
 	imgOp.Add(gtx.Ops)
 	paint.PaintOp{}.Add(gtx.Ops)
+	// the renderer asks it for the right-clicks, for the image's context menu
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+	event.Op(gtx.Ops, i)
 	return D{Size: gtx.Constraints.Constrain(size)}
+}
+
+// Src is the url of the image
+func (i *Img) Src() string {
+	return i.src
+}
 
+// frame returns the image to paint now, for gif it's the current frame
+// and the next frame is scheduled.
+func (i Img) frame(gtx C) image.Image {
+	if !i.isGif {
+		return i.img
+	}
+	now := time.Now()
+	gtx.Execute(op.InvalidateCmd{At: i.gifImg.getNextFrameTime(now)})
+	return i.gifImg.getGifFrame(now)
+}
+
+// Size returns the size of the image in pixels
+func (i Img) Size() image.Point {
+	if i.isGif {
+		return i.gifImg.composedFrames[0].Bounds().Size()
+	}
+	return i.img.Bounds().Size()
 }
 
 // newGifImg create a new *GifImg data from the reader r
@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"image/color"
+
 	"gioui.org/io/key"
 	"gioui.org/layout"
 	"gioui.org/unit"
@@ -17,7 +19,8 @@ const (
 	PasswordInput
 	NumberInput
 	EmailInput
-	// TODO: Email, Checkbox
+	UrlInput
+	// TODO: Checkbox
 )
 
 // for rendering from DOM node
@@ -26,13 +29,18 @@ var InputTypes = map[string]InputType{
 	"password": PasswordInput,
 	"number":   NumberInput,
 	"email":    EmailInput,
+	"url":      UrlInput,
 }
 
+var invalidColor = color.NRGBA{R: 191, G: 97, B: 106, A: 255}
+
 type Input struct {
 	thm       *Theme
 	inputType InputType
 	hint      string
 	editor    *widget.Editor
+	// constraint validation message, shown next to the input if not empty
+	message *string
 	// size?
 	// border?
 	// margin?
@@ -40,9 +48,11 @@ type Input struct {
 }
 
 // NewInput create a new Input
+// message is a pointer so the caller can update the validation message after the Input is created.
 // require: editor != nil
-func NewInput(thm *Theme, inputType InputType, editor *widget.Editor, hint string) Input {
+func NewInput(thm *Theme, inputType InputType, editor *widget.Editor, hint string, message *string) Input {
 	editor.SingleLine = true
+	editor.Submit = true // pressing enter submits the form (if any)
 	switch inputType {
 	case TextInput:
 		editor.InputHint = key.HintText
@@ -53,11 +63,12 @@ func NewInput(thm *Theme, inputType InputType, editor *widget.Editor, hint strin
 		editor.InputHint = key.HintNumeric
 		editor.Filter = "0123456789"
 	case EmailInput:
-		// TODO: after supporting form + button/input type submit => needs a way to check email format
-		// Here is some regex it might help: `^[a-zA-Z0-9._%+-]+@`
+		// email format is checked by form package when the form is submitted
 		editor.InputHint = key.HintEmail
+	case UrlInput:
+		editor.InputHint = key.HintURL
 	}
-	return Input{thm: thm, inputType: inputType, editor: editor, hint: hint}
+	return Input{thm: thm, inputType: inputType, editor: editor, hint: hint, message: message}
 }
 
 func (i Input) Layout(gtx C) D {
@@ -66,12 +77,28 @@ func (i Input) Layout(gtx C) D {
 	contentMargin := layout.UniformInset(unit.Dp(4))
 	input := material.Editor(i.thm, i.editor, i.hint)
 	minWidth := unit.Dp(100)
-	return border.Layout(gtx, func(gtx C) D {
-		return contentMargin.Layout(gtx, func(gtx C) D {
-			gtx.Constraints.Min.X = gtx.Dp(minWidth)
-			return input.Layout(gtx)
+	box := func(gtx C) D {
+		return border.Layout(gtx, func(gtx C) D {
+			return contentMargin.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(minWidth)
+				return input.Layout(gtx)
+			})
 		})
-	})
+	}
+
+	if i.message == nil || *i.message == "" {
+		return box(gtx)
+	}
+
+	// invalid input: red border and the message next to it
+	border.Color = invalidColor
+	message := material.Caption(i.thm, *i.message)
+	message.Color = invalidColor
+	return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+		layout.Rigid(box),
+		Rigid(layout.Spacer{Width: unit.Dp(6)}),
+		Rigid(message),
+	)
 }
 
 // TODO: check box type needs another struct.
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"gioui.org/font"
+	"gioui.org/io/event"
 	"gioui.org/io/pointer"
 	"gioui.org/layout"
 	"gioui.org/op"
@@ -22,6 +23,9 @@ import (
 type Theme = material.Theme
 type Style = css.Style
 
+// typeface for <pre> and source code, the renderer loads it
+const MonospaceTypeface = "Go Mono"
+
 // Labels are supposed to be built using a decorator pattern.
 // Start with empty LabelStyle, pass it around with all LabelStyleDecator and then
 // finish the building with NewLabel()
@@ -43,6 +47,10 @@ type Label struct {
 
 	// for <a> or <button>
 	clickable *widget.Clickable
+	hints     *LinkHints // for <a>
+
+	highlights *Highlights // find in page's matches in the text
+	selection  *Selection  // the page's selected text
 
 	// for <li>: e.g. Prefix "•"
 	prefix string
@@ -60,7 +68,13 @@ type LabelStyle struct {
 type LabelExtraStyle struct {
 	Clickable *widget.Clickable
 	Prefix    string
-	Count     *int // for <ol>
+	Count     *int       // for <ol>
+	Monospace bool       // for <pre>, keep inherited by the children
+	Hints     *LinkHints // for <a>, where its hint comes from
+	// where find in page's matches come from, labels without a selectable have none
+	Highlights *Highlights
+	// the page's text selection, same as Highlights
+	Selection *Selection
 }
 
 func (l Label) Layout(gtx C) D {
@@ -70,6 +84,9 @@ func (l Label) Layout(gtx C) D {
 		if l.clickable.Hovered() {
 			pointer.CursorPointer.Add(gtx.Ops)
 		}
+		if l.hints != nil {
+			l.hints.layout(gtx, l.clickable, l.style)
+		}
 	}
 
 	// layout
@@ -83,12 +100,40 @@ func (l Label) Layout(gtx C) D {
 						// material.LabelStyle.Layout try to takes just what it need by default.
 						// However, passed gtx might just give min = max = max
 						gtx.Constraints.Min = image.Point{}
-						return l.style.Layout(gtx)
+						if (l.highlights == nil && l.selection == nil) || l.style.State == nil {
+							return l.style.Layout(gtx)
+						}
+						// selection and highlights go behind the text, and need the text laid out to know where
+						textMacro := op.Record(gtx.Ops)
+						dims := l.style.Layout(gtx)
+						textOp := textMacro.Stop()
+						if l.selection != nil {
+							l.selection.update(gtx, l.style.State, dims.Size)
+							l.selection.paint(gtx, l.style.State)
+						}
+						if l.highlights != nil {
+							l.highlights.layout(gtx, l.style.State)
+						}
+						textOp.Add(gtx.Ops)
+						if l.selection != nil {
+							l.selection.cover(gtx, l.style.State, dims.Size, l.clickable == nil)
+						}
+						return dims
 					})
 				}
 				macro := op.Record(gtx.Ops)
 				if l.clickable != nil {
-					contentSize = l.clickable.Layout(gtx, contentWidget)
+					contentSize = l.clickable.Layout(gtx, func(gtx C) D {
+						// the clickable only knows the primary button, the renderer asks this area for the middle one.
+						// It's under the text so text selection still gets the pointer.
+						textMacro := op.Record(gtx.Ops)
+						dims := contentWidget(gtx)
+						textOp := textMacro.Stop()
+						defer clip.Rect{Max: dims.Size}.Push(gtx.Ops).Pop()
+						event.Op(gtx.Ops, l.clickable)
+						textOp.Add(gtx.Ops)
+						return dims
+					})
 				} else {
 					contentSize = contentWidget(gtx)
 				}
@@ -127,6 +172,9 @@ func NewLabel(thm *Theme, lstyle LabelStyle, selectable *widget.Selectable, txt
 	}
 
 	text.State = selectable
+	if lstyle.Extra.Monospace {
+		text.Font.Typeface = MonospaceTypeface
+	}
 	if lstyle.Base.FontStyle != nil {
 		text.Font.Style = *lstyle.Base.FontStyle
 	}
@@ -138,9 +186,12 @@ func NewLabel(thm *Theme, lstyle LabelStyle, selectable *widget.Selectable, txt
 	}
 
 	res := Label{
-		prefix:    lstyle.Extra.Prefix,
-		clickable: lstyle.Extra.Clickable,
-		style:     text,
+		prefix:     lstyle.Extra.Prefix,
+		clickable:  lstyle.Extra.Clickable,
+		hints:      lstyle.Extra.Hints,
+		highlights: lstyle.Extra.Highlights,
+		selection:  lstyle.Extra.Selection,
+		style:      text,
 	}
 
 	if lstyle.Base.BgColor != nil {
@@ -203,6 +254,17 @@ func P(thm *Theme, style LabelStyle) LabelStyle {
 	return style
 }
 
+func Pre(thm *Theme, style LabelStyle) LabelStyle {
+	style.Extra.Monospace = true
+	return style
+}
+
+// Code is inline code, unlike Pre it doesn't start a new block
+func Code(thm *Theme, style LabelStyle) LabelStyle {
+	style.Extra.Monospace = true
+	return style
+}
+
 func I(thm *Theme, style LabelStyle) LabelStyle {
 	italic := font.Italic
 	style.Base.FontStyle = &italic
@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// LinkHints labels the links on screen with letters, typing the letters follows the link (like Vimium).
+// A link gets its hint the first time it's laid out while hints are on, so only the links on screen have one.
+// One per DomRenderer, links share it through their LabelStyle.
+type LinkHints struct {
+	active bool
+	typed  string
+	hints  map[*widget.Clickable]string // by the link's clickable
+	links  map[string]*widget.Clickable // back from the hint
+	shown  map[*widget.Clickable]bool   // hinted this frame, a link split into many labels shows one hint
+}
+
+// hints are 2 of these letters, home row first
+const hintLetters = "asdfghjklqwertyuiopzxcvbnm"
+
+var (
+	hintBg = color.NRGBA{R: 255, G: 221, B: 87, A: 255}
+	hintFg = color.NRGBA{R: 46, G: 52, B: 64, A: 255}
+)
+
+func NewLinkHints() *LinkHints {
+	return &LinkHints{hints: make(map[*widget.Clickable]string), links: make(map[string]*widget.Clickable),
+		shown: make(map[*widget.Clickable]bool)}
+}
+
+// Start shows a hint on every link on screen
+func (h *LinkHints) Start() {
+	h.active, h.typed = true, ""
+	clear(h.hints)
+	clear(h.links)
+}
+
+func (h *LinkHints) Stop() {
+	h.active = false
+}
+
+func (h *LinkHints) Active() bool {
+	return h.active
+}
+
+// NewFrame is called before laying the page out
+func (h *LinkHints) NewFrame() {
+	clear(h.shown)
+}
+
+// Type takes a typed letter. When it completes a hint, it stops and returns the link's clickable and true.
+// A letter no hint goes on with stops the hints.
+func (h *LinkHints) Type(letter string) (*widget.Clickable, bool) {
+	h.typed += strings.ToLower(letter)
+	if link, ok := h.links[h.typed]; ok {
+		h.Stop()
+		return link, true
+	}
+	for hint := range h.links {
+		if strings.HasPrefix(hint, h.typed) {
+			return nil, false
+		}
+	}
+	h.Stop()
+	return nil, false
+}
+
+// Backspace takes back the last typed letter
+func (h *LinkHints) Backspace() {
+	if h.typed != "" {
+		h.typed = h.typed[:len(h.typed)-1]
+	}
+}
+
+// hint gives the link its hint, empty if we ran out of them
+func (h *LinkHints) hint(link *widget.Clickable) string {
+	if hint, ok := h.hints[link]; ok {
+		return hint
+	}
+	n := len(h.hints)
+	if n >= len(hintLetters)*len(hintLetters) {
+		return ""
+	}
+	hint := string(hintLetters[n/len(hintLetters)]) + string(hintLetters[n%len(hintLetters)])
+	h.hints[link], h.links[hint] = hint, link
+	return hint
+}
+
+// layout draws the link's hint over the top left corner of its label, with the typed part gone
+func (h *LinkHints) layout(gtx C, link *widget.Clickable, style material.LabelStyle) {
+	if !h.active || h.shown[link] {
+		return
+	}
+	h.shown[link] = true
+	hint := h.hint(link)
+	rest, ok := strings.CutPrefix(hint, h.typed)
+	if hint == "" || !ok {
+		return
+	}
+
+	style.Text, style.State = strings.ToUpper(rest), nil
+	style.Color, style.TextSize = hintFg, unit.Sp(12)
+	macro := op.Record(gtx.Ops)
+	gtx.Constraints.Min = image.Point{}
+	layout.Background{}.Layout(gtx,
+		func(gtx C) D {
+			rect := clip.UniformRRect(image.Rectangle{Max: gtx.Constraints.Min}, gtx.Dp(unit.Dp(3)))
+			paint.FillShape(gtx.Ops, hintBg, rect.Op(gtx.Ops))
+			return D{Size: gtx.Constraints.Min}
+		},
+		func(gtx C) D {
+			return layout.Inset{Left: unit.Dp(2), Right: unit.Dp(2)}.Layout(gtx, style.Layout)
+		},
+	)
+	op.Defer(gtx.Ops, macro.Stop()) // over the labels next to it
+}
@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// LinkStatus is where the hovered link goes, at the bottom left corner over the page
+type LinkStatus struct {
+	thm *Theme
+	url string
+}
+
+var linkStatusBg = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+
+func NewLinkStatus(thm *Theme, url string) LinkStatus {
+	return LinkStatus{thm: thm, url: url}
+}
+
+func (s LinkStatus) Layout(gtx C) D {
+	if s.url == "" {
+		return D{}
+	}
+	gtx.Constraints.Min.X = 0
+	gtx.Constraints.Max.X = gtx.Constraints.Max.X * 2 / 3 // leave the rest of the page in sight
+	border := widget.Border{Color: s.thm.Fg, CornerRadius: unit.Dp(2), Width: unit.Dp(1)}
+	label := material.Caption(s.thm, s.url)
+	label.MaxLines = 1
+	return border.Layout(gtx, func(gtx C) D {
+		return layout.Background{}.Layout(gtx,
+			func(gtx C) D {
+				defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+				paint.ColorOp{Color: linkStatusBg}.Add(gtx.Ops)
+				paint.PaintOp{}.Add(gtx.Ops)
+				return D{Size: gtx.Constraints.Min}
+			},
+			func(gtx C) D {
+				return layout.Inset{Top: unit.Dp(2), Bottom: unit.Dp(2), Left: unit.Dp(6), Right: unit.Dp(6)}.Layout(gtx, label.Layout)
+			},
+		)
+	})
+}
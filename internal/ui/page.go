@@ -1,25 +1,114 @@
 package ui
 
 import (
+	"math"
+
 	"gioui.org/layout"
+	"gioui.org/op"
 	"gioui.org/unit"
 	"gioui.org/widget"
 	"gioui.org/widget/material"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+	"github.com/WaronLimsakul/Gazer/internal/parser"
 )
 
 // Page is a component for rendering entire webpage
 type Page struct {
 	thm  *Theme
 	list *widget.List
+	// what the page showed last frame, a different one starts at its own scroll
+	tabId    engine.TabID
+	root     *parser.Node
+	url      string                // an entry of the same document differs by its #fragment
+	reported engine.ScrollPosition // last position told to the engine
+	zoom     float32               // 1 is 100%
+	// keyboard scrolling waiting for the next layout, it knows how tall a page is
+	lines, pages int
+	// line to bring into view and how far down in it, -1 is nothing
+	target         int
+	targetFraction float32
+	targetAt       float32 // how far down the screen it goes, 0 is the top
 }
 
+// a line of keyboard scrolling, pages don't have the same line height so it's a fixed one
+const scrollLineHeight = unit.Dp(40)
+
 func NewPage(thm *Theme) *Page {
 	list := new(widget.List)
 	list.Axis = layout.Vertical
-	return &Page{thm: thm, list: list}
+	return &Page{thm: thm, list: list, zoom: 1, target: -1}
+}
+
+// SetZoom scales everything on the page, 1 is 100%
+func (p *Page) SetZoom(zoom float32) {
+	p.zoom = zoom
+}
+
+// ScrollLines scrolls n lines down, negative is up
+func (p *Page) ScrollLines(n int) {
+	p.lines += n
+}
+
+// ScrollPages scrolls n screens down, negative is up
+func (p *Page) ScrollPages(n int) {
+	p.pages += n
+}
+
+// ScrollToLine brings the part of the line that is fraction (0 to 1) down in it to a third of the screen,
+// a line can be a long container so the top of it isn't enough
+func (p *Page) ScrollToLine(line int, fraction float32) {
+	p.target, p.targetFraction, p.targetAt = line, fraction, 1.0/3
+}
+
+// ScrollLineToTop is ScrollToLine to the top of the screen, where an anchor goes
+func (p *Page) ScrollLineToTop(line int, fraction float32) {
+	p.target, p.targetFraction, p.targetAt = line, fraction, 0
+}
+
+func (p *Page) ScrollToTop() {
+	p.lines, p.pages = 0, 0
+	p.list.ScrollTo(0)
+}
+
+// ScrollToBottom scrolls past the last line, the list stops at the end
+func (p *Page) ScrollToBottom() {
+	p.lines, p.pages = 0, 0
+	p.list.ScrollTo(math.MaxInt32)
+}
+
+// Show tells the page which tab it renders this frame.
+// When the tab or its history entry changes, the list jumps to where the user left that page.
+// It returns true if the user hasn't scrolled the newly shown entry, so it can go to its #fragment.
+func (p *Page) Show(tab engine.TabSnapshot) bool {
+	if tab.ID == p.tabId && tab.Dom.Root == p.root && tab.Url == p.url {
+		return false
+	}
+	p.tabId, p.root, p.url = tab.ID, tab.Dom.Root, tab.Url
+	p.list.Position.First, p.list.Position.Offset = tab.Scroll.First, tab.Scroll.Offset
+	p.list.Position.BeforeEnd = true
+	p.reported = tab.Scroll
+	return tab.Scroll == engine.ScrollPosition{}
+}
+
+// Scrolled returns where the page is scrolled to and true if it moved since the last call
+func (p *Page) Scrolled() (engine.ScrollPosition, bool) {
+	current := engine.ScrollPosition{First: p.list.Position.First, Offset: p.list.Position.Offset}
+	if current == p.reported {
+		return current, false
+	}
+	p.reported = current
+	return current, true
 }
 
 func (p *Page) Layout(gtx C, elements [][]Element) D {
+	if p.lines != 0 || p.pages != 0 {
+		// a page keeps a bit of the previous one in sight
+		p.list.Position.Offset += p.lines*gtx.Dp(scrollLineHeight) + p.pages*gtx.Constraints.Max.Y*9/10
+		p.list.Position.BeforeEnd = true
+		p.lines, p.pages = 0, 0
+	}
+	gtx.Metric.PxPerDp *= p.zoom
+	gtx.Metric.PxPerSp *= p.zoom
 	listUi := material.List(p.thm, p.list)
 
 	pageMargin := layout.Inset{
@@ -27,18 +116,40 @@ func (p *Page) Layout(gtx C, elements [][]Element) D {
 		Right: unit.Dp(5),
 		Top:   unit.Dp(10),
 	}
+	// the target line is first on the list so it's laid out and we get its height
+	target, targetHeight := p.target, 0
+	if target >= 0 {
+		p.list.ScrollTo(target)
+		p.target = -1
+	}
+	defer func() {
+		if target >= 0 {
+			// negative offset shows the lines before it
+			p.list.Position.Offset = int(p.targetFraction*float32(targetHeight) - p.targetAt*float32(gtx.Constraints.Max.Y))
+			gtx.Execute(op.InvalidateCmd{}) // show it at the offset on the next frame
+		}
+	}()
 	return pageMargin.Layout(gtx, func(gtx C) D {
 		return listUi.Layout(gtx, len(elements), func(gtx C, idx int) D {
-			line := elements[idx]
-			if len(line) == 1 {
-				return line[0].Layout(gtx)
-			} else {
-				return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx, elementsToFlexChildren(line)...)
+			if idx == target {
+				dims := p.layoutLine(gtx, elements[idx])
+				targetHeight = dims.Size.Y
+				return dims
 			}
+			return p.layoutLine(gtx, elements[idx])
 		})
 	})
 }
 
+// layoutLine lays the elements of a line out from left to right
+func (p *Page) layoutLine(gtx C, line []Element) D {
+	if len(line) == 1 {
+		return line[0].Layout(gtx)
+	} else {
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx, elementsToFlexChildren(line)...)
+	}
+}
+
 // elementsToFlexChildren wrap each element in elements with layout.Rigid and return
 func elementsToFlexChildren(elements []Element) []layout.FlexChild {
 	res := make([]layout.FlexChild, len(elements))
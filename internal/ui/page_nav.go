@@ -3,6 +3,7 @@ package ui
 import (
 	"log"
 
+	"gioui.org/io/key"
 	"gioui.org/layout"
 	"gioui.org/unit"
 	"gioui.org/widget"
@@ -11,13 +12,17 @@ import (
 )
 
 type PageNav struct {
-	thm            *material.Theme
-	backClickable  *widget.Clickable
-	forthClickable *widget.Clickable
+	thm             *material.Theme
+	backClickable   *widget.Clickable
+	forthClickable  *widget.Clickable
+	stopClickable   *widget.Clickable
+	reloadClickable *widget.Clickable
+	isLoading       bool // stop button takes the reload button's place while loading
 }
 
 func NewPageNav(thm *material.Theme) *PageNav {
-	return &PageNav{thm: thm, backClickable: new(widget.Clickable), forthClickable: new(widget.Clickable)}
+	return &PageNav{thm: thm, backClickable: new(widget.Clickable), forthClickable: new(widget.Clickable),
+		stopClickable: new(widget.Clickable), reloadClickable: new(widget.Clickable)}
 }
 
 func (pn PageNav) Layout(gtx C) D {
@@ -37,9 +42,31 @@ func (pn PageNav) Layout(gtx C) D {
 	forthButton.Size = unit.Dp(25)
 	forthButton.Inset = layout.UniformInset(unit.Dp(5))
 
+	buttons := []layout.FlexChild{Rigid(backButton),
+		Rigid(layout.Spacer{Width: unit.Dp(5)}), Rigid(forthButton)}
+
+	if pn.isLoading {
+		stopIcon, err := widget.NewIcon(icons.NavigationClose)
+		if err != nil {
+			log.Fatal("Couldn't create new stop icon")
+		}
+		stopButton := material.IconButton(pn.thm, pn.stopClickable, stopIcon, "Stop")
+		stopButton.Size = unit.Dp(25)
+		stopButton.Inset = layout.UniformInset(unit.Dp(5))
+		buttons = append(buttons, Rigid(layout.Spacer{Width: unit.Dp(5)}), Rigid(stopButton))
+	} else {
+		reloadIcon, err := widget.NewIcon(icons.NavigationRefresh)
+		if err != nil {
+			log.Fatal("Couldn't create new reload icon")
+		}
+		reloadButton := material.IconButton(pn.thm, pn.reloadClickable, reloadIcon, "Reload, Shift+click skips the caches")
+		reloadButton.Size = unit.Dp(25)
+		reloadButton.Inset = layout.UniformInset(unit.Dp(5))
+		buttons = append(buttons, Rigid(layout.Spacer{Width: unit.Dp(5)}), Rigid(reloadButton))
+	}
+
 	return layout.UniformInset(unit.Dp(5)).Layout(gtx, func(gtx C) D {
-		return layout.Flex{}.Layout(gtx, Rigid(backButton),
-			Rigid(layout.Spacer{Width: unit.Dp(5)}), Rigid(forthButton))
+		return layout.Flex{}.Layout(gtx, buttons...)
 	})
 }
 
@@ -50,3 +77,25 @@ func (pn PageNav) BackClicked(gtx C) bool {
 func (pn PageNav) ForthClicked(gtx C) bool {
 	return pn.forthClickable.Clicked(gtx)
 }
+
+func (pn PageNav) StopClicked(gtx C) bool {
+	return pn.stopClickable.Clicked(gtx)
+}
+
+// ReloadClicked tells if the reload button is clicked and if it's a hard reload (Shift+click)
+func (pn PageNav) ReloadClicked(gtx C) (bool, bool) {
+	clicked, hard := false, false
+	for {
+		click, ok := pn.reloadClickable.Update(gtx)
+		if !ok {
+			break
+		}
+		clicked, hard = true, click.Modifiers.Contain(key.ModShift)
+	}
+	return clicked, hard
+}
+
+// SetLoading tells the page nav whether the current tab is loading
+func (pn *PageNav) SetLoading(isLoading bool) {
+	pn.isLoading = isLoading
+}
@@ -1,14 +1,23 @@
 package ui
 
 import (
+	"image"
+	"image/color"
 	"log"
+	"strings"
+	"unicode/utf8"
 
+	"gioui.org/io/key"
 	"gioui.org/io/pointer"
 	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
 	"gioui.org/text"
 	"gioui.org/unit"
 	"gioui.org/widget"
 	"gioui.org/widget/material"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
 	"golang.org/x/exp/shiny/materialdesign/icons"
 )
 
@@ -18,28 +27,45 @@ type (
 )
 
 type SearchBar struct {
-	thm       *material.Theme
-	editor    *widget.Editor
-	clickable *widget.Clickable
+	thm    *material.Theme
+	editor *widget.Editor
+	state  *searchBarState
 }
 
-// match pair clickable with editor
-var searchButtonClickables = map[*widget.Editor]*widget.Clickable{}
+// searchBarState is what the search bar keeps between frames, the search bar itself is made every frame
+type searchBarState struct {
+	clickable   *widget.Clickable // search button
+	shown       string            // text we put in the editor, anything else is typed by the user
+	typed       string            // what the user typed, without the inline completion
+	grew        bool              // user typed more at the end, so it's fine to complete it
+	changed     bool              // typed has changed and waits for new suggestions
+	isOpen      bool              // suggestions dropdown is open
+	suggestions []engine.Suggestion
+	selected    int                // index of the selected suggestion, -1 means none
+	picked      *engine.Suggestion // suggestion used by the latest search
+	rows        []*widget.Clickable
+}
+
+// match pair search bar state with editor
+var searchBarStates = map[*widget.Editor]*searchBarState{}
+
+// MaxSuggestions is how many suggestions the dropdown shows at most
+const MaxSuggestions = 8
 
 func NewSearchBar(thm *material.Theme, editor *widget.Editor) *SearchBar {
-	clickable, ok := searchButtonClickables[editor]
+	state, ok := searchBarStates[editor]
 	if !ok {
-		clickable = new(widget.Clickable)
-		searchButtonClickables[editor] = clickable
+		state = &searchBarState{clickable: new(widget.Clickable), selected: -1}
+		searchBarStates[editor] = state
 	}
-	return &SearchBar{thm: thm, editor: editor, clickable: clickable}
+	return &SearchBar{thm: thm, editor: editor, state: state}
 }
 
 // TODO: make it longer than this
 func (s *SearchBar) Layout(gtx C) D {
 	// handle ui interaction
-	s.clickable.Update(gtx)
-	if s.clickable.Hovered() {
+	s.state.clickable.Update(gtx)
+	if s.state.clickable.Hovered() {
 		pointer.CursorPointer.Add(gtx.Ops)
 	}
 
@@ -72,16 +98,21 @@ func (s *SearchBar) Layout(gtx C) D {
 	if err != nil {
 		log.Fatal("Couldn't create new search icon")
 	}
-	searchButton := material.IconButton(s.thm, s.clickable, icon, "Search")
+	searchButton := material.IconButton(s.thm, s.state.clickable, icon, "Search")
 	searchButton.Size = unit.Dp(20)
 
 	// search bar
 	return margin.Layout(gtx, func(gtx C) D {
 		return layout.Flex{Axis: layout.Horizontal, Spacing: 2}.Layout(gtx,
 			layout.Flexed(1, func(gtx C) D {
-				return border.Layout(gtx, func(gtx C) D {
+				dims := border.Layout(gtx, func(gtx C) D {
 					return insideBorderMargin.Layout(gtx, srcInputUi.Layout)
 				})
+				// right below the editor
+				offset := op.Offset(image.Pt(0, dims.Size.Y)).Push(gtx.Ops)
+				s.layoutSuggestions(gtx, dims.Size.X)
+				offset.Pop()
+				return dims
 			}),
 			Rigid(layout.Spacer{Width: unit.Dp(5)}),
 			Rigid(searchButton),
@@ -89,9 +120,102 @@ func (s *SearchBar) Layout(gtx C) D {
 	})
 }
 
-// Searched updates the editor and clickable in search bar and
-// returns a bool whether user click or press "enter" to search
+// layoutSuggestions draws the suggestions dropdown on top of everything else, if it's open
+func (s *SearchBar) layoutSuggestions(gtx C, width int) {
+	st := s.state
+	if !st.isOpen || len(st.suggestions) == 0 {
+		return
+	}
+	for len(st.rows) < len(st.suggestions) {
+		st.rows = append(st.rows, new(widget.Clickable))
+	}
+	// TODO: use new theme system
+	panelBg := color.NRGBA{R: 245, G: 245, B: 245, A: 255}
+
+	macro := op.Record(gtx.Ops)
+	gtx.Constraints = layout.Constraints{Min: image.Pt(width, 0), Max: image.Pt(width, gtx.Constraints.Max.Y)}
+	border := widget.Border{Color: s.thm.Fg, CornerRadius: unit.Dp(2), Width: unit.Dp(1)}
+	border.Layout(gtx, func(gtx C) D {
+		return layout.Background{}.Layout(gtx,
+			func(gtx C) D {
+				defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+				paint.ColorOp{Color: panelBg}.Add(gtx.Ops)
+				paint.PaintOp{}.Add(gtx.Ops)
+				return D{Size: gtx.Constraints.Min}
+			},
+			func(gtx C) D {
+				rows := make([]layout.FlexChild, len(st.suggestions))
+				for i := range st.suggestions {
+					rows[i] = layout.Rigid(func(gtx C) D { return s.layoutSuggestion(gtx, i) })
+				}
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+			},
+		)
+	})
+	op.Defer(gtx.Ops, macro.Stop())
+}
+
+func (s *SearchBar) layoutSuggestion(gtx C, i int) D {
+	st := s.state
+	suggestion, row := st.suggestions[i], st.rows[i]
+	if row.Hovered() {
+		pointer.CursorPointer.Add(gtx.Ops)
+	}
+	selectedBg := color.NRGBA{R: 220, G: 225, B: 235, A: 255}
+
+	title, description := suggestion.Title, suggestion.Url
+	switch suggestion.Kind {
+	case engine.TabSuggestion:
+		description = "Switch to tab - " + suggestion.Url
+	case engine.SearchSuggestion:
+		description = "Search engine"
+	case engine.BookmarkSuggestion:
+		description = "Bookmark - " + suggestion.Url
+	}
+	if title == "" {
+		title = suggestion.Url
+	}
+
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	return row.Layout(gtx, func(gtx C) D {
+		return layout.Background{}.Layout(gtx,
+			func(gtx C) D {
+				if i != st.selected && !row.Hovered() {
+					return D{Size: gtx.Constraints.Min}
+				}
+				defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+				paint.ColorOp{Color: selectedBg}.Add(gtx.Ops)
+				paint.PaintOp{}.Add(gtx.Ops)
+				return D{Size: gtx.Constraints.Min}
+			},
+			func(gtx C) D {
+				margin := layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(10), Right: unit.Dp(10)}
+				return margin.Layout(gtx, func(gtx C) D {
+					titleUi := material.Body1(s.thm, title)
+					titleUi.MaxLines = 1
+					descriptionUi := material.Caption(s.thm, description)
+					descriptionUi.MaxLines = 1
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+						layout.Rigid(titleUi.Layout),
+						layout.Rigid(descriptionUi.Layout),
+					)
+				})
+			},
+		)
+	})
+}
+
+// Searched updates the editor, clickable and suggestions in search bar and
+// returns a bool whether user click, press "enter" or pick a suggestion to search
 func (s *SearchBar) Searched(gtx C) bool {
+	st := s.state
+	st.picked = nil
+	// arrows move in the dropdown, so take them before the editor does
+	if st.isOpen && gtx.Focused(s.editor) {
+		s.handleKeys(gtx)
+	}
+
+	searched := false
 	for {
 		editorEv, ok := s.editor.Update(gtx)
 		if !ok {
@@ -100,14 +224,132 @@ func (s *SearchBar) Searched(gtx C) bool {
 		switch editorEv.(type) {
 		// press "enter" search
 		case widget.SubmitEvent:
-			return true
-		default:
-			continue
+			searched = true
+		case widget.ChangeEvent:
+			s.textChanged()
 		}
 	}
 
 	// click search
-	return s.clickable.Clicked(gtx)
+	if st.clickable.Clicked(gtx) {
+		searched = true
+	}
+	// click suggestion
+	for i, row := range st.rows[:min(len(st.rows), len(st.suggestions))] {
+		if row.Clicked(gtx) {
+			s.selectSuggestion(i)
+			searched = true
+		}
+	}
+
+	if searched {
+		if st.selected >= 0 && st.selected < len(st.suggestions) {
+			st.picked = &st.suggestions[st.selected]
+		}
+		s.closeSuggestions()
+		return true
+	}
+	// user is doing something else
+	if !gtx.Focused(s.editor) {
+		st.isOpen = false
+	}
+	return false
+}
+
+func (s *SearchBar) handleKeys(gtx C) {
+	for {
+		ev, ok := gtx.Event(
+			key.Filter{Focus: s.editor, Name: key.NameUpArrow},
+			key.Filter{Focus: s.editor, Name: key.NameDownArrow},
+			key.Filter{Focus: s.editor, Name: key.NameEscape},
+		)
+		if !ok {
+			break
+		}
+		keyEv, ok := ev.(key.Event)
+		if !ok || keyEv.State != key.Press {
+			continue
+		}
+		switch keyEv.Name {
+		case key.NameUpArrow:
+			s.selectSuggestion(s.state.selected - 1)
+		case key.NameDownArrow:
+			s.selectSuggestion(s.state.selected + 1)
+		case key.NameEscape:
+			// back to what the user typed
+			s.setText(s.state.typed)
+			s.closeSuggestions()
+		}
+	}
+}
+
+// textChanged remembers what the user typed, if it's the user who changed the text
+func (s *SearchBar) textChanged() {
+	st := s.state
+	text := s.editor.Text()
+	if text == st.shown {
+		return
+	}
+	// only complete when typing at the end, not when deleting or editing in the middle
+	start, end := s.editor.Selection()
+	length := utf8.RuneCountInString(text)
+	st.grew = len(text) > len(st.typed) && strings.HasPrefix(text, st.typed) && start == length && end == length
+
+	st.typed, st.shown = text, text
+	st.changed, st.isOpen, st.selected = true, true, -1
+}
+
+// selectSuggestion selects the i-th suggestion and shows it in the editor, -1 goes back to what the user typed
+func (s *SearchBar) selectSuggestion(i int) {
+	st := s.state
+	st.selected = max(-1, min(i, len(st.suggestions)-1))
+	if st.selected == -1 || st.suggestions[st.selected].Kind == engine.SearchSuggestion {
+		s.setText(st.typed)
+		return
+	}
+	s.setText(st.suggestions[st.selected].Url)
+}
+
+func (s *SearchBar) closeSuggestions() {
+	s.state.isOpen, s.state.selected, s.state.suggestions = false, -1, nil
+}
+
+// setText puts the text in the editor with the caret at the end, without counting it as typed
+func (s *SearchBar) setText(txt string) {
+	s.editor.SetText(txt)
+	length := utf8.RuneCountInString(txt)
+	s.editor.SetCaret(length, length)
+	s.state.shown = txt
+}
+
+// Typed returns what the user has typed and true, if it has changed since the last call
+func (s *SearchBar) Typed() (string, bool) {
+	if !s.state.changed {
+		return "", false
+	}
+	s.state.changed = false
+	return s.state.typed, true
+}
+
+// SetSuggestions shows the suggestions for what the user typed, and completes the text inline
+// with the completion selected, so the next key press replaces it
+func (s *SearchBar) SetSuggestions(suggestions []engine.Suggestion, completion string) {
+	st := s.state
+	st.suggestions, st.selected = suggestions, -1
+	if !st.grew || len(completion) <= len(st.typed) || !strings.HasPrefix(completion, st.typed) || s.editor.Text() != st.typed {
+		return
+	}
+	s.editor.SetText(completion)
+	s.editor.SetCaret(utf8.RuneCountInString(completion), utf8.RuneCountInString(st.typed))
+	st.shown = completion
+}
+
+// Picked returns the suggestion the latest search was picked from and true, false if the user searched what's in the editor
+func (s SearchBar) Picked() (engine.Suggestion, bool) {
+	if s.state.picked == nil {
+		return engine.Suggestion{}, false
+	}
+	return *s.state.picked, true
 }
 
 // Text gets the text inside the search bar
@@ -115,9 +357,22 @@ func (s SearchBar) Text() string {
 	return s.editor.Text()
 }
 
-// SetText sets the text inside search bar
+// SetText sets the text inside search bar, it isn't what the user typed so nothing is suggested
 func (s SearchBar) SetText(txt string) {
 	s.editor.SetText(txt)
+	s.state.shown, s.state.typed = txt, txt
+	s.state.changed, s.state.isOpen = false, false
+}
+
+// Focus moves the keyboard to the search bar with the whole text selected, so typing replaces it
+func (s SearchBar) Focus(gtx C) {
+	gtx.Execute(key.FocusCmd{Tag: s.editor})
+	s.editor.SetCaret(s.editor.Len(), 0)
+}
+
+// Focused tells if the user is typing in the search bar
+func (s SearchBar) Focused(gtx C) bool {
+	return gtx.Focused(s.editor)
 }
 
 // SetupSearchEditor create a new widget.Editor used as
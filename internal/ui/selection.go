@@ -0,0 +1,328 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+)
+
+// TextLabel is a label's text on the page, in the order of the page
+type TextLabel struct {
+	Selectable *widget.Selectable
+	Text       string
+	Block      int // labels of the same paragraph, list item, etc. have the same one
+}
+
+// SelectionPoint is a place in the selected text: the label in the order of the page and a rune offset in it
+type SelectionPoint struct {
+	Label, Offset int
+}
+
+// Selection is the text the user selected on the page, it can go across many labels.
+// One per DomRenderer, labels share it through their LabelStyle like Highlights.
+// The labels' own selection isn't used: a drag would be stuck in one label.
+type Selection struct {
+	labels []TextLabel
+	index  map[*widget.Selectable]int
+	areas  map[*widget.Selectable]*labelArea
+
+	anchor, focus SelectionPoint // where it started and where it's at now
+	active        bool
+	dragging      bool
+
+	// the primary press on the page this frame, labels only take that one
+	press    pointer.Event
+	pressed  bool
+	claimed  bool
+	clicks   int // 2 selects the word, 3 the paragraph
+	lastTime time.Duration
+	lastPos  image.Point
+
+	regions []widget.Region // reused every frame
+}
+
+// labelArea is where a label gets the pointer, every label needs its own tag
+type labelArea struct {
+	selectable *widget.Selectable
+}
+
+var selectionColor = color.NRGBA{R: 170, G: 205, B: 255, A: 255}
+
+const (
+	multiClickTime = 400 * time.Millisecond
+	multiClickSlop = 4
+)
+
+func NewSelection() *Selection {
+	return &Selection{index: make(map[*widget.Selectable]int), areas: make(map[*widget.Selectable]*labelArea)}
+}
+
+// SetLabels tells the selection the page's labels, the old selection is gone
+func (s *Selection) SetLabels(labels []TextLabel) {
+	s.labels = labels
+	clear(s.index)
+	clear(s.areas)
+	for i, label := range labels {
+		s.index[label.Selectable] = i
+	}
+	s.Clear()
+}
+
+// Range returns where the selection starts and ends in the page's order, false if nothing is selected
+func (s *Selection) Range() (SelectionPoint, SelectionPoint, bool) {
+	start, end := s.anchor, s.focus
+	if end.Label < start.Label || (end.Label == start.Label && end.Offset < start.Offset) {
+		start, end = end, start
+	}
+	return start, end, s.active && start != end
+}
+
+// SelectAll selects all the text of the page
+func (s *Selection) SelectAll() {
+	if len(s.labels) == 0 {
+		return
+	}
+	last := len(s.labels) - 1
+	s.anchor, s.focus = SelectionPoint{}, SelectionPoint{Label: last, Offset: s.runeLen(last)}
+	s.active, s.dragging = true, false
+}
+
+func (s *Selection) Clear() {
+	s.anchor, s.focus, s.active, s.dragging = SelectionPoint{}, SelectionPoint{}, false, false
+}
+
+// Text returns the selected text of the labels, a new block starts a new line
+func (s *Selection) Text() string {
+	start, end, ok := s.Range()
+	if !ok {
+		return ""
+	}
+	var builder strings.Builder
+	for i := start.Label; i <= end.Label; i++ {
+		from, to := s.labelRange(i, start, end)
+		if i > start.Label && s.labels[i].Block != s.labels[i-1].Block {
+			builder.WriteByte('\n')
+		}
+		runes := []rune(s.labels[i].Text)
+		builder.WriteString(string(runes[from:to]))
+	}
+	return builder.String()
+}
+
+// Layout lays the page out under the selection. A press on the page that isn't on a text clears it.
+func (s *Selection) Layout(gtx C, w func(gtx C) D) D {
+	s.pressed, s.claimed = false, false
+	for {
+		ev, ok := gtx.Event(pointer.Filter{Target: s, Kinds: pointer.Press})
+		if !ok {
+			break
+		}
+		pointerEv, ok := ev.(pointer.Event)
+		if !ok || pointerEv.Buttons != pointer.ButtonPrimary {
+			continue
+		}
+		pos := pointerEv.Position.Round()
+		near := pos.Sub(s.lastPos)
+		if pointerEv.Time-s.lastTime < multiClickTime && max(near.X, -near.X, near.Y, -near.Y) <= multiClickSlop {
+			s.clicks++
+		} else {
+			s.clicks = 1
+		}
+		s.press, s.pressed, s.lastTime, s.lastPos = pointerEv, true, pointerEv.Time, pos
+		// the page has the keyboard now e.g. not the address bar, an input on it takes it back
+		gtx.Execute(key.FocusCmd{Tag: nil})
+	}
+
+	dims := func() D {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+		event.Op(gtx.Ops, s)
+		return w(gtx)
+	}()
+	if s.pressed && !s.claimed && s.press.Modifiers&key.ModShift == 0 {
+		s.Clear()
+	}
+	return dims
+}
+
+// update handles the pointer on a label. The label's text must be laid out, size is the text's.
+func (s *Selection) update(gtx C, selectable *widget.Selectable, size image.Point) {
+	idx, ok := s.index[selectable]
+	if !ok {
+		return
+	}
+	area := s.areas[selectable]
+	if area == nil {
+		area = &labelArea{selectable: selectable}
+		s.areas[selectable] = area
+	}
+	changed := false
+	for {
+		ev, ok := gtx.Event(pointer.Filter{Target: area, Kinds: pointer.Press | pointer.Drag | pointer.Release | pointer.Cancel})
+		if !ok {
+			break
+		}
+		pointerEv, ok := ev.(pointer.Event)
+		if !ok {
+			continue
+		}
+		inside := pointerEv.Position.Round().In(image.Rectangle{Max: size})
+		switch pointerEv.Kind {
+		case pointer.Press:
+			if !s.pressed || s.claimed || pointerEv.Time != s.press.Time || !inside {
+				continue // not this label's, or not on the page
+			}
+			s.claimed, changed = true, true
+			s.pressLabel(idx, s.offsetAt(selectable, pointerEv.Position.Round()))
+		case pointer.Drag:
+			if s.dragging && inside {
+				s.focus, changed = SelectionPoint{Label: idx, Offset: s.offsetAt(selectable, pointerEv.Position.Round())}, true
+			}
+		case pointer.Release, pointer.Cancel:
+			s.dragging = false
+		}
+	}
+	if changed {
+		gtx.Execute(op.InvalidateCmd{}) // labels before this one are painted already
+	}
+}
+
+// pressLabel starts the selection where the user pressed, or picks the word or the paragraph there
+func (s *Selection) pressLabel(idx, offset int) {
+	point := SelectionPoint{Label: idx, Offset: offset}
+	switch {
+	case s.press.Modifiers&key.ModShift != 0 && s.active:
+		s.focus = point
+	case s.clicks == 2:
+		start, end := engine.WordAt(s.labels[idx].Text, offset)
+		s.anchor, s.focus = SelectionPoint{Label: idx, Offset: start}, SelectionPoint{Label: idx, Offset: end}
+	case s.clicks >= 3:
+		first, last := idx, idx
+		for first > 0 && s.labels[first-1].Block == s.labels[idx].Block {
+			first--
+		}
+		for last < len(s.labels)-1 && s.labels[last+1].Block == s.labels[idx].Block {
+			last++
+		}
+		s.anchor, s.focus = SelectionPoint{Label: first}, SelectionPoint{Label: last, Offset: s.runeLen(last)}
+	default:
+		s.anchor, s.focus = point, point
+	}
+	s.active, s.dragging = true, s.clicks == 1
+}
+
+// paint paints the selected part of the label's text, the text must be laid out already
+func (s *Selection) paint(gtx C, selectable *widget.Selectable) {
+	idx, ok := s.index[selectable]
+	start, end, selected := s.Range()
+	if !ok || !selected || idx < start.Label || idx > end.Label {
+		return
+	}
+	from, to := s.labelRange(idx, start, end)
+	s.regions = selectable.Regions(from, to, s.regions[:0])
+	for _, region := range s.regions {
+		paint.FillShape(gtx.Ops, selectionColor, clip.Rect(region.Bounds).Op())
+	}
+}
+
+// cover takes the pointer of the label from its text, and gets the drags going past it.
+// isText shows the text cursor, a link shows its own.
+func (s *Selection) cover(gtx C, selectable *widget.Selectable, size image.Point, isText bool) {
+	area, ok := s.areas[selectable]
+	if !ok {
+		return
+	}
+	textArea := clip.Rect{Max: size}.Push(gtx.Ops)
+	if isText {
+		pointer.CursorText.Add(gtx.Ops)
+	}
+	event.Op(gtx.Ops, area)
+	textArea.Pop()
+
+	// a press anywhere gets to every label on screen, so while dragging each one knows if the pointer is on it
+	macro := op.Record(gtx.Ops)
+	pass := pointer.PassOp{}.Push(gtx.Ops)
+	everywhere := clip.Rect{Min: image.Pt(-1<<20, -1<<20), Max: image.Pt(1<<20, 1<<20)}.Push(gtx.Ops)
+	event.Op(gtx.Ops, area)
+	everywhere.Pop()
+	pass.Pop()
+	op.Defer(gtx.Ops, macro.Stop())
+}
+
+// offsetAt returns the rune offset of the label's text closest to pos
+func (s *Selection) offsetAt(selectable *widget.Selectable, pos image.Point) int {
+	count := utf8.RuneCountInString(selectable.Text())
+	offset := 0
+	for i := range count {
+		s.regions = selectable.Regions(i, i+1, s.regions[:0])
+		if len(s.regions) == 0 {
+			continue
+		}
+		bounds := s.regions[0].Bounds
+		switch {
+		case pos.Y >= bounds.Max.Y:
+			offset = i + 1 // a line above the pointer
+		case pos.Y < bounds.Min.Y:
+			return offset // past the pointer's line
+		case pos.X < (bounds.Min.X+bounds.Max.X)/2:
+			return i
+		default:
+			offset = i + 1
+		}
+	}
+	return offset
+}
+
+// labelRange returns which runes of the label idx are in the selection from start to end
+func (s *Selection) labelRange(idx int, start, end SelectionPoint) (int, int) {
+	from, to := 0, s.runeLen(idx)
+	if idx == start.Label {
+		from = min(start.Offset, to)
+	}
+	if idx == end.Label {
+		to = min(end.Offset, to)
+	}
+	return from, max(from, to)
+}
+
+func (s *Selection) runeLen(idx int) int {
+	return utf8.RuneCountInString(s.labels[idx].Text)
+}
+
+// TextLabels returns the texts of the element (and of the elements inside it) in order, Block isn't set
+func TextLabels(element Element) []TextLabel {
+	var res []TextLabel
+	var walk func(lines [][]Element)
+	walk = func(lines [][]Element) {
+		for _, line := range lines {
+			for _, element := range line {
+				switch element := element.(type) {
+				case Label:
+					if element.style.State != nil {
+						res = append(res, TextLabel{Selectable: element.style.State, Text: element.style.Text})
+					}
+				case Div:
+					walk(element.children)
+				case Table:
+					for _, row := range element.rows {
+						for _, cell := range row {
+							walk(cell.Children)
+						}
+					}
+				}
+			}
+		}
+	}
+	walk([][]Element{{element}})
+	return res
+}
@@ -0,0 +1,25 @@
+package ui
+
+import (
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// StatusText is a small line of text telling the user what's going on e.g. loading status
+type StatusText struct {
+	thm  *material.Theme
+	text string
+}
+
+func NewStatusText(thm *material.Theme, text string) StatusText {
+	return StatusText{thm: thm, text: text}
+}
+
+func (s StatusText) Layout(gtx C) D {
+	if s.text == "" {
+		return D{}
+	}
+	margin := layout.Inset{Top: unit.Dp(2), Bottom: unit.Dp(2), Left: unit.Dp(10)}
+	return margin.Layout(gtx, material.Caption(s.thm, s.text).Layout)
+}
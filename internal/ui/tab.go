@@ -5,10 +5,14 @@ import (
 	"image"
 	"image/color"
 	"log"
+	"math"
 	"net/http"
 	urlPkg "net/url"
 	"strings"
+	"time"
 
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/clip"
@@ -22,11 +26,17 @@ import (
 	_ "github.com/mat/besticon/ico"
 )
 
+// Tabs is the tab bar, it renders tabs in the order of the engine state.
+// ui data of each tab is kept by the tab's engine id.
+// Tabs shrink when they don't fit, then the bar scrolls sideways.
 type Tabs struct {
-	Tabs     []*Tab
+	views    map[engine.TabID]*Tab
 	addTab   *widget.Clickable
+	reopen   *widget.Clickable // reopens the latest closed tab
+	search   *TabSearch
+	list     *layout.List
+	selected engine.TabID // selected tab last frame, the bar scrolls to a newly selected one
 	thm      *Theme
-	Selected int // idx of selected tab
 }
 
 type Tab struct {
@@ -36,18 +46,44 @@ type Tab struct {
 	Title          string
 	// map url to fetched favicon (cache)
 	favIcons map[string]image.Image
+	zoom     int // index in pageZoomLevels, from the 100% one
+
+	// the pointer on the tab, for dragging it, its menu and its tooltip
+	pressed, dragging bool
+	pressX, dragX     float32 // where it's pressed and how far it's dragged, in pixels
+	slotWidth         int     // width of the tab with its margin, last frame
+	moved             int     // how many places the finished drag moves it, until TabMoved reads it
+	hasMoved          bool
+	rightClicked      bool
+	hovered           bool
+	hoveredAt         time.Time
 }
 
+const (
+	maxTabWidth    = unit.Dp(220)
+	minTabWidth    = unit.Dp(90)
+	pinnedTabWidth = unit.Dp(48) // only the favicon
+	tabSpacing     = unit.Dp(3)  // between the tabs
+	// the pointer stays on a tab this long to show its tooltip
+	tooltipDelay = 600 * time.Millisecond
+	// the pointer goes this far with the button down to drag a tab, not click it
+	dragSlop = unit.Dp(4)
+)
+
+var tooltipBg = color.NRGBA{R: 250, G: 250, B: 250, A: 255}
+
+// page zoom levels, like other browsers have
+var pageZoomLevels = []float32{0.5, 0.67, 0.8, 0.9, 1, 1.1, 1.25, 1.5, 1.75, 2, 2.5, 3}
+
+// index of 100% in pageZoomLevels
+const defaultZoom = 4
+
 func NewTabs(thm *Theme) *Tabs {
-	firstTab := newTab()
-	tabs := []*Tab{firstTab}
-	addTab := new(widget.Clickable)
-	res := &Tabs{Tabs: tabs, addTab: addTab, thm: thm}
-	res.Select(0)
-	return res
+	return &Tabs{views: make(map[engine.TabID]*Tab), addTab: new(widget.Clickable), reopen: new(widget.Clickable),
+		search: NewTabSearch(thm), list: &layout.List{Axis: layout.Horizontal, Alignment: layout.Middle}, thm: thm}
 }
 
-func (t Tabs) Layout(gtx C, stateTabs []*engine.Tab) D {
+func (t *Tabs) Layout(gtx C, snapshot engine.Snapshot) D {
 	// TODO: use new theme system
 	tabsBarBg := color.NRGBA{R: 240, G: 240, B: 240, A: 255}
 	tabsMargin := layout.Inset{
@@ -61,18 +97,6 @@ func (t Tabs) Layout(gtx C, stateTabs []*engine.Tab) D {
 		Alignment: layout.Middle,
 	}
 
-	flexChildren := make([]layout.FlexChild, len(t.Tabs)+1)
-	for i, tab := range t.Tabs {
-		flexChildren[i] = layout.Rigid(func(gtx C) D {
-			isSelected := i == t.Selected
-			var url string
-			if i < len(stateTabs) {
-				url = stateTabs[i].Url
-			}
-			return tab.Layout(t.thm, gtx, isSelected, url)
-		})
-	}
-
 	plusIcon, err := widget.NewIcon(icons.ContentAdd)
 	if err != nil {
 		log.Fatalf("Couldn't get icon: %v", err)
@@ -85,7 +109,28 @@ func (t Tabs) Layout(gtx C, stateTabs []*engine.Tab) D {
 		Left:   10,
 		Right:  10,
 	}
-	flexChildren[len(flexChildren)-1] = Rigid(newTabButton)
+	// the tabs take what the buttons leave
+	var stripWidths []int
+	flexChildren := []layout.FlexChild{
+		layout.Flexed(1, func(gtx C) D {
+			dims, widths := t.layoutStrip(gtx, snapshot)
+			stripWidths = widths
+			return dims
+		}),
+		Rigid(newTabButton),
+	}
+
+	// only when there is something to reopen
+	if snapshot.Closed > 0 {
+		undoIcon, err := widget.NewIcon(icons.ContentUndo)
+		if err != nil {
+			log.Fatalf("Couldn't get icon: %v", err)
+		}
+		reopenButton := material.IconButton(t.thm, t.reopen, undoIcon, "Reopen closed tab")
+		reopenButton.Size = newTabButton.Size
+		reopenButton.Inset = newTabButton.Inset
+		flexChildren = append(flexChildren, Rigid(reopenButton))
+	}
 	return layout.Background{}.Layout(gtx,
 		func(gtx C) D {
 			// expand horizontal
@@ -98,70 +143,258 @@ func (t Tabs) Layout(gtx C, stateTabs []*engine.Tab) D {
 		},
 		func(gtx C) D {
 			return tabsMargin.Layout(gtx, func(gtx C) D {
-				return flex.Layout(gtx, flexChildren...)
+				dims := layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, func(gtx C) D { return flex.Layout(gtx, flexChildren...) }),
+					layout.Rigid(func(gtx C) D { return t.search.Layout(gtx, snapshot) }),
+				)
+				t.layoutTooltip(gtx, snapshot, stripWidths, dims.Size.Y)
+				return dims
 			})
 		},
 	)
 }
 
-// AddTab adds a new tab to the Tabs (no select happen)
-func (t *Tabs) AddTab() {
-	t.Tabs = append(t.Tabs, newTab())
+// layoutStrip lays the tabs out in a row as wide as they fit, and returns the width of each one
+func (t *Tabs) layoutStrip(gtx C, snapshot engine.Snapshot) (D, []int) {
+	pinned := 0
+	for _, stateTab := range snapshot.Tabs {
+		if stateTab.Pinned {
+			pinned++
+		}
+	}
+	pinnedWidth := gtx.Dp(pinnedTabWidth)
+	width := gtx.Dp(maxTabWidth)
+	if unpinned := len(snapshot.Tabs) - pinned; unpinned > 0 {
+		width = min(width, max((gtx.Constraints.Max.X-pinned*pinnedWidth)/unpinned, gtx.Dp(minTabWidth)))
+	}
+	widths := make([]int, len(snapshot.Tabs))
+	for i, stateTab := range snapshot.Tabs {
+		widths[i] = width
+		if stateTab.Pinned {
+			widths[i] = pinnedWidth
+		}
+	}
+
+	// bring a newly selected tab into sight
+	if snapshot.Selected != t.selected {
+		t.selected = snapshot.Selected
+		idx := snapshot.TabIndex(snapshot.Selected)
+		pos := t.list.Position
+		if idx != -1 && (idx < pos.First || idx >= pos.First+pos.Count-1) {
+			t.list.ScrollTo(max(0, idx-gtx.Constraints.Max.X/width+1))
+		}
+	}
+
+	dims := t.list.Layout(gtx, len(snapshot.Tabs), func(gtx C, i int) D {
+		stateTab := snapshot.Tabs[i]
+		tab := t.View(stateTab.ID)
+		isSelected := stateTab.ID == snapshot.Selected
+		return tab.Layout(t.thm, gtx, isSelected, stateTab.Url, stateTab.Pinned, widths[i])
+	})
+	return dims, widths
 }
 
-func (t *Tabs) Select(idx int) bool {
-	if idx >= len(t.Tabs) {
-		return false
+// layoutTooltip shows the full title and url of the tab the pointer stays on, under the bar
+func (t *Tabs) layoutTooltip(gtx C, snapshot engine.Snapshot, widths []int, barHeight int) {
+	pos := t.list.Position
+	x := -pos.Offset
+	for i := pos.First; i < len(snapshot.Tabs) && i < len(widths); i++ {
+		stateTab := snapshot.Tabs[i]
+		tab := t.View(stateTab.ID)
+		if !tab.hovered || tab.pressed {
+			x += widths[i]
+			continue
+		}
+		if showAt := tab.hoveredAt.Add(tooltipDelay); gtx.Now.Before(showAt) {
+			gtx.Execute(op.InvalidateCmd{At: showAt})
+			return
+		}
+
+		title := tab.Title
+		if title == "" {
+			title = engine.PageTitle(stateTab.Dom.Root)
+		}
+		macro := op.Record(gtx.Ops)
+		tooltipGtx := gtx
+		tooltipGtx.Constraints.Min = image.Point{}
+		tooltipGtx.Constraints.Max.X = min(gtx.Dp(unit.Dp(400)), gtx.Constraints.Max.X)
+		dims := layoutTooltip(tooltipGtx, t.thm, title, stateTab.Url)
+		tooltip := macro.Stop()
+		// under the tab, in the window
+		at := image.Point{X: max(0, min(x, gtx.Constraints.Max.X-dims.Size.X)), Y: barHeight + gtx.Dp(unit.Dp(4))}
+		defer op.Offset(at).Push(gtx.Ops).Pop()
+		op.Defer(gtx.Ops, tooltip)
+		return
 	}
+}
 
-	t.Selected = idx
-	return true
+func layoutTooltip(gtx C, thm *Theme, title, url string) D {
+	border := widget.Border{Color: thm.Fg, CornerRadius: unit.Dp(2), Width: unit.Dp(1)}
+	return border.Layout(gtx, func(gtx C) D {
+		return layout.Background{}.Layout(gtx,
+			func(gtx C) D {
+				defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+				paint.ColorOp{Color: tooltipBg}.Add(gtx.Ops)
+				paint.PaintOp{}.Add(gtx.Ops)
+				return D{Size: gtx.Constraints.Min}
+			},
+			func(gtx C) D {
+				inset := layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8), Right: unit.Dp(8)}
+				return inset.Layout(gtx, func(gtx C) D {
+					titleLabel := material.Body2(thm, title)
+					titleLabel.MaxLines = 2
+					urlLabel := material.Caption(thm, url)
+					urlLabel.MaxLines = 1
+					children := []layout.FlexChild{layout.Rigid(urlLabel.Layout)}
+					if title != "" {
+						children = append([]layout.FlexChild{layout.Rigid(titleLabel.Layout)}, children...)
+					}
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+				})
+			},
+		)
+	})
 }
 
-func (t Tabs) SelectedTab() *Tab {
-	return t.Tabs[t.Selected]
+// View returns the ui data of the tab with the id, create a new one if not exists yet
+func (t *Tabs) View(id engine.TabID) *Tab {
+	tab, ok := t.views[id]
+	if !ok {
+		tab = newTab()
+		t.views[id] = tab
+	}
+	return tab
+}
+
+// Remove forgets the ui data of the tab with the id
+func (t *Tabs) Remove(id engine.TabID) {
+	delete(t.views, id)
 }
 
 func (t Tabs) AddTabClicked(gtx C) bool {
 	return t.addTab.Clicked(gtx)
 }
 
-// TabClicked return index of the clicked tab if exist, otherwise returns -1
-func (t Tabs) TabClicked(gtx C) int {
-	for idx, tab := range t.Tabs {
+func (t Tabs) ReopenClicked(gtx C) bool {
+	return t.reopen.Clicked(gtx)
+}
+
+// TabClicked return id of the clicked tab and true if exist
+func (t Tabs) TabClicked(gtx C) (engine.TabID, bool) {
+	for id, tab := range t.views {
 		if tab.clickable.Clicked(gtx) {
-			return idx
+			return id, true
 		}
 	}
-	return -1
+	return 0, false
 }
 
-// DeleteTab... yeah you guess
-func (t *Tabs) DeleteTab(idx int) {
-	t.Tabs = append(t.Tabs[:idx], t.Tabs[idx+1:]...)
-	// deal with selected idx shifting
-	if idx <= t.Selected {
-		if t.Selected == 0 {
-			t.Selected++
-		} else {
-			t.Selected--
+// TabClosed returns id of the tab that got "close tab" clicked and true if exist
+func (t Tabs) TabClosed(gtx C) (engine.TabID, bool) {
+	for id, tab := range t.views {
+		if tab.closeClickable.Clicked(gtx) {
+			return id, true
 		}
 	}
+	return 0, false
 }
 
-// TabClosed returns index of the tab that got "close tab" clicked if exist, otherwise returns -1
-func (t Tabs) TabClosed(gtx C) int {
-	for idx, tab := range t.Tabs {
-		if tab.closeClickable.Clicked(gtx) {
-			return idx
+// TabMoved returns the tab the user dropped somewhere else in the bar and its new display index
+func (t Tabs) TabMoved(gtx C, snapshot engine.Snapshot) (engine.TabID, int, bool) {
+	for id, tab := range t.views {
+		tab.update(gtx)
+		if tab.hasMoved {
+			tab.hasMoved = false
+			if idx := snapshot.TabIndex(id); idx != -1 && tab.moved != 0 {
+				return id, max(0, idx+tab.moved), true
+			}
 		}
 	}
-	return -1
+	return 0, 0, false
 }
 
-func (t *Tab) Layout(thm *Theme, gtx C, isSelected bool, url string) D {
+// TabRightClicked returns the tab the user right-clicked, for its menu
+func (t Tabs) TabRightClicked(gtx C) (engine.TabID, bool) {
+	for id, tab := range t.views {
+		tab.update(gtx)
+		if tab.rightClicked {
+			tab.rightClicked = false
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// SearchPicked returns the tab the user picked in the tab search
+func (t Tabs) SearchPicked(gtx C) (engine.TabID, bool) {
+	return t.search.Picked(gtx)
+}
+
+// OpenSearch opens the tab search with the keyboard in it
+func (t Tabs) OpenSearch(gtx C) {
+	t.search.Open(gtx)
+}
+
+// SearchFocused tells if the user is typing in the tab search
+func (t Tabs) SearchFocused(gtx C) bool {
+	return t.search.Focused(gtx)
+}
+
+// update follows the pointer on the tab: dragging it, right-clicking it and staying on it
+func (t *Tab) update(gtx C) {
+	for {
+		ev, ok := gtx.Event(pointer.Filter{
+			Target: t,
+			Kinds:  pointer.Press | pointer.Drag | pointer.Release | pointer.Cancel | pointer.Enter | pointer.Leave,
+		})
+		if !ok {
+			break
+		}
+		pointerEv, ok := ev.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch pointerEv.Kind {
+		case pointer.Enter:
+			if !t.hovered {
+				t.hovered, t.hoveredAt = true, gtx.Now
+			}
+		case pointer.Leave:
+			t.hovered = false
+		case pointer.Press:
+			t.hoveredAt = gtx.Now // no tooltip over what the user is doing
+			switch pointerEv.Buttons {
+			case pointer.ButtonPrimary:
+				t.pressed, t.pressX, t.dragX = true, pointerEv.Position.X, 0
+			case pointer.ButtonSecondary:
+				t.rightClicked = true
+			}
+		case pointer.Drag:
+			if !t.pressed {
+				continue
+			}
+			t.dragX = pointerEv.Position.X - t.pressX
+			if !t.dragging && (t.dragX > float32(gtx.Dp(dragSlop)) || -t.dragX > float32(gtx.Dp(dragSlop))) {
+				// it's ours now, the tab isn't clicked
+				t.dragging = true
+				gtx.Execute(pointer.GrabCmd{Tag: t, ID: pointerEv.PointerID})
+			}
+		case pointer.Release, pointer.Cancel:
+			if t.dragging && pointerEv.Kind == pointer.Release && t.slotWidth > 0 {
+				// over the middle of a neighbor takes its place
+				t.moved, t.hasMoved = int(math.Round(float64(t.dragX)/float64(t.slotWidth))), true
+			}
+			t.pressed, t.dragging, t.dragX = false, false, 0
+		}
+	}
+}
+
+// Layout lays the tab out as wide as width, with its margin. Pinned tab only has its favicon.
+func (t *Tab) Layout(thm *Theme, gtx C, isSelected bool, url string, pinned bool, width int) D {
+	t.update(gtx)
+	t.slotWidth = width
 	tabMargin := layout.Inset{
-		Right: unit.Dp(3),
+		Right: tabSpacing,
 	}
 
 	title := t.Title
@@ -183,32 +416,44 @@ func (t *Tab) Layout(thm *Theme, gtx C, isSelected bool, url string) D {
 		favicon = defaultFavIcon
 	}
 
-	return tabMargin.Layout(gtx, func(gtx C) D {
+	contentInset := layout.Inset{
+		Top: unit.Dp(8), Bottom: unit.Dp(8),
+		Left: unit.Dp(15), Right: unit.Dp(15),
+	}
+	if pinned {
+		contentInset.Left, contentInset.Right = unit.Dp(8), unit.Dp(8)
+	}
+
+	gtx.Constraints.Min.X, gtx.Constraints.Max.X = width, width
+	slotMacro := op.Record(gtx.Ops)
+	dims := tabMargin.Layout(gtx, func(gtx C) D {
 		return t.clickable.Layout(gtx, func(gtx C) D {
 			// check the tab content size first
 			macro := op.Record(gtx.Ops)
-			tabContentDim := layout.Inset{
-				Top: unit.Dp(8), Bottom: unit.Dp(8),
-				Left: unit.Dp(15), Right: unit.Dp(15),
-			}.Layout(gtx, func(gtx C) D {
+			tabContentDim := contentInset.Layout(gtx, func(gtx C) D {
+				faviconChild := layout.Rigid(func(gtx C) D {
+					if favicon == nil {
+						return D{}
+					}
+					gtx.Constraints.Max = image.Point{X: gtx.Dp(16), Y: gtx.Dp(16)}
+					img := widget.Image{
+						Src: paint.NewImageOp(favicon),
+						Fit: widget.Contain,
+					}
+					return img.Layout(gtx)
+				})
+				if pinned {
+					return layout.Flex{Alignment: layout.Middle, Spacing: layout.SpaceSides}.Layout(gtx, faviconChild)
+				}
 				return layout.Flex{
 					Axis:      layout.Horizontal,
 					Alignment: layout.Middle,
 				}.Layout(gtx,
-					layout.Rigid(func(gtx C) D {
-						if favicon == nil {
-							return D{}
-						}
-						gtx.Constraints.Max = image.Point{X: gtx.Dp(16), Y: gtx.Dp(16)}
-						img := widget.Image{
-							Src: paint.NewImageOp(favicon),
-							Fit: widget.Contain,
-						}
-						return img.Layout(gtx)
-					}),
+					faviconChild,
 					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
-					layout.Rigid(func(gtx C) D {
+					layout.Flexed(1, func(gtx C) D {
 						label := material.Body1(thm, title)
+						label.MaxLines = 1
 						if isSelected {
 							label.Color = thm.Bg
 						}
@@ -245,6 +490,19 @@ func (t *Tab) Layout(thm *Theme, gtx C, isSelected bool, url string) D {
 			return tabContentDim
 		})
 	})
+	content := slotMacro.Stop()
+
+	// the area stays where the tab is, the tab itself follows the pointer over the others while it's dragged
+	defer clip.Rect{Max: dims.Size}.Push(gtx.Ops).Pop()
+	event.Op(gtx.Ops, t)
+	if t.dragging {
+		offset := op.Offset(image.Point{X: int(t.dragX)}).Push(gtx.Ops)
+		op.Defer(gtx.Ops, content)
+		offset.Pop()
+	} else {
+		content.Add(gtx.Ops)
+	}
+	return dims
 }
 
 // getFavIcon fetch favicon.ico from the raw string address then decode and return it in image.Image
@@ -289,5 +547,23 @@ func newTab() *Tab {
 		clickable:      clickable,
 		closeClickable: closeClickable,
 		SearchEditor:   searchEditor,
-		favIcons:       make(map[string]image.Image)}
+		favIcons:       make(map[string]image.Image),
+		zoom:           defaultZoom}
+}
+
+// Zoom is how much the tab's page is scaled, 1 is 100%
+func (t *Tab) Zoom() float32 {
+	return pageZoomLevels[t.zoom]
+}
+
+func (t *Tab) ZoomIn() {
+	t.zoom = min(t.zoom+1, len(pageZoomLevels)-1)
+}
+
+func (t *Tab) ZoomOut() {
+	t.zoom = max(t.zoom-1, 0)
+}
+
+func (t *Tab) ResetZoom() {
+	t.zoom = defaultZoom
 }
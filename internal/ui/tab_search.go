@@ -0,0 +1,229 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+	"log"
+
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/WaronLimsakul/Gazer/internal/engine"
+	"golang.org/x/exp/shiny/materialdesign/icons"
+)
+
+// TabSearch is the button at the end of the tab bar with a dropdown of the open tabs,
+// typing narrows them down by title or url. Enter goes to the first one, Escape closes it.
+type TabSearch struct {
+	thm     *Theme
+	button  *widget.Clickable
+	editor  *widget.Editor
+	list    *widget.List
+	rows    map[engine.TabID]*widget.Clickable
+	matches []engine.TabSnapshot // shown in the dropdown last frame
+	isOpen  bool
+	focused bool // the keyboard has got to the editor since it's opened
+}
+
+const tabSearchWidth = unit.Dp(360)
+
+func NewTabSearch(thm *Theme) *TabSearch {
+	editor := new(widget.Editor)
+	editor.SingleLine, editor.Submit = true, true
+	return &TabSearch{thm: thm, button: new(widget.Clickable), editor: editor,
+		list: &widget.List{List: layout.List{Axis: layout.Vertical}}, rows: make(map[engine.TabID]*widget.Clickable)}
+}
+
+// Open shows the dropdown with the keyboard in it, it starts with all the tabs
+func (s *TabSearch) Open(gtx C) {
+	s.isOpen, s.focused = true, false
+	s.editor.SetText("")
+	s.list.Position = layout.Position{}
+	gtx.Execute(key.FocusCmd{Tag: s.editor})
+}
+
+func (s *TabSearch) Close() {
+	s.isOpen = false
+}
+
+func (s *TabSearch) IsOpen() bool {
+	return s.isOpen
+}
+
+// Focused tells if the user is typing in the tab search
+func (s *TabSearch) Focused(gtx C) bool {
+	return gtx.Focused(s.editor)
+}
+
+// Picked handles the button and the dropdown, it returns the tab the user picked
+func (s *TabSearch) Picked(gtx C) (engine.TabID, bool) {
+	if s.button.Clicked(gtx) {
+		if s.isOpen {
+			s.Close()
+		} else {
+			s.Open(gtx)
+		}
+	}
+	if !s.isOpen {
+		return 0, false
+	}
+
+	for {
+		ev, ok := s.editor.Update(gtx)
+		if !ok {
+			break
+		}
+		if _, ok := ev.(widget.SubmitEvent); ok && len(s.matches) > 0 {
+			s.Close()
+			return s.matches[0].ID, true
+		}
+	}
+	for {
+		ev, ok := gtx.Event(key.Filter{Focus: s.editor, Name: key.NameEscape})
+		if !ok {
+			break
+		}
+		if keyEv, ok := ev.(key.Event); ok && keyEv.State == key.Press {
+			s.Close()
+			return 0, false
+		}
+	}
+	for _, match := range s.matches {
+		if row, ok := s.rows[match.ID]; ok && row.Clicked(gtx) {
+			s.Close()
+			return match.ID, true
+		}
+	}
+
+	// user is doing something else
+	if gtx.Focused(s.editor) {
+		s.focused = true
+	} else if s.focused {
+		s.Close()
+	}
+	return 0, false
+}
+
+// Layout lays the button out, the dropdown goes under it over everything else
+func (s *TabSearch) Layout(gtx C, snapshot engine.Snapshot) D {
+	icon, err := widget.NewIcon(icons.NavigationExpandMore)
+	if err != nil {
+		log.Fatalf("Couldn't get icon: %v", err)
+	}
+	button := material.IconButton(s.thm, s.button, icon, "Search tabs")
+	button.Size = unit.Dp(15)
+	button.Inset = layout.UniformInset(unit.Dp(10))
+	dims := button.Layout(gtx)
+	if !s.isOpen {
+		return dims
+	}
+
+	s.matches = snapshot.FindTabs(s.editor.Text())
+	for _, tab := range snapshot.Tabs {
+		if _, ok := s.rows[tab.ID]; !ok {
+			s.rows[tab.ID] = new(widget.Clickable)
+		}
+	}
+	for id := range s.rows {
+		if snapshot.TabIndex(id) == -1 {
+			delete(s.rows, id) // closed
+		}
+	}
+
+	macro := op.Record(gtx.Ops)
+	dropdownGtx := gtx
+	width := gtx.Dp(tabSearchWidth)
+	dropdownGtx.Constraints = layout.Constraints{
+		Min: image.Point{X: width},
+		Max: image.Point{X: width, Y: gtx.Dp(unit.Dp(420))},
+	}
+	s.layoutDropdown(dropdownGtx, snapshot.Selected)
+	dropdown := macro.Stop()
+	// its right edge is the button's
+	defer op.Offset(image.Point{X: dims.Size.X - width, Y: dims.Size.Y}).Push(gtx.Ops).Pop()
+	op.Defer(gtx.Ops, dropdown)
+	return dims
+}
+
+func (s *TabSearch) layoutDropdown(gtx C, selected engine.TabID) D {
+	// TODO: use new theme system
+	panelBg := color.NRGBA{R: 245, G: 245, B: 245, A: 255}
+	border := widget.Border{Color: s.thm.Fg, CornerRadius: unit.Dp(2), Width: unit.Dp(1)}
+	return border.Layout(gtx, func(gtx C) D {
+		return layout.Background{}.Layout(gtx,
+			func(gtx C) D {
+				defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+				paint.ColorOp{Color: panelBg}.Add(gtx.Ops)
+				paint.PaintOp{}.Add(gtx.Ops)
+				return D{Size: gtx.Constraints.Min}
+			},
+			func(gtx C) D {
+				editor := material.Editor(s.thm, s.editor, "Search tabs")
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						return layout.UniformInset(unit.Dp(8)).Layout(gtx, editor.Layout)
+					}),
+					layout.Rigid(func(gtx C) D {
+						if len(s.matches) == 0 {
+							inset := layout.Inset{Left: unit.Dp(10), Bottom: unit.Dp(8)}
+							return inset.Layout(gtx, material.Body2(s.thm, "No tabs found").Layout)
+						}
+						return material.List(s.thm, s.list).Layout(gtx, len(s.matches), func(gtx C, i int) D {
+							return s.layoutRow(gtx, s.matches[i], s.matches[i].ID == selected)
+						})
+					}),
+				)
+			},
+		)
+	})
+}
+
+func (s *TabSearch) layoutRow(gtx C, tab engine.TabSnapshot, isSelected bool) D {
+	row := s.rows[tab.ID]
+	if row.Hovered() {
+		pointer.CursorPointer.Add(gtx.Ops)
+	}
+	highlightBg := color.NRGBA{R: 220, G: 225, B: 235, A: 255}
+
+	title := engine.PageTitle(tab.Dom.Root)
+	if title == "" {
+		title = tab.Url
+	}
+	if title == "" {
+		title = "New Tab"
+	}
+
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	return row.Layout(gtx, func(gtx C) D {
+		return layout.Background{}.Layout(gtx,
+			func(gtx C) D {
+				if !isSelected && !row.Hovered() {
+					return D{Size: gtx.Constraints.Min}
+				}
+				defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+				paint.ColorOp{Color: highlightBg}.Add(gtx.Ops)
+				paint.PaintOp{}.Add(gtx.Ops)
+				return D{Size: gtx.Constraints.Min}
+			},
+			func(gtx C) D {
+				margin := layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(10), Right: unit.Dp(10)}
+				return margin.Layout(gtx, func(gtx C) D {
+					titleUi := material.Body1(s.thm, title)
+					titleUi.MaxLines = 1
+					urlUi := material.Caption(s.thm, tab.Url)
+					urlUi.MaxLines = 1
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+						layout.Rigid(titleUi.Layout),
+						layout.Rigid(urlUi.Layout),
+					)
+				})
+			},
+		)
+	})
+}
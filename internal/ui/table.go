@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// space around the content of each cell
+const cellPadding = unit.Dp(6)
+
+// TableCell is one <td> or <th>, header cell has a darker background
+type TableCell struct {
+	Children ContainerChildren
+	Header   bool
+}
+
+// A table component, used for rendering <table>.
+// Columns are as wide as their widest cell, rows as tall as their tallest cell.
+type Table struct {
+	thm  *material.Theme
+	rows [][]TableCell
+}
+
+func NewTable(thm *material.Theme, rows [][]TableCell) Table {
+	return Table{thm: thm, rows: rows}
+}
+
+func (t Table) Layout(gtx C) D {
+	if len(t.rows) == 0 {
+		return D{}
+	}
+	padding := gtx.Dp(cellPadding)
+
+	// phase 1: lay every cell out to know its size, keep the ops to draw later
+	calls := make([][]op.CallOp, len(t.rows))
+	colWidths := make([]int, 0)
+	rowHeights := make([]int, len(t.rows))
+	cellGtx := gtx
+	cellGtx.Constraints.Min = image.Point{}
+	for i, row := range t.rows {
+		calls[i] = make([]op.CallOp, len(row))
+		for j, cell := range row {
+			macro := op.Record(gtx.Ops)
+			dims := cell.Children.Layout(cellGtx)
+			calls[i][j] = macro.Stop()
+
+			if j >= len(colWidths) {
+				colWidths = append(colWidths, 0)
+			}
+			colWidths[j] = max(colWidths[j], dims.Size.X+2*padding)
+			rowHeights[i] = max(rowHeights[i], dims.Size.Y+2*padding)
+		}
+	}
+
+	width := 0
+	for _, colWidth := range colWidths {
+		width += colWidth
+	}
+	height := 0
+	for _, rowHeight := range rowHeights {
+		height += rowHeight
+	}
+
+	// phase 2: draw the cells at their place with the grid lines
+	lineColor := t.thm.ContrastBg
+	headerColor := color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+	y := 0
+	for i, row := range t.rows {
+		x := 0
+		for j, cell := range row {
+			cellRect := image.Rect(x, y, x+colWidths[j], y+rowHeights[i])
+			if cell.Header {
+				paint.FillShape(gtx.Ops, headerColor, clip.Rect(cellRect).Op())
+			}
+			trans := op.Offset(image.Pt(x+padding, y+padding)).Push(gtx.Ops)
+			calls[i][j].Add(gtx.Ops)
+			trans.Pop()
+			x += colWidths[j]
+		}
+		// line under each row
+		paint.FillShape(gtx.Ops, lineColor, clip.Rect(image.Rect(0, y+rowHeights[i]-1, width, y+rowHeights[i])).Op())
+		y += rowHeights[i]
+	}
+	return D{Size: image.Pt(width, height)}
+}
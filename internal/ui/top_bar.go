@@ -2,16 +2,19 @@ package ui
 
 import "gioui.org/layout"
 
-// just a component that wrap the page nav and search bar
+// just a component that wrap the page nav, search bar, the bookmark star and the downloads button
 type TopBar struct {
-	searchBar *SearchBar
-	pageNav   *PageNav
+	searchBar    *SearchBar
+	pageNav      *PageNav
+	bookmarksBar *BookmarksBar
+	downloads    *Downloads
 }
 
-func NewTopBar(searchBar *SearchBar, pageNav *PageNav) *TopBar {
-	return &TopBar{searchBar: searchBar, pageNav: pageNav}
+func NewTopBar(searchBar *SearchBar, pageNav *PageNav, bookmarksBar *BookmarksBar, downloads *Downloads) *TopBar {
+	return &TopBar{searchBar: searchBar, pageNav: pageNav, bookmarksBar: bookmarksBar, downloads: downloads}
 }
 
 func (tb TopBar) Layout(gtx C) D {
-	return layout.Flex{Alignment: layout.Middle}.Layout(gtx, Rigid(tb.pageNav), Rigid(tb.searchBar))
+	return layout.Flex{Alignment: layout.Middle}.Layout(gtx, Rigid(tb.pageNav), Rigid(tb.searchBar),
+		layout.Rigid(tb.bookmarksBar.layoutStar), layout.Rigid(tb.downloads.layoutButton))
 }
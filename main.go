@@ -1,12 +1,20 @@
 package main
 
 import (
+	"log"
+
 	"gioui.org/app"
 	"github.com/WaronLimsakul/Gazer/internal/engine"
 	"github.com/WaronLimsakul/Gazer/internal/renderer"
 )
 
 func main() {
+	if err := engine.LoadSettings(); err != nil {
+		log.Println("LoadSettings:", err)
+	}
+	if err := engine.LoadKeymap(); err != nil {
+		log.Println("LoadKeymap:", err)
+	}
 	w := renderer.NewWindow()
 	state := engine.NewState()
 	go renderer.Draw(w, state)